@@ -0,0 +1,73 @@
+// Package main implements typed accessors on ggufFile.
+// They replace switch-on-interface boilerplate with calls like GetString(key)
+// that either return the value in its GGUF type or explain what went wrong.
+// Package main is not importable, so only the getters subcommands use exist;
+// getScalar and getSlice make any other one a one-liner.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errKeyNotFound is returned (wrapped) when a requested key is absent.
+var errKeyNotFound = errors.New("key not found")
+
+// typeMismatchError reports a key whose stored type differs from the requested one.
+type typeMismatchError struct {
+	Key  string
+	Want string // requested GGUF type label, e.g. "array[string]"
+	Got  string // stored GGUF type label
+}
+
+func (e *typeMismatchError) Error() string {
+	return fmt.Sprintf("key %q: type mismatch: want %s, got %s", e.Key, e.Want, e.Got)
+}
+
+// getScalar fetches key and asserts its value to T, whose GGUF name is want.
+func getScalar[T any](gf *ggufFile, key, want string) (T, error) {
+	var zero T
+	kv, ok := gf.Get(key)
+	if !ok {
+		return zero, fmt.Errorf("%w: %q", errKeyNotFound, key)
+	}
+	v, ok := kv.Value.(T)
+	if !ok {
+		return zero, &typeMismatchError{Key: key, Want: want, Got: kv.Type}
+	}
+	return v, nil
+}
+
+// getSlice fetches an array key whose elements are all T. Arrays loaded as
+// placeholders must be expanded via the policy before they can be read.
+func getSlice[T any](gf *ggufFile, key, elem string) ([]T, error) {
+	want := "array[" + elem + "]"
+	kv, ok := gf.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errKeyNotFound, key)
+	}
+	if kv.Type != want {
+		return nil, &typeMismatchError{Key: key, Want: want, Got: kv.Type}
+	}
+	items, ok := kv.Value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("key %q: array was not expanded (add it to the policy's expandArrays)", key)
+	}
+	out := make([]T, len(items))
+	for i, it := range items {
+		if out[i], ok = it.(T); !ok {
+			return nil, &typeMismatchError{Key: fmt.Sprintf("%s[%d]", key, i), Want: elem, Got: fmt.Sprintf("%T", it)}
+		}
+	}
+	return out, nil
+}
+
+// GetString returns a string key; other types are a typeMismatchError.
+func (gf *ggufFile) GetString(key string) (string, error) {
+	return getScalar[string](gf, key, "string")
+}
+
+// GetStringSlice returns an expanded array of strings.
+func (gf *ggufFile) GetStringSlice(key string) ([]string, error) {
+	return getSlice[string](gf, key, "string")
+}
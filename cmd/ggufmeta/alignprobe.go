@@ -0,0 +1,95 @@
+// Package main implements automatic detection of the value-alignment quirk.
+// Some non-standard writers pad every value to an 8-byte boundary after its
+// type tag. Where a value starts off-boundary on zero bytes, both layouts are
+// possible, so the parser test-decodes the KV pair both ways over a look-ahead
+// window and keeps whichever layout also leaves a plausible next entry.
+package main
+
+import "io"
+
+// probeAlignment decides, at the start of key's value, whether values are
+// padded to 8 bytes. It only switches p.padded when the current layout
+// fails the trial and the other one passes; otherwise it leaves it alone.
+func (p *parser) probeAlignment(key string, tag uint32) {
+	if p.scn.pos%8 == 0 {
+		return // both layouts agree
+	}
+	pad, err := p.scn.peek(int(8 - p.scn.pos%8))
+	if err != nil {
+		return // no look-ahead; keep the current layout
+	}
+	if !p.padded && !zeroPad(pad, p.scn.pos) {
+		return // padding would have to be zero, so values are packed
+	}
+	// Only now is a trial decode needed, so widen to the full window. Streamed
+	// input outside lenient mode sits behind a small buffer and may yield less.
+	window, err := p.scn.peek(resyncWindow)
+	if err != nil {
+		return
+	}
+	if p.trialKV(window, tag, p.padded) || !p.trialKV(window, tag, !p.padded) {
+		return
+	}
+	p.padded = !p.padded
+	if p.padded {
+		logger.Warn("alignment quirk: values are padded to 8 bytes after their type tag", "key", key, "pos", p.scn.pos)
+	} else {
+		logger.Warn("alignment quirk: values are tightly packed again", "key", key, "pos", p.scn.pos)
+	}
+}
+
+// trialKV decodes the value at the current position from window, under the
+// given layout, and checks that what follows looks like the next entry.
+func (p *parser) trialKV(window []byte, tag uint32, aligned bool) bool {
+	pol := p.pol
+	pol.expandArrays, pol.expandPrefixes, pol.lenient = nil, nil, false
+	scn := newScannerAt(windowAt{b: window, base: p.scn.pos}, p.scn.pos)
+	scn.order = p.scn.order
+	t := &parser{scn: scn, fileSize: p.fileSize, pol: pol, ctx: p.ctx, padded: aligned}
+	if aligned && !zeroPad(window, p.scn.pos) {
+		return false
+	}
+	if _, _, _, err := t.readValue(tag, ""); err != nil {
+		return false
+	}
+	rest := window[t.scn.pos-p.scn.pos:]
+	switch {
+	case p.kvRemain > 1:
+		return p.looksLikeKV(rest)
+	case p.tnRemain > 0:
+		return p.looksLikeTensorInfo(rest)
+	}
+	return true
+}
+
+// zeroPad reports whether the bytes from pos up to the next 8-byte boundary,
+// found at the start of window, are all zero.
+func zeroPad(window []byte, pos uint64) bool {
+	pad := 8 - pos%8
+	if uint64(len(window)) < pad {
+		return false
+	}
+	for _, c := range window[:pad] {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// windowAt serves ReadAt from a buffer that holds the file bytes starting at base.
+type windowAt struct {
+	b    []byte
+	base uint64
+}
+
+func (w windowAt) ReadAt(b []byte, off int64) (int, error) {
+	if uint64(off) < w.base || uint64(off)-w.base >= uint64(len(w.b)) {
+		return 0, io.EOF
+	}
+	n := copy(b, w.b[uint64(off)-w.base:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
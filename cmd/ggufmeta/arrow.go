@@ -0,0 +1,488 @@
+// Package main implements the --format arrow output.
+// The Arrow IPC stream format is a schema message followed by record batches,
+// each a small FlatBuffers header plus column buffers. Linking the Arrow
+// library would end the standard-library-only build, so this file encodes
+// the few FlatBuffers tables the format needs by hand. The main dump writes
+// the KV table; `tensors --format arrow` writes the tensor table.
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+)
+
+func init() {
+	registerFormat("arrow", outputFormat{
+		Description: "Arrow IPC stream: one kv table with typed value columns",
+		Binary:      true,
+		New: func(w io.Writer, _ bool) recordEncoder {
+			return newArrowStream(w, kvArrowColumns(), appendKVRow)
+		},
+	})
+}
+
+// arrowBatchRows bounds a record batch, so memory stays flat on long streams.
+const arrowBatchRows = 4096
+
+// Column kinds; each maps to one Arrow type.
+const (
+	arrowUtf8 = iota
+	arrowInt64
+	arrowUint64
+	arrowFloat64
+	arrowBool
+	arrowUint64List // list<uint64>
+)
+
+// arrowColumn accumulates one column of the current batch.
+type arrowColumn struct {
+	name     string
+	kind     int
+	nullable bool
+
+	valid   []bool
+	nulls   int
+	offsets []int32 // utf8 and list: len(valid)+1 entries
+	data    []byte  // values; bools are one byte each until encoded
+}
+
+func kvArrowColumns() []*arrowColumn {
+	return []*arrowColumn{
+		{name: "key", kind: arrowUtf8},
+		{name: "type", kind: arrowUtf8},
+		{name: "value", kind: arrowUtf8}, // JSON text, for arrays and anything not typed below
+		{name: "value_string", kind: arrowUtf8, nullable: true},
+		{name: "value_int", kind: arrowInt64, nullable: true},
+		{name: "value_float", kind: arrowFloat64, nullable: true},
+		{name: "value_bool", kind: arrowBool, nullable: true},
+	}
+}
+
+func tensorArrowColumns() []*arrowColumn {
+	return []*arrowColumn{
+		{name: "name", kind: arrowUtf8},
+		{name: "type", kind: arrowUtf8},
+		{name: "dims", kind: arrowUint64List},
+		{name: "offset", kind: arrowUint64},
+		{name: "size", kind: arrowUint64},
+		{name: "share", kind: arrowFloat64},
+		{name: "hash", kind: arrowUtf8},
+	}
+}
+
+// appendKVRow adds a kvEvent; the header and other records have no row.
+func appendKVRow(cols []*arrowColumn, v any) (bool, error) {
+	kv, ok := v.(kvEvent)
+	if !ok {
+		return false, nil
+	}
+	js, err := json.Marshal(kv.Value)
+	if err != nil {
+		return false, err
+	}
+	row, err := typedKVOf(kv)
+	if err != nil {
+		return false, err
+	}
+	cols[0].appendString(kv.Key)
+	cols[1].appendString(kv.Type)
+	cols[2].appendString(string(js))
+	if row.ValueString != nil {
+		cols[3].appendString(*row.ValueString)
+	} else {
+		cols[3].appendNull()
+	}
+	if row.ValueInt != nil {
+		cols[4].appendUint64(uint64(*row.ValueInt))
+	} else {
+		cols[4].appendNull()
+	}
+	if row.ValueFloat != nil {
+		cols[5].appendUint64(math.Float64bits(*row.ValueFloat))
+	} else {
+		cols[5].appendNull()
+	}
+	if row.ValueBool != nil {
+		cols[6].appendBool(*row.ValueBool)
+	} else {
+		cols[6].appendNull()
+	}
+	return true, nil
+}
+
+// appendTensorRow adds a tensorRecord.
+func appendTensorRow(cols []*arrowColumn, v any) (bool, error) {
+	r, ok := v.(tensorRecord)
+	if !ok {
+		return false, nil
+	}
+	cols[0].appendString(r.Name)
+	cols[1].appendString(r.Type)
+	cols[2].appendList(r.Dims)
+	cols[3].appendUint64(r.Offset)
+	cols[4].appendUint64(r.Size)
+	cols[5].appendUint64(math.Float64bits(r.Share))
+	if r.Hash != "" {
+		cols[6].appendString(r.Hash)
+	} else {
+		cols[6].appendNull()
+	}
+	return true, nil
+}
+
+func (c *arrowColumn) begin(valid bool) {
+	if c.offsets == nil && (c.kind == arrowUtf8 || c.kind == arrowUint64List) {
+		c.offsets = []int32{0}
+	}
+	c.valid = append(c.valid, valid)
+	if !valid {
+		c.nulls++
+	}
+}
+
+func (c *arrowColumn) appendString(s string) {
+	c.begin(true)
+	c.data = append(c.data, s...)
+	c.offsets = append(c.offsets, int32(len(c.data)))
+}
+
+func (c *arrowColumn) appendUint64(u uint64) {
+	c.begin(true)
+	c.data = binary.LittleEndian.AppendUint64(c.data, u)
+}
+
+func (c *arrowColumn) appendBool(b bool) {
+	c.begin(true)
+	c.data = append(c.data, b2u8(b))
+}
+
+// appendList stores list elements in data; offsets count elements, not bytes.
+func (c *arrowColumn) appendList(items []uint64) {
+	c.begin(true)
+	for _, u := range items {
+		c.data = binary.LittleEndian.AppendUint64(c.data, u)
+	}
+	c.offsets = append(c.offsets, int32(len(c.data)/8))
+}
+
+func (c *arrowColumn) appendNull() {
+	c.begin(false)
+	switch c.kind {
+	case arrowUtf8, arrowUint64List:
+		c.offsets = append(c.offsets, c.offsets[len(c.offsets)-1])
+	case arrowBool:
+		c.data = append(c.data, 0)
+	default:
+		c.data = append(c.data, make([]byte, 8)...)
+	}
+}
+
+func (c *arrowColumn) reset() {
+	c.valid, c.nulls, c.offsets, c.data = c.valid[:0], 0, nil, c.data[:0]
+}
+
+// bitmap packs flags least significant bit first, as Arrow lays them out.
+func bitmap(flags []bool) []byte {
+	out := make([]byte, (len(flags)+7)/8)
+	for i, f := range flags {
+		if f {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// arrowStream writes a schema message before the first batch and the
+// end-of-stream marker on Flush.
+type arrowStream struct {
+	w       io.Writer
+	cols    []*arrowColumn
+	add     func(cols []*arrowColumn, v any) (bool, error)
+	started bool
+}
+
+func newArrowStream(w io.Writer, cols []*arrowColumn, add func([]*arrowColumn, any) (bool, error)) *arrowStream {
+	return &arrowStream{w: w, cols: cols, add: add}
+}
+
+func (s *arrowStream) Encode(v any) error {
+	added, err := s.add(s.cols, v)
+	if err != nil || !added {
+		return err
+	}
+	if len(s.cols[0].valid) >= arrowBatchRows {
+		return s.writeBatch()
+	}
+	return nil
+}
+
+func (s *arrowStream) Flush() error {
+	if len(s.cols[0].valid) > 0 || !s.started {
+		if err := s.writeBatch(); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	return err
+}
+
+// Message header union tags and enum values from Arrow's Message.fbs and Schema.fbs.
+const (
+	arrowMetadataV5     = 4
+	arrowHeaderSchema   = 1
+	arrowHeaderBatch    = 3
+	arrowTypeInt        = 2
+	arrowTypeFloat      = 3
+	arrowTypeUtf8       = 5
+	arrowTypeBool       = 6
+	arrowTypeList       = 12
+	arrowPrecisionFloat = 2 // DOUBLE
+)
+
+// writeBatch writes the schema if needed, then the buffered rows as one batch.
+func (s *arrowStream) writeBatch() error {
+	if !s.started {
+		s.started = true
+		fields := make(fbVector, len(s.cols))
+		for i, c := range s.cols {
+			fields[i] = arrowField(c)
+		}
+		schema := &fbTable{}
+		schema.add(1, fields)
+		if err := s.writeMessage(arrowHeaderSchema, schema, nil); err != nil {
+			return err
+		}
+		if len(s.cols[0].valid) == 0 {
+			return nil
+		}
+	}
+
+	var body []byte
+	var nodes, buffers []byte
+	addBuffer := func(b []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(b)))
+		body = append(body, b...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+	addNode := func(length, nulls int) {
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(length))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(nulls))
+	}
+	rows := len(s.cols[0].valid)
+	for _, c := range s.cols {
+		addNode(rows, c.nulls)
+		if c.nulls > 0 {
+			addBuffer(bitmap(c.valid))
+		} else {
+			addBuffer(nil)
+		}
+		switch c.kind {
+		case arrowUtf8:
+			addBuffer(int32Bytes(c.offsets))
+			addBuffer(c.data)
+		case arrowUint64List:
+			addBuffer(int32Bytes(c.offsets))
+			addNode(len(c.data)/8, 0)
+			addBuffer(nil)
+			addBuffer(c.data)
+		case arrowBool:
+			flags := make([]bool, len(c.data))
+			for i, b := range c.data {
+				flags[i] = b != 0
+			}
+			addBuffer(bitmap(flags))
+		default:
+			addBuffer(c.data)
+		}
+		c.reset()
+	}
+	batch := &fbTable{}
+	batch.add(0, fbInt64(int64(rows)))
+	batch.add(1, fbStructs{data: nodes, size: 16})
+	batch.add(2, fbStructs{data: buffers, size: 16})
+	return s.writeMessage(arrowHeaderBatch, batch, body)
+}
+
+// writeMessage frames one encapsulated IPC message: continuation marker,
+// metadata length, the Message flatbuffer padded to 8 bytes, then the body.
+func (s *arrowStream) writeMessage(kind byte, header *fbTable, body []byte) error {
+	msg := &fbTable{}
+	msg.add(0, fbInt16(arrowMetadataV5))
+	msg.add(1, fbUint8(kind))
+	msg.add(2, header)
+	msg.add(3, fbInt64(int64(len(body))))
+	meta := fbFinish(msg)
+	prefix := binary.LittleEndian.AppendUint32([]byte{0xff, 0xff, 0xff, 0xff}, uint32(len(meta)))
+	if _, err := s.w.Write(append(prefix, meta...)); err != nil {
+		return err
+	}
+	_, err := s.w.Write(body)
+	return err
+}
+
+// arrowField builds a Schema.fbs Field table for c.
+func arrowField(c *arrowColumn) *fbTable {
+	f := &fbTable{}
+	f.add(0, fbString(c.name))
+	f.add(1, fbBool(c.nullable))
+	children := fbVector{}
+	typ := &fbTable{}
+	switch c.kind {
+	case arrowUtf8:
+		f.add(2, fbUint8(arrowTypeUtf8))
+	case arrowInt64, arrowUint64:
+		f.add(2, fbUint8(arrowTypeInt))
+		typ.add(0, fbInt32(64))
+		typ.add(1, fbBool(c.kind == arrowInt64))
+	case arrowFloat64:
+		f.add(2, fbUint8(arrowTypeFloat))
+		typ.add(0, fbInt16(arrowPrecisionFloat))
+	case arrowBool:
+		f.add(2, fbUint8(arrowTypeBool))
+	case arrowUint64List:
+		f.add(2, fbUint8(arrowTypeList))
+		children = fbVector{arrowField(&arrowColumn{name: "item", kind: arrowUint64})}
+	}
+	f.add(3, typ)
+	f.add(5, children)
+	return f
+}
+
+func int32Bytes(v []int32) []byte {
+	out := make([]byte, 0, 4*len(v))
+	for _, x := range v {
+		out = binary.LittleEndian.AppendUint32(out, uint32(x))
+	}
+	return out
+}
+
+// A minimal FlatBuffers writer. Unlike the official builders it lays the
+// buffer out front to back: each table is preceded by its vtable and followed
+// by the objects it references, so every uoffset points forward as required.
+type (
+	fbTable struct{ fields []fbSlot }
+	fbSlot  struct {
+		slot  int
+		value any // fbScalar or a referenced object
+	}
+	fbScalar  []byte
+	fbString  string
+	fbVector  []*fbTable
+	fbStructs struct {
+		data []byte
+		size int // bytes per struct, also its alignment
+	}
+)
+
+func (t *fbTable) add(slot int, v any) { t.fields = append(t.fields, fbSlot{slot, v}) }
+
+func fbUint8(v uint8) fbScalar { return fbScalar{v} }
+func fbBool(v bool) fbScalar   { return fbScalar{b2u8(v)} }
+func fbInt16(v int16) fbScalar { return binary.LittleEndian.AppendUint16(nil, uint16(v)) }
+func fbInt32(v int32) fbScalar { return binary.LittleEndian.AppendUint32(nil, uint32(v)) }
+func fbInt64(v int64) fbScalar { return binary.LittleEndian.AppendUint64(nil, uint64(v)) }
+
+// fbFinish serializes root and pads the result to a multiple of 8 bytes.
+func fbFinish(root *fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	pos := b.write(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(pos))
+	b.pad(8)
+	return b.buf
+}
+
+type fbBuilder struct{ buf []byte }
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// patch stores at pos the forward uoffset to target.
+func (b *fbBuilder) patch(pos, target int) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(target-pos))
+}
+
+// write appends obj and returns its position.
+func (b *fbBuilder) write(obj any) int {
+	switch o := obj.(type) {
+	case *fbTable:
+		return b.writeTable(o)
+	case fbString:
+		b.pad(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(o)))
+		b.buf = append(append(b.buf, o...), 0)
+		return pos
+	case fbVector:
+		b.pad(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(o)))
+		b.buf = append(b.buf, make([]byte, 4*len(o))...)
+		for i, t := range o {
+			b.patch(pos+4+4*i, b.write(t))
+		}
+		return pos
+	case fbStructs:
+		// The length prefix sits just before the first, aligned, element
+		for (len(b.buf)+4)%o.size != 0 {
+			b.buf = append(b.buf, 0)
+		}
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(o.data)/o.size))
+		b.buf = append(b.buf, o.data...)
+		return pos
+	}
+	panic("flatbuffers: unsupported object")
+}
+
+func (b *fbBuilder) writeTable(t *fbTable) int {
+	slots := 0
+	for _, f := range t.fields {
+		slots = max(slots, f.slot+1)
+	}
+	// Inline layout: the vtable soffset, then each field at its natural alignment
+	offsets := make([]int, len(t.fields))
+	size := 4
+	for i, f := range t.fields {
+		n := 4 // uoffset to a referenced object
+		if s, ok := f.value.(fbScalar); ok {
+			n = len(s)
+		}
+		size = (size + n - 1) / n * n
+		offsets[i] = size
+		size += n
+	}
+
+	b.pad(2)
+	vt := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*slots))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	entries := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 2*slots)...)
+	for i, f := range t.fields {
+		binary.LittleEndian.PutUint16(b.buf[entries+2*f.slot:], uint16(offsets[i]))
+	}
+
+	b.pad(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vt)))
+	for i, f := range t.fields {
+		if s, ok := f.value.(fbScalar); ok {
+			copy(b.buf[pos+offsets[i]:], s)
+		}
+	}
+	for i, f := range t.fields {
+		if _, ok := f.value.(fbScalar); !ok {
+			b.patch(pos+offsets[i], b.write(f.value))
+		}
+	}
+	return pos
+}
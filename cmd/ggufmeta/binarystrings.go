@@ -0,0 +1,86 @@
+// Package main implements the JSON form of string values that are not valid
+// UTF-8. GGUF promises UTF-8 but does not enforce it, and encoding/json
+// silently turns each bad byte into U+FFFD, so such a value would come out
+// readable but wrong. The JSON formats write it as
+// {"encoding":"base64","data":"..."} instead, which keeps every byte; the
+// record's type still says "string". Binary formats carry the bytes as they
+// are (see cbor.go and protobuf.go), and --raw-values writes them raw.
+package main
+
+import (
+	"encoding/base64"
+	"maps"
+	"unicode/utf8"
+)
+
+// base64String is the JSON stand-in for a string that is not valid UTF-8.
+type base64String struct {
+	Encoding string `json:"encoding"` // always "base64"
+	Data     string `json:"data"`     // standard encoding, padded
+}
+
+// jsonSafeValue returns v with every string that is not valid UTF-8, at any
+// depth of an expanded array or placeholder, replaced by a base64String. v itself is never
+// modified; an array is copied only when one of its elements changes.
+func jsonSafeValue(v any) any {
+	safe, _ := jsonSafe(v)
+	return safe
+}
+
+// jsonSafe is jsonSafeValue, also reporting whether anything was replaced.
+func jsonSafe(v any) (any, bool) {
+	switch x := v.(type) {
+	case string:
+		if !utf8.ValidString(x) {
+			return base64String{Encoding: "base64", Data: base64.StdEncoding.EncodeToString([]byte(x))}, true
+		}
+	case []any:
+		var out []any
+		for i, el := range x {
+			safe, changed := jsonSafe(el)
+			if changed && out == nil {
+				out = append(make([]any, 0, len(x)), x[:i]...)
+			}
+			if out != nil {
+				out = append(out, safe)
+			}
+		}
+		if out != nil {
+			return out, true
+		}
+	case map[string]any:
+		// Placeholders, e.g. the prefix of a truncated string
+		var out map[string]any
+		for k, el := range x {
+			if safe, changed := jsonSafe(el); changed {
+				if out == nil {
+					out = maps.Clone(x)
+				}
+				out[k] = safe
+			}
+		}
+		if out != nil {
+			return out, true
+		}
+	}
+	return v, false
+}
+
+// jsonSafeEncoder applies jsonSafeValue to the values of the records passing
+// through it.
+type jsonSafeEncoder struct{ recordEncoder }
+
+func (e jsonSafeEncoder) Encode(v any) error {
+	switch ev := v.(type) {
+	case kvEvent:
+		ev.Value = jsonSafeValue(ev.Value)
+		v = ev
+	case annotatedKV:
+		ev.Value = jsonSafeValue(ev.Value)
+		v = ev
+	case grepEvent:
+		ev.Value = jsonSafeValue(ev.Value)
+		v = ev
+	}
+	return e.recordEncoder.Encode(v)
+}
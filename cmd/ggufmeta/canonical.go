@@ -0,0 +1,216 @@
+// Package main implements canonical JSON output for --canonical.
+// This file serializes records following the JSON Canonicalization Scheme
+// (RFC 8785) so identical metadata hashes identically on every machine.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxSafeInteger is the largest integer an IEEE 754 double holds exactly (2^53).
+// JCS numbers are doubles, so larger integers are emitted as strings instead
+// of silently losing precision (RFC 8785 section 3.2.2.3 / I-JSON).
+const maxSafeInteger = 1 << 53
+
+// canonicalEncoder writes one JCS-canonicalized JSON document per line.
+// It backs the JSON formats whenever --canonical is set.
+type canonicalEncoder struct {
+	w io.Writer
+}
+
+func newCanonicalEncoder(w io.Writer) *canonicalEncoder { return &canonicalEncoder{w: w} }
+
+// Flush is a no-op; every record is written as soon as it is encoded.
+func (e *canonicalEncoder) Flush() error { return nil }
+
+// Encode marshals v with encoding/json, re-reads it as a generic tree with
+// json.Number to keep the original digits, then writes it canonically.
+func (e *canonicalEncoder) Encode(v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, tree); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = e.w.Write(buf.Bytes())
+	return err
+}
+
+// writeCanonical serializes a decoded JSON tree per RFC 8785.
+func writeCanonical(buf *bytes.Buffer, v any) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(x))
+	case string:
+		writeCanonicalString(buf, x)
+	case json.Number:
+		return writeCanonicalNumber(buf, x)
+	case []any:
+		buf.WriteByte('[')
+		for i, el := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, el); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		// JCS orders members by their UTF-16 code units, not by bytes
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, x[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("canonical: unexpected JSON value %T", v)
+	}
+	return nil
+}
+
+// lessUTF16 compares two strings by their UTF-16 code unit sequences.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeCanonicalString applies the ECMAScript JSON.stringify escaping rules:
+// only quote, backslash and control characters are escaped, nothing else.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// writeCanonicalNumber formats a number the way ECMAScript Number.prototype.toString does.
+// Integers outside the exact double range are emitted as strings.
+func writeCanonicalNumber(buf *bytes.Buffer, n json.Number) error {
+	s := n.String()
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil && i >= -maxSafeInteger && i <= maxSafeInteger {
+			buf.WriteString(strconv.FormatInt(i, 10))
+			return nil
+		}
+		// Too large for a double: keep every digit as a string
+		writeCanonicalString(buf, strings.TrimPrefix(s, "+"))
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("canonical: bad number %q: %w", s, err)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("canonical: %v is not representable in JSON", f)
+	}
+	buf.WriteString(formatES(f))
+	return nil
+}
+
+// formatES renders a finite float64 using the ECMAScript Number::toString algorithm.
+func formatES(f float64) string {
+	if f == 0 {
+		return "0" // also covers -0
+	}
+	neg := f < 0
+	if neg {
+		f = -f
+	}
+	// Shortest round-trip digits in the form d.ddde±x
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mant, expStr, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mant, ".", "", 1)
+	exp, _ := strconv.Atoi(expStr)
+	k := len(digits)
+	n := exp + 1 // position of the decimal point relative to the digits
+
+	var out string
+	switch {
+	case k <= n && n <= 21:
+		out = digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		out = digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		out = "0." + strings.Repeat("0", -n) + digits
+	default:
+		sign := "+"
+		if n-1 < 0 {
+			sign = "-"
+		}
+		out = digits[:1]
+		if k > 1 {
+			out += "." + digits[1:]
+		}
+		out += "e" + sign + strconv.Itoa(abs(n-1))
+	}
+	if neg {
+		return "-" + out
+	}
+	return out
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,206 @@
+//go:build cgo && cshared
+
+// Package main implements the C API of the shared library build.
+// `make cshared` builds this package with -buildmode=c-shared into
+// libggufmeta.so and the libggufmeta.h cgo generates for it, so Python (ctypes,
+// cffi), Rust and C++ tooling can embed this parser instead of keeping their
+// own. A file is parsed once by gguf_open and then read through an opaque
+// handle; records cross the boundary as the same JSON the dump prints:
+//
+//	char *err = NULL;
+//	uintptr_t f = gguf_open("model.gguf", NULL, &err);
+//	if (!f) { fprintf(stderr, "%s\n", err); gguf_free(err); return 1; }
+//	for (char *kv; (kv = gguf_next_kv(f)) != NULL; gguf_free(kv))
+//		puts(kv);
+//	gguf_close(f);
+//
+// Every string returned is allocated with malloc and must be released with
+// gguf_free. Handles may be used from any thread; calls on one handle are
+// serialised.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/cgo"
+	"sync"
+	"unsafe"
+)
+
+// capiFile is what a handle refers to: the parsed file and the position of
+// the gguf_next_kv iterator.
+type capiFile struct {
+	mu   sync.Mutex
+	gf   *ggufFile
+	next int
+}
+
+// capiFileOf resolves a handle; 0 (a failed gguf_open) resolves to nil.
+func capiFileOf(h C.uintptr_t) *capiFile {
+	if h == 0 {
+		return nil
+	}
+	return cgo.Handle(h).Value().(*capiFile)
+}
+
+// capiJSON marshals v into a C string, or returns NULL if v does not encode
+// (a NaN float, which JSON cannot represent).
+func capiJSON(v any) *C.char {
+	b, err := json.Marshal(v)
+	if err != nil {
+		logger.Debug("capi: record not representable as JSON", "err", err)
+		return nil
+	}
+	return C.CString(string(b))
+}
+
+// capiOpen parses path with the options JSON in opts (NULL for defaults).
+func capiOpen(path, opts *C.char) (*capiFile, error) {
+	if path == nil {
+		return nil, fmt.Errorf("gguf_open: path is NULL")
+	}
+	var o embedOptions
+	if opts != nil {
+		if err := json.Unmarshal([]byte(C.GoString(opts)), &o); err != nil {
+			return nil, fmt.Errorf("gguf_open: bad options: %w", err)
+		}
+	}
+	gf, err := loadFile(context.Background(), C.GoString(path), o.policy())
+	if err != nil {
+		return nil, err
+	}
+	return &capiFile{gf: gf}, nil
+}
+
+// gguf_open parses the header, KVs and tensor infos of path - a local file or
+// any remote reference the command line accepts - and returns a handle, or 0
+// on failure with the message in *err (when err is not NULL). opts is a JSON
+// object with the js wrapper's options (maxArray, maxString, expandArrays,
+// lenient, truncateStrings, noAutoExpand, expandTypes), or NULL.
+//
+//export gguf_open
+func gguf_open(path, opts *C.char, err **C.char) C.uintptr_t {
+	f, e := capiOpen(path, opts)
+	if e != nil {
+		if err != nil {
+			*err = C.CString(e.Error())
+		}
+		return 0
+	}
+	return C.uintptr_t(cgo.NewHandle(f))
+}
+
+// gguf_close releases a handle. Closing 0 is a no-op.
+//
+//export gguf_close
+func gguf_close(h C.uintptr_t) {
+	if h != 0 {
+		cgo.Handle(h).Delete()
+	}
+}
+
+// gguf_free releases a string returned by this library.
+//
+//export gguf_free
+func gguf_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+// gguf_header returns the header record as JSON.
+//
+//export gguf_header
+func gguf_header(h C.uintptr_t) *C.char {
+	f := capiFileOf(h)
+	if f == nil {
+		return nil
+	}
+	return capiJSON(f.gf.Header)
+}
+
+// gguf_next_kv returns the next KV record, {"key","type","value"}, as JSON,
+// or NULL after the last one. gguf_rewind starts over.
+//
+//export gguf_next_kv
+func gguf_next_kv(h C.uintptr_t) *C.char {
+	f := capiFileOf(h)
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for f.next < len(f.gf.KVs) {
+		kv := f.gf.KVs[f.next]
+		f.next++
+		if s := capiJSON(kv); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// gguf_rewind moves the gguf_next_kv iterator back to the first KV.
+//
+//export gguf_rewind
+func gguf_rewind(h C.uintptr_t) {
+	if f := capiFileOf(h); f != nil {
+		f.mu.Lock()
+		f.next = 0
+		f.mu.Unlock()
+	}
+}
+
+// gguf_get returns the KV record for key as JSON, or NULL if the file has no
+// such key. With duplicate keys the last one wins.
+//
+//export gguf_get
+func gguf_get(h C.uintptr_t, key *C.char) *C.char {
+	f := capiFileOf(h)
+	if f == nil || key == nil {
+		return nil
+	}
+	kv, ok := f.gf.Get(C.GoString(key))
+	if !ok {
+		return nil
+	}
+	return capiJSON(kv)
+}
+
+// gguf_kv_count returns the number of KV pairs.
+//
+//export gguf_kv_count
+func gguf_kv_count(h C.uintptr_t) C.int64_t {
+	f := capiFileOf(h)
+	if f == nil {
+		return 0
+	}
+	return C.int64_t(len(f.gf.KVs))
+}
+
+// gguf_tensors returns the tensors subcommand's records as a JSON array.
+//
+//export gguf_tensors
+func gguf_tensors(h C.uintptr_t) *C.char {
+	f := capiFileOf(h)
+	if f == nil {
+		return nil
+	}
+	return capiJSON(tensorRecords(f.gf))
+}
+
+// gguf_data_offset returns the absolute offset of the tensor data section.
+//
+//export gguf_data_offset
+func gguf_data_offset(h C.uintptr_t) C.uint64_t {
+	f := capiFileOf(h)
+	if f == nil {
+		return 0
+	}
+	return C.uint64_t(f.gf.DataOffset)
+}
@@ -0,0 +1,209 @@
+// Package main implements the `card` subcommand.
+// It writes a Hugging Face style model card: YAML frontmatter (license,
+// base_model, tags, language, datasets) translated from the general.* keys
+// the GGUF spec defines, followed by a Markdown summary of the file, ready to
+// upload as README.md next to the model.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// modelCard is the frontmatter of a model card, in the terms of the Hugging
+// Face model card metadata.
+type modelCard struct {
+	License     string
+	LicenseName string // for license "other"
+	LicenseLink string
+	BaseModels  []string // repo ids, "org/name"
+	Tags        []string
+	Languages   []string
+	Datasets    []string
+}
+
+// cardFromGGUF collects the frontmatter fields from gf's general.* keys.
+func cardFromGGUF(gf *ggufFile) modelCard {
+	var c modelCard
+	c.License, _ = gf.GetString("general.license")
+	c.LicenseName, _ = gf.GetString("general.license.name")
+	c.LicenseLink, _ = gf.GetString("general.license.link")
+	c.BaseModels = cardRepos(gf, "general.base_model")
+	c.Datasets = cardRepos(gf, "general.dataset")
+	c.Tags, _ = gf.GetStringSlice("general.tags")
+	c.Languages, _ = gf.GetStringSlice("general.languages")
+	if !containsFold(c.Tags, "gguf") {
+		c.Tags = append(c.Tags, "gguf")
+	}
+	return c
+}
+
+// cardRepos returns the repo ids of the numbered sources under prefix
+// ("general.base_model.0.name", ...), from repo_url when it points at the
+// Hugging Face hub and from organization/name otherwise.
+func cardRepos(gf *ggufFile, prefix string) []string {
+	n, _ := gf.Uint(prefix + ".count")
+	if n == 0 {
+		// Writers disagree on the plural for datasets
+		n, _ = gf.Uint(prefix + "s.count")
+	}
+	var repos []string
+	for i := uint64(0); i < n; i++ {
+		field := func(name string) string {
+			s, _ := gf.GetString(fmt.Sprintf("%s.%d.%s", prefix, i, name))
+			return s
+		}
+		url := field("repo_url")
+		if id, ok := strings.CutPrefix(url, "https://huggingface.co/"); ok {
+			if _, rest, ok := strings.Cut(id, "datasets/"); ok {
+				id = rest
+			}
+			repos = append(repos, strings.Trim(id, "/"))
+			continue
+		}
+		name := field("name")
+		if name == "" {
+			continue
+		}
+		if org := field("organization"); org != "" {
+			name = strings.ReplaceAll(org, " ", "-") + "/" + strings.ReplaceAll(name, " ", "-")
+		}
+		repos = append(repos, name)
+	}
+	return repos
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func runCard(args []string) error {
+	fs := flag.NewFlagSet("card", flag.ExitOnError)
+	out := fs.String("o", "", "write the card to `FILE` (e.g. README.md) instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta card [-o README.md] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := rest[0]
+	pol := basePolicy()
+	pol.expandPrefixes = []string{"general."}
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		return writeCard(os.Stdout, gf, path)
+	}
+	af, err := createAtomicFile(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeCard(af, gf, path); err != nil {
+		af.Abort()
+		return err
+	}
+	return af.Commit()
+}
+
+// writeCard writes the complete model card for gf, loaded from path.
+func writeCard(w io.Writer, gf *ggufFile, path string) error {
+	ew := &errWriter{w: w}
+	c := cardFromGGUF(gf)
+	ew.printf("---\n")
+	if c.License != "" {
+		ew.printf("license: %s\n", yamlScalar(c.License))
+	}
+	if c.LicenseName != "" {
+		ew.printf("license_name: %s\n", yamlScalar(c.LicenseName))
+	}
+	if c.LicenseLink != "" {
+		ew.printf("license_link: %s\n", yamlScalar(c.LicenseLink))
+	}
+	yamlList(ew, "base_model", c.BaseModels)
+	yamlList(ew, "language", c.Languages)
+	yamlList(ew, "datasets", c.Datasets)
+	yamlList(ew, "tags", c.Tags)
+	ew.printf("---\n\n")
+
+	title, _ := gf.GetString("general.name")
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	ew.printf("# %s\n\n", title)
+	if desc, _ := gf.GetString("general.description"); desc != "" {
+		ew.printf("%s\n\n", desc)
+	}
+	if len(c.BaseModels) > 0 {
+		ew.printf("GGUF conversion of [%s](https://huggingface.co/%s).\n\n", c.BaseModels[0], c.BaseModels[0])
+	}
+
+	ew.printf("## Model details\n\n| | |\n|---|---|\n")
+	row := func(label, value string) {
+		if value != "" {
+			ew.printf("| %s | %s |\n", label, strings.ReplaceAll(value, "|", "\\|"))
+		}
+	}
+	row("File", "`"+filepath.Base(path)+"`")
+	row("Architecture", gf.Arch())
+	row("Parameters", humanCount(paramCount(gf)))
+	row("Quantization", quantLabel(gf))
+	if n, ok := gf.Uint("{arch}.context_length"); ok {
+		row("Context length", fmt.Sprint(n))
+	}
+	row("File size", humanBytes(uint64(gf.Size)))
+	for _, key := range []string{"author", "organization", "quantized_by"} {
+		v, _ := gf.GetString("general." + key)
+		row(strings.ToUpper(key[:1])+strings.ReplaceAll(key[1:], "_", " "), v)
+	}
+	if _, err := gf.GetString("tokenizer.chat_template"); err == nil {
+		row("Chat template", "embedded")
+	}
+
+	ew.printf("\n## Usage\n\n```sh\nllama-cli -m %s -cnv\n```\n", filepath.Base(path))
+	return ew.err
+}
+
+// yamlPlain matches strings that YAML reads back unchanged without quotes.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/+-]*$`)
+
+// yamlScalar renders s as a YAML scalar, double-quoted (JSON syntax, which
+// YAML accepts) unless it is plainly safe.
+func yamlScalar(s string) string {
+	_, numErr := strconv.ParseFloat(s, 64)
+	if yamlPlain.MatchString(s) && !yamlReserved[strings.ToLower(s)] && numErr != nil {
+		return s
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// yamlReserved are plain scalars YAML would read as something other than a string.
+var yamlReserved = map[string]bool{"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "null": true, "y": true, "n": true}
+
+func yamlList(ew *errWriter, key string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	ew.printf("%s:\n", key)
+	for _, it := range items {
+		ew.printf("- %s\n", yamlScalar(it))
+	}
+}
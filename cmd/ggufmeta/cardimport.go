@@ -0,0 +1,281 @@
+// Package main implements the `import-card` subcommand, the inverse of `card`.
+// It reads the YAML frontmatter of a Hugging Face model card and writes the
+// license, base_model, datasets, language and tags fields into the matching
+// general.* keys. Only the frontmatter subset model cards use is understood:
+// top-level scalars and lists of scalars; nested mappings are skipped.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cardKV is one general.* pair derived from a model card.
+type cardKV struct {
+	key string
+	val any // string, []string or uint32
+}
+
+func runImportCard(args []string) error {
+	fs := flag.NewFlagSet("import-card", flag.ExitOnError)
+	out := fs.String("o", "", "write the edited file to `OUT` instead of replacing FILE")
+	dryRun := fs.Bool("dry-run", false, "print the keys that would be written as NDJSON and change nothing")
+	stamp := fs.Bool("stamp", false, "record the edit time, tool version and prior file hash under ggufmeta.*")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta import-card [-o out.gguf] [--dry-run] [--stamp] README.md file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cardPath, path := rest[0], rest[1]
+	raw, err := os.ReadFile(cardPath)
+	if err != nil {
+		return err
+	}
+	fm, err := parseFrontmatter(raw)
+	if err != nil {
+		return fmt.Errorf("import-card: %s: %w", cardPath, err)
+	}
+	pairs, owned := cardKVs(fm)
+	if len(pairs) == 0 {
+		return fmt.Errorf("import-card: %s has no license, base_model, datasets, language or tags", cardPath)
+	}
+
+	if *dryRun {
+		enc := json.NewEncoder(os.Stdout)
+		for _, p := range pairs {
+			typ := "string"
+			switch p.val.(type) {
+			case []string:
+				typ = "array[string]"
+			case uint32:
+				typ = "uint32"
+			}
+			if err := enc.Encode(kvEvent{Key: p.key, Type: typ, Value: p.val}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	encoded := make([]rawKV, 0, len(pairs))
+	for _, p := range pairs {
+		gw := newWriter(io.Discard)
+		if err := gw.AddKV(p.key, p.val); err != nil {
+			return fmt.Errorf("import-card: %w", err)
+		}
+		encoded = append(encoded, rawKV{key: p.key, enc: bytes.Clone(gw.kvs.Bytes())})
+	}
+	dest := path
+	if *out != "" {
+		dest = *out
+	}
+	return rewriteMetadata(path, dest, *stamp, func(kvs []rawKV) ([]rawKV, error) {
+		// Drop every key a card field owns, so stale numbered entries go too
+		kept := kvs[:0]
+		for _, kv := range kvs {
+			if !owned(kv.key) {
+				kept = append(kept, kv)
+			}
+		}
+		return append(kept, encoded...), nil
+	})
+}
+
+// cardKVs translates frontmatter fields to general.* pairs. owned reports
+// whether an existing key belongs to one of the fields present, and so is
+// replaced.
+func cardKVs(fm map[string][]string) (pairs []cardKV, owned func(key string) bool) {
+	var exact []string
+	var prefixes []string
+	scalar := func(field, key string) {
+		if v, ok := fm[field]; ok && len(v) > 0 {
+			pairs = append(pairs, cardKV{key, v[0]})
+			exact = append(exact, key)
+		}
+	}
+	list := func(field, key string) {
+		if v, ok := fm[field]; ok && len(v) > 0 {
+			pairs = append(pairs, cardKV{key, v})
+			exact = append(exact, key)
+		}
+	}
+	repos := func(field, prefix, urlBase string) {
+		ids, ok := fm[field]
+		if !ok || len(ids) == 0 {
+			return
+		}
+		pairs = append(pairs, cardKV{prefix + ".count", uint32(len(ids))})
+		for i, id := range ids {
+			org, name, found := strings.Cut(id, "/")
+			if !found {
+				org, name = "", id
+			}
+			if org != "" {
+				pairs = append(pairs, cardKV{fmt.Sprintf("%s.%d.organization", prefix, i), org})
+			}
+			pairs = append(pairs,
+				cardKV{fmt.Sprintf("%s.%d.name", prefix, i), name},
+				cardKV{fmt.Sprintf("%s.%d.repo_url", prefix, i), urlBase + id})
+		}
+		prefixes = append(prefixes, prefix+".")
+	}
+
+	scalar("license", "general.license")
+	scalar("license_name", "general.license.name")
+	scalar("license_link", "general.license.link")
+	repos("base_model", "general.base_model", "https://huggingface.co/")
+	repos("datasets", "general.dataset", "https://huggingface.co/datasets/")
+	list("language", "general.languages")
+	list("tags", "general.tags")
+
+	owned = func(key string) bool {
+		for _, k := range exact {
+			if key == k {
+				return true
+			}
+		}
+		for _, p := range prefixes {
+			if strings.HasPrefix(key, p) {
+				return true
+			}
+		}
+		return false
+	}
+	return pairs, owned
+}
+
+// parseFrontmatter returns the top-level fields of the YAML block between the
+// leading "---" lines of a model card; a scalar field is a one-item list.
+func parseFrontmatter(doc []byte) (map[string][]string, error) {
+	lines := strings.Split(strings.ReplaceAll(string(doc), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil, fmt.Errorf("no YAML frontmatter (the file must start with ---)")
+	}
+	fm := make(map[string][]string)
+	var list string // field whose block list is being read
+	for i, line := range lines[1:] {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "---":
+			return fm, nil
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "- "):
+			if list != "" {
+				v, err := yamlValue(strings.TrimPrefix(trimmed, "- "))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+2, err)
+				}
+				fm[list] = append(fm[list], v)
+			}
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			continue // nested mapping content
+		}
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY: VALUE", i+2)
+		}
+		key, rest = strings.TrimSpace(key), strings.TrimSpace(rest)
+		list = ""
+		switch {
+		case rest == "":
+			list = key
+			fm[key] = nil
+		case strings.HasPrefix(rest, "["):
+			items, err := yamlFlowList(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+2, err)
+			}
+			fm[key] = items
+		default:
+			v, err := yamlValue(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+2, err)
+			}
+			fm[key] = []string{v}
+		}
+	}
+	return nil, fmt.Errorf("frontmatter is not closed with ---")
+}
+
+// yamlFlowList parses a one-line flow sequence such as [en, "fr"].
+func yamlFlowList(s string) ([]string, error) {
+	inner, ok := strings.CutSuffix(strings.TrimPrefix(s, "["), "]")
+	if !ok {
+		return nil, fmt.Errorf("flow list %q must end on the same line", s)
+	}
+	var items []string
+	for len(strings.TrimSpace(inner)) > 0 {
+		inner = strings.TrimSpace(inner)
+		end := strings.IndexByte(inner, ',')
+		if q := inner[0]; q == '"' || q == '\'' {
+			// The closing quote, not the first comma, ends a quoted item
+			close := strings.IndexByte(inner[1:], q)
+			for q == '\'' && close >= 0 && close+2 < len(inner) && inner[close+2] == '\'' {
+				next := strings.IndexByte(inner[close+3:], q)
+				if next < 0 {
+					close = -1
+					break
+				}
+				close += next + 2
+			}
+			if close < 0 {
+				return nil, fmt.Errorf("unterminated string in %q", s)
+			}
+			end = strings.IndexByte(inner[close+2:], ',')
+			if end >= 0 {
+				end += close + 2
+			}
+		}
+		item := inner
+		if end >= 0 {
+			item, inner = inner[:end], inner[end+1:]
+		} else {
+			inner = ""
+		}
+		v, err := yamlValue(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// yamlValue decodes a plain, single-quoted or double-quoted scalar,
+// dropping a trailing comment from plain ones.
+func yamlValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := strings.LastIndexByte(s, '"')
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("bad string %s: %w", s, err)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		end := strings.LastIndexByte(s, '\'')
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
@@ -0,0 +1,344 @@
+// Package main implements the local model catalog: `index` walks a directory
+// tree and records one entry per GGUF file (name, architecture, parameter
+// count, quantization, modality, size, hash, fingerprint), and `search` queries the
+// saved catalog without touching the models again. Re-indexing reuses the
+// hashes of files whose size and modification time did not change.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// catalogVersion is written to every catalog and checked on load.
+const catalogVersion = 1
+
+// catalog is the document `ggufmeta index` writes.
+type catalog struct {
+	Version int            `json:"version"`
+	Root    string         `json:"root"` // directory that was indexed, absolute
+	Created time.Time      `json:"created"`
+	Models  []catalogEntry `json:"models"`
+}
+
+// catalogEntry describes one GGUF file.
+type catalogEntry struct {
+	Path        string    `json:"path"` // absolute
+	Name        string    `json:"name"` // general.name, or the file name without extension
+	Arch        string    `json:"arch"`
+	Params      uint64    `json:"params"`               // total tensor elements
+	Quant       string    `json:"quant"`                // e.g. "Q4_K_M"
+	Modality    string    `json:"modality,omitempty"`   // see modalityLabel
+	Modalities  []string  `json:"modalities,omitempty"` // see modalitiesOf
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modTime"`
+	SHA256      string    `json:"sha256,omitempty"` // whole file; empty with --no-hash
+	Fingerprint string    `json:"fingerprint"`      // see fingerprintOf
+}
+
+// fileTypeNames maps general.file_type (llama.cpp's llama_ftype) to the
+// quantization preset name.
+var fileTypeNames = map[uint64]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S",
+	15: "Q4_K_M", 16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS",
+	20: "IQ2_XS", 21: "Q2_K_S", 22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S",
+	25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M", 28: "IQ2_S", 29: "IQ2_M",
+	30: "IQ4_XS", 31: "IQ1_M", 32: "BF16", 36: "TQ1_0", 37: "TQ2_0",
+	38: "MXFP4_MOE",
+}
+
+// quantLabel names gf's quantization: the general.file_type preset when it is
+// known, otherwise the tensor type holding the most bytes.
+func quantLabel(gf *ggufFile) string {
+	if ft, ok := gf.Uint("general.file_type"); ok {
+		if name, ok := fileTypeNames[ft]; ok {
+			return name
+		}
+	}
+	ends := gf.tensorEnds()
+	bytesByType := make(map[uint32]uint64)
+	var top uint32
+	for _, t := range gf.Tensors {
+		bytesByType[t.Type] += gf.tensorSize(t, ends)
+		if bytesByType[t.Type] > bytesByType[top] {
+			top = t.Type
+		}
+	}
+	if len(bytesByType) == 0 {
+		return ""
+	}
+	return ggmlTypeName(top)
+}
+
+// paramCount returns the total number of tensor elements in gf.
+func paramCount(gf *ggufFile) uint64 {
+	var n uint64
+	for _, t := range gf.Tensors {
+		n += tensorElems(t.Dims)
+	}
+	return n
+}
+
+// humanCount formats a parameter count the way model names do, e.g. "7.2B".
+func humanCount(n uint64) string {
+	switch {
+	case n >= 1e12:
+		return fmt.Sprintf("%.1fT", float64(n)/1e12)
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.0fM", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.0fK", float64(n)/1e3)
+	}
+	return fmt.Sprint(n)
+}
+
+func runIndex(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	out := fs.String("o", "catalog.json", "write the catalog to `FILE`; an existing catalog there is updated")
+	noHash := fs.Bool("no-hash", false, "skip hashing whole files (much faster on large models)")
+	stats := fs.Bool("stats", false, "print a summary record of the indexed models to stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta index [-o catalog.json] [--no-hash] [--stats] DIR\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	root, err := filepath.Abs(rest[0])
+	if err != nil {
+		return err
+	}
+
+	// Hashes from the previous catalog stay valid while size and mtime match
+	previous := make(map[string]catalogEntry)
+	if old, err := readCatalog(*out); err == nil {
+		for _, e := range old.Models {
+			previous[e.Path] = e
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	cat := catalog{Version: catalogVersion, Root: root, Created: time.Now().UTC(), Models: []catalogEntry{}}
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".gguf") {
+			return nil
+		}
+		e, err := indexFile(path, previous[path], !*noHash)
+		if err != nil {
+			logger.Warn("skipped unreadable model", "path", path, "err", err)
+			return nil
+		}
+		cat.Models = append(cat.Models, e)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+
+	af, err := createAtomicFile(*out)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(af)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(cat); err != nil {
+		af.Abort()
+		return err
+	}
+	if err := af.Commit(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "indexed %d models into %s\n", len(cat.Models), *out)
+	if *stats {
+		return writeStats(os.Stdout, summarizeCatalog(cat.Models))
+	}
+	return nil
+}
+
+// indexFile builds the catalog entry for path, reusing prev's hash when the
+// file is unchanged since prev was recorded.
+func indexFile(path string, prev catalogEntry, hash bool) (catalogEntry, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return catalogEntry{}, err
+	}
+	gf, err := loadFile(context.Background(), path, fingerprintPolicy())
+	if err != nil {
+		return catalogEntry{}, err
+	}
+	e := catalogEntry{
+		Path:        path,
+		Arch:        gf.Arch(),
+		Params:      paramCount(gf),
+		Quant:       quantLabel(gf),
+		Modalities:  modalitiesOf(gf),
+		Size:        st.Size(),
+		ModTime:     st.ModTime().UTC(),
+		Fingerprint: fingerprintOf(gf),
+	}
+	e.Modality = modalityLabel(e.Modalities)
+	if e.Name, _ = gf.GetString("general.name"); e.Name == "" {
+		e.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	switch {
+	case !hash:
+	case prev.SHA256 != "" && prev.Size == e.Size && prev.ModTime.Equal(e.ModTime):
+		e.SHA256 = prev.SHA256
+	default:
+		if e.SHA256, err = fileSHA256(path); err != nil {
+			return catalogEntry{}, err
+		}
+	}
+	return e, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readCatalog(path string) (*catalog, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cat catalog
+	if err := json.Unmarshal(raw, &cat); err != nil {
+		return nil, fmt.Errorf("%s: not a catalog: %w", path, err)
+	}
+	if cat.Version != catalogVersion {
+		return nil, fmt.Errorf("%s: catalog version %d, want %d (re-run ggufmeta index)", path, cat.Version, catalogVersion)
+	}
+	return &cat, nil
+}
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	catPath := fs.String("catalog", "catalog.json", "catalog `FILE` written by ggufmeta index")
+	arch := fs.String("arch", "", "only models of this architecture")
+	quant := fs.String("quant", "", "only models with this quantization (e.g. Q4_K_M)")
+	modality := fs.String("modality", "", "only models handling `M`: text, vision, audio, or multimodal for more than one")
+	like := fs.String("like", "", "only other files with the same fingerprint as `FILE` (other quants of its base model)")
+	asJSON := fs.Bool("json", false, "print matching entries as NDJSON")
+	stats := fs.Bool("stats", false, "end with a summary record of the matching models")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta search [--catalog FILE] [--arch A] [--quant Q] [--modality M] [--like FILE] [--json] [--stats] [WORD...]\n")
+		fmt.Fprintf(os.Stderr, "\nEvery WORD must occur, case-insensitively, in the name, path, architecture, quantization or modality.\n")
+		fs.PrintDefaults()
+	}
+	words := parseInterspersed(fs, args)
+	cat, err := readCatalog(*catPath)
+	if err != nil {
+		return err
+	}
+	var likeFP, likePath string
+	if *like != "" {
+		gf, err := loadFile(context.Background(), *like, fingerprintPolicy())
+		if err != nil {
+			return err
+		}
+		likeFP = fingerprintOf(gf)
+		if likePath, err = filepath.Abs(*like); err != nil {
+			return err
+		}
+	}
+
+	var hits []catalogEntry
+	for _, e := range cat.Models {
+		if *arch != "" && !strings.EqualFold(e.Arch, *arch) || *quant != "" && !strings.EqualFold(e.Quant, *quant) {
+			continue
+		}
+		if *modality != "" && !hasModality(e.Modalities, *modality) {
+			continue
+		}
+		if likeFP != "" && (e.Fingerprint != likeFP || e.Path == likePath) {
+			continue
+		}
+		haystack := strings.ToLower(strings.Join([]string{e.Name, e.Path, e.Arch, e.Quant, e.Modality}, "\x00"))
+		match := true
+		for _, w := range words {
+			if !strings.Contains(haystack, strings.ToLower(w)) {
+				match = false
+				break
+			}
+		}
+		if match {
+			hits = append(hits, e)
+		}
+	}
+	if likeFP != "" {
+		// Same model throughout, so order the quants from smallest to largest
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].Size < hits[j].Size })
+	} else {
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].Name < hits[j].Name })
+	}
+	if err := writeCatalogEntries(hits, *asJSON); err != nil {
+		return err
+	}
+	if *stats {
+		return writeStats(os.Stdout, summarizeCatalog(hits))
+	}
+	return nil
+}
+
+// writeCatalogEntries prints entries as NDJSON or as an aligned table.
+func writeCatalogEntries(entries []catalogEntry, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Printf("%-32s %-10s %7s %-9s %10s  %s\n", e.Name, e.Arch, humanCount(e.Params), e.Quant, humanBytes(uint64(e.Size)), e.Path)
+	}
+	return nil
+}
+
+// parseInterspersed parses fs from args, allowing flags after positional
+// arguments (`index DIR -o out.json`), and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		if args[0] == "--" {
+			return append(rest, args[1:]...)
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
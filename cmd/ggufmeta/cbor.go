@@ -0,0 +1,240 @@
+// Package main implements the --format cbor output.
+// Records are written as an RFC 8742 CBOR sequence of RFC 8949 maps, using
+// deterministic encoding: shortest-form integers, sorted map keys, and byte
+// strings for GGUF strings that are not valid UTF-8.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+func init() {
+	registerFormat("cbor", outputFormat{
+		Description: "RFC 8742 CBOR sequence, deterministic encoding",
+		Binary:      true,
+		New:         func(w io.Writer, _ bool) recordEncoder { return newCBOREncoder(w) },
+	})
+}
+
+// CBOR major types (RFC 8949 section 3.1).
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborSimple = 7 << 5
+)
+
+// cborEncoder streams records as a CBOR sequence.
+type cborEncoder struct {
+	w *bufio.Writer
+}
+
+func newCBOREncoder(w io.Writer) *cborEncoder {
+	return &cborEncoder{w: bufio.NewWriterSize(w, 64<<10)}
+}
+
+// Encode writes one record. Known event types are encoded field by field so
+// typed values keep their width; anything else goes through its JSON form.
+func (e *cborEncoder) Encode(v any) error {
+	switch ev := v.(type) {
+	case headerEvent:
+		return e.writeValue(map[string]any{
+			"kind": ev.Kind,
+			"gguf": map[string]any{
+				"version":     ev.GGUF.Version,
+				"tensorCount": ev.GGUF.TensorCount,
+				"kvCount":     ev.GGUF.KVCount,
+			},
+		})
+	case kvEvent:
+		return e.writeValue(map[string]any{"key": ev.Key, "type": ev.Type, "value": ev.Value})
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return err
+	}
+	return e.writeValue(generic)
+}
+
+// Flush pushes buffered bytes to the underlying writer.
+func (e *cborEncoder) Flush() error { return e.w.Flush() }
+
+// writeValue encodes the value shapes produced by the parser.
+func (e *cborEncoder) writeValue(v any) error {
+	switch x := v.(type) {
+	case nil:
+		e.w.WriteByte(cborSimple | 22)
+	case bool:
+		if x {
+			e.w.WriteByte(cborSimple | 21)
+		} else {
+			e.w.WriteByte(cborSimple | 20)
+		}
+	case uint8:
+		e.writeHead(cborUint, uint64(x))
+	case uint16:
+		e.writeHead(cborUint, uint64(x))
+	case uint32:
+		e.writeHead(cborUint, uint64(x))
+	case uint64:
+		e.writeHead(cborUint, x)
+	case int8:
+		e.writeInt(int64(x))
+	case int16:
+		e.writeInt(int64(x))
+	case int32:
+		e.writeInt(int64(x))
+	case int64:
+		e.writeInt(x)
+	case int:
+		e.writeInt(int64(x))
+	case float32:
+		e.writeFloat(float64(x))
+	case float64:
+		e.writeFloat(x)
+	case string:
+		// GGUF promises UTF-8 but does not enforce it; CBOR text must be valid
+		if utf8.ValidString(x) {
+			e.writeHead(cborText, uint64(len(x)))
+		} else {
+			e.writeHead(cborBytes, uint64(len(x)))
+		}
+		e.w.WriteString(x)
+	case []any:
+		e.writeHead(cborArray, uint64(len(x)))
+		for _, el := range x {
+			if err := e.writeValue(el); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		// Deterministic encoding sorts keys by their encoded bytes: shorter first, then bytewise
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		e.writeHead(cborMap, uint64(len(keys)))
+		for _, k := range keys {
+			if err := e.writeValue(k); err != nil {
+				return err
+			}
+			if err := e.writeValue(x[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported value type %T", v)
+	}
+	return nil
+}
+
+// writeInt encodes a signed integer as major type 0 or 1.
+func (e *cborEncoder) writeInt(i int64) {
+	if i >= 0 {
+		e.writeHead(cborUint, uint64(i))
+		return
+	}
+	e.writeHead(cborNegInt, uint64(-(i + 1)))
+}
+
+// writeFloat uses the shortest of half, single and double precision that keeps
+// the value exact, as RFC 8949 preferred serialization requires.
+func (e *cborEncoder) writeFloat(f float64) {
+	if math.IsNaN(f) {
+		e.w.WriteByte(cborSimple | 25)
+		e.writeBE(2, 0x7e00) // canonical quiet NaN
+		return
+	}
+	if f32 := float32(f); float64(f32) == f {
+		if h, ok := halfBits(f32); ok {
+			e.w.WriteByte(cborSimple | 25)
+			e.writeBE(2, uint64(h))
+			return
+		}
+		e.w.WriteByte(cborSimple | 26)
+		e.writeBE(4, uint64(math.Float32bits(f32)))
+		return
+	}
+	e.w.WriteByte(cborSimple | 27)
+	e.writeBE(8, math.Float64bits(f))
+}
+
+// halfBits converts f to IEEE 754 half precision when that is lossless.
+func halfBits(f float32) (uint16, bool) {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xff
+	mant := b & 0x7fffff
+	switch {
+	case exp == 0xff: // infinity (NaN is handled by the caller)
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		return 0, false // float32 subnormals are far below half range
+	}
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		// Half subnormal: value is k * 2^-24 with k < 1024
+		full := 0x800000 | mant
+		shift := uint(-(e + 1))
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	}
+	return 0, false
+}
+
+// writeHead writes a major type with its argument in the shortest form.
+func (e *cborEncoder) writeHead(major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		e.w.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		e.w.WriteByte(major | 24)
+		e.writeBE(1, arg)
+	case arg <= math.MaxUint16:
+		e.w.WriteByte(major | 25)
+		e.writeBE(2, arg)
+	case arg <= math.MaxUint32:
+		e.w.WriteByte(major | 26)
+		e.writeBE(4, arg)
+	default:
+		e.w.WriteByte(major | 27)
+		e.writeBE(8, arg)
+	}
+}
+
+// writeBE writes the low n bytes of v in network byte order.
+func (e *cborEncoder) writeBE(n int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.w.Write(b[8-n:])
+}
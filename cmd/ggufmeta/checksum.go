@@ -0,0 +1,96 @@
+// Package main implements the checksum validation check.
+// Some converters embed a digest of the tensor data in the metadata; this check
+// recomputes it instead of treating the key as an opaque string.
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+)
+
+// checksumKeys are the keys holding a digest of this file's tensor data
+// section (everything from the aligned data offset to the end of the file).
+var checksumKeys = []string{"general.file_hash", "split.tensors.hash"}
+
+// hashAlgos maps digest names to constructors; a bare hex digest is matched
+// by its length.
+var hashAlgos = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func init() {
+	registerCheck("checksum", checkChecksums)
+}
+
+func checkChecksums(v *validation) {
+	sums := make(map[string][]byte) // algorithm -> computed digest, shared by keys
+	for _, key := range checksumKeys {
+		kv, ok := v.gf.Get(key)
+		if !ok {
+			continue
+		}
+		s, ok := kv.Value.(string)
+		if !ok {
+			v.report("warning", key, "", "expected a string digest, got %s", kv.Type)
+			continue
+		}
+		algo, want, ok := parseDigest(s)
+		if !ok {
+			v.report("warning", key, "", "unrecognized digest %q (want [algo:]hex with md5, sha1, sha256 or sha512)", s)
+			continue
+		}
+		got, ok := sums[algo]
+		if !ok {
+			h := hashAlgos[algo]()
+			size := int64(v.gf.Size) - int64(v.gf.DataOffset)
+			if _, err := io.Copy(h, io.NewSectionReader(v.data, int64(v.gf.DataOffset), max(size, 0))); err != nil {
+				v.report("error", key, "", "reading tensor data: %v", err)
+				continue
+			}
+			got = h.Sum(nil)
+			sums[algo] = got
+		}
+		if hex.EncodeToString(got) != want {
+			v.report("error", key, "", "%s mismatch: file has %s, tensor data hashes to %x", algo, want, got)
+		} else {
+			v.report("info", key, "", "%s of tensor data verified", algo)
+		}
+	}
+}
+
+// parseDigest splits "algo:hex" or bare hex into a known algorithm and a
+// lower-case hex digest of the right length.
+func parseDigest(s string) (algo, digest string, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	algo, digest, found := strings.Cut(s, ":")
+	if !found {
+		digest = s
+		switch len(s) {
+		case 32:
+			algo = "md5"
+		case 40:
+			algo = "sha1"
+		case 64:
+			algo = "sha256"
+		case 128:
+			algo = "sha512"
+		}
+	}
+	newHash, ok := hashAlgos[algo]
+	if !ok {
+		return "", "", false
+	}
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != newHash().Size() {
+		return "", "", false
+	}
+	return algo, digest, true
+}
@@ -0,0 +1,219 @@
+// Package main implements the `compat` subcommand.
+// A runtime rule set lists what one loader accepts - GGUF versions,
+// architectures and tensor types, each with the build that introduced it - and
+// the keys it needs; compat checks a file against one and says whether that
+// runtime build will load it. Rule sets are registered in code or read from a
+// JSON file with --rules.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runtimeRules is one runtime's rule set. Build numbers are the runtime's own
+// release counter (llama.cpp's bNNNN); 0 means supported in every build.
+type runtimeRules struct {
+	Name          string            `json:"name"`
+	GGUFVersions  []uint32          `json:"ggufVersions"`
+	Architectures map[string]uint64 `json:"architectures"` // architecture -> first build
+	TensorTypes   map[string]uint64 `json:"tensorTypes"`   // ggml type name -> first build
+	RequiredKeys  []string          `json:"requiredKeys"`  // "{arch}" expands to the architecture
+	// WarnMissing are keys whose absence still loads but degrades behaviour.
+	WarnMissing map[string]string `json:"warnMissing"` // key -> consequence
+}
+
+var runtimeRuleSets = make(map[string]*runtimeRules)
+
+// registerRuntime adds a built-in rule set; call it from an init function.
+func registerRuntime(r *runtimeRules) {
+	if _, dup := runtimeRuleSets[r.Name]; dup {
+		panic(fmt.Sprintf("runtime %q registered twice", r.Name))
+	}
+	runtimeRuleSets[r.Name] = r
+}
+
+func init() {
+	// Build numbers mark roughly where support landed upstream; pin an exact
+	// rule set with --rules when it matters.
+	registerRuntime(&runtimeRules{
+		Name:         "llama.cpp",
+		GGUFVersions: []uint32{2, 3},
+		Architectures: map[string]uint64{
+			"llama": 0, "falcon": 0, "gpt2": 0, "gptj": 0, "gptneox": 0, "mpt": 0,
+			"baichuan": 0, "starcoder": 0, "refact": 0, "bert": 0, "bloom": 0,
+			"stablelm": 0, "qwen": 0, "qwen2": 0, "phi2": 0, "plamo": 0,
+			"codeshell": 0, "orion": 0, "internlm2": 0, "minicpm": 0, "gemma": 0,
+			"starcoder2": 0, "mamba": 0, "xverse": 0, "command-r": 0, "nomic-bert": 0,
+			"dbrx": 2780, "olmo": 2780, "qwen2moe": 2700, "phi3": 2717,
+			"arctic": 2970, "deepseek2": 2990, "jina-bert-v2": 2950,
+			"gemma2": 3259, "chatglm": 3400, "t5": 3400, "t5encoder": 3500,
+			"jais": 3400, "nemotron": 3600, "exaone": 3650, "minicpm3": 3700,
+			"rwkv6": 3650, "granite": 3800, "granitemoe": 3800, "olmoe": 3700,
+			"chameleon": 3900, "olmo2": 4100, "cohere2": 4380, "deepseek": 4400,
+			"phimoe": 4400, "qwen2vl": 4400, "deepseek3": 4400,
+			"gemma3": 4875, "llama4": 5074, "qwen3": 5092, "qwen3moe": 5092,
+			"gpt-oss": 6096,
+		},
+		TensorTypes: map[string]uint64{
+			"F32": 0, "F16": 0, "Q4_0": 0, "Q4_1": 0, "Q5_0": 0, "Q5_1": 0,
+			"Q8_0": 0, "Q8_1": 0, "Q2_K": 0, "Q3_K": 0, "Q4_K": 0, "Q5_K": 0,
+			"Q6_K": 0, "Q8_K": 0, "IQ2_XXS": 0, "IQ2_XS": 0, "IQ3_XXS": 2100,
+			"IQ1_S": 2200, "IQ4_NL": 2200, "IQ3_S": 2300, "IQ2_S": 2300,
+			"IQ4_XS": 2300, "I8": 2400, "I16": 2400, "I32": 2400, "I64": 2400,
+			"F64": 2400, "IQ1_M": 2500, "BF16": 2800, "TQ1_0": 3730, "TQ2_0": 3730,
+			"MXFP4": 6096,
+		},
+		RequiredKeys: []string{"general.architecture", "{arch}.block_count", "{arch}.embedding_length"},
+		WarnMissing: map[string]string{
+			"tokenizer.ggml.model":    "no vocabulary: the model loads but cannot tokenize text",
+			"tokenizer.ggml.pre":      "missing pre-tokenizer type: llama.cpp falls back to 'default', which may split text wrongly",
+			"tokenizer.chat_template": "no chat template: chat front ends fall back to a generic format",
+		},
+	})
+}
+
+func runCompat(args []string) error {
+	fs := flag.NewFlagSet("compat", flag.ExitOnError)
+	runtime := fs.String("runtime", "llama.cpp", "runtime to check against, as NAME or NAME@bBUILD (e.g. llama.cpp@b4500)")
+	rulesFile := fs.String("rules", "", "read the rule set from a JSON `FILE` instead of the built-in one")
+	asJSON := fs.Bool("json", false, "print findings as one JSON document")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta compat [--runtime NAME[@bBUILD]] [--rules FILE] [--json] file.gguf\n")
+		fmt.Fprintf(os.Stderr, "\nBuilt-in runtimes: %s\n", strings.Join(runtimeNames(), ", "))
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	name, build, err := parseRuntime(*runtime)
+	if err != nil {
+		return fmt.Errorf("compat: %w", err)
+	}
+	rules, ok := runtimeRuleSets[name]
+	if *rulesFile != "" {
+		raw, err := os.ReadFile(*rulesFile)
+		if err != nil {
+			return err
+		}
+		rules = &runtimeRules{}
+		if err := json.Unmarshal(raw, rules); err != nil {
+			return fmt.Errorf("compat: %s: %w", *rulesFile, err)
+		}
+		if rules.Name == "" {
+			rules.Name = name
+		}
+	} else if !ok {
+		return fmt.Errorf("compat: unknown runtime %q (built in: %s; or use --rules)", name, strings.Join(runtimeNames(), ", "))
+	}
+
+	path := fs.Arg(0)
+	gf, err := loadFile(context.Background(), path, basePolicy())
+	if err != nil {
+		return err
+	}
+	ok, err = writeFindings(path, checkCompat(gf, rules, build), *asJSON)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runtimeNames() []string {
+	names := make([]string, 0, len(runtimeRuleSets))
+	for name := range runtimeRuleSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseRuntime splits "llama.cpp@b4500" into name and build; a missing build
+// means the newest one.
+func parseRuntime(spec string) (string, uint64, error) {
+	name, ver, found := strings.Cut(spec, "@")
+	if !found {
+		return name, ^uint64(0), nil
+	}
+	build, err := strconv.ParseUint(strings.TrimPrefix(ver, "b"), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("bad build %q in %q (want e.g. @b4500)", ver, spec)
+	}
+	return name, build, nil
+}
+
+// checkCompat lists the reasons rules' runtime at build would refuse gf
+// (errors) or load it with reduced function (warnings).
+func checkCompat(gf *ggufFile, rules *runtimeRules, build uint64) []finding {
+	v := &validation{gf: gf}
+	at := func(first uint64) string {
+		return fmt.Sprintf("%s b%d", rules.Name, first)
+	}
+
+	v.check = "version"
+	if len(rules.GGUFVersions) > 0 && !slices.Contains(rules.GGUFVersions, gf.Header.GGUF.Version) {
+		v.report("error", "", "", "GGUF version %d is not supported by %s", gf.Header.GGUF.Version, rules.Name)
+	}
+
+	v.check = "architecture"
+	arch := gf.Arch()
+	if first, ok := rules.Architectures[arch]; arch != "" && len(rules.Architectures) > 0 {
+		switch {
+		case !ok:
+			v.report("error", "general.architecture", "", "architecture %q is not supported by %s", arch, rules.Name)
+		case first > build:
+			v.report("error", "general.architecture", "", "architecture %q needs %s or later", arch, at(first))
+		}
+	}
+
+	v.check = "required"
+	for _, key := range rules.RequiredKeys {
+		key = expandArch(gf, key)
+		if _, ok := gf.Get(key); !ok {
+			v.report("error", key, "", "required key is missing")
+		}
+	}
+	missing := make([]string, 0, len(rules.WarnMissing))
+	for key := range rules.WarnMissing {
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		if _, ok := gf.Get(expandArch(gf, key)); !ok {
+			v.report("warning", expandArch(gf, key), "", "%s", rules.WarnMissing[key])
+		}
+	}
+
+	v.check = "tensor-type"
+	if len(rules.TensorTypes) > 0 {
+		// Report each unsupported type once, naming its first tensor
+		seen := make(map[uint32]bool)
+		for _, t := range gf.Tensors {
+			if seen[t.Type] {
+				continue
+			}
+			seen[t.Type] = true
+			name := ggmlTypeName(t.Type)
+			first, ok := rules.TensorTypes[name]
+			switch {
+			case !ok:
+				v.report("error", "", t.Name, "tensor type %s is not supported by %s", name, rules.Name)
+			case first > build:
+				v.report("error", "", t.Name, "tensor type %s needs %s or later", name, at(first))
+			}
+		}
+	}
+	return v.findings
+}
@@ -0,0 +1,79 @@
+// Package main implements --stats for the catalog commands.
+// After `index` walks a directory, or `search` selects from a catalog, the
+// summary record tallies the models by architecture, quantization, modality
+// and parameter bucket, with their total size, to describe a model zoo at a
+// glance.
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// corpusStats is the summary record; its kind tells it apart in NDJSON output.
+type corpusStats struct {
+	Kind       string         `json:"kind"` // always "stats"
+	Files      int            `json:"files"`
+	TotalBytes int64          `json:"totalBytes"`
+	ByArch     map[string]int `json:"byArch"`
+	ByQuant    map[string]int `json:"byQuant"`
+	ByModality map[string]int `json:"byModality"`
+	ByParams   map[string]int `json:"byParams"` // see paramBuckets
+}
+
+// paramBuckets are the parameter count ranges, as upper bounds, with the
+// label used in corpusStats.ByParams. They follow common model sizes.
+var paramBuckets = []struct {
+	below uint64
+	label string
+}{
+	{1e9, "<1B"},
+	{3e9, "1B-3B"},
+	{9e9, "3B-9B"},
+	{16e9, "9B-16B"},
+	{40e9, "16B-40B"},
+	{80e9, "40B-80B"},
+}
+
+// paramBucket labels a parameter count; 80B and above share one bucket.
+func paramBucket(n uint64) string {
+	for _, b := range paramBuckets {
+		if n < b.below {
+			return b.label
+		}
+	}
+	return ">=80B"
+}
+
+func summarizeCatalog(entries []catalogEntry) corpusStats {
+	st := corpusStats{
+		Kind:       "stats",
+		ByArch:     make(map[string]int),
+		ByQuant:    make(map[string]int),
+		ByModality: make(map[string]int),
+		ByParams:   make(map[string]int),
+	}
+	for _, e := range entries {
+		st.Files++
+		st.TotalBytes += e.Size
+		st.ByArch[orUnknown(e.Arch)]++
+		st.ByQuant[orUnknown(e.Quant)]++
+		st.ByModality[orUnknown(e.Modality)]++
+		st.ByParams[paramBucket(e.Params)]++
+	}
+	return st
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// writeStats prints st as one JSON line.
+func writeStats(w io.Writer, st corpusStats) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false) // keep "<1B" readable
+	return enc.Encode(st)
+}
@@ -0,0 +1,289 @@
+// Package main implements the `daemon` subcommand.
+// It parses every model under a directory once and answers metadata queries
+// over a Unix socket from memory, for local integrations that cannot afford
+// to re-read a file per question.
+//
+// Protocol: each message in either direction is a 4-byte big-endian length
+// followed by that many bytes of JSON. A connection may send any number of
+// requests; each gets exactly one response, in order.
+//
+//	{"op":"list"}                                  -> [{file, name, arch, quant, size}, ...]
+//	{"op":"kvs","file":F,"keys":"general.,llama."} -> [kv record, ...] (keys as for --keys)
+//	{"op":"get","file":F,"key":K}                  -> kv record
+//	{"op":"tensors","file":F}                      -> [tensor record, ...]
+//	{"op":"reload"}                                -> {"files": N}, re-reading changed files
+//
+// Responses are {"ok":true,"result":...} or {"ok":false,"error":"..."}.
+// Files are named by their slash-separated path relative to the directory.
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonMaxRequest bounds a request message; requests are tiny.
+const daemonMaxRequest = 1 << 20
+
+type daemonRequest struct {
+	Op   string `json:"op"`
+	File string `json:"file,omitempty"`
+	Key  string `json:"key,omitempty"`
+	Keys string `json:"keys,omitempty"`
+}
+
+type daemonResponse struct {
+	OK     bool   `json:"ok"`
+	Result any    `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// daemonFile is one loaded model with the stat it was loaded at.
+type daemonFile struct {
+	gf      *ggufFile
+	size    int64
+	modTime time.Time
+}
+
+// daemonListEntry is one element of the list result.
+type daemonListEntry struct {
+	File  string `json:"file"`
+	Name  string `json:"name,omitempty"`
+	Arch  string `json:"arch,omitempty"`
+	Quant string `json:"quant,omitempty"`
+	Size  int64  `json:"size"`
+}
+
+// modelStore holds the parsed models of one directory.
+type modelStore struct {
+	root  string
+	mu    sync.RWMutex
+	files map[string]daemonFile
+}
+
+// load walks the directory, re-parsing only files whose size or mtime
+// changed since the last load, and drops files that are gone.
+func (s *modelStore) load() error {
+	s.mu.RLock()
+	old := s.files
+	s.mu.RUnlock()
+	files := make(map[string]daemonFile)
+	err := filepath.WalkDir(s.root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".gguf") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		st, err := os.Stat(path)
+		if err != nil {
+			logger.Warn("skipped unreadable model", "path", path, "err", err)
+			return nil
+		}
+		if prev, ok := old[rel]; ok && prev.size == st.Size() && prev.modTime.Equal(st.ModTime()) {
+			files[rel] = prev
+			return nil
+		}
+		gf, err := loadFile(context.Background(), path, basePolicy())
+		if err != nil {
+			logger.Warn("skipped unreadable model", "path", path, "err", err)
+			return nil
+		}
+		files[rel] = daemonFile{gf: gf, size: st.Size(), modTime: st.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.files = files
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *modelStore) file(name string) (*ggufFile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("no model %q", name)
+	}
+	return f.gf, nil
+}
+
+// handle answers one request.
+func (s *modelStore) handle(req daemonRequest) (any, error) {
+	switch req.Op {
+	case "list":
+		s.mu.RLock()
+		list := make([]daemonListEntry, 0, len(s.files))
+		for name, f := range s.files {
+			e := daemonListEntry{File: name, Arch: f.gf.Arch(), Quant: quantLabel(f.gf), Size: f.size}
+			e.Name, _ = f.gf.GetString("general.name")
+			list = append(list, e)
+		}
+		s.mu.RUnlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+		return list, nil
+	case "kvs":
+		gf, err := s.file(req.File)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := parseKeyFilter(req.Keys, false)
+		if err != nil {
+			return nil, err
+		}
+		kvs := []kvEvent{}
+		for _, kv := range gf.KVs {
+			if filter.match(kv.Key) {
+				kvs = append(kvs, kv)
+			}
+		}
+		return kvs, nil
+	case "get":
+		gf, err := s.file(req.File)
+		if err != nil {
+			return nil, err
+		}
+		kv, ok := gf.Get(req.Key)
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", req.Key)
+		}
+		return kv, nil
+	case "tensors":
+		gf, err := s.file(req.File)
+		if err != nil {
+			return nil, err
+		}
+		return tensorRecords(gf), nil
+	case "reload":
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return map[string]int{"files": len(s.files)}, nil
+	}
+	return nil, fmt.Errorf("unknown op %q (want list, kvs, get, tensors or reload)", req.Op)
+}
+
+// serve answers requests on conn until the client hangs up.
+func (s *modelStore) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var n uint32
+		if err := binary.Read(conn, binary.BigEndian, &n); err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Warn("daemon: read failed", "err", err)
+			}
+			return
+		}
+		if n > daemonMaxRequest {
+			logger.Warn("daemon: request too large; closing connection", "bytes", n)
+			return
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			logger.Warn("daemon: read failed", "err", err)
+			return
+		}
+		var resp daemonResponse
+		var req daemonRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			resp.Error = "bad request: " + err.Error()
+		} else if result, err := s.handle(req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.OK, resp.Result = true, result
+		}
+		out, err := json.Marshal(resp)
+		if err != nil {
+			out, _ = json.Marshal(daemonResponse{Error: err.Error()})
+		}
+		if err := binary.Write(conn, binary.BigEndian, uint32(len(out))); err != nil {
+			return
+		}
+		if _, err := conn.Write(out); err != nil {
+			return
+		}
+	}
+}
+
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	socket := fs.String("socket", "", "listen on the Unix socket at `PATH`")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta daemon --socket PATH DIR\n")
+		fmt.Fprintf(os.Stderr, "\nMessages are a 4-byte big-endian length and JSON; ops: list, kvs, get, tensors, reload.\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if *socket == "" || len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	root, err := filepath.Abs(rest[0])
+	if err != nil {
+		return err
+	}
+	// A socket file left by a crashed daemon blocks Listen; remove it, but
+	// never take over from a daemon that still answers
+	if _, err := os.Stat(*socket); err == nil {
+		if c, err := net.Dial("unix", *socket); err == nil {
+			c.Close()
+			return fmt.Errorf("daemon: %s is in use by another process", *socket)
+		}
+		if err := os.Remove(*socket); err != nil {
+			return fmt.Errorf("daemon: %w", err)
+		}
+	}
+	store := &modelStore{root: root}
+	if err := store.load(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	ln, err := net.Listen("unix", *socket)
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	// Closing the listener also removes the socket file
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ln.Close()
+	}()
+
+	store.mu.RLock()
+	logger.Info("daemon: serving", "socket", *socket, "dir", root, "models", len(store.files))
+	store.mu.RUnlock()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("daemon: %w", err)
+		}
+		go store.serve(conn)
+	}
+}
@@ -0,0 +1,62 @@
+// Package main lists keys that newer GGUF revisions renamed.
+// Loaders keep reading some old spellings, but not forever; validate reports
+// them as warnings, --annotate marks them, and --warnings logs them to stderr
+// during a normal dump, each with the current spelling.
+package main
+
+import "strings"
+
+// deprecatedSpec maps an old key pattern (same syntax as keySpec) to its
+// replacement.
+type deprecatedSpec struct {
+	Pattern    string
+	ReplacedBy string
+	Note       string // extra step the rename needs, if any
+}
+
+var deprecatedKeys = []deprecatedSpec{
+	{"{arch}.rope.scale_linear", "{arch}.rope.scaling.factor", `also set {arch}.rope.scaling.type to "linear"`},
+	{"tokenizer.ggml.prefix_token_id", "tokenizer.ggml.fim_pre_token_id", ""},
+	{"tokenizer.ggml.suffix_token_id", "tokenizer.ggml.fim_suf_token_id", ""},
+	{"tokenizer.ggml.middle_token_id", "tokenizer.ggml.fim_mid_token_id", ""},
+	{"tokenizer.ggml.seperator_token_id", "tokenizer.ggml.separator_token_id", ""},
+	{"general.source.huggingface.repository", "general.source.repo_url", `store the full URL, "https://huggingface.co/ORG/NAME"`},
+	{"general.source.hugginface.repository", "general.source.repo_url", `store the full URL, "https://huggingface.co/ORG/NAME"`},
+}
+
+func init() {
+	registerCheck("deprecated", func(v *validation) {
+		arch := v.gf.Arch()
+		for _, kv := range v.gf.KVs {
+			if d, ok := lookupDeprecated(kv.Key, arch); ok {
+				v.report("warning", kv.Key, "", "%s", deprecationMessage(d, kv.Key))
+			}
+		}
+	})
+}
+
+// lookupDeprecated returns the entry for key if it is an old spelling.
+func lookupDeprecated(key, arch string) (deprecatedSpec, bool) {
+	segs := strings.Split(key, ".")
+	for _, d := range deprecatedKeys {
+		if matchKeyPattern(strings.Split(d.Pattern, "."), segs, arch) {
+			return d, true
+		}
+	}
+	return deprecatedSpec{}, false
+}
+
+// replacementKey spells d's replacement for the concrete key it matched.
+func replacementKey(d deprecatedSpec, key string) string {
+	ns, _, _ := strings.Cut(key, ".")
+	return strings.ReplaceAll(d.ReplacedBy, "{arch}", ns)
+}
+
+func deprecationMessage(d deprecatedSpec, key string) string {
+	msg := "deprecated key; use " + replacementKey(d, key)
+	if d.Note != "" {
+		ns, _, _ := strings.Cut(key, ".")
+		msg += " (" + strings.ReplaceAll(d.Note, "{arch}", ns) + ")"
+	}
+	return msg
+}
@@ -0,0 +1,190 @@
+// Package main implements the `diagram` subcommand.
+// This file renders a layer/block overview (embedding -> N blocks -> head) as
+// Mermaid or Graphviz DOT, annotated with dimensions and dtypes taken from the
+// metadata and the tensor info table.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diagramNode is one box of the diagram; the first line is its title.
+type diagramNode struct {
+	id    string
+	lines []string
+}
+
+func runDiagram(args []string) error {
+	fs := flag.NewFlagSet("diagram", flag.ExitOnError)
+	format := fs.String("format", "mermaid", "diagram syntax: 'mermaid' or 'dot'")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta diagram [--format mermaid|dot] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != "mermaid" && *format != "dot" {
+		return fmt.Errorf("diagram: unknown format %q (want 'mermaid' or 'dot')", *format)
+	}
+
+	gf, err := loadFile(context.Background(), fs.Arg(0), basePolicy())
+	if err != nil {
+		return err
+	}
+	nodes := diagramNodes(gf)
+	if *format == "dot" {
+		return writeDOT(os.Stdout, nodes)
+	}
+	return writeMermaid(os.Stdout, nodes)
+}
+
+// diagramNodes lays the model out as a linear pipeline of boxes.
+func diagramNodes(gf *ggufFile) []diagramNode {
+	arch := gf.Arch()
+	if arch == "" {
+		arch = "unknown"
+	}
+	tensors := make(map[string]tensorInfo, len(gf.Tensors))
+	blockIdx := make(map[int]bool)
+	var block0, extra []tensorInfo
+	for _, t := range gf.Tensors {
+		tensors[t.Name] = t
+		if rest, ok := strings.CutPrefix(t.Name, "blk."); ok {
+			idx, name, _ := strings.Cut(rest, ".")
+			if n, err := strconv.Atoi(idx); err == nil {
+				blockIdx[n] = true
+				if n == 0 {
+					block0 = append(block0, tensorInfo{Name: name, Dims: t.Dims, Type: t.Type})
+				}
+				continue
+			}
+		}
+		switch t.Name {
+		case "token_embd.weight", "output_norm.weight", "output_norm.bias", "output.weight":
+		default:
+			extra = append(extra, t)
+		}
+	}
+
+	input := diagramNode{id: "input", lines: []string{"Input tokens"}}
+	if kv, ok := gf.Get("tokenizer.ggml.tokens"); ok {
+		if n, ok := arrayLen(kv.Value); ok {
+			input.lines = append(input.lines, fmt.Sprintf("vocab %d", n))
+		}
+	}
+	if n, ok := gf.Uint("{arch}.context_length"); ok {
+		input.lines = append(input.lines, fmt.Sprintf("context %d", n))
+	}
+	nodes := []diagramNode{input}
+
+	embd := diagramNode{id: "embd", lines: []string{"Token embedding"}}
+	if t, ok := tensors["token_embd.weight"]; ok {
+		embd.lines = append(embd.lines, tensorLine(t))
+	}
+	if n, ok := gf.Uint("{arch}.embedding_length"); ok {
+		embd.lines = append(embd.lines, fmt.Sprintf("n_embd %d", n))
+	}
+	nodes = append(nodes, embd)
+
+	nBlocks, ok := gf.Uint("{arch}.block_count")
+	if !ok {
+		nBlocks = uint64(len(blockIdx))
+	}
+	blocks := diagramNode{id: "blocks", lines: []string{fmt.Sprintf("%d x %s block", nBlocks, arch)}}
+	if h, ok := gf.Uint("{arch}.attention.head_count"); ok {
+		line := fmt.Sprintf("heads %d", h)
+		if kvh, ok := gf.Uint("{arch}.attention.head_count_kv"); ok && kvh != h {
+			line += fmt.Sprintf(", kv heads %d", kvh)
+		}
+		blocks.lines = append(blocks.lines, line)
+	}
+	if n, ok := gf.Uint("{arch}.feed_forward_length"); ok {
+		blocks.lines = append(blocks.lines, fmt.Sprintf("n_ff %d", n))
+	}
+	sort.Slice(block0, func(i, j int) bool { return block0[i].Name < block0[j].Name })
+	for _, t := range block0 {
+		blocks.lines = append(blocks.lines, tensorLine(t))
+	}
+	nodes = append(nodes, blocks)
+
+	if len(extra) > 0 {
+		other := diagramNode{id: "other", lines: []string{"Other tensors"}}
+		for _, t := range extra {
+			other.lines = append(other.lines, tensorLine(t))
+		}
+		nodes = append(nodes, other)
+	}
+
+	norm := diagramNode{id: "norm", lines: []string{"Output norm"}}
+	for _, name := range []string{"output_norm.weight", "output_norm.bias"} {
+		if t, ok := tensors[name]; ok {
+			norm.lines = append(norm.lines, tensorLine(t))
+		}
+	}
+	if len(norm.lines) > 1 {
+		nodes = append(nodes, norm)
+	}
+
+	head := diagramNode{id: "head", lines: []string{"Output head"}}
+	if t, ok := tensors["output.weight"]; ok {
+		head.lines = append(head.lines, tensorLine(t))
+	} else {
+		head.lines = append(head.lines, "tied to token_embd")
+	}
+	nodes = append(nodes, head, diagramNode{id: "logits", lines: []string{"Logits"}})
+	return nodes
+}
+
+// tensorLine formats "name [d0 x d1] DTYPE".
+func tensorLine(t tensorInfo) string {
+	dims := make([]string, len(t.Dims))
+	for i, d := range t.Dims {
+		dims[i] = strconv.FormatUint(d, 10)
+	}
+	return fmt.Sprintf("%s [%s] %s", t.Name, strings.Join(dims, " x "), ggmlTypeName(t.Type))
+}
+
+func writeMermaid(w io.Writer, nodes []diagramNode) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for _, n := range nodes {
+		lines := make([]string, len(n.lines))
+		for i, l := range n.lines {
+			lines[i] = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(l)
+		}
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", n.id, strings.Join(lines, "<br/>"))
+	}
+	for i := 1; i < len(nodes); i++ {
+		fmt.Fprintf(&b, "    %s --> %s\n", nodes[i-1].id, nodes[i].id)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDOT(w io.Writer, nodes []diagramNode) error {
+	var b strings.Builder
+	b.WriteString("digraph model {\n    rankdir=TB;\n    node [shape=box, fontname=\"monospace\"];\n")
+	for _, n := range nodes {
+		lines := make([]string, len(n.lines))
+		for i, l := range n.lines {
+			lines[i] = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(l)
+		}
+		fmt.Fprintf(&b, "    %s [label=\"%s\"];\n", n.id, strings.Join(lines, `\n`))
+	}
+	for i := 1; i < len(nodes); i++ {
+		fmt.Fprintf(&b, "    %s -> %s;\n", nodes[i-1].id, nodes[i].id)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
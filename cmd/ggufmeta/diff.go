@@ -0,0 +1,258 @@
+// Package main implements the `diff` subcommand.
+// It compares the metadata and tensor info tables of two files key by key
+// (tensors by name, ignoring offsets) and prints the changes as NDJSON, a
+// unified-diff-like text view or a JSON Patch; like diff(1) it exits 1 when
+// the files differ.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+)
+
+// metaChange is one difference between two files. Exactly one of Key and
+// Tensor is set; Old/New are absent for additions/removals respectively.
+type metaChange struct {
+	Op      string `json:"op"` // "add", "remove" or "change"
+	Key     string `json:"key,omitempty"`
+	Tensor  string `json:"tensor,omitempty"`
+	OldType string `json:"oldType,omitempty"`
+	NewType string `json:"newType,omitempty"`
+	Old     any    `json:"old,omitempty"`
+	New     any    `json:"new,omitempty"`
+}
+
+// tensorDesc is how a tensor appears in a metaChange: shape and ggml type.
+type tensorDesc struct {
+	Dims []uint64 `json:"dims"`
+	Type string   `json:"type"`
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "ndjson", "output: 'ndjson' (one change per line), 'text' or 'json-patch' (RFC 6902)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta diff [--format ndjson|text|json-patch] a.gguf b.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != "ndjson" && *format != "text" && *format != "json-patch" {
+		return fmt.Errorf("diff: unknown format %q (want 'ndjson', 'text' or 'json-patch')", *format)
+	}
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	a, err := loadFile(context.Background(), fs.Arg(0), pol)
+	if err != nil {
+		return err
+	}
+	b, err := loadFile(context.Background(), fs.Arg(1), pol)
+	if err != nil {
+		return err
+	}
+	changes := diffFiles(a, b)
+	switch *format {
+	case "text":
+		err = writeTextDiff(os.Stdout, a.Path, b.Path, changes)
+	case "json-patch":
+		err = writeJSONPatch(os.Stdout, changes)
+	default:
+		enc := json.NewEncoder(os.Stdout)
+		for _, c := range changes {
+			if err = enc.Encode(c); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// diffFiles lists the changes from a to b: keys in a's order followed by keys
+// only b has, then tensors the same way. For duplicate keys the last one counts.
+func diffFiles(a, b *ggufFile) []metaChange {
+	var changes []metaChange
+	for _, key := range unionOrder(fileKeys(a), fileKeys(b)) {
+		old, inA := a.Get(key)
+		cur, inB := b.Get(key)
+		switch {
+		case !inB:
+			changes = append(changes, metaChange{Op: "remove", Key: key, OldType: old.Type, Old: old.Value})
+		case !inA:
+			changes = append(changes, metaChange{Op: "add", Key: key, NewType: cur.Type, New: cur.Value})
+		case old.Type != cur.Type || !reflect.DeepEqual(old.Value, cur.Value):
+			changes = append(changes, metaChange{Op: "change", Key: key, OldType: old.Type, NewType: cur.Type, Old: old.Value, New: cur.Value})
+		}
+	}
+
+	ta, tb := tensorDescs(a), tensorDescs(b)
+	for _, name := range unionOrder(tensorNames(a), tensorNames(b)) {
+		old, inA := ta[name]
+		cur, inB := tb[name]
+		switch {
+		case !inB:
+			changes = append(changes, metaChange{Op: "remove", Tensor: name, Old: old})
+		case !inA:
+			changes = append(changes, metaChange{Op: "add", Tensor: name, New: cur})
+		case !reflect.DeepEqual(old, cur):
+			changes = append(changes, metaChange{Op: "change", Tensor: name, Old: old, New: cur})
+		}
+	}
+	return changes
+}
+
+func fileKeys(gf *ggufFile) []string {
+	keys := make([]string, 0, len(gf.KVs))
+	for _, kv := range gf.KVs {
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func tensorNames(gf *ggufFile) []string {
+	names := make([]string, 0, len(gf.Tensors))
+	for _, t := range gf.Tensors {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func tensorDescs(gf *ggufFile) map[string]tensorDesc {
+	m := make(map[string]tensorDesc, len(gf.Tensors))
+	for _, t := range gf.Tensors {
+		m[t.Name] = tensorDesc{Dims: t.Dims, Type: ggmlTypeName(t.Type)}
+	}
+	return m
+}
+
+// unionOrder returns the distinct entries of a in order, then those only in b.
+func unionOrder(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, s := range slices.Concat(a, b) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// writeTextDiff renders changes like a unified diff: "-" lines show the old
+// side, "+" lines the new one. Long values are cut to maxTextValue bytes, and
+// array changes are summarized by length and number of differing elements.
+func writeTextDiff(w io.Writer, aPath, bPath string, changes []metaChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	bw := &errWriter{w: w}
+	bw.printf("--- %s\n+++ %s\n", aPath, bPath)
+	section := ""
+	for _, c := range changes {
+		name, sec := c.Key, "metadata"
+		if c.Tensor != "" {
+			name, sec = c.Tensor, "tensors"
+		}
+		if sec != section {
+			bw.printf("@@ %s @@\n", sec)
+			section = sec
+		}
+		if c.Op != "add" {
+			bw.printf("-%s: %s\n", name, textValue(c.OldType, c.Old))
+		}
+		if c.Op != "remove" {
+			line := textValue(c.NewType, c.New)
+			if c.Op == "change" {
+				line += arrayDelta(c.Old, c.New)
+			}
+			bw.printf("+%s: %s\n", name, line)
+		}
+	}
+	return bw.err
+}
+
+// maxTextValue bounds how much of a value the text diff prints.
+const maxTextValue = 120
+
+func textValue(typ string, v any) string {
+	if td, ok := v.(tensorDesc); ok {
+		return fmt.Sprintf("%s %v", td.Type, td.Dims)
+	}
+	if items, ok := v.([]any); ok {
+		return fmt.Sprintf("%s len %d", typ, len(items))
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		raw = []byte(fmt.Sprint(v))
+	}
+	s := string(raw)
+	if len(s) > maxTextValue {
+		s = s[:maxTextValue] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", s, typ)
+}
+
+// arrayDelta describes how two expanded arrays differ, e.g. " (+5, 12 changed)".
+func arrayDelta(old, cur any) string {
+	a, okA := old.([]any)
+	b, okB := cur.([]any)
+	if !okA || !okB {
+		return ""
+	}
+	changed := 0
+	for i := range min(len(a), len(b)) {
+		if !reflect.DeepEqual(a[i], b[i]) {
+			changed++
+		}
+	}
+	return fmt.Sprintf(" (%+d, %d changed)", len(b)-len(a), changed)
+}
+
+// writeJSONPatch prints changes as one RFC 6902 document, one operation per line.
+func writeJSONPatch(w io.Writer, changes []metaChange) error {
+	ops, err := jsonPatch(changes)
+	if err != nil {
+		return err
+	}
+	ew := &errWriter{w: w}
+	ew.printf("[")
+	for i, op := range ops {
+		raw, err := json.Marshal(op)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if i == len(ops)-1 {
+			sep = ""
+		}
+		ew.printf("\n  %s%s", raw, sep)
+	}
+	ew.printf("\n]\n")
+	return ew.err
+}
+
+// errWriter remembers the first write error so formatting code can ignore it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
@@ -0,0 +1,102 @@
+// Package main implements metadata rewriting for the editing subcommands.
+// rewriteMetadata hands a file's KV pairs to an edit function and writes the
+// result; pairs the edit keeps are copied byte for byte, as are the tensor info
+// table and the tensor data, which only moves to the new aligned data offset.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// rewriteMetadata rewrites in to out with the KV pairs returned by edit. New or
+// changed pairs must carry their encoding in rawKV.enc (see encodeKV). out may
+// equal in: the result is written to a temporary file and renamed over it.
+// With stamp, the edit is recorded in the provenance arrays (see stampProvenance).
+func rewriteMetadata(in, out string, stamp bool, edit func(kvs []rawKV) ([]rawKV, error)) error {
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !st.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", in)
+	}
+	size := uint64(st.Size())
+
+	pol := basePolicy()
+	pol.noAutoExpand = true                    // values are copied as raw bytes, never looked at
+	pol.lenient = false                        // a resynced value has no trustworthy byte range to copy
+	pol.expandPrefixes = []string{stampPrefix} // except the provenance history --stamp extends
+	p, hdr, err := newParserAt(f, size, pol)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if p.endianHint != "LE" {
+		return fmt.Errorf("%s is big-endian; only little-endian files can be edited", in)
+	}
+	p.ctx = context.Background()
+	var start, end uint64
+	p.trace = func(ev traceEvent) {
+		switch ev.Field {
+		case "key":
+			start = ev.Start
+		case "value":
+			end = ev.End
+		}
+	}
+	var kvs []rawKV
+	align := uint64(32)
+	for {
+		ev, ok, err := p.nextKV()
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		if !ok {
+			break
+		}
+		tag, ok := typeTag(ev.Type)
+		if !ok {
+			tag = tArray // "array[...]" labels are not registered names
+		}
+		kvs = append(kvs, rawKV{key: ev.Key, tag: tag, val: ev.Value, start: start, end: end})
+		if a, ok := asUint64(ev.Value); ok && ev.Key == "general.alignment" && a > 0 {
+			align = a
+		}
+	}
+	if p.paddedValues > 0 {
+		return fmt.Errorf("%s pads values to 8 bytes; run `ggufmeta normalize` on it before editing", in)
+	}
+	infoStart := p.scn.pos
+	for {
+		_, ok, err := p.nextTensor()
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		if !ok {
+			break
+		}
+	}
+	infoEnd := p.scn.pos
+
+	if kvs, err = edit(kvs); err != nil {
+		return err
+	}
+	if stamp {
+		if kvs, err = stampProvenance(in, kvs); err != nil {
+			return err
+		}
+	}
+	for _, kv := range kvs {
+		if kv.key == "general.alignment" && kv.enc != nil {
+			return fmt.Errorf("key %q: changing the alignment would move tensors; use normalize", kv.key)
+		}
+	}
+	r := &repairer{ra: f, size: size, tc: hdr.GGUF.TensorCount}
+	return r.write(out, kvs, infoStart, infoEnd, alignUp(infoEnd, align), align)
+}
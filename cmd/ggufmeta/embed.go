@@ -0,0 +1,61 @@
+// Package main implements the options shared by the embedding APIs.
+// The js/wasm bindings and the C shared library take parse options as a
+// JSON object rather than flags; this file turns that object into a policy
+// the same way the dump's flags do.
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// embedOptions mirrors the dump's flags that make sense for an embedder.
+type embedOptions struct {
+	Size         uint64            `json:"size"` // total file size, 0 if unknown (js only; files know their size)
+	MaxArray     *uint64           `json:"maxArray"`
+	MaxString    *uint64           `json:"maxString"`
+	ExpandArrays []string          `json:"expandArrays"` // keys, or prefixes ending in *
+	Lenient      bool              `json:"lenient"`
+	Truncate     bool              `json:"truncateStrings"`
+	NoAutoExpand bool              `json:"noAutoExpand"`
+	ExpandTypes  map[string]uint64 `json:"expandTypes"` // element type or class -> auto-expansion limit
+}
+
+// policy starts from basePolicy and applies the options set in o.
+func (o embedOptions) policy() policy {
+	return o.apply(basePolicy())
+}
+
+// apply overrides pol with the options set in o.
+func (o embedOptions) apply(pol policy) policy {
+	if o.MaxArray != nil {
+		pol.maxArray = *o.MaxArray
+	}
+	if o.MaxString != nil {
+		pol.maxString = *o.MaxString
+	}
+	pol.lenient = pol.lenient || o.Lenient
+	pol.truncStrings = pol.truncStrings || o.Truncate
+	pol.noAutoExpand = pol.noAutoExpand || o.NoAutoExpand
+	if len(o.ExpandTypes) > 0 {
+		pol.expandTypes = maps.Clone(pol.expandTypes)
+		if pol.expandTypes == nil {
+			pol.expandTypes = make(map[string]uint64)
+		}
+		maps.Copy(pol.expandTypes, o.ExpandTypes)
+	}
+	pol.expandArrays = maps.Clone(pol.expandArrays)
+	if pol.expandArrays == nil {
+		pol.expandArrays = make(map[string]bool)
+	}
+	pol.expandPrefixes = slices.Clone(pol.expandPrefixes)
+	for _, k := range o.ExpandArrays {
+		if prefix, ok := strings.CutSuffix(k, "*"); ok {
+			pol.expandPrefixes = append(pol.expandPrefixes, prefix)
+		} else {
+			pol.expandArrays[k] = true
+		}
+	}
+	return pol
+}
@@ -0,0 +1,67 @@
+// Package main implements --emit-index, which saves where every KV pair lives.
+// The index is built from the same trace hook as --trace while the dump runs,
+// so it costs no second pass; with it, a later reader can seek straight to one
+// key, or overwrite a value of the same encoded length in place.
+package main
+
+import "encoding/json"
+
+// keyIndexEntry locates one KV pair: the encoded pair (key length prefix,
+// key, type tag and value) occupies [Offset, Offset+Length).
+type keyIndexEntry struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Offset uint64 `json:"offset"` // absolute offset of the key's length prefix
+	Length uint64 `json:"length"`
+}
+
+// keyIndex is the document --emit-index writes.
+type keyIndex struct {
+	File        string          `json:"file"`
+	Size        uint64          `json:"size"`
+	Version     uint32          `json:"version"`     // GGUF version
+	MetadataEnd uint64          `json:"metadataEnd"` // end of the last KV pair, where the tensor infos start
+	Keys        []keyIndexEntry `json:"keys"`        // file order, duplicates included
+}
+
+// keyIndexer collects index entries from trace events.
+type keyIndexer struct {
+	ix    keyIndex
+	start uint64
+}
+
+// observe consumes one trace event; chain it into parser.trace.
+func (k *keyIndexer) observe(ev traceEvent) {
+	switch ev.Field {
+	case "key":
+		k.start = ev.Start
+	case "value":
+		k.ix.Keys = append(k.ix.Keys, keyIndexEntry{Key: ev.Key, Offset: k.start, Length: ev.End - k.start})
+		k.ix.MetadataEnd = ev.End
+	}
+}
+
+// setType fills in the type of the entry just recorded for key.
+func (k *keyIndexer) setType(key, typ string) {
+	if n := len(k.ix.Keys); n > 0 && k.ix.Keys[n-1].Key == key {
+		k.ix.Keys[n-1].Type = typ
+	}
+}
+
+// write saves the index to path atomically.
+func (k *keyIndexer) write(path string) error {
+	if k.ix.Keys == nil {
+		k.ix.Keys = []keyIndexEntry{}
+	}
+	af, err := createAtomicFile(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(af)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(k.ix); err != nil {
+		af.Abort()
+		return err
+	}
+	return af.Commit()
+}
@@ -0,0 +1,89 @@
+// Package main defines the output encoder interface and the --format registry.
+// Every output format implements recordEncoder and registers itself from its own
+// file with registerFormat, so adding a format never requires touching main.go.
+// The registry is internal to the command: package main cannot be imported, so
+// a new format is added as a file here, not registered from outside.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// recordEncoder writes output records. Encode is called with a headerEvent first,
+// then kvEvent (and any other record types) in stream order; Flush is called
+// exactly once after the last record so buffering encoders can finish.
+type recordEncoder interface {
+	Encode(v any) error
+	Flush() error
+}
+
+// outputFormat describes one --format value.
+type outputFormat struct {
+	Description string // one-line summary for usage output
+	JSON        bool   // emits JSON text, so --canonical applies
+	Binary      bool   // emits bytes rather than text, so --escape cannot apply
+	// ExpandArrays asks for every array's contents when --expand-arrays is not given.
+	ExpandArrays bool
+	// New creates an encoder writing to w. canonical is only ever true for JSON formats.
+	New func(w io.Writer, canonical bool) recordEncoder
+}
+
+// outputFormats holds every registered format, keyed by its --format name.
+var outputFormats = make(map[string]outputFormat)
+
+// registerFormat adds a format; call it from an init function.
+func registerFormat(name string, f outputFormat) {
+	if _, dup := outputFormats[name]; dup {
+		panic(fmt.Sprintf("output format %q registered twice", name))
+	}
+	outputFormats[name] = f
+}
+
+// formatNames lists the registered formats in sorted order.
+func formatNames() []string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	registerFormat("ndjson", outputFormat{
+		Description: "one JSON record per line (default)",
+		JSON:        true,
+		New:         newJSONRecordEncoder,
+	})
+}
+
+// flushingEncoder pushes w out after every record, for --flush.
+type flushingEncoder struct {
+	recordEncoder
+	w *bufio.Writer
+}
+
+func (f flushingEncoder) Encode(v any) error {
+	if err := f.recordEncoder.Encode(v); err != nil {
+		return err
+	}
+	return f.w.Flush()
+}
+
+// jsonLineEncoder adapts json.Encoder, which writes through immediately.
+type jsonLineEncoder struct{ *json.Encoder }
+
+func (jsonLineEncoder) Flush() error { return nil }
+
+// newJSONRecordEncoder writes one JSON document per line, canonicalized on
+// request. Strings that are not UTF-8 are written as base64 (see jsonSafeValue).
+func newJSONRecordEncoder(w io.Writer, canonical bool) recordEncoder {
+	if canonical {
+		return jsonSafeEncoder{newCanonicalEncoder(w)}
+	}
+	return jsonSafeEncoder{jsonLineEncoder{json.NewEncoder(w)}}
+}
@@ -0,0 +1,92 @@
+// Package main implements the --format env output.
+// Each KV pair becomes one NAME=value line that `source` (or `set -a; .`)
+// accepts, for shell scripts and container entrypoints:
+// general.name -> GENERAL_NAME="TinyTest 1B". Strings and arrays are
+// double-quoted with the characters the shell expands escaped; numbers and
+// booleans are written bare. Combine with --keys to export only what is used.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	registerFormat("env", outputFormat{
+		Description: "NAME=\"value\" lines for sourcing from a shell",
+		New: func(w io.Writer, _ bool) recordEncoder {
+			return &envEncoder{w: bufio.NewWriter(w), seen: make(map[string]string)}
+		},
+	})
+}
+
+type envEncoder struct {
+	w    *bufio.Writer
+	seen map[string]string // variable name -> the key that produced it
+}
+
+func (e *envEncoder) Encode(v any) error {
+	kv, ok := v.(kvEvent)
+	if !ok {
+		return nil
+	}
+	name := envName(kv.Key)
+	if prev, dup := e.seen[name]; dup && prev != kv.Key {
+		logger.Warn("env: keys map to the same variable; the later one wins", "variable", name, "keys", []string{prev, kv.Key})
+	}
+	e.seen[name] = kv.Key
+
+	var val string
+	switch x := kv.Value.(type) {
+	case string:
+		val = envQuote(x)
+	case []any, map[string]any:
+		js, err := json.Marshal(x)
+		if err != nil {
+			return err
+		}
+		val = envQuote(string(js))
+	default:
+		val = fmt.Sprint(x)
+	}
+	_, err := fmt.Fprintf(e.w, "%s=%s\n", name, val)
+	return err
+}
+
+func (e *envEncoder) Flush() error { return e.w.Flush() }
+
+// envName upper-cases key and replaces everything but letters, digits and
+// underscores with an underscore; a leading digit gets an underscore prefix.
+func envName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// envQuote double-quotes s for POSIX shells: backslash, double quote, dollar
+// and backquote are escaped; newlines stay literal inside the quotes.
+func envQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if strings.ContainsRune("\\\"$`", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
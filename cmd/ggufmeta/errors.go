@@ -0,0 +1,40 @@
+// Package main defines the parse errors the command branches on.
+// Low-level failures wrap one of the sentinel values below, and failures inside
+// a KV pair or tensor info are wrapped once more in a parseError that records
+// where parsing stopped, so errors.Is and errors.As work on any returned error.
+// They are internal: package main cannot be imported, so callers outside the
+// command see only the messages.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errBadMagic           = errors.New("bad magic")
+	errUnsupportedVersion = errors.New("unsupported GGUF version")
+	errStringTooLarge     = errors.New("string too large")
+	errTruncated          = errors.New("truncated input") // wraps io.EOF or io.ErrUnexpectedEOF
+	errUnknownType        = errors.New("unknown value type")
+)
+
+// parseError locates a failure inside the KV section or the tensor info table.
+type parseError struct {
+	Offset uint64 // absolute offset of the read that failed
+	Key    string // KV key being read, if known
+	Tensor string // tensor name being read, if known
+	Err    error
+}
+
+func (e *parseError) Error() string {
+	switch {
+	case e.Key != "":
+		return fmt.Sprintf("key %q at offset %d: %v", e.Key, e.Offset, e.Err)
+	case e.Tensor != "":
+		return fmt.Sprintf("tensor %q at offset %d: %v", e.Tensor, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *parseError) Unwrap() error { return e.Err }
@@ -0,0 +1,128 @@
+// Package main implements --escape, which keeps invisible and
+// terminal-controlling characters in chat templates and token pieces from
+// reaching the terminal raw. The output text is rewritten on its way out:
+// each offending character becomes a \uXXXX escape (a surrogate pair above
+// U+FFFF), and bytes that are not UTF-8 become \xNN. Inside JSON strings a
+// \uXXXX escape decodes to the same character, so JSON output keeps its
+// meaning; in raw values and the env format the escapes are literal text.
+package main
+
+import (
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Escape modes for --escape.
+const (
+	escapeControls = "controls" // control, format (bidi, zero-width) and separator characters
+	escapeASCII    = "ascii"    // additionally everything outside printable ASCII
+)
+
+// escapeWriter applies an escape mode to the text written through it.
+type escapeWriter struct {
+	w       io.Writer
+	ascii   bool
+	pending []byte // incomplete UTF-8 sequence at the end of the last write
+	buf     []byte
+}
+
+func newEscapeWriter(w io.Writer, mode string) (*escapeWriter, error) {
+	switch mode {
+	case escapeControls, escapeASCII:
+		return &escapeWriter{w: w, ascii: mode == escapeASCII}, nil
+	}
+	return nil, fmt.Errorf("--escape: unknown mode %q (want %q or %q)", mode, escapeControls, escapeASCII)
+}
+
+// needsEscape reports whether r must not be written raw. Newlines and tabs
+// pass, since they separate records and lay out multi-line values.
+func (e *escapeWriter) needsEscape(r rune) bool {
+	switch {
+	case r == '\n' || r == '\t':
+		return false
+	case e.ascii && r >= 0x7f:
+		return true
+	}
+	return unicode.IsControl(r) || unicode.In(r, unicode.Cf, unicode.Zl, unicode.Zp, unicode.Co)
+}
+
+func (e *escapeWriter) Write(p []byte) (int, error) {
+	in := p
+	if len(e.pending) > 0 {
+		in = append(e.pending, p...)
+		e.pending = nil
+	}
+	out := e.buf[:0]
+	for i := 0; i < len(in); {
+		c := in[i]
+		if c < utf8.RuneSelf {
+			if e.needsEscape(rune(c)) {
+				out = appendUnicodeEscape(out, rune(c))
+			} else {
+				out = append(out, c)
+			}
+			i++
+			continue
+		}
+		if !utf8.FullRune(in[i:]) {
+			// The rest of the sequence may come with the next write
+			e.pending = append([]byte(nil), in[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(in[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			out = fmt.Appendf(out, `\x%02x`, c)
+		case e.needsEscape(r):
+			out = appendUnicodeEscape(out, r)
+		default:
+			out = append(out, in[i:i+size]...)
+		}
+		i += size
+	}
+	e.buf = out
+	if _, err := e.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out a sequence left incomplete by the last write.
+func (e *escapeWriter) Flush() error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	var out []byte
+	for _, c := range e.pending {
+		out = fmt.Appendf(out, `\x%02x`, c)
+	}
+	e.pending = nil
+	_, err := e.w.Write(out)
+	return err
+}
+
+// appendUnicodeEscape appends r as a JSON-style \uXXXX escape.
+func appendUnicodeEscape(b []byte, r rune) []byte {
+	if r > 0xffff {
+		hi, lo := utf16.EncodeRune(r)
+		return fmt.Appendf(b, `\u%04x\u%04x`, hi, lo)
+	}
+	return fmt.Appendf(b, `\u%04x`, r)
+}
+
+// escapingEncoder flushes the escapeWriter beneath an encoder after the
+// encoder's own Flush.
+type escapingEncoder struct {
+	recordEncoder
+	ew *escapeWriter
+}
+
+func (e escapingEncoder) Flush() error {
+	if err := e.recordEncoder.Flush(); err != nil {
+		return err
+	}
+	return e.ew.Flush()
+}
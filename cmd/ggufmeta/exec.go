@@ -0,0 +1,71 @@
+// Package main implements --exec, a per-record hook in the spirit of find -exec.
+// Each record is encoded as one JSON line and piped to a fresh shell command,
+// so ad-hoc integrations need nothing more than a shell one-liner.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// execEncoder runs cmdline once per record with the record on stdin.
+// Failures are reported and counted but do not stop the stream, like find -exec.
+type execEncoder struct {
+	cmdline   string
+	file      string
+	canonical bool
+	failed    int
+}
+
+func newExecEncoder(cmdline, file string, canonical bool) *execEncoder {
+	return &execEncoder{cmdline: cmdline, file: file, canonical: canonical}
+}
+
+// Encode runs the command for one record. The record is also described through
+// GGUFMETA_FILE, GGUFMETA_KIND, GGUFMETA_KEY and GGUFMETA_TYPE so simple hooks
+// can avoid parsing JSON.
+func (e *execEncoder) Encode(v any) error {
+	var buf bytes.Buffer
+	if err := newJSONRecordEncoder(&buf, e.canonical).Encode(v); err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), "GGUFMETA_FILE="+e.file)
+	switch ev := v.(type) {
+	case headerEvent:
+		env = append(env, "GGUFMETA_KIND="+ev.Kind)
+	case kvEvent:
+		env = append(env, "GGUFMETA_KIND=kv", "GGUFMETA_KEY="+ev.Key, "GGUFMETA_TYPE="+ev.Type)
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", e.cmdline)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", e.cmdline)
+	}
+	cmd.Env = env
+	cmd.Stdin = &buf
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// A missing shell is fatal; a failing hook is only counted
+		if _, ok := err.(*exec.ExitError); !ok {
+			return fmt.Errorf("exec: %w", err)
+		}
+		e.failed++
+		fmt.Fprintf(os.Stderr, "exec: %q: %v\n", e.cmdline, err)
+	}
+	return nil
+}
+
+// Flush reports whether any invocation failed.
+func (e *execEncoder) Flush() error {
+	if e.failed > 0 {
+		return fmt.Errorf("exec: command failed for %d record(s)", e.failed)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package main implements --expand-range, which expands one slice of an
+// array instead of all of it, so tokens 100000-100100 of a 150k vocabulary
+// can be read without emitting the rest:
+//
+//	ggufmeta --expand-range tokenizer.ggml.tokens=100000:100100 model.gguf
+//
+// The record's value says which slice it holds:
+//
+//	{"_placeholder":"array_range","count":N,"element_type":T,"start":S,"end":E,"elements":[...]}
+//
+// Elements before the slice are not decoded: fixed-size ones are skipped in
+// a single seek, strings by their length prefixes.
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// arrayRange is the half-open element range [start, end) of --expand-range.
+type arrayRange struct {
+	start, end uint64
+}
+
+// expandRangeFlag collects repeated --expand-range KEY=START:END flags.
+type expandRangeFlag map[string]arrayRange
+
+func (f expandRangeFlag) String() string {
+	var parts []string
+	for key, r := range f {
+		parts = append(parts, fmt.Sprintf("%s=%d:%d", key, r.start, r.end))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses KEY=START:END. START defaults to 0 and END to the end of the
+// array, so KEY=:10 is the first ten elements and KEY=150000: the rest.
+func (f expandRangeFlag) Set(s string) error {
+	key, span, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("want KEY=START:END, got %q", s)
+	}
+	from, to, ok := strings.Cut(span, ":")
+	if !ok {
+		return fmt.Errorf("want KEY=START:END, got %q", s)
+	}
+	r := arrayRange{end: math.MaxUint64}
+	var err error
+	if from != "" {
+		if r.start, err = strconv.ParseUint(from, 10, 64); err != nil {
+			return fmt.Errorf("bad start in %q: %w", s, err)
+		}
+	}
+	if to != "" {
+		if r.end, err = strconv.ParseUint(to, 10, 64); err != nil {
+			return fmt.Errorf("bad end in %q: %w", s, err)
+		}
+	}
+	if r.start > r.end {
+		return fmt.Errorf("start after end in %q", s)
+	}
+	if _, dup := f[key]; dup {
+		return fmt.Errorf("%s: range given twice", key)
+	}
+	f[key] = r
+	return nil
+}
+
+// readArrayRange reads the elements of r from an array of count elements of
+// type et at the scanner's position, skipping the rest. r is clamped to the
+// array, so a range past its end yields no elements.
+func (p *parser) readArrayRange(et uint32, count uint64, elemName string, r arrayRange) (any, string, error) {
+	start, end := min(r.start, count), min(r.end, count)
+	if err := p.skipElements(et, start); err != nil {
+		return nil, "", err
+	}
+	p.hintArray(et, end-start)
+	elems, label, err := p.readExpandedArray(et, end-start, elemName)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.skipElements(et, count-end); err != nil {
+		return nil, "", err
+	}
+	return map[string]any{
+		"_placeholder": "array_range",
+		"count":        count,
+		"element_type": elemName,
+		"start":        start,
+		"end":          end,
+		"elements":     elems,
+	}, label, nil
+}
+
+// skipElements skips n elements of type et without decoding them: one seek
+// for fixed-size types, one per element for strings.
+func (p *parser) skipElements(et uint32, n uint64) error {
+	if size := elemSize(et); size > 0 {
+		if n > math.MaxUint64/size {
+			return fmt.Errorf("%w: array of %d elements", errTruncated, n)
+		}
+		return p.scn.skip(n * size)
+	}
+	if et != tString {
+		return p.bulkSkipArrayElements(et, n, nil)
+	}
+	for i := uint64(0); i < n; i++ {
+		if i%ctxCheckEvery == 0 {
+			if err := p.ctx.Err(); err != nil {
+				return err
+			}
+		}
+		length, err := p.scn.U64()
+		if err != nil {
+			return err
+		}
+		if err := p.scn.skip(length); err != nil {
+			return err
+		}
+	}
+	return nil
+}
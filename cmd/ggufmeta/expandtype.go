@@ -0,0 +1,63 @@
+// Package main implements --expand-type, which sets the auto-expansion limit
+// per element type instead of one --max-array for all: a 64-entry string
+// array is worth reading, a 64-entry float array rarely is.
+//
+//	ggufmeta --expand-type string:64,float:0 model.gguf
+//
+// A rule names a type (uint32, float32, string, ...) or a class (int, float)
+// and the most elements an array of that type may have to be expanded; 0
+// keeps such arrays as placeholders. An exact type wins over its class, and
+// types without a rule fall back to --max-array. Rules apply with
+// --no-auto-expand too, which then only drops the --max-array fallback.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// expandTypeFlag collects --expand-type TYPE:N rules, comma-separated or
+// repeated.
+type expandTypeFlag map[string]uint64
+
+func (f expandTypeFlag) String() string {
+	var parts []string
+	for typ, n := range f {
+		parts = append(parts, fmt.Sprintf("%s:%d", typ, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (f expandTypeFlag) Set(s string) error {
+	for _, rule := range strings.Split(s, ",") {
+		typ, count, ok := strings.Cut(strings.TrimSpace(rule), ":")
+		if !ok {
+			return fmt.Errorf("want TYPE:N, got %q", rule)
+		}
+		if _, known := typeTag(typ); !known && typ != "int" && typ != "float" {
+			return fmt.Errorf("unknown type %q (want a GGUF type name such as uint32 or string, or the class int or float)", typ)
+		}
+		n, err := strconv.ParseUint(count, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad count in %q: %w", rule, err)
+		}
+		f[typ] = n
+	}
+	return nil
+}
+
+// autoExpandLimit returns the most elements an array of type elemName may
+// have to be expanded without being asked for, and false when such arrays
+// are not auto-expanded at all.
+func (pol policy) autoExpandLimit(elemName string) (uint64, bool) {
+	if n, ok := pol.expandTypes[elemName]; ok {
+		return n, true
+	}
+	if n, ok := pol.expandTypes[typeClass(elemName)]; ok {
+		return n, true
+	}
+	return pol.maxArray, !pol.noAutoExpand
+}
@@ -0,0 +1,297 @@
+// Package main implements the `explain` subcommand, a reference for single
+// keys: `ggufmeta explain llama.rope.freq_base` prints the registry entry
+// (owner, expected type, description), typical values, and which runtimes
+// read the key and what for. Required keys and the consequences of missing
+// ones come from the compat rule sets, so the two never disagree.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// keyNote is what explain knows about a registry pattern beyond keySpec.
+type keyNote struct {
+	Typical   string
+	Consumers []keyConsumer
+}
+
+// keyConsumer is one runtime reading a key.
+type keyConsumer struct {
+	Runtime string `json:"runtime"`
+	Use     string `json:"use"`
+}
+
+// keyExplanation is the output of explain for one key.
+type keyExplanation struct {
+	Key         string        `json:"key"`
+	Pattern     string        `json:"pattern,omitempty"` // registry pattern the key matched
+	Owner       string        `json:"owner,omitempty"`
+	Type        string        `json:"type,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Typical     string        `json:"typical,omitempty"`
+	Consumers   []keyConsumer `json:"consumers,omitempty"`
+	Deprecated  string        `json:"deprecated,omitempty"` // set for an old spelling, with its replacement
+}
+
+// keyNotes holds typical values and consumers for the most used registry
+// patterns. Uses describe what the runtime takes from the key, not every
+// place it is read.
+var keyNotes = map[string]keyNote{
+	"general.architecture": {"llama, qwen2, qwen3, gemma3, phi3, mistral3, deepseek2, gpt-oss, ...", []keyConsumer{
+		{"llama.cpp", "selects the model graph and the namespace of the hyperparameters"},
+		{"ollama", "picks the model family"},
+		{"transformers", "picks the model class; only a fixed list of architectures can be loaded"},
+	}},
+	"general.alignment": {"32 (the default, and what almost every file uses)", []keyConsumer{
+		{"llama.cpp", "alignment of the tensor data section and of each tensor"},
+	}},
+	"general.file_type": {"1 (F16), 7 (Q8_0), 15 (Q4_K_M), 17 (Q5_K_M), 18 (Q6_K)", []keyConsumer{
+		{"llama.cpp", "printed as the file type at load"},
+		{"ollama", "shown as the quantization level"},
+		{"ggufmeta", "quantization label in summary, search and suggest-name"},
+	}},
+	"general.quantization_version": {"2", []keyConsumer{
+		{"llama.cpp", "written by llama-quantize; printed at load"},
+	}},
+	"general.name": {"free text, e.g. \"Meta Llama 3.1 8B Instruct\"", []keyConsumer{
+		{"llama.cpp", "printed at load"},
+		{"ggufmeta", "name in catalogs, cards and suggest-name"},
+	}},
+	"general.size_label": {"7B, 8B, 70B, 8x7B", []keyConsumer{
+		{"ggufmeta", "size in suggest-name and --output-template {size_label}"},
+	}},
+	"{arch}.context_length": {"2048, 4096, 8192, 32768, 131072", []keyConsumer{
+		{"llama.cpp", "training context (n_ctx_train); the default context size, with a warning for larger ones"},
+		{"ollama", "reported as the model's context length"},
+		{"transformers", "max_position_embeddings"},
+	}},
+	"{arch}.embedding_length": {"2048, 3072, 4096, 5120, 8192", []keyConsumer{
+		{"llama.cpp", "n_embd, the width of every layer"},
+		{"transformers", "hidden_size"},
+		{"ggufmeta", "fingerprint, kv-cache and summary"},
+	}},
+	"{arch}.block_count": {"16, 22, 28, 32, 40, 80", []keyConsumer{
+		{"llama.cpp", "n_layer; also the length per-layer arrays must have"},
+		{"transformers", "num_hidden_layers"},
+		{"ggufmeta", "fingerprint, kv-cache and summary"},
+	}},
+	"{arch}.feed_forward_length": {"8192, 11008, 14336, 28672", []keyConsumer{
+		{"llama.cpp", "n_ff, per layer when an array"},
+		{"transformers", "intermediate_size"},
+	}},
+	"{arch}.attention.head_count": {"16, 32, 40, 64", []keyConsumer{
+		{"llama.cpp", "n_head, per layer when an array"},
+		{"transformers", "num_attention_heads"},
+		{"ggufmeta", "kv-cache and summary"},
+	}},
+	"{arch}.attention.head_count_kv": {"8 for grouped-query attention; equal to head_count without it", []keyConsumer{
+		{"llama.cpp", "n_head_kv, defaulting to head_count; sets the KV cache size"},
+		{"transformers", "num_key_value_heads"},
+		{"ggufmeta", "kv-cache"},
+	}},
+	"{arch}.attention.key_length": {"128; 64 or 256 on some models", []keyConsumer{
+		{"llama.cpp", "n_embd_head_k, defaulting to embedding_length / head_count"},
+	}},
+	"{arch}.attention.layer_norm_rms_epsilon": {"1e-5, 1e-6", []keyConsumer{
+		{"llama.cpp", "epsilon of every RMSNorm"},
+		{"transformers", "rms_norm_eps"},
+	}},
+	"{arch}.attention.sliding_window": {"4096", []keyConsumer{
+		{"llama.cpp", "window of the sliding-window attention layers"},
+	}},
+	"{arch}.rope.freq_base": {"10000 (Llama 2), 500000 (Llama 3), 1000000 (Qwen2, Code Llama)", []keyConsumer{
+		{"llama.cpp", "default RoPE base frequency; --rope-freq-base overrides it"},
+		{"transformers", "rope_theta"},
+	}},
+	"{arch}.rope.dimension_count": {"the head size, e.g. 128; less for partial rotary models", []keyConsumer{
+		{"llama.cpp", "n_rot, the number of dimensions RoPE rotates"},
+	}},
+	"{arch}.rope.scaling.type": {"none, linear, yarn", []keyConsumer{
+		{"llama.cpp", "default --rope-scaling"},
+	}},
+	"{arch}.rope.scaling.factor": {"2, 4, 8, 32", []keyConsumer{
+		{"llama.cpp", "default RoPE scaling; the frequency scale is 1/factor"},
+	}},
+	"{arch}.rope.scaling.original_context_length": {"4096, 8192", []keyConsumer{
+		{"llama.cpp", "YaRN's original context (n_ctx_orig_yarn)"},
+	}},
+	"{arch}.expert_count": {"8, 16, 64, 128, 256", []keyConsumer{
+		{"llama.cpp", "n_expert; required for mixture-of-experts architectures"},
+		{"ggufmeta", "moe, fingerprint and summary"},
+	}},
+	"{arch}.expert_used_count": {"2, 4, 8", []keyConsumer{
+		{"llama.cpp", "n_expert_used, the experts routed per token"},
+		{"ggufmeta", "moe (active parameters) and fingerprint"},
+	}},
+	"{arch}.vocab_size": {"32000, 128256, 151936 (usually absent)", []keyConsumer{
+		{"llama.cpp", "n_vocab when present; otherwise the length of tokenizer.ggml.tokens"},
+	}},
+	"tokenizer.ggml.model": {"llama (SentencePiece), gpt2 (BPE), bert (WordPiece), t5, rwkv", []keyConsumer{
+		{"llama.cpp", "vocabulary type"},
+		{"transformers", "which tokenizer to rebuild from the vocabulary"},
+	}},
+	"tokenizer.ggml.pre": {"default, llama-bpe, qwen2, deepseek-llm, gpt-4o, tekken, ...", []keyConsumer{
+		{"llama.cpp", "pre-tokenizer regexes that split text before BPE"},
+	}},
+	"tokenizer.ggml.tokens": {"one string per token; 32000 to 256000 of them", []keyConsumer{
+		{"llama.cpp", "the vocabulary, indexed by token id"},
+		{"ollama", "the vocabulary, indexed by token id"},
+		{"transformers", "the vocabulary, indexed by token id"},
+	}},
+	"tokenizer.ggml.bos_token_id": {"1 (Llama 2), 128000 (Llama 3)", []keyConsumer{
+		{"llama.cpp", "BOS token, prepended when add_bos_token is set"},
+	}},
+	"tokenizer.ggml.eos_token_id": {"2 (Llama 2), 128001 or 128009 (Llama 3), 151645 (Qwen2 chat)", []keyConsumer{
+		{"llama.cpp", "EOS token; generation stops on it"},
+		{"ollama", "default stop token"},
+	}},
+	"tokenizer.ggml.add_bos_token": {"true for Llama-style SentencePiece models, false for most BPE models", []keyConsumer{
+		{"llama.cpp", "whether tokenizing prepends BOS"},
+	}},
+	"tokenizer.chat_template": {"a Jinja template copied from tokenizer_config.json", []keyConsumer{
+		{"llama.cpp", "applied by llama-server and llama-cli with --jinja; otherwise matched against built-in formats"},
+		{"ollama", "mapped to a built-in Go template on import when recognized"},
+	}},
+	"split.count": {"2 or more in a sharded model; absent otherwise", []keyConsumer{
+		{"llama.cpp", "number of -NNNNN-of-NNNNN.gguf shards to load"},
+	}},
+	"split.no": {"0 to split.count-1", []keyConsumer{
+		{"llama.cpp", "index of the shard"},
+	}},
+	"general.file_hash": {"\"sha256:\" followed by 64 hex digits", []keyConsumer{
+		{"ggufmeta", "checked against the tensor data by validate"},
+	}},
+}
+
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print one JSON object per key")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta explain [--json] KEY...\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var exps []keyExplanation
+	for _, key := range rest {
+		exp, err := explainKey(key)
+		if err != nil {
+			return fmt.Errorf("explain: %w", err)
+		}
+		exps = append(exps, exp)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, exp := range exps {
+			if err := enc.Encode(exp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, exp := range exps {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := writeExplanation(os.Stdout, exp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explainKey gathers what the registry, keyNotes and the compat rule sets
+// say about key. Any architecture matches {arch}. An old spelling is
+// explained through its replacement.
+func explainKey(key string) (keyExplanation, error) {
+	exp := keyExplanation{Key: key}
+	for _, p := range freeNamespaces {
+		if strings.HasPrefix(key, p) {
+			exp.Owner = strings.TrimSuffix(p, ".")
+			exp.Description = "free-form user metadata; no runtime reads it"
+			return exp, nil
+		}
+	}
+	lookup := key
+	if d, ok := lookupDeprecated(key, ""); ok {
+		exp.Deprecated = deprecationMessage(d, key)
+		lookup = replacementKey(d, key)
+	}
+	spec, ok := lookupKey(lookup, "")
+	if !ok {
+		if s := suggestKey(key, ""); s != "" {
+			return exp, fmt.Errorf("unknown key %q; did you mean %q?", key, s)
+		}
+		return exp, fmt.Errorf("unknown key %q", key)
+	}
+	exp.Pattern, exp.Type, exp.Description = spec.Pattern, spec.Type, spec.Description
+	exp.Owner = spec.Owner
+	if exp.Owner == "{arch}" {
+		exp.Owner, _, _ = strings.Cut(lookup, ".")
+	}
+	note := keyNotes[spec.Pattern]
+	exp.Typical = note.Typical
+	exp.Consumers = append(exp.Consumers, note.Consumers...)
+	for _, name := range runtimeNames() {
+		rules := runtimeRuleSets[name]
+		for _, req := range rules.RequiredKeys {
+			if req == spec.Pattern {
+				exp.Consumers = explainRuntimeUse(exp.Consumers, name, "required to load")
+			}
+		}
+		if consequence, ok := rules.WarnMissing[spec.Pattern]; ok {
+			exp.Consumers = explainRuntimeUse(exp.Consumers, name, "if missing, "+consequence)
+		}
+	}
+	return exp, nil
+}
+
+// explainRuntimeUse adds what a compat rule set says to runtime's entry,
+// creating it if keyNotes has none.
+func explainRuntimeUse(consumers []keyConsumer, runtime, use string) []keyConsumer {
+	for i, c := range consumers {
+		if c.Runtime == runtime {
+			consumers[i].Use += "; " + use
+			return consumers
+		}
+	}
+	return append(consumers, keyConsumer{Runtime: runtime, Use: use})
+}
+
+// writeExplanation prints exp as aligned text.
+func writeExplanation(w io.Writer, exp keyExplanation) error {
+	ew := &errWriter{w: w}
+	ew.printf("%s\n", exp.Key)
+	field := func(name, value string) {
+		if value != "" {
+			ew.printf("  %-12s %s\n", name, value)
+		}
+	}
+	field("deprecated", exp.Deprecated)
+	field("description", exp.Description)
+	field("type", exp.Type)
+	field("owner", exp.Owner)
+	if exp.Pattern != exp.Key {
+		field("pattern", exp.Pattern)
+	}
+	field("typical", exp.Typical)
+	for i, c := range exp.Consumers {
+		name := ""
+		if i == 0 {
+			name = "read by"
+		}
+		ew.printf("  %-12s %s: %s\n", name, c.Runtime, c.Use)
+	}
+	if len(exp.Consumers) == 0 && exp.Pattern != "" {
+		field("read by", "no consumers recorded")
+	}
+	return ew.err
+}
@@ -0,0 +1,65 @@
+// Package main implements the `fetch-meta` subcommand.
+// It copies a model's header, KV section and tensor info table - byte for
+// byte, padded to the data offset - into a metadata-only GGUF stub. Read from
+// a URL, that is a few megabytes of a multi-gigabyte file, yet every metadata
+// command (dump, tensors, fingerprint, diff, ...) gives the same answers on
+// the stub as on the original, so it can be archived and queried offline.
+// The tensor infos still point into the data section the stub leaves out, so
+// runtimes cannot load it and commands that read tensor data fail on it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runFetchMeta(args []string) error {
+	fs := flag.NewFlagSet("fetch-meta", flag.ExitOnError)
+	out := fs.String("o", "", "write the stub to `FILE` (required)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta fetch-meta -o model.meta.gguf URL|file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 || *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	in := rest[0]
+	if samePath(in, *out) {
+		return fmt.Errorf("fetch-meta: output must differ from input")
+	}
+
+	ctx := context.Background()
+	f, size, err := openInput(ctx, in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pol := basePolicy()
+	if rf, ok := f.(*remoteFile); ok {
+		pol.prefetch = rf.prefetch
+	}
+	gf, err := readFile(ctx, f, size, pol)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	// Remote inputs serve these bytes from the prefix the parse just cached
+	af, err := createAtomicFile(*out)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(af, io.NewSectionReader(f, 0, int64(gf.InfoEnd))); err != nil {
+		af.Abort()
+		return fmt.Errorf("fetch-meta: %s: %w", in, err)
+	}
+	if _, err := af.Write(make([]byte, gf.DataOffset-gf.InfoEnd)); err != nil {
+		af.Abort()
+		return fmt.Errorf("fetch-meta: %w", err)
+	}
+	return af.Commit()
+}
@@ -0,0 +1,181 @@
+// Package main implements whole-file loading for subcommands.
+// This file collects the header, every KV pair and the tensor info table into
+// one in-memory ggufFile, for commands that need to look at metadata as a whole
+// rather than stream it.
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ggufFile is the fully parsed metadata of a GGUF file (tensor payloads are not read).
+type ggufFile struct {
+	Path    string
+	Size    uint64
+	Header  headerEvent
+	KVs     []kvEvent
+	Tensors []tensorInfo
+	// DataOffset is the absolute offset of the tensor data section: the end of
+	// the tensor info table rounded up to general.alignment.
+	DataOffset uint64
+	// InfoStart and InfoEnd delimit the tensor info table; the KV section
+	// runs from the 24-byte header to InfoStart.
+	InfoStart, InfoEnd uint64
+	byKey              map[string]int
+}
+
+// basePolicy is the policy subcommands start from: the same limits as the
+// default dump, with arrays left as placeholders.
+func basePolicy() policy {
+	return policy{
+		maxArray:  envUint64("GGUF_META_MAX_ARRAY", 32),
+		maxString: envUint64("GGUF_META_MAX_STRING", 131072),
+		lenient:   envBool("GGUF_META_LENIENT", false),
+	}
+}
+
+// loadFile opens and parses path, which may also be a remote reference (see openInput).
+func loadFile(ctx context.Context, path string, pol policy) (*ggufFile, error) {
+	f, size, err := openInput(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if rf, ok := f.(*remoteFile); ok {
+		pol.prefetch = rf.prefetch
+	}
+
+	gf, err := readFile(ctx, f, size, pol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	gf.Path = path
+	return gf, nil
+}
+
+// readFile parses the header, KV section and tensor info table from r.
+func readFile(ctx context.Context, r io.Reader, size uint64, pol policy) (*ggufFile, error) {
+	gf := &ggufFile{Size: size, byKey: make(map[string]int)}
+	// Buffer inside the counter so it still counts only consumed bytes
+	cr := &countingReader{r: bufio.NewReaderSize(r, resyncWindow)}
+	err := walk(ctx, cr, size, pol, func(ev event) error {
+		switch ev := ev.(type) {
+		case headerEvent:
+			gf.Header = ev
+			gf.InfoStart = cr.n
+		case kvEvent:
+			gf.byKey[ev.Key] = len(gf.KVs)
+			gf.KVs = append(gf.KVs, ev)
+			gf.InfoStart = cr.n
+		case tensorInfo:
+			gf.Tensors = append(gf.Tensors, ev)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	gf.InfoEnd = cr.n
+	gf.DataOffset = alignUp(cr.n, gf.Alignment())
+	return gf, nil
+}
+
+// countingReader counts bytes read so far; the scanner does no read-ahead, so
+// after a walk this is exactly the end of the tensor info table.
+type countingReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += uint64(n)
+	return n, err
+}
+
+// Peek lets the parser look ahead through the counter.
+func (c *countingReader) Peek(n int) ([]byte, error) {
+	if pk, ok := c.r.(peeker); ok {
+		return pk.Peek(n)
+	}
+	return nil, fmt.Errorf("input does not support look-ahead")
+}
+
+// alignUp rounds off up to a multiple of align.
+func alignUp(off, align uint64) uint64 {
+	return (off + align - 1) / align * align
+}
+
+// Get returns the KV pair for key. With duplicate keys the last one wins.
+func (gf *ggufFile) Get(key string) (kvEvent, bool) {
+	i, ok := gf.byKey[key]
+	if !ok {
+		return kvEvent{}, false
+	}
+	return gf.KVs[i], true
+}
+
+// Arch returns general.architecture, or "" if missing.
+func (gf *ggufFile) Arch() string {
+	kv, _ := gf.Get("general.architecture")
+	s, _ := kv.Value.(string)
+	return s
+}
+
+// Alignment returns general.alignment, or the GGUF default of 32 if it is
+// missing or not a positive integer.
+func (gf *ggufFile) Alignment() uint64 {
+	if a, ok := gf.Uint("general.alignment"); ok && a > 0 {
+		return a
+	}
+	return 32
+}
+
+// Uint returns an integer-valued key as uint64, accepting any GGUF integer width.
+// Architecture-specific keys may be given as "{arch}.suffix".
+func (gf *ggufFile) Uint(key string) (uint64, bool) {
+	kv, ok := gf.Get(strings.ReplaceAll(key, "{arch}", gf.Arch()))
+	if !ok {
+		return 0, false
+	}
+	return asUint64(kv.Value)
+}
+
+// asUint64 converts any non-negative GGUF integer value to uint64.
+func asUint64(v any) (uint64, bool) {
+	switch x := v.(type) {
+	case uint8:
+		return uint64(x), true
+	case uint16:
+		return uint64(x), true
+	case uint32:
+		return uint64(x), true
+	case uint64:
+		return x, true
+	case int8:
+		return uint64(x), x >= 0
+	case int16:
+		return uint64(x), x >= 0
+	case int32:
+		return uint64(x), x >= 0
+	case int64:
+		return uint64(x), x >= 0
+	}
+	return 0, false
+}
+
+// arrayLen returns the element count of an array value, expanded or placeholder.
+func arrayLen(v any) (uint64, bool) {
+	switch x := v.(type) {
+	case []any:
+		return uint64(len(x)), true
+	case map[string]any:
+		n, ok := x["count"].(uint64)
+		return n, ok
+	}
+	return 0, false
+}
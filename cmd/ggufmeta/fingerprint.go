@@ -0,0 +1,149 @@
+// Package main implements architecture fingerprints and the `fingerprint`
+// subcommand. A fingerprint hashes what a model's shape is made of - the
+// architecture, its structural hyperparameters and every tensor's name and
+// dimensions - and leaves out tensor types, names, tokenizer data and other
+// descriptive metadata, so every quant and most finetunes of one base model
+// share a fingerprint while different models do not.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// fingerprintVersion prefixes every fingerprint; bump it whenever the hashed
+// form changes so old and new fingerprints never compare equal by accident.
+const fingerprintVersion = "fp2"
+
+// fingerprintKeys are the hyperparameters that define a model's shape, as
+// suffixes of "{arch}.". Context length and RoPE settings are left out on
+// purpose: long-context finetunes change them without changing the weights'
+// shape.
+var fingerprintKeys = []string{
+	"block_count",
+	"embedding_length",
+	"feed_forward_length",
+	"attention.head_count",
+	"attention.head_count_kv",
+	"attention.key_length",
+	"attention.value_length",
+	"expert_count",
+	"expert_used_count",
+	"ssm.state_size",
+	"ssm.conv_kernel",
+	"ssm.inner_size",
+}
+
+// fingerprintRecord is one line of `ggufmeta fingerprint --json` output.
+type fingerprintRecord struct {
+	Path        string `json:"path"`
+	Arch        string `json:"arch"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// fingerprintPolicy loads enough of a file to fingerprint it: per-layer
+// hyperparameter arrays must be expanded to be hashed by value.
+func fingerprintPolicy() policy {
+	pol := basePolicy()
+	pol.maxArray = max(pol.maxArray, 4096)
+	return pol
+}
+
+// fingerprintOf returns gf's fingerprint, e.g. "fp2:3f2a...".
+func fingerprintOf(gf *ggufFile) string {
+	h := sha256.New()
+	arch := gf.Arch()
+	fmt.Fprintf(h, "arch=%s\n", arch)
+	for _, suffix := range fingerprintKeys {
+		if kv, ok := gf.Get(arch + "." + suffix); ok {
+			fmt.Fprintf(h, "%s=%v\n", suffix, fingerprintValue(kv.Value))
+		}
+	}
+	// Sort by name so info-table order, which converters do not agree on,
+	// does not matter
+	tensors := make([]string, 0, len(gf.Tensors))
+	for _, t := range gf.Tensors {
+		tensors = append(tensors, fmt.Sprintf("%s%v", t.Name, t.Dims))
+	}
+	sort.Strings(tensors)
+	fmt.Fprintf(h, "tensors=%s\n", strings.Join(tensors, "\n"))
+	return fingerprintVersion + ":" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// fingerprintValue reduces an array placeholder to its count and element
+// type, so the previews placeholders carry do not change fingerprints.
+func fingerprintValue(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return v
+	}
+	return map[string]any{
+		"_placeholder": m["_placeholder"],
+		"count":        m["count"],
+		"element_type": m["element_type"],
+	}
+}
+
+func runFingerprint(args []string) error {
+	fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print one JSON record per file")
+	group := fs.Bool("group", false, "print files grouped by fingerprint, one group per paragraph")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta fingerprint [--json | --group] file.gguf...\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 || (*asJSON && *group) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	recs := make([]fingerprintRecord, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		gf, err := loadFile(context.Background(), path, fingerprintPolicy())
+		if err != nil {
+			return err
+		}
+		recs = append(recs, fingerprintRecord{Path: path, Arch: gf.Arch(), Fingerprint: fingerprintOf(gf)})
+	}
+
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+	case *group:
+		// Groups in order of first appearance, files in argument order
+		var order []string
+		groups := make(map[string][]string)
+		for _, r := range recs {
+			if _, ok := groups[r.Fingerprint]; !ok {
+				order = append(order, r.Fingerprint)
+			}
+			groups[r.Fingerprint] = append(groups[r.Fingerprint], r.Path)
+		}
+		for i, fp := range order {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(fp)
+			for _, path := range groups[fp] {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+	default:
+		for _, r := range recs {
+			fmt.Printf("%s  %s\n", r.Fingerprint, r.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package main implements the padding content validation check.
+// Alignment padding - between the tensor info table and the data section,
+// and between tensors - is written as zeros. Non-zero bytes there are stale
+// data from a broken writer; they are harmless to loaders but mislead tools
+// that locate sections by scanning for padding.
+package main
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+func init() {
+	registerCheck("padding", checkPaddingBytes)
+}
+
+func checkPaddingBytes(v *validation) {
+	gf := v.gf
+	if v.data == nil {
+		return
+	}
+	if gf.InfoEnd < gf.DataOffset {
+		end := min(gf.DataOffset, gf.Size)
+		checkZeroRange(v, "", gf.InfoEnd, end, "between the tensor infos and the tensor data")
+	}
+
+	// Padding after each tensor up to the next one's aligned start
+	sorted := make([]tensorInfo, 0, len(gf.Tensors))
+	for _, t := range gf.Tensors {
+		if _, ok := ggmlTensorSize(t.Type, t.Dims); ok {
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+	for i, t := range sorted {
+		size, _ := ggmlTensorSize(t.Type, t.Dims)
+		start := gf.DataOffset + t.Offset + size
+		end := gf.DataOffset + alignUp(t.Offset+size, gf.Alignment())
+		if i+1 < len(sorted) {
+			// Gaps and overlaps are the layout check's business
+			end = min(end, gf.DataOffset+sorted[i+1].Offset)
+		}
+		end = min(end, gf.Size)
+		if start < end {
+			checkZeroRange(v, t.Name, start, end, "in the alignment padding after this tensor")
+		}
+	}
+}
+
+// checkZeroRange reports non-zero bytes in the absolute range [start, end).
+func checkZeroRange(v *validation, tensor string, start, end uint64, where string) {
+	buf := make([]byte, end-start)
+	if _, err := v.data.ReadAt(buf, int64(start)); err != nil && err != io.EOF {
+		v.report("error", "", tensor, "reading padding at %d: %v", start, err)
+		return
+	}
+	first := -1
+	nonZero := 0
+	for i, b := range buf {
+		if b != 0 {
+			if first < 0 {
+				first = i
+			}
+			nonZero++
+		}
+	}
+	if first < 0 {
+		return
+	}
+	preview := buf[first:min(first+16, len(buf))]
+	preview = bytes.TrimRight(preview, "\x00")
+	v.report("warning", "", tensor, "%d non-zero bytes %s, range [%d, %d); first at %d: % x", nonZero, where, start, end, start+uint64(first), preview)
+}
@@ -0,0 +1,56 @@
+// Package main implements --grep, a search over metadata values.
+// Every array is expanded while grepping, so a pattern finds a token in
+// tokenizer.ggml.tokens as readily as a word in general.description; each hit
+// is reported on its own, with the array index when it came from an element.
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// grepEvent is one value that matched --grep.
+type grepEvent struct {
+	Kind  string  `json:"kind"` // always "match"
+	Key   string  `json:"key"`
+	Index *uint64 `json:"index,omitempty"` // element index for matches inside arrays
+	Type  string  `json:"type"`            // type of the matched value, e.g. "string"
+	Value any     `json:"value"`
+}
+
+// grepKV returns the matches of re in kv's value: the value itself if it is
+// a scalar, otherwise each matching element.
+func grepKV(kv kvEvent, re *regexp.Regexp) []grepEvent {
+	items, ok := kv.Value.([]any)
+	if !ok {
+		if grepScalar(kv.Value, re) {
+			return []grepEvent{{Kind: "match", Key: kv.Key, Type: kv.Type, Value: kv.Value}}
+		}
+		return nil
+	}
+	elem := kv.Type
+	if len(elem) > len("array[]") {
+		elem = elem[len("array[") : len(elem)-1]
+	}
+	var out []grepEvent
+	for i, it := range items {
+		if grepScalar(it, re) {
+			idx := uint64(i)
+			out = append(out, grepEvent{Kind: "match", Key: kv.Key, Index: &idx, Type: elem, Value: it})
+		}
+	}
+	return out
+}
+
+// grepScalar matches strings as they are and other scalars in their JSON
+// form; placeholders (nested arrays, unknown types) never match.
+func grepScalar(v any, re *regexp.Regexp) bool {
+	switch x := v.(type) {
+	case string:
+		return re.MatchString(x)
+	case map[string]any, []any:
+		return false
+	}
+	raw, err := json.Marshal(v)
+	return err == nil && re.Match(raw)
+}
@@ -0,0 +1,127 @@
+// Package main implements http(s):// and hf:// inputs.
+// A URL is read like an oci:// blob, with range requests, but its leading
+// bytes go through the remote cache: the first request is a conditional
+// range request, so an unchanged file costs one 304 and no body.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hfURL turns hf://owner/repo[@revision]/path/file.gguf into the Hugging Face
+// resolve URL, honouring $HF_ENDPOINT for mirrors.
+func hfURL(s string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(s, "hf://"), "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("%s: want hf://owner/repo[@revision]/path.gguf", s)
+	}
+	repo, rev, ok := strings.Cut(parts[1], "@")
+	if !ok || rev == "" {
+		rev = "main"
+	}
+	endpoint := os.Getenv("HF_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://huggingface.co"
+	}
+	return url.JoinPath(endpoint, parts[0], repo, "resolve", url.PathEscape(rev), parts[2])
+}
+
+// openHTTP opens a URL. The first request fetches the first window and
+// learns the size; with a cache entry it is conditional and a 304 serves the
+// cached bytes instead.
+func openHTTP(ctx context.Context, u string, header http.Header) (inputFile, uint64, error) {
+	client := remoteHTTPClient()
+	// Entries are keyed without the password a URL may carry
+	key := redactURL(u)
+	if remoteOpts.offline {
+		return openCached(ctx, key)
+	}
+	cached := loadRemoteCache(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", remoteFirstChunk-1))
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := remoteDo(client, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var entry *remoteCacheEntry
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, 0, fmt.Errorf("%s: unexpected %s", key, resp.Status)
+		}
+		logger.Debug("remote cache hit", "url", key, "bytes", len(cached.data))
+		entry = cached
+	case http.StatusPartialContent, http.StatusOK:
+		size, err := responseSize(resp)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", key, err)
+		}
+		if resp.StatusCode == http.StatusOK && size > remoteFirstChunk {
+			return nil, 0, fmt.Errorf("%s: the server ignored the range request (it must support HTTP range requests)", key)
+		}
+		// What arrived before an interruption is still the start of the
+		// file; range requests fetch the rest
+		data, err := io.ReadAll(io.LimitReader(resp.Body, remoteFirstChunk))
+		if err != nil {
+			logger.Warn("remote transfer interrupted; resuming", "url", key, "offset", len(data), "err", err)
+		}
+		entry = &remoteCacheEntry{
+			URL:          key,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Size:         size,
+			data:         data,
+		}
+		// Servers that ignore conditional headers still send the same ETag
+		if cached != nil && cached.ETag != "" && cached.ETag == entry.ETag && cached.Size == size && len(cached.data) > len(data) {
+			entry.data = cached.data
+		}
+	default:
+		return nil, 0, fmt.Errorf("%s: %s", key, resp.Status)
+	}
+
+	r := newRemoteFile(ctx, client, u, header, entry.Size)
+	r.cache, r.dirty = entry, entry != cached
+	return r, uint64(entry.Size), nil
+}
+
+// responseSize reads the total size from Content-Range, or Content-Length
+// for a whole-file response.
+func responseSize(resp *http.Response) (int64, error) {
+	if resp.StatusCode == http.StatusOK {
+		if resp.ContentLength < 0 {
+			return 0, fmt.Errorf("the server sent no Content-Length")
+		}
+		return resp.ContentLength, nil
+	}
+	cr := resp.Header.Get("Content-Range")
+	_, total, ok := strings.Cut(cr, "/")
+	size, err := strconv.ParseInt(total, 10, 64)
+	if !ok || err != nil || size < 0 {
+		return 0, fmt.Errorf("bad Content-Range %q", cr)
+	}
+	return size, nil
+}
@@ -0,0 +1,106 @@
+// Package main implements random-access metadata lookups with kvIndex.
+// One sequential pass records where every KV pair starts; afterwards Get seeks
+// straight to a key through io.ReaderAt and decodes only that value, so servers
+// can answer point queries on huge metadata without re-parsing the file.
+// Inside ggufmeta it backs --get; it is not an importable API.
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+)
+
+// kvLoc is where one KV pair lives in the file.
+type kvLoc struct {
+	Offset uint64 // absolute offset of the key's length prefix
+	End    uint64 // absolute offset just past the value
+	Tag    uint32 // GGUF value type tag
+	Padded bool   // value is padded to 8 bytes after its tag (alignment quirk)
+}
+
+// kvIndex maps keys to byte offsets and decodes values on demand.
+// It is safe for concurrent Get calls when the underlying ReaderAt is.
+type kvIndex struct {
+	ra     io.ReaderAt
+	size   int64
+	order  binary.ByteOrder
+	pol    policy
+	header headerEvent
+	keys   []string // file order, duplicates collapsed to the last occurrence
+	locs   map[string]kvLoc
+}
+
+// openIndex builds the index with one pass over the KV section. Arrays are
+// skipped during indexing regardless of pol; pol governs decoding in Get.
+func openIndex(ctx context.Context, ra io.ReaderAt, size int64, pol policy) (*kvIndex, error) {
+	scanPol := pol
+	scanPol.expandArrays, scanPol.expandPrefixes = nil, nil
+	p, hdr, err := newParserAt(ra, uint64(size), scanPol)
+	if err != nil {
+		return nil, err
+	}
+	p.ctx = ctx
+	ix := &kvIndex{
+		ra:     ra,
+		size:   size,
+		order:  p.scn.order,
+		pol:    pol,
+		header: hdr,
+		locs:   make(map[string]kvLoc),
+	}
+	for p.kvRemain > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		start := p.scn.pos
+		key, err := p.scn.GGUFString(p.pol.maxString)
+		if err != nil {
+			return nil, p.errAt("", "", err)
+		}
+		tag, err := p.scn.U32()
+		if err != nil {
+			return nil, p.errAt(key, "", err)
+		}
+		p.probeAlignment(key, tag)
+		padded := p.padded
+		if _, _, _, err := p.readValue(tag, key); err != nil {
+			return nil, p.errAt(key, "", err)
+		}
+		p.kvRemain--
+		if _, dup := ix.locs[key]; !dup {
+			ix.keys = append(ix.keys, key)
+		}
+		ix.locs[key] = kvLoc{Offset: start, End: p.scn.pos, Tag: tag, Padded: padded}
+	}
+	return ix, nil
+}
+
+// Header returns the file header read while indexing.
+func (ix *kvIndex) Header() headerEvent { return ix.header }
+
+// Keys returns every indexed key in file order.
+func (ix *kvIndex) Keys() []string { return ix.keys }
+
+// Loc returns the byte range of key's KV pair.
+func (ix *kvIndex) Loc(key string) (kvLoc, bool) {
+	loc, ok := ix.locs[key]
+	return loc, ok
+}
+
+// Get decodes key's value by reading only its byte range.
+func (ix *kvIndex) Get(ctx context.Context, key string) (kvEvent, bool, error) {
+	loc, ok := ix.locs[key]
+	if !ok {
+		return kvEvent{}, false, nil
+	}
+	// Absolute positions keep alignment identical to the indexing pass
+	scn := newScannerAt(ix.ra, loc.Offset)
+	scn.order = ix.order
+	p := &parser{scn: scn, fileSize: uint64(ix.size), pol: ix.pol, kvRemain: 1, ctx: ctx, padded: loc.Padded}
+	kv, _, err := p.nextKV()
+	if err != nil {
+		return kvEvent{}, false, err
+	}
+	return kv, true, nil
+}
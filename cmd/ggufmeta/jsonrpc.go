@@ -0,0 +1,160 @@
+// Package main implements JSON-RPC 2.0 over newline-delimited JSON.
+// Each line on the input is one request, notification or batch; responses go
+// to the output one per line, in order. Requests are answered one at a time,
+// so a handler never runs concurrently with itself. `mcp` and --stdio
+// speak this transport.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// rpcMaxMessage bounds one input line.
+const rpcMaxMessage = 16 << 20
+
+// Error codes defined by JSON-RPC 2.0.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcServerError    = -32000 // first of the implementation-defined codes
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error object. Handlers return one to choose the
+// code; any other error is reported as an internal error.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// rpcErrorf builds an rpcError with a formatted message.
+func rpcErrorf(code int, format string, args ...any) *rpcError {
+	return &rpcError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// rpcHandler answers one method call. The result must not be nil: a
+// successful response always carries one.
+type rpcHandler func(method string, params json.RawMessage) (any, error)
+
+// serveRPC reads messages from r until EOF and writes responses to w.
+func serveRPC(r io.Reader, w io.Writer, handle rpcHandler) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), rpcMaxMessage)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		out, err := rpcDispatch(line, handle)
+		if err != nil {
+			return err
+		}
+		if out == nil {
+			continue // only notifications
+		}
+		if _, err := w.Write(append(out, '\n')); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("jsonrpc: %w", err)
+	}
+	return nil
+}
+
+// rpcDispatch answers one line: a single message or a batch. It returns nil
+// when there is nothing to send back.
+func rpcDispatch(line []byte, handle rpcHandler) ([]byte, error) {
+	if line[0] != '[' {
+		resp := rpcCall(line, handle)
+		if resp == nil {
+			return nil, nil
+		}
+		return json.Marshal(resp)
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return json.Marshal(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rpcErrorf(rpcParseError, "parse error: %v", err)})
+	}
+	if len(batch) == 0 {
+		return json.Marshal(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rpcErrorf(rpcInvalidRequest, "empty batch")})
+	}
+	var resps []*rpcResponse
+	for _, msg := range batch {
+		if resp := rpcCall(msg, handle); resp != nil {
+			resps = append(resps, resp)
+		}
+	}
+	if len(resps) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(resps)
+}
+
+// rpcCall answers one message; nil for a notification.
+func rpcCall(msg []byte, handle rpcHandler) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		code := rpcInvalidRequest
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			code = rpcParseError
+		}
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rpcErrorf(code, "bad request: %v", err)}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = json.RawMessage("null")
+		}
+		return &rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErrorf(rpcInvalidRequest, "not a JSON-RPC 2.0 request")}
+	}
+	result, err := handle(req.Method, req.Params)
+	if req.ID == nil {
+		if err != nil {
+			logger.Debug("jsonrpc: notification failed", "method", req.Method, "err", err)
+		}
+		return nil
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	var rerr *rpcError
+	switch {
+	case errors.As(err, &rerr):
+		resp.Error = rerr
+	case err != nil:
+		resp.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+	default:
+		// Encode here so a result JSON cannot represent fails this call only
+		raw, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		} else {
+			resp.Result = json.RawMessage(raw)
+		}
+	}
+	return resp
+}
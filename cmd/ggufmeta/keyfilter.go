@@ -0,0 +1,66 @@
+// Package main implements the --keys selection.
+// The flag takes a comma-separated list; a key is shown when any entry is a
+// prefix of it, so "general.,tokenizer.chat_template" selects both at once.
+// An entry containing *, ? or [ is a shell-style glob that must match the
+// whole key instead: "*.attention.*" finds keys by a middle segment.
+// With --keys-ci both sides are trimmed and lower-cased before comparing, for
+// converters that are inconsistent about the casing of custom keys.
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// keyFilter is a parsed --keys value; the zero value matches every key.
+type keyFilter struct {
+	prefixes []string
+	globs    []string
+	fold     bool // --keys-ci
+}
+
+// parseKeyFilter splits spec on commas, dropping blanks around and between
+// entries, and rejects malformed globs. fold makes matching case-insensitive.
+func parseKeyFilter(spec string, fold bool) (keyFilter, error) {
+	f := keyFilter{fold: fold}
+	for _, p := range strings.Split(spec, ",") {
+		p = strings.TrimSpace(p)
+		if fold {
+			p = strings.ToLower(p)
+		}
+		switch {
+		case p == "":
+		case strings.ContainsAny(p, "*?["):
+			// Keys never contain '/', so path.Match's * spans dots as well
+			if _, err := path.Match(p, ""); err != nil {
+				return f, fmt.Errorf("--keys: bad pattern %q: %w", p, err)
+			}
+			f.globs = append(f.globs, p)
+		default:
+			f.prefixes = append(f.prefixes, p)
+		}
+	}
+	return f, nil
+}
+
+// match reports whether key is selected.
+func (f keyFilter) match(key string) bool {
+	if len(f.prefixes) == 0 && len(f.globs) == 0 {
+		return true
+	}
+	if f.fold {
+		key = strings.ToLower(strings.TrimSpace(key))
+	}
+	for _, p := range f.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	for _, g := range f.globs {
+		if ok, _ := path.Match(g, key); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+// Package main holds the registry of documented GGUF keys.
+// Each entry gives a key pattern, the type writers are expected to use, the
+// namespace that owns it and a one-line description. --annotate attaches the
+// entry to every kv record and flags keys that are unknown, probably
+// misspelled, of an unexpected type or in another architecture's namespace.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keySpec documents one key. Pattern segments "{arch}" (the file's
+// architecture), "{n}" (a decimal index) and "{name}" (any one segment)
+// match variable parts.
+type keySpec struct {
+	Pattern     string
+	Type        string // type class, see typeClass; "a|b" allows either
+	Owner       string // "general", "tokenizer", an architecture name, ...; "{arch}" for every architecture
+	Description string
+}
+
+// keyAnnotation is attached to kv records by --annotate.
+type keyAnnotation struct {
+	Owner       string `json:"owner,omitempty"`
+	Expected    string `json:"expected,omitempty"` // type class, when the key is known
+	Description string `json:"description,omitempty"`
+	Warning     string `json:"warning,omitempty"`
+	Suggestion  string `json:"suggestion,omitempty"` // current spelling of a deprecated key, closest known key to an unknown one
+}
+
+// annotatedKV is a kv record with its registry annotation.
+type annotatedKV struct {
+	kvEvent
+	Annotation keyAnnotation `json:"annotation"`
+}
+
+// freeNamespaces hold keys no registry can list.
+var freeNamespaces = []string{"user.", "x."}
+
+// reservedNamespaces are top-level key segments that are not architectures.
+var reservedNamespaces = map[string]bool{"general": true, "tokenizer": true, "split": true, "adapter": true, "quantize": true, "ggufmeta": true, "user": true, "x": true}
+
+var knownKeys = []keySpec{
+	{"general.architecture", "string", "general", "model architecture; names the {arch} namespace of the hyperparameters"},
+	{"general.quantization_version", "int", "general", "version of the quantization format"},
+	{"general.alignment", "int", "general", "alignment of the tensor data section and of each tensor, in bytes (default 32)"},
+	{"general.file_type", "int", "general", "llama_ftype describing the majority tensor type, e.g. 15 for Q4_K_M"},
+	{"general.type", "string", "general", "kind of file: \"model\" or \"adapter\""},
+	{"general.name", "string", "general", "human-readable model name"},
+	{"general.author", "string", "general", "author of the model"},
+	{"general.version", "string", "general", "model version"},
+	{"general.organization", "string", "general", "organization that published the model"},
+	{"general.basename", "string", "general", "base model name without size or finetune, for file naming"},
+	{"general.finetune", "string", "general", "finetune label, e.g. \"Instruct\""},
+	{"general.description", "string", "general", "free-form description of the model"},
+	{"general.quantized_by", "string", "general", "who quantized the file"},
+	{"general.size_label", "string", "general", "size class, e.g. \"7B\" or \"8x7B\""},
+	{"general.license", "string", "general", "SPDX license expression"},
+	{"general.license.name", "string", "general", "license name when general.license is \"other\""},
+	{"general.license.link", "string", "general", "URL of the license text"},
+	{"general.url", "string", "general", "model homepage"},
+	{"general.doi", "string", "general", "DOI of the model"},
+	{"general.uuid", "string", "general", "UUID of the model"},
+	{"general.repo_url", "string", "general", "repository of the model"},
+	{"general.tags", "array[string]", "general", "search tags"},
+	{"general.languages", "array[string]", "general", "languages the model handles, as ISO 639 codes"},
+	{"general.source.url", "string", "general", "homepage of the source model"},
+	{"general.source.doi", "string", "general", "DOI of the source model"},
+	{"general.source.uuid", "string", "general", "UUID of the source model"},
+	{"general.source.repo_url", "string", "general", "repository the file was converted from"},
+	{"general.base_model.count", "int", "general", "number of general.base_model.{n}.* entries"},
+	{"general.base_model.{n}.name", "string", "general", "name of a model this one derives from"},
+	{"general.base_model.{n}.author", "string", "general", "author of a base model"},
+	{"general.base_model.{n}.version", "string", "general", "version of a base model"},
+	{"general.base_model.{n}.organization", "string", "general", "organization of a base model"},
+	{"general.base_model.{n}.description", "string", "general", "description of a base model"},
+	{"general.base_model.{n}.url", "string", "general", "homepage of a base model"},
+	{"general.base_model.{n}.doi", "string", "general", "DOI of a base model"},
+	{"general.base_model.{n}.uuid", "string", "general", "UUID of a base model"},
+	{"general.base_model.{n}.repo_url", "string", "general", "repository of a base model"},
+	{"general.dataset.count", "int", "general", "number of general.dataset.{n}.* entries"},
+	{"general.dataset.{n}.name", "string", "general", "name of a training dataset"},
+	{"general.dataset.{n}.author", "string", "general", "author of a training dataset"},
+	{"general.dataset.{n}.version", "string", "general", "version of a training dataset"},
+	{"general.dataset.{n}.organization", "string", "general", "organization of a training dataset"},
+	{"general.dataset.{n}.description", "string", "general", "description of a training dataset"},
+	{"general.dataset.{n}.url", "string", "general", "homepage of a training dataset"},
+	{"general.dataset.{n}.doi", "string", "general", "DOI of a training dataset"},
+	{"general.dataset.{n}.uuid", "string", "general", "UUID of a training dataset"},
+	{"general.dataset.{n}.repo_url", "string", "general", "repository of a training dataset"},
+	{"general.file_hash", "string", "general", "digest of the tensor data section, \"algo:hex\" (checked by validate)"},
+
+	{"{arch}.vocab_size", "int", "{arch}", "vocabulary size"},
+	{"{arch}.context_length", "int", "{arch}", "context length the model was trained with"},
+	{"{arch}.embedding_length", "int", "{arch}", "embedding width (n_embd)"},
+	{"{arch}.block_count", "int", "{arch}", "number of transformer blocks"},
+	{"{arch}.feed_forward_length", "int|array[int]", "{arch}", "feed-forward width, per layer when an array"},
+	{"{arch}.expert_feed_forward_length", "int", "{arch}", "feed-forward width of each expert"},
+	{"{arch}.expert_shared_feed_forward_length", "int", "{arch}", "feed-forward width of the shared expert"},
+	{"{arch}.use_parallel_residual", "bool", "{arch}", "attention and feed-forward run in parallel"},
+	{"{arch}.tensor_data_layout", "string", "{arch}", "layout of the tensor data, e.g. \"Meta AI original pth\""},
+	{"{arch}.expert_count", "int", "{arch}", "number of experts in mixture-of-experts layers"},
+	{"{arch}.expert_used_count", "int", "{arch}", "experts routed per token"},
+	{"{arch}.expert_shared_count", "int", "{arch}", "always-active shared experts"},
+	{"{arch}.expert_weights_scale", "float", "{arch}", "scale applied to expert routing weights"},
+	{"{arch}.expert_weights_norm", "bool", "{arch}", "expert routing weights are normalized"},
+	{"{arch}.expert_gating_func", "int", "{arch}", "expert gating function (1 softmax, 2 sigmoid)"},
+	{"{arch}.leading_dense_block_count", "int", "{arch}", "leading blocks that use a dense feed-forward"},
+	{"{arch}.pooling_type", "int", "{arch}", "embedding pooling (0 none, 1 mean, 2 cls, 3 last, 4 rank)"},
+	{"{arch}.logit_scale", "float", "{arch}", "scale applied to output logits"},
+	{"{arch}.final_logit_softcapping", "float", "{arch}", "soft cap on output logits"},
+	{"{arch}.attn_logit_softcapping", "float", "{arch}", "soft cap on attention logits"},
+	{"{arch}.decoder_start_token_id", "int", "{arch}", "token that starts decoding in encoder-decoder models"},
+	{"{arch}.attention.head_count", "int|array[int]", "{arch}", "attention heads, per layer when an array"},
+	{"{arch}.attention.head_count_kv", "int|array[int]", "{arch}", "key/value heads for grouped-query attention, per layer when an array"},
+	{"{arch}.attention.max_alibi_bias", "float", "{arch}", "maximum ALiBi bias"},
+	{"{arch}.attention.clamp_kqv", "float", "{arch}", "clamp applied to Q, K and V"},
+	{"{arch}.attention.key_length", "int", "{arch}", "size of each key head (default embedding_length / head_count)"},
+	{"{arch}.attention.value_length", "int", "{arch}", "size of each value head (default embedding_length / head_count)"},
+	{"{arch}.attention.layer_norm_epsilon", "float", "{arch}", "epsilon of LayerNorm"},
+	{"{arch}.attention.layer_norm_rms_epsilon", "float", "{arch}", "epsilon of RMSNorm"},
+	{"{arch}.attention.causal", "bool", "{arch}", "attention is causal (false for embedding models)"},
+	{"{arch}.attention.sliding_window", "int", "{arch}", "sliding-window attention size"},
+	{"{arch}.attention.q_lora_rank", "int", "{arch}", "rank of the low-rank query projection"},
+	{"{arch}.attention.kv_lora_rank", "int", "{arch}", "rank of the low-rank key/value projection"},
+	{"{arch}.rope.dimension_count", "int", "{arch}", "dimensions RoPE rotates"},
+	{"{arch}.rope.dimension_sections", "array[int]", "{arch}", "RoPE sections for multimodal positions"},
+	{"{arch}.rope.freq_base", "float", "{arch}", "RoPE base frequency"},
+	{"{arch}.rope.scaling.type", "string", "{arch}", "RoPE scaling: \"none\", \"linear\", \"yarn\" or \"longrope\""},
+	{"{arch}.rope.scaling.factor", "float", "{arch}", "RoPE scaling factor"},
+	{"{arch}.rope.scaling.attn_factor", "float", "{arch}", "YaRN attention factor"},
+	{"{arch}.rope.scaling.original_context_length", "int", "{arch}", "context length before RoPE scaling"},
+	{"{arch}.rope.scaling.finetuned", "bool", "{arch}", "the model was finetuned with the scaling applied"},
+	{"{arch}.rope.scaling.yarn_log_multiplier", "float", "{arch}", "YaRN log multiplier"},
+	{"{arch}.ssm.conv_kernel", "int", "{arch}", "state-space model convolution kernel size"},
+	{"{arch}.ssm.inner_size", "int", "{arch}", "state-space model inner size"},
+	{"{arch}.ssm.state_size", "int", "{arch}", "state-space model state size"},
+	{"{arch}.ssm.time_step_rank", "int", "{arch}", "state-space model time step rank"},
+	{"{arch}.ssm.group_count", "int", "{arch}", "state-space model group count"},
+
+	{"tokenizer.ggml.model", "string", "tokenizer", "tokenizer family: \"llama\", \"gpt2\", \"bert\", ..."},
+	{"tokenizer.ggml.pre", "string", "tokenizer", "pre-tokenizer type"},
+	{"tokenizer.ggml.tokens", "array[string]", "tokenizer", "vocabulary, indexed by token id"},
+	{"tokenizer.ggml.scores", "array[float]", "tokenizer", "score of each token"},
+	{"tokenizer.ggml.token_type", "array[int]", "tokenizer", "type of each token (normal, control, user-defined, ...)"},
+	{"tokenizer.ggml.token_type_count", "int", "tokenizer", "number of token types (BERT segment embeddings)"},
+	{"tokenizer.ggml.merges", "array[string]", "tokenizer", "BPE merges"},
+	{"tokenizer.ggml.added_tokens", "array[string]", "tokenizer", "tokens added after training"},
+	{"tokenizer.ggml.bos_token_id", "int", "tokenizer", "beginning-of-sequence token"},
+	{"tokenizer.ggml.eos_token_id", "int", "tokenizer", "end-of-sequence token"},
+	{"tokenizer.ggml.eot_token_id", "int", "tokenizer", "end-of-turn token"},
+	{"tokenizer.ggml.eom_token_id", "int", "tokenizer", "end-of-message token"},
+	{"tokenizer.ggml.unknown_token_id", "int", "tokenizer", "unknown token"},
+	{"tokenizer.ggml.separator_token_id", "int", "tokenizer", "separator token"},
+	{"tokenizer.ggml.padding_token_id", "int", "tokenizer", "padding token"},
+	{"tokenizer.ggml.mask_token_id", "int", "tokenizer", "mask token"},
+	{"tokenizer.ggml.fim_pre_token_id", "int", "tokenizer", "fill-in-the-middle prefix token"},
+	{"tokenizer.ggml.fim_suf_token_id", "int", "tokenizer", "fill-in-the-middle suffix token"},
+	{"tokenizer.ggml.fim_mid_token_id", "int", "tokenizer", "fill-in-the-middle middle token"},
+	{"tokenizer.ggml.fim_pad_token_id", "int", "tokenizer", "fill-in-the-middle padding token"},
+	{"tokenizer.ggml.fim_rep_token_id", "int", "tokenizer", "fill-in-the-middle repository token"},
+	{"tokenizer.ggml.fim_sep_token_id", "int", "tokenizer", "fill-in-the-middle file separator token"},
+	{"tokenizer.ggml.add_bos_token", "bool", "tokenizer", "prepend the BOS token when tokenizing"},
+	{"tokenizer.ggml.add_eos_token", "bool", "tokenizer", "append the EOS token when tokenizing"},
+	{"tokenizer.ggml.add_space_prefix", "bool", "tokenizer", "prefix text with a space before tokenizing"},
+	{"tokenizer.ggml.remove_extra_whitespaces", "bool", "tokenizer", "collapse whitespace before tokenizing"},
+	{"tokenizer.ggml.precompiled_charsmap", "array[int]", "tokenizer", "SentencePiece normalization map"},
+	{"tokenizer.chat_template", "string", "tokenizer", "default Jinja chat template"},
+	{"tokenizer.chat_template.{name}", "string", "tokenizer", "named Jinja chat template (e.g. tool_use)"},
+	{"tokenizer.chat_templates", "array[string]", "tokenizer", "names of the tokenizer.chat_template.{name} templates"},
+	{"tokenizer.huggingface.json", "string", "tokenizer", "complete Hugging Face tokenizer.json"},
+	{"tokenizer.rwkv.world", "string", "tokenizer", "RWKV world tokenizer vocabulary"},
+
+	{"split.no", "int", "split", "index of this shard, from 0"},
+	{"split.count", "int", "split", "number of shards"},
+	{"split.tensors.count", "int", "split", "tensors across all shards"},
+	{"split.tensors.hash", "string", "split", "digest of this shard's tensor data, \"algo:hex\""},
+
+	{"adapter.type", "string", "adapter", "adapter kind, e.g. \"lora\""},
+	{"adapter.lora.alpha", "float", "adapter", "LoRA alpha"},
+
+	{"quantize.imatrix.file", "string", "quantize", "importance matrix file the quantization used"},
+	{"quantize.imatrix.dataset", "string", "quantize", "calibration data the importance matrix was computed from"},
+	{"quantize.imatrix.entries_count", "int", "quantize", "tensors the importance matrix covers"},
+	{"quantize.imatrix.chunks_count", "int", "quantize", "calibration chunks the importance matrix was computed from"},
+
+	{signatureKey, "string", "ggufmeta", "Ed25519 signature written by ggufmeta sign"},
+	{stampTimeKey, "array[string]", "ggufmeta", "times of ggufmeta edits (--stamp)"},
+	{stampToolKey, "array[string]", "ggufmeta", "ggufmeta versions that edited the file (--stamp)"},
+	{stampHashKey, "array[string]", "ggufmeta", "SHA-256 of the file before each ggufmeta edit (--stamp)"},
+}
+
+// lookupKey returns the registry entry matching key in a file of
+// architecture arch; with arch "" any non-reserved namespace matches {arch}.
+func lookupKey(key, arch string) (keySpec, bool) {
+	segs := strings.Split(key, ".")
+	for _, spec := range knownKeys {
+		if matchKeyPattern(strings.Split(spec.Pattern, "."), segs, arch) {
+			return spec, true
+		}
+	}
+	return keySpec{}, false
+}
+
+func matchKeyPattern(pat, segs []string, arch string) bool {
+	if len(pat) != len(segs) {
+		return false
+	}
+	for i, p := range pat {
+		s := segs[i]
+		switch p {
+		case "{arch}":
+			if arch != "" && s != arch || arch == "" && reservedNamespaces[s] {
+				return false
+			}
+		case "{n}":
+			if s == "" || strings.Trim(s, "0123456789") != "" {
+				return false
+			}
+		case "{name}":
+			if s == "" {
+				return false
+			}
+		default:
+			if p != s {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// typeClass reduces a GGUF type name to the class the registry lists:
+// "int", "float", "bool", "string" or "array[class]".
+func typeClass(typ string) string {
+	if elem, ok := strings.CutPrefix(typ, "array["); ok {
+		return "array[" + typeClass(strings.TrimSuffix(elem, "]")) + "]"
+	}
+	switch {
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		return "int"
+	case strings.HasPrefix(typ, "float"):
+		return "float"
+	}
+	return typ
+}
+
+// annotateKV looks kv up in the registry for a file of architecture arch.
+func annotateKV(kv kvEvent, arch string) keyAnnotation {
+	for _, p := range freeNamespaces {
+		if strings.HasPrefix(kv.Key, p) {
+			return keyAnnotation{Owner: strings.TrimSuffix(p, "."), Description: "free-form user metadata"}
+		}
+	}
+	if d, ok := lookupDeprecated(kv.Key, arch); ok {
+		ns, _, _ := strings.Cut(kv.Key, ".")
+		return keyAnnotation{Owner: ns, Warning: deprecationMessage(d, kv.Key), Suggestion: replacementKey(d, kv.Key)}
+	}
+	spec, ok := lookupKey(kv.Key, arch)
+	if !ok {
+		a := keyAnnotation{Warning: "unknown key"}
+		ns, _, _ := strings.Cut(kv.Key, ".")
+		if arch != "" && !reservedNamespaces[ns] && ns != arch {
+			if _, ok := lookupKey(kv.Key, ns); ok {
+				a.Owner = ns
+				a.Warning = fmt.Sprintf("key of architecture %q in a %q file", ns, arch)
+				return a
+			}
+		}
+		if s := suggestKey(kv.Key, arch); s != "" {
+			a.Suggestion = s
+			a.Warning = fmt.Sprintf("unknown key; did you mean %q?", s)
+		}
+		return a
+	}
+	a := keyAnnotation{Owner: spec.Owner, Expected: spec.Type, Description: spec.Description}
+	if a.Owner == "{arch}" {
+		a.Owner, _, _ = strings.Cut(kv.Key, ".")
+	}
+	got := typeClass(kv.Type)
+	okType := false
+	for _, want := range strings.Split(spec.Type, "|") {
+		okType = okType || got == want
+	}
+	if !okType {
+		a.Warning = fmt.Sprintf("type %s, expected %s", kv.Type, spec.Type)
+	}
+	return a
+}
+
+// suggestKey returns the known key closest to an unknown one, or "" when
+// nothing is within a couple of edits.
+func suggestKey(key, arch string) string {
+	segs := strings.Split(key, ".")
+	best, bestDist := "", 3
+	for _, spec := range knownKeys {
+		pat := strings.Split(spec.Pattern, ".")
+		cand := make([]string, len(pat))
+		for i, p := range pat {
+			switch {
+			case p == "{arch}":
+				if arch == "" {
+					p = segs[0]
+				} else {
+					p = arch
+				}
+			case strings.HasPrefix(p, "{"):
+				p = "0"
+				if i < len(segs) {
+					p = segs[i]
+				}
+			}
+			cand[i] = p
+		}
+		c := strings.Join(cand, ".")
+		if d := editDistance(key, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b, in bytes.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
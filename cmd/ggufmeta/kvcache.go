@@ -0,0 +1,153 @@
+// Package main implements the `kv-cache` subcommand.
+// It estimates the memory llama.cpp allocates for the KV cache of a context:
+// for every layer, K and V rows of n_head_kv * head_dim values per token,
+// stored in the cache type's block layout.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// kvCacheReport is the document `ggufmeta kv-cache` prints.
+type kvCacheReport struct {
+	File        string `json:"file"`
+	Arch        string `json:"arch"`
+	Layers      uint64 `json:"layers"`
+	HeadCountKV uint64 `json:"headCountKV"` // per layer, or the largest when it varies
+	KeyLength   uint64 `json:"keyLength"`   // per head
+	ValueLength uint64 `json:"valueLength"` // per head
+	Context     uint64 `json:"context"`
+	Type        string `json:"type"`
+	Bytes       uint64 `json:"bytes"`
+	Human       string `json:"human"`
+}
+
+func runKVCache(args []string) error {
+	fs := flag.NewFlagSet("kv-cache", flag.ExitOnError)
+	ctxLen := fs.Uint64("ctx", 0, "context length in tokens, shared by all parallel sequences (default: the model's context_length)")
+	cacheType := fs.String("type", "f16", "cache type: f32, f16, bf16, q8_0, q5_1, q5_0, q4_1, q4_0 or iq4_nl")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta kv-cache [--ctx N] [--type f16|q8_0|...] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	typ, ok := ggmlTypeByName(*cacheType)
+	if !ok || ggmlBlocks[typ].Elems == 0 {
+		return fmt.Errorf("kv-cache: unknown cache type %q", *cacheType)
+	}
+
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""} // head_count_kv may be a per-layer array
+	gf, err := loadFile(context.Background(), fs.Arg(0), pol)
+	if err != nil {
+		return err
+	}
+	rep, err := estimateKVCache(gf, *ctxLen, typ)
+	if err != nil {
+		return fmt.Errorf("kv-cache: %w", err)
+	}
+	rep.Human = humanBytes(rep.Bytes)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// estimateKVCache sizes the cache of one sequence of ctx tokens (0 for the
+// model's context_length) in cache type typ.
+func estimateKVCache(gf *ggufFile, ctx uint64, typ uint32) (kvCacheReport, error) {
+	rep := kvCacheReport{File: gf.Path, Arch: gf.Arch(), Type: ggmlTypeName(typ)}
+	if rep.Arch == "" {
+		return rep, fmt.Errorf("general.architecture is missing")
+	}
+	var ok bool
+	if rep.Layers, ok = gf.Uint("{arch}.block_count"); !ok || rep.Layers == 0 {
+		return rep, fmt.Errorf("%s.block_count is missing", rep.Arch)
+	}
+	if ctx == 0 {
+		if ctx, ok = gf.Uint("{arch}.context_length"); !ok {
+			return rep, fmt.Errorf("%s.context_length is missing; pass --ctx", rep.Arch)
+		}
+	}
+	rep.Context = ctx
+
+	heads, err := perLayer(gf, "{arch}.attention.head_count", rep.Layers)
+	if err != nil {
+		return rep, err
+	}
+	headsKV, err := perLayer(gf, "{arch}.attention.head_count_kv", rep.Layers)
+	if err != nil {
+		headsKV = heads // no GQA: every head has its own K/V
+	}
+	embd, _ := gf.Uint("{arch}.embedding_length")
+	if heads[0] == 0 && embd == 0 {
+		return rep, fmt.Errorf("%s.attention.head_count is missing", rep.Arch)
+	}
+	rep.KeyLength, ok = gf.Uint("{arch}.attention.key_length")
+	if !ok && heads[0] > 0 {
+		rep.KeyLength = embd / heads[0]
+	}
+	if rep.ValueLength, ok = gf.Uint("{arch}.attention.value_length"); !ok {
+		rep.ValueLength = rep.KeyLength
+	}
+
+	blk := ggmlBlocks[typ]
+	for _, n := range headsKV {
+		rep.HeadCountKV = max(rep.HeadCountKV, n)
+		// K and V are separate tensors with rows of n * length values per token
+		for _, length := range []uint64{rep.KeyLength, rep.ValueLength} {
+			elems := n * length * ctx
+			rep.Bytes += (elems + blk.Elems - 1) / blk.Elems * blk.Bytes
+		}
+	}
+	return rep, nil
+}
+
+// perLayer reads an integer key that may be a scalar or one value per layer.
+func perLayer(gf *ggufFile, key string, layers uint64) ([]uint64, error) {
+	out := make([]uint64, layers)
+	if v, ok := gf.Uint(key); ok {
+		for i := range out {
+			out[i] = v
+		}
+		return out, nil
+	}
+	kv, _ := gf.Get(expandArch(gf, key))
+	items, ok := kv.Value.([]any)
+	if !ok || uint64(len(items)) != layers {
+		return nil, fmt.Errorf("%s is missing or not one value per layer", expandArch(gf, key))
+	}
+	for i, it := range items {
+		if out[i], ok = asUint64(it); !ok {
+			return nil, fmt.Errorf("%s[%d] is not an integer", expandArch(gf, key), i)
+		}
+	}
+	return out, nil
+}
+
+// expandArch substitutes gf's architecture for {arch} in key.
+func expandArch(gf *ggufFile, key string) string {
+	return strings.ReplaceAll(key, "{arch}", gf.Arch())
+}
+
+// humanBytes formats n with a binary unit, e.g. "1.50 GiB".
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,140 @@
+// Package main implements the `labels` subcommand.
+// It derives container image labels from metadata, so a model-serving image
+// built around a GGUF file describes the model it carries: the standard
+// org.opencontainers.image.* annotations plus ai.model.* labels for the
+// facts schedulers and inventories filter on.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// imageLabel is one label; labels are printed in the order they are built.
+type imageLabel struct {
+	Key, Value string
+}
+
+// ociLabelKeys maps OCI annotation names to the general.* keys they come from,
+// first present key wins.
+var ociLabelKeys = []struct {
+	label string
+	keys  []string
+}{
+	{"org.opencontainers.image.title", []string{"general.name", "general.basename"}},
+	{"org.opencontainers.image.description", []string{"general.description"}},
+	{"org.opencontainers.image.version", []string{"general.version"}},
+	{"org.opencontainers.image.authors", []string{"general.author"}},
+	{"org.opencontainers.image.vendor", []string{"general.organization"}},
+	{"org.opencontainers.image.licenses", []string{"general.license"}},
+	{"org.opencontainers.image.url", []string{"general.url", "general.repo_url"}},
+	{"org.opencontainers.image.source", []string{"general.source.url", "general.source.repo_url"}},
+	{"org.opencontainers.image.documentation", []string{"general.doi"}},
+}
+
+// imageLabels builds the labels for gf; empty values are left out.
+func imageLabels(gf *ggufFile) []imageLabel {
+	var labels []imageLabel
+	add := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			labels = append(labels, imageLabel{key, value})
+		}
+	}
+	for _, l := range ociLabelKeys {
+		for _, key := range l.keys {
+			if v, err := gf.GetString(key); err == nil && v != "" {
+				add(l.label, v)
+				break
+			}
+		}
+	}
+	add("ai.model.format", "gguf")
+	add("ai.model.gguf.version", strconv.FormatUint(uint64(gf.Header.GGUF.Version), 10))
+	add("ai.model.architecture", gf.Arch())
+	if n := paramCount(gf); n > 0 {
+		add("ai.model.parameters", strconv.FormatUint(n, 10))
+	}
+	size, _ := gf.GetString("general.size_label")
+	if size == "" {
+		size = sizeLabel(gf)
+	}
+	add("ai.model.size_label", size)
+	add("ai.model.quantization", quantLabel(gf))
+	if ctx, ok := gf.Uint("{arch}.context_length"); ok {
+		add("ai.model.context_length", strconv.FormatUint(ctx, 10))
+	}
+	if base := cardRepos(gf, "general.base_model"); len(base) > 0 {
+		add("ai.model.base_model", strings.Join(base, ","))
+	}
+	if _, err := gf.GetString("tokenizer.chat_template"); err == nil {
+		add("ai.model.chat_template", "true")
+	}
+	add("ai.model.fingerprint", fingerprintOf(gf))
+	return labels
+}
+
+func runLabels(args []string) error {
+	fs := flag.NewFlagSet("labels", flag.ExitOnError)
+	format := fs.String("format", "lines", "'lines' (key=value), 'dockerfile' (one LABEL instruction) or 'json'")
+	prefix := fs.String("prefix", "ai.model.", "namespace for the model labels, e.g. com.example.model.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta labels [--format lines|dockerfile|json] [--prefix P] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	gf, err := loadFile(context.Background(), rest[0], fingerprintPolicy())
+	if err != nil {
+		return err
+	}
+	labels := imageLabels(gf)
+	for i, l := range labels {
+		if rest, ok := strings.CutPrefix(l.Key, "ai.model."); ok {
+			labels[i].Key = *prefix + rest
+		}
+		// Label values are single-line
+		labels[i].Value = strings.Join(strings.Fields(l.Value), " ")
+	}
+
+	switch *format {
+	case "lines":
+		for _, l := range labels {
+			fmt.Printf("%s=%s\n", l.Key, l.Value)
+		}
+	case "dockerfile":
+		fmt.Print("LABEL")
+		for i, l := range labels {
+			sep := " \\\n"
+			if i == len(labels)-1 {
+				sep = "\n"
+			}
+			fmt.Printf(" %s=%s%s", l.Key, dockerQuote(l.Value), sep)
+		}
+	case "json":
+		m := make(map[string]string, len(labels))
+		for _, l := range labels {
+			m[l.Key] = l.Value
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	default:
+		return fmt.Errorf("labels: unknown format %q (want lines, dockerfile or json)", *format)
+	}
+	return nil
+}
+
+// dockerQuote double-quotes s for a Dockerfile, where $ would otherwise
+// start a variable substitution.
+func dockerQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
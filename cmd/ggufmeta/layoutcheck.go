@@ -0,0 +1,67 @@
+// Package main implements the tensor layout validation check.
+// Walking the tensors in data order, each one must start at or after the
+// aligned end of the previous one and stay inside the file. Overlaps and
+// out-of-bounds tensors are errors; gaps and trailing bytes waste space and
+// usually mean the converter computed a size differently than loaders do.
+package main
+
+import "sort"
+
+func init() {
+	registerCheck("layout", checkTensorLayout)
+}
+
+func checkTensorLayout(v *validation) {
+	gf := v.gf
+	align := gf.Alignment()
+	sorted := make([]tensorInfo, len(gf.Tensors))
+	copy(sorted, gf.Tensors)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Offset < sorted[j].Offset })
+
+	var prev *tensorInfo
+	var prevEnd uint64 // relative to the data section, unaligned
+	// A truncated file puts every later tensor out of bounds; report it once
+	var outside int
+	var firstOutside string
+	var maxEnd uint64
+	for i := range sorted {
+		t := &sorted[i]
+		if t.Offset%align != 0 {
+			v.report("error", "", t.Name, "offset %d is not a multiple of the alignment %d", t.Offset, align)
+		}
+		size, known := ggmlTensorSize(t.Type, t.Dims)
+		if prev != nil {
+			switch want := alignUp(prevEnd, align); {
+			case t.Offset < prevEnd:
+				v.report("error", "", t.Name, "data [%d, %d) overlaps %s, which ends at %d", t.Offset, t.Offset+size, prev.Name, prevEnd)
+			case t.Offset > want:
+				v.report("warning", "", t.Name, "starts at %d, leaving a gap of %d bytes after %s (aligned end %d)", t.Offset, t.Offset-want, prev.Name, want)
+			}
+		}
+		if !known {
+			// Unknown layout: the size cannot be checked, and nothing after
+			// it can be measured against its end
+			v.report("info", "", t.Name, "type %s has no known block layout; its size is not checked", ggmlTypeName(t.Type))
+			prev, prevEnd = nil, 0
+			continue
+		}
+		if end := gf.DataOffset + t.Offset + size; end > gf.Size {
+			if outside == 0 {
+				firstOutside = t.Name
+			}
+			outside++
+			maxEnd = max(maxEnd, end)
+		}
+		if prev == nil || t.Offset+size > prevEnd {
+			prev, prevEnd = t, t.Offset+size
+		}
+	}
+	if outside > 0 {
+		v.report("error", "", firstOutside, "%d tensors, starting with this one, extend past the end of the file; it is %d bytes short", outside, maxEnd-gf.Size)
+	}
+	if prev != nil && outside == 0 {
+		if end := gf.DataOffset + alignUp(prevEnd, align); end < gf.Size {
+			v.report("warning", "", "", "%d bytes after the last tensor (%s) are not part of any tensor", gf.Size-end, prev.Name)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Package main implements lenient handling of unknown value types.
+// A tag this tool does not know has no known size, so strict parsing must stop.
+// With --lenient the parser instead scans ahead for the next plausible KV pair
+// (or tensor info), skips to it and reports the unknown value as a placeholder,
+// so files written by newer tooling still mostly parse.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// resyncWindow bounds how far ahead a resync may look; streamed input is
+// buffered by this much in lenient mode.
+const resyncWindow = 1 << 20
+
+// maxResyncName bounds the key or tensor name length a resync candidate may have.
+const maxResyncName = 1024
+
+// skipUnknown recovers from an unknown value type in key's value by skipping
+// to the next entry. tag is the value's own tag (tArray for unknown elements).
+func (p *parser) skipUnknown(key string, tag uint32) (any, string, error) {
+	typ := typeLabel(tag, "")
+	if tag == tArray {
+		typ = "array[unknown]"
+	}
+	placeholder := map[string]any{"_placeholder": "unknown_type", "tag": tag}
+
+	if p.kvRemain == 1 && p.tnRemain == 0 {
+		// Nothing follows that could mark where the value ends
+		logger.Warn("unknown value type at end of metadata", "key", key, "tag", tag, "pos", p.scn.pos)
+		return placeholder, typ, nil
+	}
+	next := p.looksLikeKV
+	if p.kvRemain == 1 {
+		next = p.looksLikeTensorInfo
+	}
+	window, err := p.scn.peek(resyncWindow)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w %d: cannot look ahead: %v", errUnknownType, tag, err)
+	}
+	for i := range window {
+		if !next(window[i:]) {
+			continue
+		}
+		if _, err := p.scn.readExact(i); err != nil {
+			return nil, "", err
+		}
+		placeholder["skipped"] = uint64(i)
+		logger.Warn("skipped value of unknown type", "key", key, "tag", tag, "bytes", i, "pos", p.scn.pos)
+		return placeholder, typ, nil
+	}
+	return nil, "", fmt.Errorf("%w %d: no plausible next entry within %d bytes", errUnknownType, tag, len(window))
+}
+
+// looksLikeKV reports whether b starts with a printable key followed by a known tag.
+func (p *parser) looksLikeKV(b []byte) bool {
+	rest, ok := p.printableString(b)
+	if !ok || len(rest) < 4 {
+		return false
+	}
+	_, known := valueTypes[p.scn.order.Uint32(rest)]
+	return known
+}
+
+// looksLikeTensorInfo reports whether b starts with a printable tensor name
+// followed by a sane dimension count.
+func (p *parser) looksLikeTensorInfo(b []byte) bool {
+	rest, ok := p.printableString(b)
+	if !ok || len(rest) < 4 {
+		return false
+	}
+	nd := p.scn.order.Uint32(rest)
+	return nd >= 1 && nd <= maxTensorDims
+}
+
+// printableString checks for a length-prefixed string of printable ASCII at
+// the start of b and returns the bytes after it.
+func (p *parser) printableString(b []byte) ([]byte, bool) {
+	if len(b) < 8 {
+		return nil, false
+	}
+	n := p.scn.order.Uint64(b)
+	if n == 0 || n > maxResyncName || n > uint64(len(b)-8) {
+		return nil, false
+	}
+	if !printable(b[8 : 8+n]) {
+		return nil, false
+	}
+	return b[8+n:], true
+}
+
+// printable reports whether b is non-empty visible ASCII, as keys and tensor names are.
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// isUnknownType reports whether err stems from an unknown value type.
+func isUnknownType(err error) bool { return errors.Is(err, errUnknownType) }
@@ -0,0 +1,60 @@
+// Package main implements diagnostic logging to stderr.
+// Diagnostics go through a package-level slog.Logger so they never mix with the
+// records on stdout; -v/-vv raise the level and --log-format json makes the
+// lines machine-readable for log collectors. Fatal errors still use package log.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// logLevel is shared by every handler setupLogging installs; Warn by default.
+var logLevel = func() *slog.LevelVar {
+	v := new(slog.LevelVar)
+	v.Set(slog.LevelWarn)
+	return v
+}()
+
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
+// setupLogging sets the level from a verbosity count (0 warn, 1 info, 2+ debug)
+// and the line format ("text" or "json").
+func setupLogging(verbosity int, format string) error {
+	switch {
+	case verbosity >= 2:
+		logLevel.Set(slog.LevelDebug)
+	case verbosity == 1:
+		logLevel.Set(slog.LevelInfo)
+	default:
+		logLevel.Set(slog.LevelWarn)
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	switch format {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return fmt.Errorf("--log-format: unknown format %q (want 'text' or 'json')", format)
+	}
+	return nil
+}
+
+// envVerbosity maps GGUF_META_DEBUG to a verbosity count; it is the starting
+// point for subcommands, which do not take -v themselves.
+func envVerbosity() int {
+	if envBool("GGUF_META_DEBUG", false) {
+		return 2
+	}
+	return 0
+}
+
+// envLogFormat returns GGUF_META_LOG_FORMAT, defaulting to "text".
+func envLogFormat() string {
+	if v := os.Getenv("GGUF_META_LOG_FORMAT"); v != "" {
+		return v
+	}
+	return "text"
+}
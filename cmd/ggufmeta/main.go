@@ -1,14 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func envUint64(name string, def uint64) uint64 {
@@ -31,6 +35,15 @@ func envBool(name string, def bool) bool {
 	return def
 }
 
+func envDuration(name string, def time.Duration) time.Duration {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return def
+}
+
 func safeCapFromCount(n uint64) int {
 	const maxInt = int(^uint(0) >> 1)
 	if n > uint64(maxInt) {
@@ -39,128 +52,681 @@ func safeCapFromCount(n uint64) int {
 	return int(n)
 }
 
+// subcommands maps a leading positional word (e.g. `ggufmeta schema`) to its handler.
+// Anything not listed here falls through to the default metadata dump.
+var subcommands = map[string]func(args []string) error{
+	"schema":           runSchema,
+	"diagram":          runDiagram,
+	"quirks":           runQuirks,
+	"repair":           runRepair,
+	"normalize":        runNormalize,
+	"validate":         runValidate,
+	"sign":             runSign,
+	"verify":           runVerify,
+	"user":             runUser,
+	"diff":             runDiff,
+	"merge":            runMerge,
+	"patch":            runPatch,
+	"sql":              runSQL,
+	"tensors":          runTensors,
+	"padding":          runPadding,
+	"kv-cache":         runKVCache,
+	"requant-estimate": runRequantEstimate,
+	"compat":           runCompat,
+	"ollama":           runOllama,
+	"fingerprint":      runFingerprint,
+	"index":            runIndex,
+	"search":           runSearch,
+	"suggest-name":     runSuggestName,
+	"card":             runCard,
+	"import-card":      runImportCard,
+	"matrix":           runMatrix,
+	"daemon":           runDaemon,
+	"verify-server":    runVerifyServer,
+	"labels":           runLabels,
+	"fetch-meta":       runFetchMeta,
+	"mcp":              runMCP,
+	"summary":          runSummary,
+	"moe":              runMoE,
+	"quant-provenance": runQuantProvenance,
+	"selftest":         runSelftest,
+	"explain":          runExplain,
+}
+
+// jsMain is set by the js/wasm build, where there is no command line: it
+// serves the JavaScript bindings instead (see wasm_js.go).
+var jsMain func()
+
 func main() {
 	log.SetFlags(0)
+	if err := setupLogging(envVerbosity(), envLogFormat()); err != nil {
+		log.Fatal(err)
+	}
+	if jsMain != nil {
+		jsMain()
+		return
+	}
+
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
 
 	var (
 		keys         string
+		keysCI       bool
 		maxArray     uint64
 		maxString    uint64
 		debug        bool
+		verbose      bool
+		veryVerbose  bool
+		logFormat    string
 		tensors      bool
 		tokens       bool
 		expandArrays string
+		canonical    bool
+		output       string
+		splitDir     string
+		splitBy      string
+		format       string
+		execCmd      string
+		getKey       string
+		trace        bool
+		lenient      bool
+		truncStrings bool
+		noAutoExpand bool
+		stringsDir   string
+		arrayDigest  string
+		grep         string
+		annotate     bool
+		warnings     bool
+		flushEach    bool
+		emitIndex    string
+		rawValues    bool
+		escape       string
+		outputDir    string
+		outputTmpl   string
+		stdio        bool
+		verify       bool
+		expandRanges = make(expandRangeFlag)
+		expandTypes  = make(expandTypeFlag)
 	)
 
-	flag.StringVar(&keys, "keys", "", "show only KV pairs with keys matching this prefix (e.g., 'tokenizer.' for tokenizer.*, 'general.' for model info)")
+	flag.StringVar(&getKey, "get", "", "print only the record for KEY, seeking to it through a key index (arrays expanded)")
+	flag.StringVar(&keys, "keys", "", "show only KV pairs with keys matching one of these comma-separated prefixes or globs (e.g., 'tokenizer.' for tokenizer.*, 'general.,llama.' for both, '*.attention.*')")
+	flag.BoolVar(&rawValues, "raw-values", false, "print only values, one per line, with strings unquoted and unescaped")
+	flag.BoolVar(&rawValues, "r", false, "shorthand for --raw-values")
+	flag.StringVar(&escape, "escape", "", "write invisible and control characters in the output as \\uXXXX: 'controls', or 'ascii' for everything outside printable ASCII")
+	flag.BoolVar(&keysCI, "keys-ci", false, "match --keys case-insensitively, ignoring surrounding whitespace in keys")
 	flag.Uint64Var(&maxArray, "max-array", envUint64("GGUF_META_MAX_ARRAY", 32), "threshold for large arrays - show placeholder instead of full content")
+	flag.BoolVar(&noAutoExpand, "no-auto-expand", false, "show every array not named by --expand-arrays or --expand-type as a placeholder, however short")
+	flag.Var(expandTypes, "expand-type", "expand arrays of element type or class TYPE (uint32, string, float, ...) up to N elements, overriding --max-array; 0 never; comma-separated or repeated `TYPE:N`")
 	flag.Uint64Var(&maxString, "max-string", envUint64("GGUF_META_MAX_STRING", 131072), "maximum string length (bytes)")
-	flag.BoolVar(&debug, "debug", envBool("GGUF_META_DEBUG", false), "print debug info to stderr")
+	flag.BoolVar(&debug, "debug", envBool("GGUF_META_DEBUG", false), "print debug info to stderr (same as -vv)")
+	flag.BoolVar(&verbose, "v", false, "log progress to stderr")
+	flag.BoolVar(&veryVerbose, "vv", false, "log per-field parse details to stderr")
+	flag.StringVar(&logFormat, "log-format", envLogFormat(), "stderr log format: 'text' or 'json'")
 	flag.BoolVar(&tensors, "tensors", false, "include tensor-related KV pairs (*.weight, *.bias, etc.)")
 	flag.BoolVar(&tokens, "tokens", false, "include tokenizer KV pairs (tokenizer.*)")
 	flag.StringVar(&expandArrays, "expand-arrays", "", "comma-separated list of array keys to expand (e.g., 'general.special_tokens,tokenizer.ggml.added_tokens')")
+	flag.Var(expandRanges, "expand-range", "expand only a slice of one array, given as `KEY=START:END` (END exclusive); repeatable")
+	flag.StringVar(&format, "format", "ndjson", "output format: "+strings.Join(formatNames(), ", "))
+	flag.BoolVar(&canonical, "canonical", false, "emit RFC 8785 (JCS) canonical JSON: sorted keys, normalized numbers")
+	flag.StringVar(&output, "output", "", "write records to FILE atomically (temp file + rename); gzip if FILE ends in .gz")
+	flag.StringVar(&outputDir, "output-dir", "", "write each input file's records to its own file in DIR, named by --output-template")
+	flag.StringVar(&outputTmpl, "output-template", defaultOutputTemplate, "file name for --output-dir, with {stem}, {name}, {arch}, {quant}, {size_label} and {ext} replaced per file")
+	flag.StringVar(&splitDir, "split-output", "", "write each record into its own file under DIR instead of stdout")
+	flag.StringVar(&execCmd, "exec", "", "run shell command CMD once per record, with the record as a JSON line on stdin")
+	flag.BoolVar(&lenient, "lenient", envBool("GGUF_META_LENIENT", false), "skip values of unknown type by resyncing on the next entry instead of failing")
+	flag.BoolVar(&truncStrings, "truncate-strings", false, "cut string values longer than --max-string to a truncated_string placeholder instead of failing")
+	flag.StringVar(&stringsDir, "strings-to-dir", "", "copy string values longer than --max-string into one file per key under `DIR`, leaving a string_file placeholder")
+	flag.StringVar(&arrayDigest, "array-digest", "", "add a digest of the skipped contents to array placeholders, using `ALGO` (md5, sha1, sha256 or sha512)")
+	flag.StringVar(&emitIndex, "emit-index", "", "also write a JSON index of every key's byte offset and encoded length to FILE")
+	flag.BoolVar(&stdio, "stdio", false, "serve JSON-RPC (open, query, close) on stdin/stdout for editor extensions instead of dumping a file")
+	flag.BoolVar(&verify, "verify", false, "parse the input twice, as a stream and with positioned reads, and fail unless both agree")
+	flag.BoolVar(&flushEach, "flush", false, "flush stdout after every record, for live consumers of slow sources")
+	flag.IntVar(&remoteOpts.retries, "retries", remoteOpts.retries, "retry failed remote requests and interrupted transfers up to N times, with exponential backoff (or $GGUF_META_RETRIES)")
+	flag.StringVar(&remoteOpts.bearer, "bearer", remoteOpts.bearer, "send TOKEN as a bearer token with remote requests (or $GGUF_META_BEARER_TOKEN)")
+	flag.StringVar(&remoteOpts.user, "user", remoteOpts.user, "basic auth `USER:PASSWORD` for remote requests (or $GGUF_META_USER)")
+	flag.Var(headerFlag{remoteOpts.headers}, "header", "add `\"Name: value\"` to remote requests; repeatable (or $GGUF_META_HEADERS, one per line)")
+	flag.BoolVar(&remoteOpts.offline, "offline", remoteOpts.offline, "read remote inputs only from the metadata cache, never the network (or GGUF_META_OFFLINE=1)")
+	flag.DurationVar(&remoteOpts.connectTimeout, "connect-timeout", remoteOpts.connectTimeout, "give up connecting to a remote host after this long (or $GGUF_META_CONNECT_TIMEOUT)")
+	flag.BoolVar(&trace, "trace", false, "interleave trace records giving the byte range of every header field, key, tag and value")
+	flag.StringVar(&grep, "grep", "", "print only values matching regexp PATTERN, searching inside every array")
+	flag.BoolVar(&annotate, "annotate", false, "attach the key registry's description to each record and flag unknown, misspelled or mistyped keys")
+	flag.BoolVar(&warnings, "warnings", false, "log deprecated keys, with their current spelling, and tokenizer array length mismatches to stderr")
+	flag.StringVar(&splitBy, "split-by", "key", "how --split-output groups records: 'key' (one file per key) or 'namespace' (one file per top-level prefix)")
 
-	// NEW: let us flip the critical alignment rule at runtime
-	flag.BoolVar(&alignBeforeValue, "align-before-value", false, "align to 8 before reading each value payload")
+	// Start in the padded-value layout; probeAlignment still corrects it per value
+	flag.BoolVar(&alignBeforeValue, "align-before-value", false, "assume values are padded to 8 bytes after their tag (normally auto-detected)")
 
 	flag.Parse()
 
-	if flag.NArg() != 1 {
+	if !stdio && flag.NArg() != 1 && (outputDir == "" || flag.NArg() == 0) {
 		fmt.Fprintf(os.Stderr, "usage: %s [options] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s --output-dir DIR [--output-template T] [options] file.gguf...\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s --stdio [options]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s schema\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s diagram [--format mermaid|dot] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s quirks file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s repair [--dry-run] [--stamp] in.gguf out.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s normalize [--stamp] in.gguf out.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s validate [--json] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s sign --key NAME.key [--stamp] in.gguf out.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s verify --pub NAME.pub file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s user list|set|clear [--stamp] file.gguf [KEY=VALUE...]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s diff [--format ndjson|text|json-patch] a.gguf b.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s merge --base base.gguf --ours a.gguf --theirs b.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s patch --apply changes.json [-o out.gguf] [--stamp] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s sql QUERY file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s tensors [--sort size|name|offset] [--top N] [--hash ALGO [--jobs N]] [--format ndjson|arrow] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s padding file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s kv-cache [--ctx N] [--type f16|q8_0|...] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s requant-estimate --target Q4_K_M file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s compat [--runtime llama.cpp@b4500] [--rules FILE] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s ollama [--name NAME] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s fingerprint [--json | --group] file.gguf...\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s index [-o catalog.json] [--no-hash] [--stats] DIR\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s search [--catalog FILE] [--arch A] [--quant Q] [--modality M] [--like FILE] [--json] [--stats] [WORD...]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s suggest-name [--rename] file.gguf...\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s card [-o README.md] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s import-card [-o out.gguf] [--dry-run] [--stamp] README.md file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s matrix --keys KEY,KEY... file.gguf...\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s daemon --socket PATH DIR\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s verify-server [--url URL] [--api-key KEY] [--json] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s labels [--format lines|dockerfile|json] [--prefix P] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s fetch-meta -o model.meta.gguf URL|file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s mcp [--allow-remote] [DIR...]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s summary [--json] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s moe [--json] file.gguf\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s quant-provenance [--json] file.gguf...\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s selftest [--runs N] [--seed N]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "       %s explain [--json] KEY...\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "\nExtract GGUF metadata as NDJSON. By default, shows all keys with array placeholders.\n")
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
-		fmt.Fprintf(os.Stderr, "  --keys PREFIX        show only keys with this prefix (e.g., 'tokenizer.', 'general.')\n")
+		fmt.Fprintf(os.Stderr, "  --get KEY            print only KEY's record (arrays expanded)\n")
+		fmt.Fprintf(os.Stderr, "  -r, --raw-values     print only values, one per line; strings unquoted and unescaped\n")
+		fmt.Fprintf(os.Stderr, "  --escape MODE        show control, bidi and zero-width characters as \\uXXXX ('controls'), or all non-ASCII ('ascii')\n")
+		fmt.Fprintf(os.Stderr, "  --keys PREFIXES      show only keys with one of these comma-separated prefixes or globs (e.g., 'general.,llama.', '*.attention.*')\n")
+		fmt.Fprintf(os.Stderr, "  --keys-ci            match --keys ignoring case and surrounding whitespace\n")
+		fmt.Fprintf(os.Stderr, "  --grep PATTERN       print a match record per value or array element matching PATTERN\n")
+		fmt.Fprintf(os.Stderr, "  --annotate           add an annotation (owner, expected type, description, warning) to each record\n")
+		fmt.Fprintf(os.Stderr, "  --warnings           log deprecated keys and tokenizer array length mismatches to stderr\n")
 		fmt.Fprintf(os.Stderr, "  --tokens             (legacy flag, no effect - arrays show as placeholders by default)\n")
 		fmt.Fprintf(os.Stderr, "  --tensors            (legacy flag, no effect - arrays show as placeholders by default)\n")
-		fmt.Fprintf(os.Stderr, "  --max-array N        threshold for large arrays - show placeholder (default: 32)\n")
+		fmt.Fprintf(os.Stderr, "  --max-array N        arrays up to N elements are expanded, longer ones show a placeholder (default: 32)\n")
+		fmt.Fprintf(os.Stderr, "  --no-auto-expand     show a placeholder for every array not named by --expand-arrays or --expand-type\n")
+		fmt.Fprintf(os.Stderr, "  --expand-type T:N    expand arrays of type or class T (string, float32, int, ...) up to N elements; 0 never (repeatable)\n")
 		fmt.Fprintf(os.Stderr, "  --max-string BYTES   maximum string length in bytes (default: 131072)\n")
+		fmt.Fprintf(os.Stderr, "  --truncate-strings   cut longer strings to --max-string bytes and mark them truncated, instead of failing\n")
+		fmt.Fprintf(os.Stderr, "  --strings-to-dir DIR copy longer strings to one file per key under DIR instead of failing\n")
+		fmt.Fprintf(os.Stderr, "  --array-digest ALGO  add an ALGO:hex digest of each skipped array to its placeholder (md5, sha1, sha256, sha512)\n")
 		fmt.Fprintf(os.Stderr, "  --expand-arrays LIST comma-separated array keys to expand fully (overrides size limits)\n")
-		fmt.Fprintf(os.Stderr, "  --debug              print debug info to stderr\n")
-		fmt.Fprintf(os.Stderr, "  --align-before-value experimental alignment toggle\n")
+		fmt.Fprintf(os.Stderr, "  --expand-range KEY=A:B expand only elements A to B-1 of array KEY; A or B may be left out (repeatable)\n")
+		fmt.Fprintf(os.Stderr, "  --format FORMAT      output format (default: ndjson), one of:\n")
+		for _, name := range formatNames() {
+			fmt.Fprintf(os.Stderr, "      %-16s %s\n", name, outputFormats[name].Description)
+		}
+		fmt.Fprintf(os.Stderr, "  --canonical          emit RFC 8785 canonical JSON (sorted keys, normalized numbers)\n")
+		fmt.Fprintf(os.Stderr, "  --output FILE        write atomically to FILE instead of stdout (.gz suffix compresses)\n")
+		fmt.Fprintf(os.Stderr, "  --output-dir DIR     with several files, write each one's records to DIR/--output-template\n")
+		fmt.Fprintf(os.Stderr, "  --output-template T  file name for --output-dir (default {stem}.{ext}); also {name}, {arch}, {quant}, {size_label}\n")
+		fmt.Fprintf(os.Stderr, "  --split-output DIR   write one file per key into DIR (see --split-by)\n")
+		fmt.Fprintf(os.Stderr, "  --split-by MODE      'key' (default) or 'namespace' for one file per top-level prefix\n")
+		fmt.Fprintf(os.Stderr, "  --exec CMD           run CMD via the shell once per record, record JSON on stdin\n")
+		fmt.Fprintf(os.Stderr, "  --lenient            skip values of unknown type instead of failing (heuristic resync)\n")
+		fmt.Fprintf(os.Stderr, "  --trace              add trace records with the byte range of every field (ndjson only)\n")
+		fmt.Fprintf(os.Stderr, "  --verify             parse twice (streaming and positioned reads) and fail unless the results match\n")
+		fmt.Fprintf(os.Stderr, "  --flush              flush stdout after every record instead of in 64 KiB blocks (ndjson only)\n")
+		fmt.Fprintf(os.Stderr, "  --stdio              serve JSON-RPC open/query/close requests on stdin/stdout (editor extensions)\n")
+		fmt.Fprintf(os.Stderr, "  --emit-index FILE    write each key's absolute byte offset and encoded length to FILE as JSON\n")
+		fmt.Fprintf(os.Stderr, "  --retries N          retry failed remote requests and resume cut-off transfers N times (default: 3)\n")
+		fmt.Fprintf(os.Stderr, "  --connect-timeout D  connection timeout for remote inputs, e.g. 5s (default: 10s)\n")
+		fmt.Fprintf(os.Stderr, "  --bearer TOKEN       send an Authorization: Bearer header with remote requests\n")
+		fmt.Fprintf(os.Stderr, "  --user USER:PASS     use basic auth for remote requests\n")
+		fmt.Fprintf(os.Stderr, "  --header 'N: V'      add a header to remote requests (repeatable)\n")
+		fmt.Fprintf(os.Stderr, "  --offline            serve remote inputs from the metadata cache only; fail if not cached\n")
+		fmt.Fprintf(os.Stderr, "  -v, -vv              log progress (-v) or per-field parse details (-vv) to stderr\n")
+		fmt.Fprintf(os.Stderr, "  --log-format FORMAT  stderr log format: 'text' (default) or 'json'\n")
+		fmt.Fprintf(os.Stderr, "  --debug              same as -vv\n")
+		fmt.Fprintf(os.Stderr, "  --align-before-value assume 8-byte padding before values (normally auto-detected)\n")
+		fmt.Fprintf(os.Stderr, "\nCommands:\n")
+		fmt.Fprintf(os.Stderr, "  schema               print the JSON Schema of the NDJSON output, tensor and finding records\n")
+		fmt.Fprintf(os.Stderr, "  diagram              draw an embedding -> blocks -> head diagram (Mermaid or DOT)\n")
+		fmt.Fprintf(os.Stderr, "  quirks               report deviations from the GGUF spec as JSON\n")
+		fmt.Fprintf(os.Stderr, "  repair               fix off-by-one string lengths, KV count and split counts\n")
+		fmt.Fprintf(os.Stderr, "  normalize            rewrite metadata in canonical key order and alignment\n")
+		fmt.Fprintf(os.Stderr, "  validate             run consistency checks (embedded checksums, ...) and exit 1 on errors\n")
+		fmt.Fprintf(os.Stderr, "  sign                 store an Ed25519 signature over the metadata\n")
+		fmt.Fprintf(os.Stderr, "  verify               check the signature stored by sign\n")
+		fmt.Fprintf(os.Stderr, "  user                 list, set or clear user.* and x.* keys\n")
+		fmt.Fprintf(os.Stderr, "  diff                 show metadata and tensor changes between two files\n")
+		fmt.Fprintf(os.Stderr, "  merge                three-way merge of metadata as NDJSON with conflict records\n")
+		fmt.Fprintf(os.Stderr, "  patch                apply an RFC 6902 JSON Patch to the metadata\n")
+		fmt.Fprintf(os.Stderr, "  sql                  query the kv and tensors tables with a SQL SELECT subset\n")
+		fmt.Fprintf(os.Stderr, "  tensors              list tensors with payload size and share of the file\n")
+		fmt.Fprintf(os.Stderr, "  padding              report bytes lost to alignment padding, and at other alignments\n")
+		fmt.Fprintf(os.Stderr, "  kv-cache             estimate KV cache memory for a context length\n")
+		fmt.Fprintf(os.Stderr, "  requant-estimate     predict the file size after requantizing to a target type\n")
+		fmt.Fprintf(os.Stderr, "  compat               check whether a runtime build will load the file\n")
+		fmt.Fprintf(os.Stderr, "  ollama               preflight an Ollama import and print the ollama create steps\n")
+		fmt.Fprintf(os.Stderr, "  fingerprint          print a shape fingerprint shared by quants of one base model\n")
+		fmt.Fprintf(os.Stderr, "  index                build a searchable catalog of the GGUF files under DIR\n")
+		fmt.Fprintf(os.Stderr, "  search               query a catalog built by index\n")
+		fmt.Fprintf(os.Stderr, "  suggest-name         print (or apply) the conventional file name built from metadata\n")
+		fmt.Fprintf(os.Stderr, "  card                 write a Hugging Face model card with YAML frontmatter\n")
+		fmt.Fprintf(os.Stderr, "  import-card          copy license, base_model, datasets, language and tags from a model card\n")
+		fmt.Fprintf(os.Stderr, "  matrix               CSV of selected keys, one row per file\n")
+		fmt.Fprintf(os.Stderr, "  daemon               answer metadata queries for a directory over a Unix socket\n")
+		fmt.Fprintf(os.Stderr, "  verify-server        check a running llama.cpp server's loaded model against the file\n")
+		fmt.Fprintf(os.Stderr, "  labels               print OCI image labels derived from metadata\n")
+		fmt.Fprintf(os.Stderr, "  fetch-meta           save header, KVs and tensor infos as a metadata-only GGUF stub\n")
+		fmt.Fprintf(os.Stderr, "  mcp                  Model Context Protocol server on stdio for coding agents\n")
+		fmt.Fprintf(os.Stderr, "  summary              hyperparameters tailored to the architecture, with readable labels\n")
+		fmt.Fprintf(os.Stderr, "  moe                  expert configuration and active vs total parameters of a MoE model\n")
+		fmt.Fprintf(os.Stderr, "  quant-provenance     quantization preset, quantizer and imatrix calibration data\n")
+		fmt.Fprintf(os.Stderr, "  selftest             write random files with the writer, parse them back and compare\n")
+		fmt.Fprintf(os.Stderr, "  explain              describe a key: type, meaning, typical values and which runtimes read it\n")
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s model.gguf                              # show all metadata with array placeholders\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  %s --expand-arrays tokenizer.ggml.tokens   # expand specific arrays fully\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  %s --keys general. model.gguf              # show only general.* keys\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --keys '*.attention.*' model.gguf       # glob: keys with an attention segment\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --output meta.ndjson.gz model.gguf      # gzip into a file, published only on success\n", filepath.Base(os.Args[0]))
 		os.Exit(2)
 	}
 
-	path := flag.Arg(0)
-	f, err := os.Open(path)
-	if err != nil {
+	verbosity := 0
+	switch {
+	case debug || veryVerbose:
+		verbosity = 2
+	case verbose:
+		verbosity = 1
+	}
+	if err := setupLogging(verbosity, logFormat); err != nil {
 		log.Fatal(err)
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			log.Printf("failed to close file: %v", err)
+	if stdio {
+		opts := embedOptions{MaxArray: &maxArray, MaxString: &maxString, Lenient: lenient, Truncate: truncStrings, NoAutoExpand: noAutoExpand, ExpandTypes: expandTypes}
+		if expandArrays != "" {
+			opts.ExpandArrays = strings.Split(expandArrays, ",")
 		}
-	}()
-
-	var fsize uint64
-	if st, err := f.Stat(); err == nil && st.Mode().IsRegular() {
-		fsize = uint64(st.Size())
+		if err := serveStdio(opts.policy()); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
+	// dumpFile streams one file's records to output, or to stdout when output is empty
+	dumpFile := func(path, output string) {
+		start := time.Now()
 
-	// Parse expand-arrays parameter
-	expandMap := make(map[string]bool)
-	var expandPrefixes []string
-	if expandArrays != "" {
-		for _, pattern := range strings.Split(expandArrays, ",") {
-			pattern = strings.TrimSpace(pattern)
-			if strings.HasSuffix(pattern, "*") {
-				// Pattern like "tokenizer.*" - treat as prefix
-				expandPrefixes = append(expandPrefixes, strings.TrimSuffix(pattern, "*"))
-			} else {
-				// Exact key match
-				expandMap[pattern] = true
+		f, fsize, err := openInput(context.Background(), path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Printf("failed to close file: %v", err)
+			}
+		}()
+
+		// Parse expand-arrays parameter
+		expandMap := make(map[string]bool)
+		var expandPrefixes []string
+		if expandArrays != "" {
+			for _, pattern := range strings.Split(expandArrays, ",") {
+				pattern = strings.TrimSpace(pattern)
+				if strings.HasSuffix(pattern, "*") {
+					// Pattern like "tokenizer.*" - treat as prefix
+					expandPrefixes = append(expandPrefixes, strings.TrimSuffix(pattern, "*"))
+				} else {
+					// Exact key match
+					expandMap[pattern] = true
+				}
 			}
 		}
-	}
 
-	pol := policy{
-		maxArray:       maxArray,
-		maxString:      maxString,
-		debug:          debug,
-		expandArrays:   expandMap,
-		expandPrefixes: expandPrefixes,
-	}
+		if f, ok := outputFormats[format]; ok && f.ExpandArrays && expandArrays == "" {
+			expandPrefixes = []string{""}
+		}
 
-	p, hdr, err := newParser(f, fsize, pol)
-	if err != nil {
-		log.Fatal(err)
-	}
+		var grepRE *regexp.Regexp
+		if grep != "" {
+			if grepRE, err = regexp.Compile(grep); err != nil {
+				log.Fatalf("--grep: %v", err)
+			}
+			// Matches inside arrays count too, so expand them all
+			expandPrefixes = []string{""}
+		}
 
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(hdr)
+		pol := policy{
+			maxArray:       maxArray,
+			maxString:      maxString,
+			expandArrays:   expandMap,
+			expandPrefixes: expandPrefixes,
+			expandRanges:   expandRanges,
+			lenient:        lenient,
+			truncStrings:   truncStrings,
+			noAutoExpand:   noAutoExpand,
+			expandTypes:    expandTypes,
+			arrayDigest:    arrayDigest,
+		}
+		if _, ok := hashAlgos[arrayDigest]; arrayDigest != "" && !ok {
+			log.Fatalf("--array-digest: unknown algorithm %q (want md5, sha1, sha256 or sha512)", arrayDigest)
+		}
+		if rf, ok := f.(*remoteFile); ok {
+			pol.prefetch = rf.prefetch
+		}
 
-	// Define key filtering logic - now only filters based on --keys parameter
-	matchKey := func(k string) bool {
-		// If --keys is specified, use exact prefix matching
-		if keys != "" {
-			return strings.HasPrefix(k, strings.TrimSpace(keys))
+		if verify {
+			if stringsDir != "" {
+				log.Fatal("--verify cannot be combined with --strings-to-dir")
+			}
+			if err := verifyParses(context.Background(), f, fsize, pol); err != nil {
+				log.Fatalf("%s: %v", path, err)
+			}
 		}
-		// Default: show all keys
-		return true
-	}
 
-	for {
-		kv, ok, err := p.nextKV()
+		p, hdr, err := newParser(f, fsize, pol)
 		if err != nil {
 			log.Fatal(err)
 		}
+		if stringsDir != "" {
+			if getKey != "" {
+				log.Fatal("--strings-to-dir cannot be combined with --get")
+			}
+			if p.strFiles, err = newStringFiles(stringsDir); err != nil {
+				log.Fatal(err)
+			}
+		}
+		logger.Info("opened", "file", path, "size", fsize, "version", hdr.GGUF.Version,
+			"tensors", hdr.GGUF.TensorCount, "kvs", hdr.GGUF.KVCount)
+
+		if (output != "" && splitDir != "") || (execCmd != "" && (output != "" || splitDir != "")) {
+			log.Fatal("--output, --split-output and --exec are mutually exclusive")
+		}
+		if splitBy != "key" && splitBy != "namespace" {
+			log.Fatalf("--split-by: unknown mode %q (want 'key' or 'namespace')", splitBy)
+		}
+		outFmt, ok := outputFormats[format]
 		if !ok {
-			break
+			log.Fatalf("--format: unknown format %q (want one of: %s)", format, strings.Join(formatNames(), ", "))
+		}
+		if canonical && !outFmt.JSON {
+			log.Fatal("--canonical only applies to JSON formats")
+		}
+		if format != "ndjson" && (splitDir != "" || execCmd != "") {
+			log.Fatal("--split-output and --exec only support --format ndjson")
+		}
+		if trace && (format != "ndjson" || splitDir != "" || execCmd != "" || getKey != "") {
+			log.Fatal("--trace only supports plain --format ndjson output without --get")
+		}
+		if grepRE != nil && (getKey != "" || trace || splitDir != "") {
+			log.Fatal("--grep cannot be combined with --get, --trace or --split-output")
+		}
+		if emitIndex != "" && getKey != "" {
+			log.Fatal("--emit-index cannot be combined with --get")
+		}
+		if flushEach && (format != "ndjson" || output != "" || splitDir != "" || execCmd != "") {
+			log.Fatal("--flush only applies to --format ndjson written to stdout")
+		}
+		if annotate && (format != "ndjson" || grepRE != nil) {
+			log.Fatal("--annotate only supports --format ndjson without --grep")
+		}
+		if rawValues && (format != "ndjson" || canonical || annotate || grepRE != nil || trace || splitDir != "" || execCmd != "") {
+			log.Fatal("--raw-values only supports plain --format ndjson output without --canonical, --annotate, --grep, --trace, --split-output or --exec")
+		}
+		if escape != "" && (outFmt.Binary || canonical || execCmd != "") {
+			log.Fatal("--escape only applies to text output without --canonical or --exec")
+		}
+		if escape != "" {
+			if _, err := newEscapeWriter(io.Discard, escape); err != nil {
+				log.Fatal(err)
+			}
+		}
+		formatEnc := func(w io.Writer) recordEncoder { return outFmt.New(w, canonical) }
+		if rawValues {
+			formatEnc = func(w io.Writer) recordEncoder { return rawValueEncoder{w} }
+		}
+		if escape != "" {
+			plain := formatEnc
+			formatEnc = func(w io.Writer) recordEncoder {
+				ew, _ := newEscapeWriter(w, escape)
+				return escapingEncoder{plain(ew), ew}
+			}
+		}
+
+		// Route output through atomic temp files when --output or --split-output is given.
+		// fatal discards partial files before exiting so nothing half-written is published.
+		var enc recordEncoder
+		var sink outputSink
+		var stdout *bufio.Writer // buffers stdout; nil when records go elsewhere
+		switch {
+		case output != "":
+			af, err := createAtomicFile(output)
+			if err != nil {
+				log.Fatal(err)
+			}
+			enc, sink = formatEnc(af), af
+		case splitDir != "":
+			so, err := newSplitOutput(splitDir, splitBy == "namespace", formatEnc)
+			if err != nil {
+				log.Fatal(err)
+			}
+			enc, sink = so, so
+		case execCmd != "":
+			enc = newExecEncoder(execCmd, path, canonical)
+		default:
+			stdout = bufio.NewWriterSize(os.Stdout, 64<<10)
+			enc = formatEnc(stdout)
+			if flushEach {
+				enc = flushingEncoder{enc, stdout}
+			}
+		}
+		if sink != nil {
+			defer abortOnSignal(sink)()
+		}
+		fatal := func(err error) {
+			if sink != nil {
+				sink.Abort()
+			}
+			if stdout != nil {
+				_ = stdout.Flush() // keep the records printed before the error
+			}
+			log.Fatal(err)
+		}
+
+		// --get answers a single point query from the key index instead of streaming
+		if getKey != "" {
+			if fsize == 0 {
+				fatal(fmt.Errorf("--get needs a regular file"))
+			}
+			getPol := pol
+			getPol.expandArrays = map[string]bool{getKey: true}
+			ix, err := openIndex(context.Background(), f, int64(fsize), getPol)
+			if err != nil {
+				fatal(err)
+			}
+			kv, ok, err := ix.Get(context.Background(), getKey)
+			if err != nil {
+				fatal(err)
+			}
+			if !ok {
+				fatal(fmt.Errorf("key %q not found", getKey))
+			}
+			var rec any = kv
+			if annotate {
+				archKV, _, err := ix.Get(context.Background(), "general.architecture")
+				if err != nil {
+					fatal(err)
+				}
+				arch, _ := archKV.Value.(string)
+				rec = annotatedKV{kv, annotateKV(kv, arch)}
+			}
+			if err := enc.Encode(rec); err != nil {
+				fatal(err)
+			}
+			if err := enc.Flush(); err != nil {
+				fatal(err)
+			}
+			if stdout != nil {
+				if err := stdout.Flush(); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if sink != nil {
+				if err := sink.Commit(); err != nil {
+					log.Fatal(err)
+				}
+			}
+			return
 		}
-		if kv.Key == "" { // omitted
-			continue
+
+		// Key filtering only depends on --keys; with none, every key is shown
+		filter, err := parseKeyFilter(keys, keysCI)
+		if err != nil {
+			log.Fatal(err)
+		}
+		matchKey := filter.match
+
+		if trace {
+			for _, ev := range headerTrace() {
+				if err := enc.Encode(ev); err != nil {
+					fatal(err)
+				}
+			}
+			p.trace = func(ev traceEvent) {
+				if ev.Key != "" && !matchKey(ev.Key) {
+					return // no kv record for it follows
+				}
+				if err := enc.Encode(ev); err != nil {
+					fatal(err)
+				}
+			}
+		}
+		if err := enc.Encode(hdr); err != nil {
+			fatal(err)
+		}
+		var indexer *keyIndexer
+		if emitIndex != "" {
+			indexer = &keyIndexer{ix: keyIndex{File: path, Size: fsize, Version: hdr.GGUF.Version, MetadataEnd: 24}}
+			traceOut := p.trace
+			p.trace = func(ev traceEvent) {
+				indexer.observe(ev)
+				if traceOut != nil {
+					traceOut(ev)
+				}
+			}
 		}
-		if !matchKey(kv.Key) {
-			continue
+
+		var emitted int
+		var arch string // general.architecture once seen, for --annotate and --warnings
+		tokenCounts := make(map[string]uint64)
+		for {
+			kv, ok, err := p.nextKV()
+			if err != nil {
+				fatal(err)
+			}
+			if !ok {
+				break
+			}
+			if indexer != nil {
+				indexer.setType(kv.Key, kv.Type)
+			}
+			if kv.Key == "" { // omitted
+				continue
+			}
+			if kv.Key == "general.architecture" {
+				arch, _ = kv.Value.(string)
+			}
+			if warnings {
+				if d, ok := lookupDeprecated(kv.Key, arch); ok {
+					logger.Warn(deprecationMessage(d, kv.Key), "key", kv.Key)
+				}
+				if n, ok := arrayLen(kv.Value); ok && strings.HasPrefix(kv.Key, "tokenizer.ggml.") {
+					tokenCounts[kv.Key] = n
+				}
+			}
+			if !matchKey(kv.Key) {
+				continue
+			}
+
+			// For arrays, always show placeholder info by default
+			// The --tokens and --tensors flags control whether to expand arrays, not whether to show them
+
+			if grepRE != nil {
+				for _, m := range grepKV(kv, grepRE) {
+					if err := enc.Encode(m); err != nil {
+						fatal(err)
+					}
+					emitted++
+				}
+				continue
+			}
+			var rec any = kv
+			if annotate {
+				rec = annotatedKV{kv, annotateKV(kv, arch)}
+			}
+			if err := enc.Encode(rec); err != nil {
+				fatal(err)
+			}
+			emitted++
 		}
 
-		// For arrays, always show placeholder info by default
-		// The --tokens and --tensors flags control whether to expand arrays, not whether to show them
+		if msg := tokenArrayMismatch(tokenCounts); msg != "" {
+			logger.Warn(msg)
+		}
+		if indexer != nil {
+			if err := indexer.write(emitIndex); err != nil {
+				fatal(err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			fatal(err)
+		}
+		if stdout != nil {
+			if err := stdout.Flush(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if sink != nil {
+			if err := sink.Commit(); err != nil {
+				log.Fatal(err)
+			}
+		}
+		logger.Info("done", "records", emitted, "elapsed", time.Since(start))
+	}
 
-		_ = enc.Encode(kv)
+	if outputDir == "" {
+		dumpFile(flag.Arg(0), output)
+		return
+	}
+	if output != "" || splitDir != "" || execCmd != "" || emitIndex != "" || stringsDir != "" {
+		log.Fatal("--output-dir cannot be combined with --output, --split-output, --exec, --emit-index or --strings-to-dir")
+	}
+	// Resolve every name first so a collision is caught before anything is written
+	outs := make([]string, flag.NArg())
+	seen := make(map[string]string)
+	for i, path := range flag.Args() {
+		name, err := outputName(outputTmpl, path, format)
+		if err != nil {
+			log.Fatal(err)
+		}
+		outs[i] = filepath.Join(outputDir, name)
+		if prev, dup := seen[outs[i]]; dup {
+			log.Fatalf("--output-template: %s and %s both write %s", prev, path, outs[i])
+		}
+		seen[outs[i]] = path
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		log.Fatalf("--output-dir: %v", err)
+	}
+	for i, path := range flag.Args() {
+		dumpFile(path, outs[i])
 	}
 }
 
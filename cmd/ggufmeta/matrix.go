@@ -0,0 +1,72 @@
+// Package main implements the `matrix` subcommand.
+// It writes a CSV with one row per file and one column per requested key,
+// the shape a spreadsheet needs to compare a directory of models side by side.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runMatrix(args []string) error {
+	fs := flag.NewFlagSet("matrix", flag.ExitOnError)
+	keys := fs.String("keys", "", "comma-separated `KEYS`, one column each; {arch} stands for each file's architecture")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta matrix --keys KEY,KEY... file.gguf...\n")
+		fmt.Fprintf(os.Stderr, "\nMissing keys leave the cell empty; arrays are written as JSON.\n")
+		fs.PrintDefaults()
+	}
+	files := parseInterspersed(fs, args)
+	var cols []string
+	for _, k := range strings.Split(*keys, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			cols = append(cols, k)
+		}
+	}
+	if len(cols) == 0 || len(files) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write(append([]string{"file"}, cols...)); err != nil {
+		return err
+	}
+	for _, path := range files {
+		gf, err := loadFile(context.Background(), path, basePolicy())
+		if err != nil {
+			return err
+		}
+		row := []string{path}
+		for _, key := range cols {
+			cell, err := matrixCell(gf, expandArch(gf, key))
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+			row = append(row, cell)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// matrixCell formats key's value: strings as-is, anything else as JSON.
+func matrixCell(gf *ggufFile, key string) (string, error) {
+	kv, ok := gf.Get(key)
+	if !ok {
+		return "", nil
+	}
+	if s, ok := kv.Value.(string); ok {
+		return s, nil
+	}
+	js, err := json.Marshal(kv.Value)
+	return string(js), err
+}
@@ -0,0 +1,410 @@
+// Package main implements the `mcp` subcommand: a Model Context Protocol
+// server on stdin/stdout, so coding agents can inspect GGUF files through
+// tools instead of shelling out and parsing dumps.
+//
+//	list_models     {dir?}                            -> [{path, name, arch, quant, params, size}]
+//	get_metadata    {path, keys?, expand?, max_items?} -> {header, kvs, tensorCount, dataOffset}
+//	get_tensors     {path, match?}                    -> [tensor record, ...]
+//	compare_models  {a, b}                            -> the text diff of `ggufmeta diff`
+//
+// The server only ever reads metadata, and only from files under the
+// directories it was started with (the working directory by default);
+// symlinks are resolved before that check. Remote inputs are refused unless
+// --allow-remote is given. Relative paths name files under the first
+// directory. Output stays small enough for a model's context: arrays are
+// placeholders unless expanded, and expanded arrays are cut to max_items.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// mcpProtocolVersions are the protocol revisions the server speaks, newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const (
+	mcpMaxModels = 1000 // list_models entries per call
+	mcpMaxItems  = 256  // default max_items for expanded arrays
+)
+
+// mcpServer holds what the tools may touch.
+type mcpServer struct {
+	roots       []string // absolute, symlinks resolved
+	allowRemote bool
+}
+
+// mcpTool is one entry of tools/list plus its implementation.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	call        func(s *mcpServer, args json.RawMessage) (any, error)
+}
+
+// mcpSchema builds an object schema from property descriptions; required
+// names the mandatory ones.
+func mcpSchema(props map[string]map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "list_models",
+		Description: "List the GGUF models under the served directories (or under dir) with name, architecture, quantization, parameter count and size.",
+		InputSchema: mcpSchema(map[string]map[string]any{
+			"dir": {"type": "string", "description": "directory to list, inside a served directory; default: all of them"},
+		}),
+		call: (*mcpServer).listModels,
+	},
+	{
+		Name:        "get_metadata",
+		Description: "Read the header and key/value metadata of a GGUF file. Arrays are shown as {count, element_type} placeholders unless listed in expand.",
+		InputSchema: mcpSchema(map[string]map[string]any{
+			"path":      {"type": "string", "description": "the .gguf file"},
+			"keys":      {"type": "string", "description": "comma-separated key prefixes or globs to return, e.g. \"general.,*.context_length\"; default: all"},
+			"expand":    {"type": "array", "items": map[string]any{"type": "string"}, "description": "array keys to return in full, or prefixes ending in *"},
+			"max_items": {"type": "integer", "minimum": 1, "description": fmt.Sprintf("elements kept of each expanded array (default %d)", mcpMaxItems)},
+		}, "path"),
+		call: (*mcpServer).getMetadata,
+	},
+	{
+		Name:        "get_tensors",
+		Description: "List the tensors of a GGUF file: name, ggml type, shape, offset, size and share of the file.",
+		InputSchema: mcpSchema(map[string]map[string]any{
+			"path":  {"type": "string", "description": "the .gguf file"},
+			"match": {"type": "string", "description": "shell-style glob on tensor names, e.g. \"blk.0.*\""},
+		}, "path"),
+		call: (*mcpServer).getTensors,
+	},
+	{
+		Name:        "compare_models",
+		Description: "Compare the metadata and tensor layout of two GGUF files; returns a unified-diff-like listing, or \"no differences\".",
+		InputSchema: mcpSchema(map[string]map[string]any{
+			"a": {"type": "string", "description": "the old .gguf file"},
+			"b": {"type": "string", "description": "the new .gguf file"},
+		}, "a", "b"),
+		call: (*mcpServer).compareModels,
+	},
+}
+
+// handle is the rpcHandler for the MCP methods a tools-only server needs.
+func (s *mcpServer) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, rpcErrorf(rpcInvalidParams, "initialize: %v", err)
+		}
+		version := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "ggufmeta", "version": toolVersion()},
+			"instructions":    "Read-only access to GGUF model metadata. Start with list_models, then get_metadata or get_tensors on a path it returned.",
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, rpcErrorf(rpcInvalidParams, "tools/call: %v", err)
+		}
+		i := slices.IndexFunc(mcpTools, func(t mcpTool) bool { return t.Name == p.Name })
+		if i < 0 {
+			return nil, rpcErrorf(rpcInvalidParams, "unknown tool %q", p.Name)
+		}
+		if len(p.Arguments) == 0 || string(p.Arguments) == "null" {
+			p.Arguments = json.RawMessage("{}")
+		}
+		return mcpResult(mcpTools[i].call(s, p.Arguments)), nil
+	}
+	if strings.HasPrefix(method, "notifications/") {
+		return nil, nil
+	}
+	return nil, rpcErrorf(rpcMethodNotFound, "method %q not found", method)
+}
+
+// mcpResult wraps a tool's outcome as a CallToolResult. Tool failures are
+// results with isError set, so the agent sees the message; strings are
+// passed through as text and anything else as JSON.
+func mcpResult(v any, err error) map[string]any {
+	if err != nil {
+		return map[string]any{"content": []map[string]any{{"type": "text", "text": err.Error()}}, "isError": true}
+	}
+	text, ok := v.(string)
+	if !ok {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return mcpResult(nil, err)
+		}
+		text = string(raw)
+	}
+	return map[string]any{"content": []map[string]any{{"type": "text", "text": text}}, "isError": false}
+}
+
+// mcpArgs decodes tool arguments strictly, so a misspelt argument is an error
+// rather than silently ignored.
+func mcpArgs(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("bad arguments: %w", err)
+	}
+	return nil
+}
+
+// resolve maps a tool's path argument to what loadFile should open,
+// refusing anything outside the served directories.
+func (s *mcpServer) resolve(p string) (string, error) {
+	if p == "" {
+		return "", errors.New("path is required")
+	}
+	if strings.Contains(p, "://") {
+		if !s.allowRemote {
+			return "", fmt.Errorf("%s: remote inputs are disabled (start the server with --allow-remote)", p)
+		}
+		return p, nil
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.roots[0], p)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range s.roots {
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("%s: outside the served directories", p)
+}
+
+// mcpModel is one list_models entry.
+type mcpModel struct {
+	Path   string `json:"path"`
+	Name   string `json:"name,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	Quant  string `json:"quant,omitempty"`
+	Params uint64 `json:"params"`
+	Size   uint64 `json:"size"`
+}
+
+func (s *mcpServer) listModels(args json.RawMessage) (any, error) {
+	var a struct {
+		Dir string `json:"dir"`
+	}
+	if err := mcpArgs(args, &a); err != nil {
+		return nil, err
+	}
+	dirs := s.roots
+	if a.Dir != "" {
+		dir, err := s.resolve(a.Dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs = []string{dir}
+	}
+	models := []mcpModel{}
+	truncated := false
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				logger.Debug("mcp: skipped unreadable path", "path", p, "err", err)
+				return nil
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(p), ".gguf") {
+				return nil
+			}
+			if len(models) == mcpMaxModels {
+				truncated = true
+				return fs.SkipAll
+			}
+			// A symlink may point out of the served directories
+			if _, err := s.resolve(p); err != nil {
+				logger.Debug("mcp: skipped model", "path", p, "err", err)
+				return nil
+			}
+			gf, err := loadFile(context.Background(), p, basePolicy())
+			if err != nil {
+				logger.Debug("mcp: skipped unreadable model", "path", p, "err", err)
+				return nil
+			}
+			m := mcpModel{Path: p, Arch: gf.Arch(), Quant: quantLabel(gf), Params: paramCount(gf), Size: gf.Size}
+			m.Name, _ = gf.GetString("general.name")
+			models = append(models, m)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if truncated {
+		return map[string]any{"models": models, "truncated": true}, nil
+	}
+	return map[string]any{"models": models}, nil
+}
+
+func (s *mcpServer) getMetadata(args json.RawMessage) (any, error) {
+	var a struct {
+		Path     string   `json:"path"`
+		Keys     string   `json:"keys"`
+		Expand   []string `json:"expand"`
+		MaxItems int      `json:"max_items"`
+	}
+	if err := mcpArgs(args, &a); err != nil {
+		return nil, err
+	}
+	p, err := s.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := parseKeyFilter(a.Keys, false)
+	if err != nil {
+		return nil, err
+	}
+	if a.MaxItems <= 0 {
+		a.MaxItems = mcpMaxItems
+	}
+	gf, err := loadFile(context.Background(), p, embedOptions{ExpandArrays: a.Expand}.policy())
+	if err != nil {
+		return nil, err
+	}
+	kvs := []kvEvent{}
+	cut := make(map[string]int)
+	for _, kv := range gf.KVs {
+		if !filter.match(kv.Key) {
+			continue
+		}
+		if items, ok := kv.Value.([]any); ok && len(items) > a.MaxItems {
+			kv.Value = items[:a.MaxItems]
+			cut[kv.Key] = len(items)
+		}
+		kvs = append(kvs, kv)
+	}
+	res := map[string]any{"path": p, "header": gf.Header.GGUF, "kvs": kvs, "tensorCount": len(gf.Tensors), "dataOffset": gf.DataOffset}
+	if len(cut) > 0 {
+		res["truncatedArrays"] = cut // key -> full length, for arrays cut to max_items
+	}
+	return res, nil
+}
+
+func (s *mcpServer) getTensors(args json.RawMessage) (any, error) {
+	var a struct {
+		Path  string `json:"path"`
+		Match string `json:"match"`
+	}
+	if err := mcpArgs(args, &a); err != nil {
+		return nil, err
+	}
+	p, err := s.resolve(a.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := path.Match(a.Match, ""); err != nil {
+		return nil, fmt.Errorf("match: %w", err)
+	}
+	gf, err := loadFile(context.Background(), p, basePolicy())
+	if err != nil {
+		return nil, err
+	}
+	records := []tensorRecord{}
+	for _, r := range tensorRecords(gf) {
+		if ok, _ := path.Match(a.Match, r.Name); a.Match == "" || ok {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+func (s *mcpServer) compareModels(args json.RawMessage) (any, error) {
+	var a struct {
+		A string `json:"a"`
+		B string `json:"b"`
+	}
+	if err := mcpArgs(args, &a); err != nil {
+		return nil, err
+	}
+	pa, err := s.resolve(a.A)
+	if err != nil {
+		return nil, err
+	}
+	pb, err := s.resolve(a.B)
+	if err != nil {
+		return nil, err
+	}
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	ga, err := loadFile(context.Background(), pa, pol)
+	if err != nil {
+		return nil, err
+	}
+	gb, err := loadFile(context.Background(), pb, pol)
+	if err != nil {
+		return nil, err
+	}
+	changes := diffFiles(ga, gb)
+	if len(changes) == 0 {
+		return "no differences", nil
+	}
+	var sb strings.Builder
+	if err := writeTextDiff(&sb, pa, pb, changes); err != nil {
+		return nil, err
+	}
+	return sb.String(), nil
+}
+
+func runMCP(args []string) error {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	allowRemote := fs.Bool("allow-remote", false, "let tools read http(s)://, hf:// and oci:// inputs")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta mcp [--allow-remote] [DIR...]\n")
+		fmt.Fprintf(os.Stderr, "\nServes the Model Context Protocol on stdin/stdout; tools read only files under DIR (default: .).\n")
+		fs.PrintDefaults()
+	}
+	dirs := parseInterspersed(fs, args)
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	s := &mcpServer{allowRemote: *allowRemote}
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			return fmt.Errorf("mcp: %w", err)
+		}
+		s.roots = append(s.roots, resolved)
+	}
+	logger.Info("mcp: serving on stdio", "dirs", s.roots, "allow_remote", s.allowRemote)
+	if err := serveRPC(os.Stdin, os.Stdout, s.handle); err != nil {
+		return fmt.Errorf("mcp: %w", err)
+	}
+	return nil
+}
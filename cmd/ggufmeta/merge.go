@@ -0,0 +1,106 @@
+// Package main implements the `merge` subcommand.
+// It reconciles two edited copies of the same model's metadata against their
+// common ancestor, key by key, the way a three-way merge of text does line by
+// line: a side that left a key untouched takes the other side's change.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// conflictEvent is emitted instead of a kvEvent for a key both sides changed
+// differently. A nil side means the key is absent there.
+type conflictEvent struct {
+	Kind   string   `json:"kind"` // always "conflict"
+	Key    string   `json:"key"`
+	Base   *kvEvent `json:"base"`
+	Ours   *kvEvent `json:"ours"`
+	Theirs *kvEvent `json:"theirs"`
+}
+
+func runMerge(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	basePath := fs.String("base", "", "common ancestor file")
+	oursPath := fs.String("ours", "", "our edited copy")
+	theirsPath := fs.String("theirs", "", "their edited copy")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta merge --base base.gguf --ours a.gguf --theirs b.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *basePath == "" || *oursPath == "" || *theirsPath == "" || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	var files [3]*ggufFile
+	for i, path := range []string{*basePath, *oursPath, *theirsPath} {
+		gf, err := loadFile(context.Background(), path, pol)
+		if err != nil {
+			return err
+		}
+		files[i] = gf
+	}
+
+	merged, conflicts := mergeKVs(files[0], files[1], files[2])
+	enc := json.NewEncoder(os.Stdout)
+	for _, rec := range merged {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if conflicts > 0 {
+		fmt.Fprintf(os.Stderr, "merge: %d conflicting keys\n", conflicts)
+		os.Exit(1)
+	}
+	return nil
+}
+
+// mergeKVs returns the merged records - kvEvent for resolved keys,
+// conflictEvent otherwise - in ours' key order followed by keys only theirs
+// or base has, and the number of conflicts. A key deleted on one side and
+// untouched on the other stays deleted.
+func mergeKVs(base, ours, theirs *ggufFile) ([]any, int) {
+	var out []any
+	conflicts := 0
+	keys := unionOrder(unionOrder(fileKeys(ours), fileKeys(theirs)), fileKeys(base))
+	for _, key := range keys {
+		b, o, t := lookupKV(base, key), lookupKV(ours, key), lookupKV(theirs, key)
+		var pick *kvEvent
+		switch {
+		case sameKV(o, t), sameKV(t, b):
+			pick = o
+		case sameKV(o, b):
+			pick = t
+		default:
+			out = append(out, conflictEvent{Kind: "conflict", Key: key, Base: b, Ours: o, Theirs: t})
+			conflicts++
+			continue
+		}
+		if pick != nil {
+			out = append(out, *pick)
+		}
+	}
+	return out, conflicts
+}
+
+func lookupKV(gf *ggufFile, key string) *kvEvent {
+	if kv, ok := gf.Get(key); ok {
+		return &kv
+	}
+	return nil
+}
+
+// sameKV reports whether two optional KV pairs have the same presence, type and value.
+func sameKV(a, b *kvEvent) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && reflect.DeepEqual(a.Value, b.Value)
+}
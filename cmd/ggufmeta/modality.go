@@ -0,0 +1,95 @@
+// Package main implements modality detection: which kinds of input a file
+// handles, judged from its key namespaces and tensor names. llama.cpp keeps
+// vision and audio encoders in separate projector files (architecture
+// "clip", with clip.vision.* and clip.audio.* keys and v./a. tensors), but a
+// converter may also put an encoder next to the language model in one file,
+// and whisper.cpp-style GGUFs use whisper.* keys.
+package main
+
+import (
+	"strings"
+)
+
+// Modality labels. A file with more than one modality is "multimodal".
+const (
+	modalityText       = "text"
+	modalityVision     = "vision"
+	modalityAudio      = "audio"
+	modalityMultimodal = "multimodal"
+)
+
+// nonTextArchs are architectures whose files hold no language model.
+var nonTextArchs = map[string]bool{"clip": true, "whisper": true, "wavtokenizer-dec": true}
+
+// modalitiesOf returns the modalities gf handles, in the order text, vision,
+// audio; empty when nothing identifies the file.
+func modalitiesOf(gf *ggufFile) []string {
+	arch := gf.Arch()
+	text := arch != "" && !nonTextArchs[arch]
+	vision, audio := false, false
+	for _, kv := range gf.KVs {
+		switch {
+		case kv.Key == "clip.has_vision_encoder":
+			vision = vision || kv.Value == true
+		case kv.Key == "clip.has_audio_encoder":
+			audio = audio || kv.Value == true
+		case strings.HasPrefix(kv.Key, "clip.vision."):
+			vision = true
+		case strings.HasPrefix(kv.Key, "clip.audio."), strings.HasPrefix(kv.Key, "whisper."):
+			audio = true
+		}
+	}
+	for _, t := range gf.Tensors {
+		switch {
+		case strings.HasPrefix(t.Name, "v."):
+			vision = true
+		case strings.HasPrefix(t.Name, "a."):
+			audio = true
+		}
+	}
+	switch arch {
+	case "whisper", "wavtokenizer-dec":
+		audio = true
+	case "clip":
+		// Projectors from before clip.has_*_encoder and the v./a. prefixes
+		// were vision-only
+		vision = vision || !audio
+	}
+
+	var mods []string
+	for _, m := range []struct {
+		on   bool
+		name string
+	}{{text, modalityText}, {vision, modalityVision}, {audio, modalityAudio}} {
+		if m.on {
+			mods = append(mods, m.name)
+		}
+	}
+	return mods
+}
+
+// modalityLabel classifies modalities as returned by modalitiesOf: the single
+// modality, "multimodal" for several, or "" for none.
+func modalityLabel(mods []string) string {
+	switch len(mods) {
+	case 0:
+		return ""
+	case 1:
+		return mods[0]
+	}
+	return modalityMultimodal
+}
+
+// hasModality reports whether mods satisfies the filter want, which is a
+// modality name or "multimodal" (two or more).
+func hasModality(mods []string, want string) bool {
+	if strings.EqualFold(want, modalityMultimodal) {
+		return len(mods) > 1
+	}
+	for _, m := range mods {
+		if strings.EqualFold(m, want) {
+			return true
+		}
+	}
+	return false
+}
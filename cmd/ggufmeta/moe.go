@@ -0,0 +1,192 @@
+// Package main implements the `moe` subcommand.
+// It reports a mixture-of-experts model's expert configuration - routed and
+// shared experts, experts used per token, the per-expert feed-forward size,
+// which layers are MoE - and estimates the active parameters per token next
+// to the total. Memory has to hold every expert, but a token only reads the
+// dense weights and its chosen experts, so the two numbers size a deployment
+// from different ends: RAM/VRAM from the total, speed from the active share.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// moeReport is the result of `ggufmeta moe`.
+type moeReport struct {
+	File        string   `json:"file"`
+	Arch        string   `json:"arch"`
+	Experts     uint64   `json:"experts"`                     // routed experts per MoE layer
+	Used        uint64   `json:"used,omitempty"`              // routed experts per token
+	Shared      uint64   `json:"shared,omitempty"`            // always-active shared experts
+	ExpertFF    uint64   `json:"expertFeedForward,omitempty"` // hidden size of one expert
+	Gating      string   `json:"gating,omitempty"`
+	MoELayers   int      `json:"moeLayers"`
+	DenseLayers int      `json:"denseLayers"`
+	Params      moeSplit `json:"params"`
+	Bytes       moeSplit `json:"bytes"`
+	Notes       []string `json:"notes,omitempty"`
+}
+
+// moeSplit divides a quantity (parameters or bytes) between routed experts and
+// everything else. Active is what one token touches; it is only set when
+// the number of experts used per token is known.
+type moeSplit struct {
+	Total     uint64 `json:"total"`
+	Experts   uint64 `json:"experts"`   // all routed experts of all layers
+	PerExpert uint64 `json:"perExpert"` // one expert, summed over the MoE layers
+	Active    uint64 `json:"active,omitempty"`
+}
+
+// moeGatingFuncs names {arch}.expert_gating_func (llama.cpp's
+// llama_expert_gating_func_type).
+var moeGatingFuncs = map[uint64]string{1: "softmax", 2: "sigmoid", 3: "softmax over selected"}
+
+// isExpertTensor reports whether a tensor holds the routed experts of a layer
+// (ffn_up_exps, ffn_gate_exps, ffn_down_exps and their biases): the last
+// dimension is the expert index.
+func isExpertTensor(name string) bool {
+	return strings.Contains(name, "_exps.")
+}
+
+// moeAnalyze builds the report, or fails for a model without routed experts.
+func moeAnalyze(gf *ggufFile) (*moeReport, error) {
+	rep := &moeReport{File: gf.Path, Arch: gf.Arch()}
+	ends := gf.tensorEnds()
+	moeBlocks := make(map[string]bool)
+	var tensorExperts, tensorFF uint64
+	for _, t := range gf.Tensors {
+		n, size := tensorElems(t.Dims), gf.tensorSize(t, ends)
+		rep.Params.Total += n
+		rep.Bytes.Total += size
+		if !isExpertTensor(t.Name) || len(t.Dims) < 2 {
+			continue
+		}
+		rep.Params.Experts += n
+		rep.Bytes.Experts += size
+		tensorExperts = max(tensorExperts, t.Dims[len(t.Dims)-1])
+		if strings.Contains(t.Name, "ffn_up_exps.weight") && len(t.Dims) == 3 {
+			tensorFF = t.Dims[1]
+		}
+		if blk, _, ok := strings.Cut(strings.TrimPrefix(t.Name, "blk."), "."); ok && strings.HasPrefix(t.Name, "blk.") {
+			moeBlocks[blk] = true
+		}
+	}
+	if rep.Params.Experts == 0 {
+		return nil, fmt.Errorf("%s is not a mixture-of-experts model: no expert tensors (*_exps)", gf.Path)
+	}
+
+	rep.Experts, _ = gf.Uint("{arch}.expert_count")
+	switch {
+	case rep.Experts == 0:
+		rep.Experts = tensorExperts
+		rep.Notes = append(rep.Notes, "expert_count is missing; taken from the expert tensors")
+	case rep.Experts != tensorExperts:
+		rep.Notes = append(rep.Notes, fmt.Sprintf("expert_count is %d but the expert tensors hold %d", rep.Experts, tensorExperts))
+		rep.Experts = tensorExperts
+	}
+	rep.Used, _ = gf.Uint("{arch}.expert_used_count")
+	if rep.Used == 0 {
+		rep.Notes = append(rep.Notes, "expert_used_count is missing; active parameters cannot be estimated")
+	} else if rep.Used > rep.Experts {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("expert_used_count %d exceeds the %d experts", rep.Used, rep.Experts))
+	}
+	rep.Shared, _ = gf.Uint("{arch}.expert_shared_count")
+	rep.ExpertFF, _ = gf.Uint("{arch}.expert_feed_forward_length")
+	if rep.ExpertFF == 0 {
+		rep.ExpertFF = tensorFF
+	} else if tensorFF != 0 && tensorFF != rep.ExpertFF {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("expert_feed_forward_length is %d but ffn_up_exps rows are %d", rep.ExpertFF, tensorFF))
+	}
+	if g, ok := gf.Uint("{arch}.expert_gating_func"); ok {
+		if rep.Gating = moeGatingFuncs[g]; rep.Gating == "" {
+			rep.Gating = "unknown (" + strconv.FormatUint(g, 10) + ")"
+		}
+	}
+	rep.MoELayers = len(moeBlocks)
+	if blocks, ok := gf.Uint("{arch}.block_count"); ok && blocks > uint64(rep.MoELayers) {
+		rep.DenseLayers = int(blocks) - rep.MoELayers
+	}
+
+	rep.Params.PerExpert = rep.Params.Experts / rep.Experts
+	rep.Bytes.PerExpert = rep.Bytes.Experts / rep.Experts
+	if rep.Used > 0 && rep.Used <= rep.Experts {
+		rep.Params.Active = rep.Params.Total - rep.Params.Experts + rep.Params.PerExpert*rep.Used
+		rep.Bytes.Active = rep.Bytes.Total - rep.Bytes.Experts + rep.Bytes.PerExpert*rep.Used
+	}
+	return rep, nil
+}
+
+func runMoE(args []string) error {
+	fs := flag.NewFlagSet("moe", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta moe [--json] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	gf, err := loadFile(context.Background(), rest[0], basePolicy())
+	if err != nil {
+		return err
+	}
+	rep, err := moeAnalyze(gf)
+	if err != nil {
+		return fmt.Errorf("moe: %w", err)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return writeMoEReport(os.Stdout, rep)
+}
+
+// writeMoEReport prints rep as aligned text.
+func writeMoEReport(w io.Writer, rep *moeReport) error {
+	ew := &errWriter{w: w}
+	ew.printf("%s: %s\n", rep.File, rep.Arch)
+	line := func(label, format string, args ...any) {
+		ew.printf("  %-17s %s\n", label, fmt.Sprintf(format, args...))
+	}
+	experts := fmt.Sprintf("%d routed", rep.Experts)
+	if rep.Used > 0 {
+		experts += fmt.Sprintf(", %d per token", rep.Used)
+	}
+	if rep.Shared > 0 {
+		experts += fmt.Sprintf(", %d shared", rep.Shared)
+	}
+	line("experts", "%s", experts)
+	if rep.ExpertFF > 0 {
+		line("expert ff", "%d", rep.ExpertFF)
+	}
+	if rep.DenseLayers > 0 {
+		line("layers", "%d MoE, %d dense", rep.MoELayers, rep.DenseLayers)
+	} else {
+		line("layers", "%d MoE", rep.MoELayers)
+	}
+	if rep.Gating != "" {
+		line("gating", "%s", rep.Gating)
+	}
+	line("parameters", "%s total, %s in routed experts (%s per expert)",
+		humanCount(rep.Params.Total), humanCount(rep.Params.Experts), humanCount(rep.Params.PerExpert))
+	line("weights", "%s total, %s in routed experts (%s per expert)",
+		humanBytes(rep.Bytes.Total), humanBytes(rep.Bytes.Experts), humanBytes(rep.Bytes.PerExpert))
+	if rep.Params.Active > 0 {
+		line("active per token", "%s params (%.1f%%), %s of weights read",
+			humanCount(rep.Params.Active), 100*float64(rep.Params.Active)/float64(rep.Params.Total), humanBytes(rep.Bytes.Active))
+	}
+	for _, n := range rep.Notes {
+		ew.printf("  note: %s\n", n)
+	}
+	return ew.err
+}
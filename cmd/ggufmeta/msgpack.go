@@ -0,0 +1,227 @@
+// Package main implements the --format msgpack output.
+// Each record is written as one MessagePack map, back to back, preserving the
+// GGUF integer and float widths instead of round-tripping through JSON text.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+func init() {
+	registerFormat("msgpack", outputFormat{
+		Description: "MessagePack maps, one per record",
+		Binary:      true,
+		New:         func(w io.Writer, _ bool) recordEncoder { return newMsgpackEncoder(w) },
+	})
+}
+
+// msgpackEncoder streams records as a sequence of MessagePack maps.
+type msgpackEncoder struct {
+	w *bufio.Writer
+}
+
+func newMsgpackEncoder(w io.Writer) *msgpackEncoder {
+	return &msgpackEncoder{w: bufio.NewWriterSize(w, 64<<10)}
+}
+
+// Encode writes one record. Known event types are encoded field by field so
+// typed values keep their width; anything else goes through its JSON form.
+func (e *msgpackEncoder) Encode(v any) error {
+	switch ev := v.(type) {
+	case headerEvent:
+		e.writeMapLen(2)
+		e.writeString("kind")
+		e.writeString(ev.Kind)
+		e.writeString("gguf")
+		e.writeMapLen(3)
+		e.writeString("version")
+		e.writeUint(uint64(ev.GGUF.Version))
+		e.writeString("tensorCount")
+		e.writeUint(ev.GGUF.TensorCount)
+		e.writeString("kvCount")
+		e.writeUint(ev.GGUF.KVCount)
+		return nil
+	case kvEvent:
+		e.writeMapLen(3)
+		e.writeString("key")
+		e.writeString(ev.Key)
+		e.writeString("type")
+		e.writeString(ev.Type)
+		e.writeString("value")
+		return e.writeValue(ev.Value)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return err
+	}
+	return e.writeValue(generic)
+}
+
+// Flush pushes buffered bytes to the underlying writer.
+func (e *msgpackEncoder) Flush() error { return e.w.Flush() }
+
+// writeValue encodes the value shapes produced by the parser.
+func (e *msgpackEncoder) writeValue(v any) error {
+	switch x := v.(type) {
+	case nil:
+		e.w.WriteByte(0xc0)
+	case bool:
+		if x {
+			e.w.WriteByte(0xc3)
+		} else {
+			e.w.WriteByte(0xc2)
+		}
+	case uint8:
+		e.writeUint(uint64(x))
+	case uint16:
+		e.writeUint(uint64(x))
+	case uint32:
+		e.writeUint(uint64(x))
+	case uint64:
+		e.writeUint(x)
+	case int8:
+		e.writeInt(int64(x))
+	case int16:
+		e.writeInt(int64(x))
+	case int32:
+		e.writeInt(int64(x))
+	case int64:
+		e.writeInt(x)
+	case int:
+		e.writeInt(int64(x))
+	case float32:
+		e.w.WriteByte(0xca)
+		e.writeBE(4, uint64(math.Float32bits(x)))
+	case float64:
+		e.w.WriteByte(0xcb)
+		e.writeBE(8, math.Float64bits(x))
+	case string:
+		e.writeString(x)
+	case []any:
+		e.writeArrayLen(len(x))
+		for _, el := range x {
+			if err := e.writeValue(el); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) // deterministic output
+		e.writeMapLen(len(keys))
+		for _, k := range keys {
+			e.writeString(k)
+			if err := e.writeValue(x[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported value type %T", v)
+	}
+	return nil
+}
+
+// writeUint uses the smallest unsigned representation, as the spec recommends.
+func (e *msgpackEncoder) writeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.w.WriteByte(byte(u)) // positive fixint
+	case u <= math.MaxUint8:
+		e.w.WriteByte(0xcc)
+		e.writeBE(1, u)
+	case u <= math.MaxUint16:
+		e.w.WriteByte(0xcd)
+		e.writeBE(2, u)
+	case u <= math.MaxUint32:
+		e.w.WriteByte(0xce)
+		e.writeBE(4, u)
+	default:
+		e.w.WriteByte(0xcf)
+		e.writeBE(8, u)
+	}
+}
+
+// writeInt uses the smallest signed representation; non-negative values share writeUint.
+func (e *msgpackEncoder) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.writeUint(uint64(i))
+	case i >= -32:
+		e.w.WriteByte(byte(i)) // negative fixint
+	case i >= math.MinInt8:
+		e.w.WriteByte(0xd0)
+		e.writeBE(1, uint64(i))
+	case i >= math.MinInt16:
+		e.w.WriteByte(0xd1)
+		e.writeBE(2, uint64(i))
+	case i >= math.MinInt32:
+		e.w.WriteByte(0xd2)
+		e.writeBE(4, uint64(i))
+	default:
+		e.w.WriteByte(0xd3)
+		e.writeBE(8, uint64(i))
+	}
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		e.w.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.w.WriteByte(0xd9)
+		e.writeBE(1, uint64(n))
+	case n <= math.MaxUint16:
+		e.w.WriteByte(0xda)
+		e.writeBE(2, uint64(n))
+	default:
+		e.w.WriteByte(0xdb)
+		e.writeBE(4, uint64(n))
+	}
+	e.w.WriteString(s)
+}
+
+func (e *msgpackEncoder) writeArrayLen(n int) {
+	switch {
+	case n <= 15:
+		e.w.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		e.w.WriteByte(0xdc)
+		e.writeBE(2, uint64(n))
+	default:
+		e.w.WriteByte(0xdd)
+		e.writeBE(4, uint64(n))
+	}
+}
+
+func (e *msgpackEncoder) writeMapLen(n int) {
+	switch {
+	case n <= 15:
+		e.w.WriteByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		e.w.WriteByte(0xde)
+		e.writeBE(2, uint64(n))
+	default:
+		e.w.WriteByte(0xdf)
+		e.writeBE(4, uint64(n))
+	}
+}
+
+// writeBE writes the low n bytes of v in big-endian order (MessagePack's byte order).
+func (e *msgpackEncoder) writeBE(n int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	e.w.Write(b[8-n:])
+}
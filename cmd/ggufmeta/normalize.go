@@ -0,0 +1,167 @@
+// Package main implements the `normalize` subcommand.
+// This file re-serializes a file's metadata in one canonical layout - keys in a
+// fixed order, tensors packed in data order at the file's alignment - so two
+// files with the same content end up byte-identical.
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func runNormalize(args []string) error {
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	stamp := fs.Bool("stamp", false, "record the edit time, tool version and prior file hash under ggufmeta.*")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta normalize [--stamp] in.gguf out.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+	if samePath(in, out) {
+		return fmt.Errorf("normalize: output must differ from input")
+	}
+
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	gf, err := loadFile(context.Background(), in, pol)
+	if err != nil {
+		return err
+	}
+	if kv, ok := gf.Get("general.alignment"); ok {
+		if a, ok := kv.Value.(uint32); !ok || a == 0 || a%8 != 0 {
+			return fmt.Errorf("normalize: %s: general.alignment is %v (%s); it must be a non-zero uint32 multiple of 8", in, kv.Value, kv.Type)
+		}
+	}
+	if *stamp {
+		stamped, err := provenanceKVs(in, func(key string) []string {
+			history, _ := gf.GetStringSlice(key)
+			return history
+		})
+		if err != nil {
+			return fmt.Errorf("normalize: %w", err)
+		}
+		for _, kv := range stamped {
+			if i, ok := gf.byKey[kv.Key]; ok {
+				gf.KVs[i] = kv
+				continue
+			}
+			gf.byKey[kv.Key] = len(gf.KVs)
+			gf.KVs = append(gf.KVs, kv)
+		}
+	}
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Tensor data is copied as is, so it would stay big-endian under little-endian metadata
+	if p, _, err := newParserAt(f, gf.Size, basePolicy()); err != nil {
+		return fmt.Errorf("normalize: %s: %w", in, err)
+	} else if p.endianHint != "LE" {
+		return fmt.Errorf("normalize: %s is big-endian; only little-endian files can be normalized", in)
+	}
+
+	af, err := createAtomicFile(out)
+	if err != nil {
+		return err
+	}
+	err = writeNormalized(newWriter(af), gf, f)
+	if err == nil {
+		err = af.Close()
+	}
+	if err == nil {
+		err = checkRewrite(af.tmp.Name(), canonicalKeys(gf), uint64(len(gf.Tensors)))
+	}
+	if err != nil {
+		af.Abort()
+		return fmt.Errorf("normalize: %w", err)
+	}
+	return af.Commit()
+}
+
+// writeNormalized emits gf through gw: general.architecture first, every other
+// key in byte order (the last occurrence of a duplicate wins), then tensors in
+// the order of their data, each at the next aligned offset.
+func writeNormalized(gw *ggufWriter, gf *ggufFile, data *os.File) error {
+	if err := addCanonicalKVs(gw, gf, data, canonicalKeys(gf)); err != nil {
+		return err
+	}
+	if n := len(gf.KVs) - len(gf.byKey); n > 0 {
+		logger.Warn("dropped duplicate keys", "count", n)
+	}
+
+	var tensors []tensorData
+	for td := range gf.TensorData(data) {
+		tensors = append(tensors, td)
+	}
+	sort.SliceStable(tensors, func(i, j int) bool { return tensors[i].Offset < tensors[j].Offset })
+	for _, td := range tensors {
+		if err := gw.AddTensor(td.Name, td.Dims, td.Type, td.Data); err != nil {
+			return err
+		}
+	}
+	return gw.Close()
+}
+
+// addCanonicalKVs adds the last occurrence of each of keys to gw, re-encoded
+// from its parsed value; gf must be loaded with every array expanded. data is
+// the file gf came from, for copying nested arrays.
+func addCanonicalKVs(gw *ggufWriter, gf *ggufFile, data *os.File, keys []string) error {
+	var ix *kvIndex
+	for _, key := range keys {
+		kv, _ := gf.Get(key)
+		if kv.Type != "array[array]" {
+			if err := gw.AddTypedKV(kv.Key, kv.Type, kv.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		// Nested arrays are never decoded, so copy their bytes verbatim
+		if ix == nil {
+			var err error
+			if ix, err = openIndex(context.Background(), data, int64(gf.Size), basePolicy()); err != nil {
+				return err
+			}
+			if ix.order != binary.LittleEndian {
+				return fmt.Errorf("key %q: cannot copy a nested array out of a big-endian file", key)
+			}
+		}
+		loc, _ := ix.Loc(key)
+		if loc.Padded {
+			return fmt.Errorf("key %q: cannot copy a padded nested array", key)
+		}
+		pair := make([]byte, loc.End-loc.Offset)
+		if _, err := data.ReadAt(pair, int64(loc.Offset)); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		if err := gw.AddRawKV(key, pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalKeys lists gf's distinct keys with general.architecture first, as
+// llama.cpp expects, and the rest sorted.
+func canonicalKeys(gf *ggufFile) []string {
+	keys := make([]string, 0, len(gf.byKey))
+	for key := range gf.byKey {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "general.architecture") != (keys[j] == "general.architecture") {
+			return keys[i] == "general.architecture"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,88 @@
+// Package main implements the --format npz output.
+// Every numeric metadata array becomes one .npy member of a NumPy .npz
+// archive, named after its key, so np.load("meta.npz")["tokenizer.ggml.scores"]
+// returns the array with its GGUF element width. Strings, scalars and nested
+// arrays are not numeric arrays and are left out; --keys selects which arrays
+// are written.
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func init() {
+	registerFormat("npz", outputFormat{
+		Description:  "NumPy .npz archive of the numeric arrays (arrays are expanded)",
+		Binary:       true,
+		ExpandArrays: true,
+		New:          func(w io.Writer, _ bool) recordEncoder { return &npzEncoder{zw: zip.NewWriter(w)} },
+	})
+}
+
+// npyDescr maps GGUF element types to NumPy dtype descriptors.
+var npyDescr = map[string]string{
+	"uint8": "|u1", "int8": "|i1", "uint16": "<u2", "int16": "<i2",
+	"uint32": "<u4", "int32": "<i4", "uint64": "<u8", "int64": "<i8",
+	"float32": "<f4", "float64": "<f8", "bool": "|b1",
+}
+
+// npzEncoder writes each numeric array as it arrives; Flush closes the archive.
+type npzEncoder struct {
+	zw *zip.Writer
+}
+
+func (e *npzEncoder) Encode(v any) error {
+	kv, ok := v.(kvEvent)
+	if !ok {
+		return nil
+	}
+	elem, ok := strings.CutPrefix(kv.Type, "array[")
+	if !ok {
+		return nil
+	}
+	descr, ok := npyDescr[strings.TrimSuffix(elem, "]")]
+	items, isSlice := kv.Value.([]any)
+	if !ok || !isSlice {
+		return nil // not numeric, or left as a placeholder
+	}
+	w, err := e.zw.Create(kv.Key + ".npy")
+	if err != nil {
+		return err
+	}
+	return writeNPY(w, descr, items)
+}
+
+func (e *npzEncoder) Flush() error { return e.zw.Close() }
+
+// writeNPY writes items as a one-dimensional .npy file (format version 1.0).
+func writeNPY(w io.Writer, descr string, items []any) error {
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%d,), }", descr, len(items))
+	// Magic, version and length take 10 bytes; pad the header with spaces so
+	// the data starts 64-byte aligned, and end it with a newline
+	total := 10 + len(header) + 1
+	header += strings.Repeat(" ", (64-total%64)%64) + "\n"
+	pre := append([]byte("\x93NUMPY\x01\x00"), byte(len(header)), byte(len(header)>>8))
+	if _, err := w.Write(append(pre, header...)); err != nil {
+		return err
+	}
+	for _, it := range items {
+		if b, ok := it.(bool); ok {
+			it = b2u8(b)
+		}
+		if err := binary.Write(w, binary.LittleEndian, it); err != nil {
+			return fmt.Errorf("npy: element %v: %w", it, err)
+		}
+	}
+	return nil
+}
+
+func b2u8(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
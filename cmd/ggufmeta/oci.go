@@ -0,0 +1,244 @@
+// Package main implements oci:// inputs.
+// Ollama and ORAS store a GGUF model as one layer of an OCI artifact. An
+// oci://registry/repo:tag (or @sha256:...) reference is resolved through the
+// distribution API - manifest, then the GGUF layer's blob - and the blob is
+// read with range requests, so only the metadata is downloaded. Anonymous
+// bearer tokens are obtained as registries ask for them.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ociManifestAccept lists the manifest and index media types we understand.
+var ociManifestAccept = strings.Join([]string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}, ", ")
+
+// ociManifest covers both image manifests (Layers) and indexes (Manifests).
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ociRef is a parsed oci:// reference.
+type ociRef struct {
+	registry, repo, ref string
+}
+
+func parseOCIRef(s string) (ociRef, error) {
+	rest := strings.TrimPrefix(s, "oci://")
+	registry, repoRef, ok := strings.Cut(rest, "/")
+	if !ok || registry == "" || repoRef == "" {
+		return ociRef{}, fmt.Errorf("%s: want oci://registry/repository[:tag|@digest]", s)
+	}
+	r := ociRef{registry: registry, repo: repoRef, ref: "latest"}
+	if repo, digest, ok := strings.Cut(repoRef, "@"); ok {
+		r.repo, r.ref = repo, digest
+	} else if i := strings.LastIndex(repoRef, ":"); i > strings.LastIndex(repoRef, "/") {
+		r.repo, r.ref = repoRef[:i], repoRef[i+1:]
+	}
+	return r, nil
+}
+
+// baseURL uses plain HTTP only for local test registries.
+func (r ociRef) baseURL() string {
+	host := r.registry
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" {
+		return "http://" + r.registry
+	}
+	return "https://" + r.registry
+}
+
+// ociClient talks to one registry repository, remembering its bearer token.
+type ociClient struct {
+	ctx    context.Context
+	client *http.Client
+	ref    ociRef
+	header http.Header
+}
+
+// get performs a GET, answering one bearer challenge with an anonymous token.
+func (c *ociClient) get(u, accept string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range c.header {
+			req.Header[k] = v
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := remoteDo(c.client, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := c.token(challenge)
+		if err != nil {
+			return nil, err
+		}
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// token fetches an anonymous token for a `Bearer realm=...,service=...,scope=...` challenge.
+func (c *ociClient) token(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry wants %q authentication", scheme)
+	}
+	q := url.Values{}
+	var realm string
+	for _, p := range splitChallenge(params) {
+		k, v, _ := strings.Cut(p, "=")
+		v = strings.Trim(v, `"`)
+		if k == "realm" {
+			realm = v
+		} else {
+			q.Set(k, v)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge without realm: %s", challenge)
+	}
+	if q.Get("scope") == "" {
+		q.Set("scope", "repository:"+c.ref.repo+":pull")
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, realm+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := remoteDo(c.client, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request: %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	return tok.Token, nil
+}
+
+// splitChallenge splits challenge parameters on commas outside quotes.
+func splitChallenge(s string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func (c *ociClient) manifest(ref string) (*ociManifest, error) {
+	u := fmt.Sprintf("%s/v2/%s/manifests/%s", c.ref.baseURL(), c.ref.repo, ref)
+	resp, err := c.get(u, ociManifestAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("manifest %s: %s", ref, resp.Status)
+	}
+	var m ociManifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", ref, err)
+	}
+	return &m, nil
+}
+
+// ggufLayer picks the model layer: Ollama's model media type, a GGUF media
+// type or file name annotation (ORAS), or the only layer there is.
+func ggufLayer(m *ociManifest) (ociDescriptor, bool) {
+	for _, l := range m.Layers {
+		title := l.Annotations["org.opencontainers.image.title"]
+		if l.MediaType == "application/vnd.ollama.image.model" ||
+			strings.Contains(strings.ToLower(l.MediaType), "gguf") ||
+			strings.HasSuffix(strings.ToLower(title), ".gguf") {
+			return l, true
+		}
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], true
+	}
+	return ociDescriptor{}, false
+}
+
+// openOCI resolves an oci:// reference to its GGUF blob.
+func openOCI(ctx context.Context, s string) (inputFile, uint64, error) {
+	ref, err := parseOCIRef(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	if remoteOpts.offline {
+		// Whatever the tag pointed at when it was cached
+		return openCached(ctx, s)
+	}
+	c := &ociClient{ctx: ctx, client: remoteHTTPClient(), ref: ref, header: make(http.Header)}
+	m, err := c.manifest(ref.ref)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", s, err)
+	}
+	if len(m.Manifests) > 0 {
+		// An index: model artifacts are platform-independent, take the first
+		if m, err = c.manifest(m.Manifests[0].Digest); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", s, err)
+		}
+	}
+	layer, ok := ggufLayer(m)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s: no GGUF layer among %d layers", s, len(m.Layers))
+	}
+	blob := fmt.Sprintf("%s/v2/%s/blobs/%s", ref.baseURL(), ref.repo, layer.Digest)
+	// Registries redirect blobs to storage; net/http drops Authorization on
+	// redirects to other hosts, as those URLs are pre-signed
+	r := newRemoteFile(ctx, c.client, blob, c.header, layer.Size)
+	// The digest pins the content, so a cached prefix needs no revalidation
+	r.cache = loadRemoteCache(s)
+	if r.cache == nil || r.cache.Digest != layer.Digest || r.cache.Size != layer.Size {
+		r.cache, r.dirty = &remoteCacheEntry{URL: s, Digest: layer.Digest, Size: layer.Size}, true
+	}
+	return r, uint64(layer.Size), nil
+}
@@ -0,0 +1,136 @@
+// Package main implements the `ollama` subcommand, an import preflight.
+// It runs the compat checks with Ollama's rule set, adds the checks specific
+// to `ollama create` (a usable chat template, stop tokens), and prints the
+// Modelfile and commands that import the file, so imports work the first time.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	// Architectures Ollama loads from a plain GGUF file, through its own
+	// engine or the bundled llama.cpp. Ollama has no build counter, so every
+	// entry is 0; use --rules for an older installation.
+	archs := make(map[string]uint64)
+	for _, a := range []string{
+		"llama", "llama4", "mllama", "gemma", "gemma2", "gemma3", "gemma3n",
+		"qwen2", "qwen2moe", "qwen25vl", "qwen3", "qwen3moe", "phi2", "phi3",
+		"command-r", "cohere2", "starcoder2", "deepseek2", "granite",
+		"granitemoe", "olmo2", "exaone", "stablelm", "falcon", "gptneox",
+		"bert", "nomic-bert", "mistral3", "gpt-oss", "internlm2", "minicpm",
+	} {
+		archs[a] = 0
+	}
+	types := make(map[string]uint64)
+	for i, t := range ggmlTypeNames {
+		if t != "" && t != "Q8_1" && t != "Q8_K" && !ggmlTypeRemoved(uint32(i)) {
+			types[t] = 0
+		}
+	}
+	registerRuntime(&runtimeRules{
+		Name:          "ollama",
+		GGUFVersions:  []uint32{2, 3},
+		Architectures: archs,
+		TensorTypes:   types,
+		RequiredKeys:  []string{"general.architecture", "{arch}.block_count", "{arch}.embedding_length", "tokenizer.ggml.model"},
+		WarnMissing: map[string]string{
+			"tokenizer.chat_template": "no chat template: the Modelfile below needs a TEMPLATE, or chat requests are sent as raw text",
+		},
+	})
+}
+
+func runOllama(args []string) error {
+	fs := flag.NewFlagSet("ollama", flag.ExitOnError)
+	name := fs.String("name", "", "model name for `ollama create` (default: derived from general.name or the file name)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta ollama [--name NAME] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	pol := basePolicy()
+	pol.expandArrays = map[string]bool{"tokenizer.ggml.tokens": true}
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return err
+	}
+
+	findings := checkCompat(gf, runtimeRuleSets["ollama"], ^uint64(0))
+	v := &validation{gf: gf, check: "ollama"}
+	if tmpl, err := gf.GetString("tokenizer.chat_template"); err == nil && strings.Contains(tmpl, "{%") && strings.Contains(tmpl, "macro") {
+		v.report("warning", "tokenizer.chat_template", "", "template uses Jinja macros, which Ollama cannot convert; supply a Go TEMPLATE in the Modelfile")
+	}
+	stops := ollamaStops(gf)
+	if len(stops) == 0 {
+		v.report("warning", "tokenizer.ggml.eos_token_id", "", "no end-of-sequence token found; add PARAMETER stop lines or generation may not end")
+	}
+	findings = append(findings, v.findings...)
+	ok, err := writeFindings(path, findings, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		os.Exit(1)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if *name == "" {
+		*name = ollamaName(gf, path)
+	}
+	fmt.Printf("\n# Import with:\ncat > Modelfile <<'EOF'\nFROM %s\n", abs)
+	if _, err := gf.GetString("tokenizer.chat_template"); err != nil {
+		fmt.Printf("TEMPLATE \"\"\"{{ if .System }}{{ .System }}\n\n{{ end }}{{ .Prompt }}\"\"\"\n")
+	}
+	for _, s := range stops {
+		fmt.Printf("PARAMETER stop %q\n", s)
+	}
+	fmt.Printf("EOF\nollama create %s -f Modelfile\nollama run %s\n", *name, *name)
+	return nil
+}
+
+// ollamaStops returns the text of the end-of-sequence and end-of-turn tokens.
+func ollamaStops(gf *ggufFile) []string {
+	tokens, err := gf.GetStringSlice("tokenizer.ggml.tokens")
+	if err != nil {
+		return nil
+	}
+	var stops []string
+	for _, key := range []string{"tokenizer.ggml.eos_token_id", "tokenizer.ggml.eot_token_id"} {
+		id, ok := gf.Uint(key)
+		if !ok || id >= uint64(len(tokens)) {
+			continue
+		}
+		if tok := tokens[id]; tok != "" && (len(stops) == 0 || stops[0] != tok) {
+			stops = append(stops, tok)
+		}
+	}
+	return stops
+}
+
+// ollamaName turns general.name (or the file name) into a valid Ollama model
+// name: lower case, with runs of other characters replaced by '-'.
+func ollamaName(gf *ggufFile, path string) string {
+	base, _ := gf.GetString("general.name")
+	if base == "" {
+		base = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	name := strings.Trim(regexp.MustCompile(`[^a-z0-9._]+`).ReplaceAllString(strings.ToLower(base), "-"), "-._")
+	if name == "" {
+		name = "model"
+	}
+	return name
+}
@@ -0,0 +1,170 @@
+// Package main implements atomic file output for --output.
+// Records are written to a temporary file next to the destination and renamed
+// into place only after the whole stream succeeded, so an interrupted run never
+// leaves a half-written inventory behind.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// outputSink is a destination that must be explicitly published or discarded.
+type outputSink interface {
+	Commit() error
+	Abort()
+}
+
+// atomicFile buffers output into a temp file and publishes it with rename.
+// A ".gz" destination is transparently gzip-compressed.
+type atomicFile struct {
+	path string
+	tmp  *os.File
+	buf  *bufio.Writer
+	gz   *gzip.Writer
+	w    io.Writer
+
+	mu   sync.Mutex // serializes Commit, Close and Abort, which a signal handler may call
+	done bool       // committed or aborted
+
+	closed   bool  // temp file finished and closed, awaiting rename
+	closeErr error // result of finishing the temp file
+}
+
+func createAtomicFile(path string) (*atomicFile, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	// Same directory as the destination so the final rename never crosses filesystems
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("create output: %w", err)
+	}
+	a := &atomicFile{path: path, tmp: tmp, buf: bufio.NewWriter(tmp)}
+	a.w = a.buf
+	if strings.HasSuffix(path, ".gz") {
+		a.gz = gzip.NewWriter(a.buf)
+		a.w = a.gz
+	}
+	return a, nil
+}
+
+func (a *atomicFile) Write(p []byte) (int, error) { return a.w.Write(p) }
+
+// Commit flushes everything to disk and renames the temp file over the destination.
+func (a *atomicFile) Commit() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done {
+		return nil
+	}
+	err := a.close()
+	a.done = true
+	if err == nil {
+		err = os.Rename(a.tmp.Name(), a.path)
+	}
+	if err != nil {
+		_ = os.Remove(a.tmp.Name())
+		return fmt.Errorf("write output %s: %w", a.path, err)
+	}
+	return nil
+}
+
+// Close finishes the temp file and releases its descriptor without publishing
+// it; Commit still renames it into place. Writers holding many outputs at once
+// use it to stay clear of the open-file limit.
+func (a *atomicFile) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.close()
+}
+
+func (a *atomicFile) close() error {
+	if !a.closed {
+		a.closed = true
+		a.closeErr = a.finish()
+	}
+	return a.closeErr
+}
+
+// finish flushes the compression and buffering layers, then syncs and closes the temp file.
+func (a *atomicFile) finish() error {
+	if a.gz != nil {
+		if err := a.gz.Close(); err != nil {
+			_ = a.tmp.Close()
+			return err
+		}
+	}
+	if err := a.buf.Flush(); err != nil {
+		_ = a.tmp.Close()
+		return err
+	}
+	// CreateTemp uses 0600; outputs are ordinary data files
+	if err := a.tmp.Chmod(0o644); err != nil {
+		_ = a.tmp.Close()
+		return err
+	}
+	if err := a.tmp.Sync(); err != nil {
+		_ = a.tmp.Close()
+		return err
+	}
+	return a.tmp.Close()
+}
+
+// Abort discards the temp file, leaving any existing destination untouched.
+func (a *atomicFile) Abort() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.done {
+		return
+	}
+	a.done = true
+	if !a.closed {
+		_ = a.tmp.Close()
+	}
+	_ = os.Remove(a.tmp.Name())
+}
+
+// pendingSink is the output the signal handler discards on interrupt.
+var (
+	signalOnce  sync.Once
+	pendingMu   sync.Mutex
+	pendingSink outputSink
+)
+
+// abortOnSignal makes an interrupt discard sink instead of leaving its temp
+// files behind. One handler serves the whole process, however many outputs it
+// writes in turn; call the returned release once sink is committed or aborted.
+func abortOnSignal(sink outputSink) (release func()) {
+	signalOnce.Do(func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			pendingMu.Lock() // held until exit, so no new sink slips in
+			if pendingSink != nil {
+				pendingSink.Abort()
+			}
+			os.Exit(130)
+		}()
+	})
+	pendingMu.Lock()
+	pendingSink = sink
+	pendingMu.Unlock()
+	return func() {
+		pendingMu.Lock()
+		if pendingSink == sink {
+			pendingSink = nil
+		}
+		pendingMu.Unlock()
+	}
+}
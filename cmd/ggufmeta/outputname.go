@@ -0,0 +1,86 @@
+// Package main implements --output-template for --output-dir.
+// Batch outputs are named from each file's own metadata, e.g.
+// "{name}-{quant}.ndjson" -> "TinyLlama-1.1B-Chat-Q4_K_M.ndjson", so the
+// results describe themselves instead of depending on argument order.
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultOutputTemplate = "{stem}.{ext}"
+
+// formatExtensions are the usual file extensions of the output formats;
+// formats not listed use their own name.
+var formatExtensions = map[string]string{
+	"ndjson": "ndjson", "typed": "ndjson", "tree": "json", "proto": "pb",
+}
+
+// templateField matches one {field} in an output template.
+var templateField = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// outputName resolves tmpl for the file at path. Every field becomes a single
+// safe file name component; a field the file has no value for is an error,
+// since silently dropping it would make names collide.
+func outputName(tmpl, path, format string) (string, error) {
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var gf *ggufFile
+	var err error
+	out := templateField.ReplaceAllStringFunc(tmpl, func(field string) string {
+		if err != nil {
+			return ""
+		}
+		var v string
+		switch field {
+		case "{stem}":
+			v = stem
+		case "{ext}":
+			if v = formatExtensions[format]; v == "" {
+				v = format
+			}
+		case "{name}", "{arch}", "{quant}", "{size_label}":
+			if gf == nil {
+				if gf, err = loadFile(context.Background(), path, basePolicy()); err != nil {
+					return ""
+				}
+			}
+			v = templateValue(gf, field)
+		default:
+			err = fmt.Errorf("--output-template: unknown field %s (want {stem}, {name}, {arch}, {quant}, {size_label} or {ext})", field)
+			return ""
+		}
+		if v = strings.Trim(nameUnsafe.ReplaceAllString(v, "-"), "-."); v == "" && err == nil {
+			err = fmt.Errorf("--output-template: %s has no value for %s", path, field)
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(out, `/\`) {
+		return "", fmt.Errorf("--output-template: %q is not a plain file name", tmpl)
+	}
+	return out, nil
+}
+
+// templateValue looks up a metadata field, falling back the way suggest-name does.
+func templateValue(gf *ggufFile, field string) string {
+	switch field {
+	case "{name}":
+		name, _ := gf.GetString("general.name")
+		return name
+	case "{arch}":
+		return gf.Arch()
+	case "{quant}":
+		return quantLabel(gf)
+	}
+	size, _ := gf.GetString("general.size_label")
+	if size == "" {
+		size = sizeLabel(gf)
+	}
+	return size
+}
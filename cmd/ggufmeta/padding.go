@@ -0,0 +1,122 @@
+// Package main implements the `padding` subcommand.
+// It accounts for every byte of a file - header, KV section, tensor info table,
+// tensor payloads - and reports the rest as alignment padding, together with
+// what the padding would be if the tensors were packed at other alignments.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// paddingReport is the document `ggufmeta padding` prints.
+type paddingReport struct {
+	File        string          `json:"file"`
+	Size        uint64          `json:"size"`
+	Alignment   uint64          `json:"alignment"`
+	Header      uint64          `json:"header"`      // fixed 24-byte header
+	Metadata    uint64          `json:"metadata"`    // KV section
+	TensorInfos uint64          `json:"tensorInfos"` // tensor info table
+	TensorData  uint64          `json:"tensorData"`  // sum of tensor payloads
+	Padding     paddingBytes    `json:"padding"`
+	Share       float64         `json:"share"` // Padding.Total as a fraction of Size
+	WhatIf      []paddingWhatIf `json:"whatIf"`
+}
+
+// paddingBytes splits padding by where it sits.
+type paddingBytes struct {
+	BeforeData     uint64 `json:"beforeData"`     // tensor info table end to data section start
+	BetweenTensors uint64 `json:"betweenTensors"` // gaps between consecutive payloads
+	AfterData      uint64 `json:"afterData"`      // last payload end to end of file
+	Total          uint64 `json:"total"`
+}
+
+// paddingWhatIf is the padding a file packed at Alignment would have, laid
+// out as normalize writes it.
+type paddingWhatIf struct {
+	Alignment uint64 `json:"alignment"`
+	Padding   uint64 `json:"padding"`
+}
+
+// whatIfAlignments are the alignments the report compares; general.alignment
+// must be a multiple of 8.
+var whatIfAlignments = []uint64{8, 16, 32, 64, 128, 256, 4096}
+
+func runPadding(args []string) error {
+	fs := flag.NewFlagSet("padding", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta padding file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	gf, err := loadFile(context.Background(), fs.Arg(0), basePolicy())
+	if err != nil {
+		return err
+	}
+	if gf.Size == 0 {
+		return fmt.Errorf("padding: %s is not a regular file", fs.Arg(0))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(paddingOf(gf))
+}
+
+// paddingOf measures gf's layout. Payloads of types without a known size run
+// to the next tensor, so their padding counts as data.
+func paddingOf(gf *ggufFile) paddingReport {
+	rep := paddingReport{
+		File:        gf.Path,
+		Size:        gf.Size,
+		Alignment:   gf.Alignment(),
+		Header:      24,
+		Metadata:    gf.InfoStart - 24,
+		TensorInfos: gf.InfoEnd - gf.InfoStart,
+	}
+	recs := tensorRecords(gf)
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Offset < recs[j].Offset })
+
+	rep.Padding.BeforeData = gf.DataOffset - gf.InfoEnd
+	end := uint64(0) // relative to the data section
+	for _, r := range recs {
+		rep.TensorData += r.Size
+		if r.Offset > end {
+			rep.Padding.BetweenTensors += r.Offset - end
+		}
+		end = max(end, r.Offset+r.Size)
+	}
+	if len(recs) == 0 {
+		// Nothing is padded to a data section that is not there
+		rep.Padding.BeforeData = min(rep.Padding.BeforeData, gf.Size-gf.InfoEnd)
+		end = gf.Size - gf.DataOffset - rep.Padding.BeforeData
+	}
+	if gf.DataOffset+end < gf.Size {
+		rep.Padding.AfterData = gf.Size - (gf.DataOffset + end)
+	}
+	rep.Padding.Total = rep.Padding.BeforeData + rep.Padding.BetweenTensors + rep.Padding.AfterData
+	rep.Share = float64(rep.Padding.Total) / float64(gf.Size)
+
+	for _, align := range whatIfAlignments {
+		var pad, rel uint64
+		if len(recs) > 0 {
+			pad = alignUp(gf.InfoEnd, align) - gf.InfoEnd
+		}
+		for _, r := range recs {
+			off := alignUp(rel, align)
+			pad += off - rel
+			rel = off + r.Size
+		}
+		if len(recs) > 0 {
+			pad += alignUp(rel, align) - rel
+		}
+		rep.WhatIf = append(rep.WhatIf, paddingWhatIf{Alignment: align, Padding: pad})
+	}
+	return rep
+}
@@ -2,10 +2,11 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
 )
 
 type parser struct {
@@ -13,14 +14,39 @@ type parser struct {
 	fileSize   uint64
 	endianHint string
 	kvRemain   uint64
+	tnRemain   uint64
 	version    uint32
 	tc, kv     uint64
 	pol        policy
+	ctx        context.Context  // checked between reads; Background unless a caller sets it
+	trace      func(traceEvent) // when set, receives the byte range of every field read
+	padded     bool             // values are padded to 8 bytes after their tag (see probeAlignment)
+	strFiles   *stringFiles     // when set, long string values go to files (see --strings-to-dir)
+
+	paddedValues uint64 // values read so far that actually skipped alignment padding
 }
 
 func newParser(r io.Reader, size uint64, pol policy) (*parser, headerEvent, error) {
-	scn := newScanner(r)
+	if _, ok := r.(peeker); !ok {
+		if pol.lenient {
+			r = bufio.NewReaderSize(r, resyncWindow) // lenient resync needs a full window of look-ahead
+		} else {
+			r = bufio.NewReader(r) // alignment probing peeks at the pad bytes
+		}
+	}
+	return newParserFrom(newScanner(r), size, pol)
+}
+
+// newParserAt parses through positioned reads on ra instead of a stream.
+// Parsers built this way never share state, so several can run concurrently
+// over the same *os.File, mmap or HTTP Range-request backend; within the
+// command, kvIndex relies on this to seek without a shared stream.
+func newParserAt(ra io.ReaderAt, size uint64, pol policy) (*parser, headerEvent, error) {
+	return newParserFrom(newScannerAt(ra, 0), size, pol)
+}
 
+// newParserFrom reads the header through scn and returns a parser positioned at the first KV pair.
+func newParserFrom(scn *scanner, size uint64, pol policy) (*parser, headerEvent, error) {
 	// Read exactly 24 bytes of GGUF v3 header directly
 	headerBytes, err := scn.readExact(24)
 	if err != nil {
@@ -29,7 +55,7 @@ func newParser(r io.Reader, size uint64, pol policy) (*parser, headerEvent, erro
 
 	// Parse magic (bytes 0-3)
 	if string(headerBytes[0:4]) != magicGGUF {
-		return nil, headerEvent{}, fmt.Errorf("bad magic: got %q, expected %q", string(headerBytes[0:4]), magicGGUF)
+		return nil, headerEvent{}, fmt.Errorf("%w: got %q, expected %q", errBadMagic, string(headerBytes[0:4]), magicGGUF)
 	}
 
 	// Parse version and detect endianness (bytes 4-7)
@@ -47,7 +73,7 @@ func newParser(r io.Reader, size uint64, pol policy) (*parser, headerEvent, erro
 		version = 3
 		endianness = "BE"
 	} else {
-		return nil, headerEvent{}, fmt.Errorf("unsupported GGUF version: LE=%d, BE=%d (expected 3)", versionLE, versionBE)
+		return nil, headerEvent{}, fmt.Errorf("%w: LE=%d, BE=%d (expected 3)", errUnsupportedVersion, versionLE, versionBE)
 	}
 
 	// Parse tensor count (bytes 8-15)
@@ -56,20 +82,21 @@ func newParser(r io.Reader, size uint64, pol policy) (*parser, headerEvent, erro
 	// Parse KV count (bytes 16-23)
 	kv := scn.order.Uint64(headerBytes[16:24])
 
-	if pol.debug {
-		fmt.Fprintf(os.Stderr, "[debug] magic=%s version=%d endian=%s tensors=%d kvs=%d pos=%d\n",
-			string(headerBytes[0:4]), version, endianness, tc, kv, scn.pos)
-	}
+	logger.Debug("header", "magic", string(headerBytes[0:4]), "version", version,
+		"endian", endianness, "tensors", tc, "kvs", kv, "pos", scn.pos)
 
 	p := &parser{
 		scn:        scn,
 		fileSize:   size,
 		endianHint: endianness,
 		kvRemain:   kv,
+		tnRemain:   tc,
 		version:    version,
 		tc:         tc,
 		kv:         kv,
 		pol:        pol,
+		ctx:        context.Background(),
+		padded:     alignBeforeValue,
 	}
 
 	var hdr headerEvent
@@ -84,24 +111,37 @@ func (p *parser) nextKV() (kvEvent, bool, error) {
 	if p.kvRemain == 0 {
 		return kvEvent{}, false, nil
 	}
+	if err := p.ctx.Err(); err != nil {
+		return kvEvent{}, false, err
+	}
 
 	// key (GGUF string) - KV pairs are packed consecutively
+	start := p.scn.pos
 	key, err := p.scn.GGUFString(p.pol.maxString)
 	if err != nil {
-		return kvEvent{}, false, err
+		return kvEvent{}, false, p.errAt("", "", err)
 	}
+	p.traceKV("key", key, start)
 
 	// tag (u32) immediately follows key (no alignment padding)
+	start = p.scn.pos
 	tag, err := p.scn.U32()
 	if err != nil {
-		return kvEvent{}, false, fmt.Errorf("key %q: %w", key, err)
+		return kvEvent{}, false, p.errAt(key, "", err)
 	}
+	p.traceKV("tag", key, start)
 
-	// read value (no pre-align)
+	// read value (no pre-align unless the file turns out to need it)
+	p.probeAlignment(key, tag)
+	start = p.scn.pos
 	val, typ, omitted, err := p.readValue(tag, key)
+	if err != nil && p.pol.lenient && isUnknownType(err) {
+		val, typ, err = p.skipUnknown(key, tag)
+	}
 	if err != nil {
-		return kvEvent{}, false, fmt.Errorf("key %q: %w", key, err)
+		return kvEvent{}, false, p.errAt(key, "", err)
 	}
+	p.traceKV("value", key, start)
 	p.kvRemain--
 
 	if omitted {
@@ -111,6 +151,71 @@ func (p *parser) nextKV() (kvEvent, bool, error) {
 	return kvEvent{Key: key, Type: typ, Value: val}, true, nil
 }
 
+// errAt wraps err in a parseError at the scanner's current position. Reads do
+// not advance the position on failure, so this is where the failing read began.
+func (p *parser) errAt(key, tensor string, err error) error {
+	return &parseError{Offset: p.scn.pos, Key: key, Tensor: tensor, Err: err}
+}
+
+// maxTensorDims bounds n_dims in a tensor info. ggml itself uses 4 (GGML_MAX_DIMS);
+// the slack tolerates future growth while still rejecting garbage counts.
+const maxTensorDims = 16
+
+// nextTensor reads the next entry of the tensor info table.
+// It must only be called once nextKV has consumed every KV pair.
+func (p *parser) nextTensor() (tensorInfo, bool, error) {
+	if p.tnRemain == 0 {
+		return tensorInfo{}, false, nil
+	}
+	if p.kvRemain != 0 {
+		return tensorInfo{}, false, fmt.Errorf("tensor infos requested with %d KV pairs unread", p.kvRemain)
+	}
+	if err := p.ctx.Err(); err != nil {
+		return tensorInfo{}, false, err
+	}
+
+	start := p.scn.pos
+	name, err := p.scn.GGUFString(p.pol.maxString)
+	if err != nil {
+		return tensorInfo{}, false, p.errAt("", "", err)
+	}
+	p.traceTensor("name", name, start)
+	start = p.scn.pos
+	nd, err := p.scn.U32()
+	if err != nil {
+		return tensorInfo{}, false, p.errAt("", name, err)
+	}
+	p.traceTensor("n_dims", name, start)
+	if nd > maxTensorDims {
+		return tensorInfo{}, false, p.errAt("", name, fmt.Errorf("too many dimensions: %d > %d", nd, maxTensorDims))
+	}
+	start = p.scn.pos
+	dims := make([]uint64, nd)
+	for i := range dims {
+		if dims[i], err = p.scn.U64(); err != nil {
+			return tensorInfo{}, false, p.errAt("", name, err)
+		}
+	}
+	p.traceTensor("dims", name, start)
+	start = p.scn.pos
+	typ, err := p.scn.U32()
+	if err != nil {
+		return tensorInfo{}, false, p.errAt("", name, err)
+	}
+	p.traceTensor("type", name, start)
+	start = p.scn.pos
+	off, err := p.scn.U64()
+	if err != nil {
+		return tensorInfo{}, false, p.errAt("", name, err)
+	}
+	p.traceTensor("offset", name, start)
+	p.tnRemain--
+
+	logger.Debug("tensor", "name", name, "dims", dims, "type", ggmlTypeName(typ),
+		"offset", off, "pos", p.scn.pos)
+	return tensorInfo{Name: name, Dims: dims, Type: typ, Offset: off}, true, nil
+}
+
 // The parser coordinates between the low-level scanner (binary reading)
 // and high-level value interpretation, implementing the GGUF v3 specification
 // with robust error handling and endianness support.
@@ -0,0 +1,500 @@
+// Package main implements JSON Patch (RFC 6902) support.
+// The metadata is treated as one JSON object keyed by GGUF key, so "/general.name"
+// addresses a key and "/tokenizer.ggml.tokens/5" an array element. GGUF types
+// travel in an extra "type" member of add and replace operations (RFC 6902
+// lets processors ignore unknown members); without it a replaced value keeps
+// the key's current type and a new one is inferred as `user set` does.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// patchOp is one JSON Patch operation.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Type  string          `json:"type,omitempty"` // GGUF type label, e.g. "uint32"
+}
+
+func runPatch(args []string) error {
+	fs := flag.NewFlagSet("patch", flag.ExitOnError)
+	apply := fs.String("apply", "", "JSON Patch document to apply ('-' for stdin)")
+	out := fs.String("o", "", "write the patched file to `OUT` instead of replacing FILE")
+	stamp := fs.Bool("stamp", false, "record the edit time, tool version and prior file hash under ggufmeta.*")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta patch --apply changes.json [-o out.gguf] [--stamp] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *apply == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var raw []byte
+	var err error
+	if *apply == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(*apply)
+	}
+	if err != nil {
+		return err
+	}
+	var ops []patchOp
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return fmt.Errorf("patch: %s: %w", *apply, err)
+	}
+
+	path := fs.Arg(0)
+	dest := path
+	if *out != "" {
+		dest = *out
+	}
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return err
+	}
+	doc := newPatchDoc(gf)
+	for i, op := range ops {
+		if err := doc.apply(op); err != nil {
+			return fmt.Errorf("patch: operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return rewriteMetadata(path, dest, *stamp, doc.edit)
+}
+
+// patchDoc is the metadata of one file as a patchable object.
+type patchDoc struct {
+	kvs   map[string]kvEvent // current values; absent keys are removed
+	order []string           // original keys, then added ones
+	dirty map[string]bool    // keys whose encoding must be regenerated
+}
+
+func newPatchDoc(gf *ggufFile) *patchDoc {
+	d := &patchDoc{kvs: make(map[string]kvEvent), dirty: make(map[string]bool)}
+	for _, kv := range gf.KVs {
+		if _, dup := d.kvs[kv.Key]; !dup {
+			d.order = append(d.order, kv.Key)
+		}
+		d.kvs[kv.Key] = kv
+	}
+	return d
+}
+
+// parsePointer splits a JSON Pointer into a key and an optional array index
+// token ("" when the pointer names the key itself).
+func parsePointer(ptr string) (key, index string, err error) {
+	if !strings.HasPrefix(ptr, "/") {
+		return "", "", fmt.Errorf("path %q must start with /", ptr)
+	}
+	parts := strings.Split(ptr[1:], "/")
+	if len(parts) > 2 {
+		return "", "", fmt.Errorf("path %q is deeper than key/index", ptr)
+	}
+	unescape := strings.NewReplacer("~1", "/", "~0", "~")
+	key = unescape.Replace(parts[0])
+	if len(parts) == 2 {
+		index = parts[1]
+		if index == "" {
+			return "", "", fmt.Errorf("path %q has an empty index", ptr)
+		}
+	}
+	return key, index, nil
+}
+
+// escapePointer turns a key into a JSON Pointer.
+func escapePointer(key string) string {
+	return "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func (d *patchDoc) apply(op patchOp) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		v, err := decodePatchValue(op.Value)
+		if err != nil {
+			return err
+		}
+		if op.Op == "test" {
+			return d.test(op.Path, v)
+		}
+		return d.set(op.Path, v, op.Type, op.Op == "replace")
+	case "remove":
+		_, err := d.remove(op.Path)
+		return err
+	case "move", "copy":
+		v, typ, err := d.get(op.From)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			if op.From == op.Path {
+				return nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return fmt.Errorf("cannot move %s into itself", op.From)
+			}
+			if _, err := d.remove(op.From); err != nil {
+				return err
+			}
+		}
+		return d.set(op.Path, v, typ, false)
+	}
+	return fmt.Errorf("unknown op %q", op.Op)
+}
+
+func decodePatchValue(raw json.RawMessage) (any, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("missing value")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// get returns the value at ptr and its GGUF type label.
+func (d *patchDoc) get(ptr string) (any, string, error) {
+	key, index, err := parsePointer(ptr)
+	if err != nil {
+		return nil, "", err
+	}
+	kv, ok := d.kvs[key]
+	if !ok {
+		return nil, "", fmt.Errorf("key %q not found", key)
+	}
+	if index == "" {
+		return kv.Value, kv.Type, nil
+	}
+	items, elem, err := patchArray(kv)
+	if err != nil {
+		return nil, "", err
+	}
+	i, err := arrayIndex(index, len(items), false)
+	if err != nil {
+		return nil, "", err
+	}
+	return items[i], elem, nil
+}
+
+func (d *patchDoc) test(ptr string, v any) error {
+	cur, typ, err := d.get(ptr)
+	if err != nil {
+		return err
+	}
+	want, err := jsonToGGUF(typ, v)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(cur, want) {
+		return fmt.Errorf("test failed: value differs")
+	}
+	return nil
+}
+
+// set stores v at ptr. typ, when given, is the GGUF type of v; otherwise a
+// replaced value keeps its type and a new key's type is inferred. With
+// mustExist (replace) the target must already be present.
+func (d *patchDoc) set(ptr string, v any, typ string, mustExist bool) error {
+	key, index, err := parsePointer(ptr)
+	if err != nil {
+		return err
+	}
+	if key == signatureKey || key == "general.alignment" {
+		return fmt.Errorf("key %q cannot be patched", key)
+	}
+	kv, exists := d.kvs[key]
+	if index == "" {
+		if mustExist && !exists {
+			return fmt.Errorf("key %q not found", key)
+		}
+		switch {
+		case typ != "":
+		case exists:
+			typ = kv.Type
+		default:
+			v = userValue(v)
+			if tag, ok := scalarTag(v); ok {
+				typ, _ = typeName(tag)
+			} else {
+				elem, _, err := arrayItems(v)
+				if err != nil {
+					return err
+				}
+				name, _ := typeName(elem)
+				typ = "array[" + name + "]"
+			}
+		}
+		gv, err := jsonToGGUF(typ, v)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			d.order = append(d.order, key)
+		}
+		d.kvs[key] = kvEvent{Key: key, Type: typ, Value: gv}
+		d.dirty[key] = true
+		return nil
+	}
+
+	if !exists {
+		return fmt.Errorf("key %q not found", key)
+	}
+	items, elem, err := patchArray(kv)
+	if err != nil {
+		return err
+	}
+	if typ != "" && typ != elem {
+		return fmt.Errorf("element type %s does not match %s", typ, kv.Type)
+	}
+	gv, err := jsonToGGUF(elem, v)
+	if err != nil {
+		return err
+	}
+	i, err := arrayIndex(index, len(items), !mustExist)
+	if err != nil {
+		return err
+	}
+	items = append([]any(nil), items...)
+	if mustExist {
+		items[i] = gv
+	} else {
+		items = append(items[:i], append([]any{gv}, items[i:]...)...)
+	}
+	kv.Value = items
+	d.kvs[key] = kv
+	d.dirty[key] = true
+	return nil
+}
+
+// remove deletes the value at ptr and returns it.
+func (d *patchDoc) remove(ptr string) (any, error) {
+	key, index, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	kv, ok := d.kvs[key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found", key)
+	}
+	if index == "" {
+		delete(d.kvs, key)
+		return kv.Value, nil
+	}
+	items, _, err := patchArray(kv)
+	if err != nil {
+		return nil, err
+	}
+	i, err := arrayIndex(index, len(items), false)
+	if err != nil {
+		return nil, err
+	}
+	old := items[i]
+	kv.Value = append(append([]any(nil), items[:i]...), items[i+1:]...)
+	d.kvs[key] = kv
+	d.dirty[key] = true
+	return old, nil
+}
+
+// patchArray returns the elements and element type of an array key.
+func patchArray(kv kvEvent) ([]any, string, error) {
+	elem, ok := strings.CutPrefix(kv.Type, "array[")
+	elem = strings.TrimSuffix(elem, "]")
+	items, isSlice := kv.Value.([]any)
+	if !ok || !isSlice || elem == "array" {
+		return nil, "", fmt.Errorf("key %q (%s) has no patchable elements", kv.Key, kv.Type)
+	}
+	return items, elem, nil
+}
+
+// arrayIndex parses an index token; "-" (one past the end) is only valid
+// when inserting.
+func arrayIndex(tok string, n int, insert bool) (int, error) {
+	if tok == "-" && insert {
+		return n, nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || (tok != "0" && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	limit := n
+	if !insert {
+		limit--
+	}
+	if i > limit {
+		return 0, fmt.Errorf("array index %d out of range (length %d)", i, n)
+	}
+	return i, nil
+}
+
+// jsonToGGUF converts a decoded JSON value (numbers as json.Number) to the Go
+// representation of GGUF type typ, as the parser would produce it.
+func jsonToGGUF(typ string, v any) (any, error) {
+	if elem, ok := strings.CutPrefix(typ, "array["); ok {
+		elem = strings.TrimSuffix(elem, "]")
+		items, ok := v.([]any)
+		if !ok || elem == "array" {
+			return nil, fmt.Errorf("cannot store %T as %s", v, typ)
+		}
+		out := make([]any, len(items))
+		for i, it := range items {
+			var err error
+			if out[i], err = jsonToGGUF(elem, it); err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return out, nil
+	}
+	tag, ok := typeTag(typ)
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+	switch x := v.(type) {
+	case string:
+		if tag == tString {
+			return x, nil
+		}
+	case bool:
+		if tag == tBool {
+			return x, nil
+		}
+	case json.Number:
+		return numberOfTag(tag, x)
+	default:
+		// Already converted, e.g. a value moved or copied within the document
+		if got, ok := scalarTag(v); ok && got == tag {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot store %v as %s", v, typ)
+}
+
+// numberOfTag parses n as the numeric GGUF type tag, rejecting values out of range.
+func numberOfTag(tag uint32, n json.Number) (any, error) {
+	s := n.String()
+	var (
+		v   any
+		err error
+	)
+	switch tag {
+	case tUint8, tUint16, tUint32, tUint64:
+		bits := map[uint32]int{tUint8: 8, tUint16: 16, tUint32: 32, tUint64: 64}[tag]
+		var u uint64
+		if u, err = strconv.ParseUint(s, 10, bits); err == nil {
+			v, _ = intOfTag(tag, u)
+		}
+	case tInt8, tInt16, tInt32, tInt64:
+		bits := map[uint32]int{tInt8: 8, tInt16: 16, tInt32: 32, tInt64: 64}[tag]
+		var i int64
+		if i, err = strconv.ParseInt(s, 10, bits); err == nil {
+			switch tag {
+			case tInt8:
+				v = int8(i)
+			case tInt16:
+				v = int16(i)
+			case tInt32:
+				v = int32(i)
+			default:
+				v = i
+			}
+		}
+	case tFloat32:
+		var f float64
+		if f, err = strconv.ParseFloat(s, 32); err == nil {
+			v = float32(f)
+		}
+	case tFloat64:
+		v, err = strconv.ParseFloat(s, 64)
+	default:
+		name, _ := typeName(tag)
+		return nil, fmt.Errorf("cannot store number %s as %s", s, name)
+	}
+	if err != nil {
+		name, _ := typeName(tag)
+		return nil, fmt.Errorf("cannot store %s as %s", s, name)
+	}
+	return v, nil
+}
+
+// edit is the rewriteMetadata callback: untouched pairs are copied, changed
+// ones re-encoded at their first occurrence, removed ones dropped, and added
+// keys appended in the order they were added.
+func (d *patchDoc) edit(kvs []rawKV) ([]rawKV, error) {
+	seen := make(map[string]bool)
+	var out []rawKV
+	emit := func(kv rawKV) error {
+		cur, ok := d.kvs[kv.key]
+		if !ok || seen[kv.key] && d.dirty[kv.key] {
+			return nil
+		}
+		seen[kv.key] = true
+		if d.dirty[kv.key] {
+			gw := newWriter(io.Discard)
+			if err := gw.AddTypedKV(cur.Key, cur.Type, cur.Value); err != nil {
+				return err
+			}
+			kv.enc = bytes.Clone(gw.kvs.Bytes())
+		}
+		out = append(out, kv)
+		return nil
+	}
+	for _, kv := range kvs {
+		if err := emit(kv); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range d.order {
+		if !seen[key] {
+			if err := emit(rawKV{key: key}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+// jsonPatch converts diff changes to a patch document; tensor changes have no
+// metadata equivalent and are skipped with a warning.
+func jsonPatch(changes []metaChange) ([]patchOp, error) {
+	ops := []patchOp{}
+	for _, c := range changes {
+		if c.Tensor != "" {
+			logger.Warn("tensor change not representable in JSON Patch", "tensor", c.Tensor, "op", c.Op)
+			continue
+		}
+		op := patchOp{Path: escapePointer(c.Key)}
+		switch c.Op {
+		case "remove":
+			op.Op = "remove"
+			ops = append(ops, op)
+			continue
+		case "add":
+			op.Op, op.Type = "add", c.NewType
+		default:
+			op.Op, op.Type = "replace", c.NewType
+		}
+		raw, err := json.Marshal(c.New)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", c.Key, err)
+		}
+		op.Value = raw
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
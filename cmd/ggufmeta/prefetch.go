@@ -0,0 +1,142 @@
+// Package main implements read-ahead hints for remote inputs.
+// The parser knows how much it is about to read before it reads it: an
+// array header gives the element count, and for fixed-size elements the
+// exact payload size. Passed on as a hint, that lets a remote input fetch
+// the payload with several range requests in parallel while the parser is
+// still decoding what came before, instead of stalling one round trip per
+// window. Hints are only ever lower bounds or estimates from what was read
+// so far, so they stay inside the metadata and never pull in tensor data.
+package main
+
+const (
+	remotePrefetchMin      = 64 << 10 // smaller gaps are left to the next window
+	remotePrefetchParallel = 4        // prefetch requests in flight per file
+	remotePrefetchQueue    = 256      // queued requests per file; hints beyond wait for the next launch
+)
+
+// elemSize is the encoded size of a fixed-size element type, 0 otherwise.
+func elemSize(tag uint32) uint64 {
+	switch tag {
+	case tUint8, tInt8, tBool:
+		return 1
+	case tUint16, tInt16:
+		return 2
+	case tUint32, tInt32, tFloat32:
+		return 4
+	case tUint64, tInt64, tFloat64:
+		return 8
+	}
+	return 0
+}
+
+// hintArray announces the payload of an n-element array of fixed-size
+// elements starting at the current position.
+func (p *parser) hintArray(et uint32, n uint64) {
+	if size := elemSize(et); p.pol.prefetch != nil && size > 0 {
+		p.pol.prefetch(p.scn.pos, n*size)
+	}
+}
+
+// hintRest estimates the rest of a string array from the average element
+// size so far; called every ctxCheckEvery elements. The first estimate
+// covers the whole array, later ones only correct it.
+func (p *parser) hintRest(et uint32, start, i, n uint64) {
+	if p.pol.prefetch == nil || et != tString || i == 0 || p.scn.pos <= start {
+		return
+	}
+	p.pol.prefetch(p.scn.pos, (n-i)*((p.scn.pos-start)/i))
+}
+
+// remoteChunk is one prefetch request; data and err are set when done closes.
+type remoteChunk struct {
+	off, n int64
+	done   chan struct{}
+	data   []byte
+	err    error
+}
+
+// prefetch records that [off, off+n) will be read and starts fetching what
+// is not already cached, windowed or pending.
+func (r *remoteFile) prefetch(off, n uint64) {
+	r.hinted = max(r.hinted, min(int64(off+n), r.size))
+	r.launch()
+}
+
+// launch queues the hinted bytes beyond everything fetched so far, split
+// so that remotePrefetchParallel workers share them. Input readers buffer
+// ahead of the parser, so hints often arrive after the bytes they start at
+// were read; the rest of the range is what matters.
+func (r *remoteFile) launch() {
+	start := max(r.ahead, r.bufOff+int64(len(r.buf)))
+	if r.cache != nil {
+		start = max(start, int64(len(r.cache.data)))
+	}
+	if r.hinted-start < remotePrefetchMin || remoteOpts.offline {
+		return
+	}
+	if r.queue == nil {
+		r.queue = make(chan *remoteChunk, remotePrefetchQueue)
+		for range remotePrefetchParallel {
+			go r.prefetchWorker()
+		}
+	}
+	// One part per worker, so a launch costs a single round trip
+	part := (r.hinted - start + remotePrefetchParallel - 1) / remotePrefetchParallel
+	part = min(max(part, remotePrefetchMin), remoteMaxChunk)
+	off := start
+queue:
+	for off < r.hinted {
+		c := &remoteChunk{off: off, n: min(part, r.hinted-off), done: make(chan struct{})}
+		select {
+		case r.queue <- c:
+			r.pending = append(r.pending, c)
+			off += c.n
+		default:
+			break queue // the rest goes with a later launch
+		}
+	}
+	logger.Debug("remote prefetch", "url", redactURL(r.url), "offset", start, "bytes", off-start)
+	r.ahead = off
+}
+
+// prefetchWorker fetches queued chunks in order until Close.
+func (r *remoteFile) prefetchWorker() {
+	for {
+		select {
+		case c := <-r.queue:
+			c.data, c.err = r.fetch(c.off, c.n)
+			close(c.done)
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// takePending returns the prefetched chunk holding off, waiting for it, and
+// forgets chunks that end before off: reading moves forward.
+func (r *remoteFile) takePending(off int64) *remoteChunk {
+	for len(r.pending) > 0 && r.pending[0].off+r.pending[0].n <= off {
+		r.pending = r.pending[1:]
+	}
+	for _, c := range r.pending {
+		if c.off <= off && off < c.off+c.n {
+			<-c.done
+			if c.err != nil {
+				logger.Debug("remote prefetch failed; fetching again", "offset", c.off, "err", c.err)
+				return nil
+			}
+			return c
+		}
+	}
+	return nil
+}
+
+// nextPending is the offset of the first pending chunk after off, or size.
+func (r *remoteFile) nextPending(off int64) int64 {
+	for _, c := range r.pending {
+		if c.off > off {
+			return c.off
+		}
+	}
+	return r.size
+}
@@ -0,0 +1,281 @@
+// Package main implements the --format proto output.
+// Records are encoded as length-delimited protobuf messages matching
+// proto/gguf_meta.proto, hand-assembled so the tool stays dependency-free.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+func init() {
+	registerFormat("proto", outputFormat{
+		Description: "length-delimited protobuf (proto/gguf_meta.proto)",
+		Binary:      true,
+		New:         func(w io.Writer, _ bool) recordEncoder { return newProtoEncoder(w) },
+	})
+}
+
+// Protobuf wire types.
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+// Field numbers from proto/gguf_meta.proto.
+const (
+	pbRecordHeader = 1
+	pbRecordKV     = 2
+	pbRecordJSON   = 15
+
+	pbHeaderVersion     = 1
+	pbHeaderTensorCount = 2
+	pbHeaderKVCount     = 3
+
+	pbKVKey   = 1
+	pbKVType  = 2
+	pbKVValue = 3
+
+	pbValueUint        = 1
+	pbValueInt         = 2
+	pbValueFloat32     = 3
+	pbValueFloat64     = 4
+	pbValueBool        = 5
+	pbValueString      = 6
+	pbValueBytes       = 7
+	pbValueArray       = 8
+	pbValuePlaceholder = 9
+
+	pbArrayElements = 1
+
+	pbPlaceholderKind        = 1
+	pbPlaceholderCount       = 2
+	pbPlaceholderElementType = 3
+	pbPlaceholderLength      = 4
+	pbPlaceholderPrefix      = 5
+	pbPlaceholderPath        = 6
+	pbPlaceholderDigest      = 7
+	pbPlaceholderFirst       = 8
+	pbPlaceholderLast        = 9
+	pbPlaceholderByteLength  = 10
+	pbPlaceholderMaxLength   = 11
+	pbPlaceholderStart       = 12
+	pbPlaceholderEnd         = 13
+	pbPlaceholderElements    = 14
+	pbPlaceholderTag         = 15
+	pbPlaceholderSkipped     = 16
+	pbPlaceholderElementTag  = 17
+)
+
+// protoEncoder writes varint-length-prefixed Record messages.
+type protoEncoder struct {
+	w *bufio.Writer
+}
+
+func newProtoEncoder(w io.Writer) *protoEncoder {
+	return &protoEncoder{w: bufio.NewWriterSize(w, 64<<10)}
+}
+
+// Encode writes one Record. Records without a dedicated message are carried as JSON.
+func (e *protoEncoder) Encode(v any) error {
+	var rec []byte
+	switch ev := v.(type) {
+	case headerEvent:
+		var h []byte
+		h = pbAppendUint(h, pbHeaderVersion, uint64(ev.GGUF.Version))
+		h = pbAppendUint(h, pbHeaderTensorCount, ev.GGUF.TensorCount)
+		h = pbAppendUint(h, pbHeaderKVCount, ev.GGUF.KVCount)
+		rec = pbAppendBytes(rec, pbRecordHeader, h)
+	case kvEvent:
+		var kv []byte
+		kv = pbAppendString(kv, pbKVKey, ev.Key)
+		kv = pbAppendString(kv, pbKVType, ev.Type)
+		val, err := pbValue(ev.Value)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", ev.Key, err)
+		}
+		kv = pbAppendBytes(kv, pbKVValue, val)
+		rec = pbAppendBytes(rec, pbRecordKV, kv)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		rec = pbAppendBytes(rec, pbRecordJSON, raw)
+	}
+	e.w.Write(binary.AppendUvarint(nil, uint64(len(rec))))
+	_, err := e.w.Write(rec)
+	return err
+}
+
+// Flush pushes buffered bytes to the underlying writer.
+func (e *protoEncoder) Flush() error { return e.w.Flush() }
+
+// pbValue encodes a parser value as a Value message body.
+func pbValue(v any) ([]byte, error) {
+	var b []byte
+	switch x := v.(type) {
+	case uint8:
+		b = pbAppendVarint(b, pbValueUint, uint64(x))
+	case uint16:
+		b = pbAppendVarint(b, pbValueUint, uint64(x))
+	case uint32:
+		b = pbAppendVarint(b, pbValueUint, uint64(x))
+	case uint64:
+		b = pbAppendVarint(b, pbValueUint, x)
+	case int8:
+		b = pbAppendVarint(b, pbValueInt, pbZigZag(int64(x)))
+	case int16:
+		b = pbAppendVarint(b, pbValueInt, pbZigZag(int64(x)))
+	case int32:
+		b = pbAppendVarint(b, pbValueInt, pbZigZag(int64(x)))
+	case int64:
+		b = pbAppendVarint(b, pbValueInt, pbZigZag(x))
+	case float32:
+		b = binary.AppendUvarint(b, pbValueFloat32<<3|pbFixed32)
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(x))
+	case float64:
+		b = binary.AppendUvarint(b, pbValueFloat64<<3|pbFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(x))
+	case bool:
+		var u uint64
+		if x {
+			u = 1
+		}
+		b = pbAppendVarint(b, pbValueBool, u)
+	case string:
+		// proto3 string fields must be UTF-8; anything else travels as bytes
+		if utf8.ValidString(x) {
+			b = pbAppendBytes(b, pbValueString, []byte(x))
+		} else {
+			b = pbAppendBytes(b, pbValueBytes, []byte(x))
+		}
+	case []any:
+		arr, err := pbArray(x)
+		if err != nil {
+			return nil, err
+		}
+		b = pbAppendBytes(b, pbValueArray, arr)
+	case map[string]any:
+		kind, ok := x["_placeholder"].(string)
+		if !ok {
+			return nil, fmt.Errorf("proto: unsupported object value")
+		}
+		var ph []byte
+		ph = pbAppendString(ph, pbPlaceholderKind, kind)
+		if n, ok := x["count"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderCount, n)
+		}
+		if et, ok := x["element_type"].(string); ok {
+			ph = pbAppendString(ph, pbPlaceholderElementType, et)
+		}
+		if tag, ok := x["element_tag"].(uint32); ok {
+			ph = pbAppendUint(ph, pbPlaceholderElementTag, uint64(tag))
+		}
+		if n, ok := x["length"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderLength, n)
+		}
+		if s, ok := x["prefix"].(string); ok {
+			ph = pbAppendBytes(ph, pbPlaceholderPrefix, []byte(s))
+		}
+		if path, ok := x["path"].(string); ok {
+			ph = pbAppendString(ph, pbPlaceholderPath, path)
+		}
+		if d, ok := x["digest"].(string); ok {
+			ph = pbAppendString(ph, pbPlaceholderDigest, d)
+		}
+		for _, f := range []struct {
+			key   string
+			field int
+		}{{"first", pbPlaceholderFirst}, {"last", pbPlaceholderLast}} {
+			if el, ok := x[f.key]; ok {
+				ev, err := pbValue(el)
+				if err != nil {
+					return nil, err
+				}
+				ph = pbAppendBytes(ph, f.field, ev)
+			}
+		}
+		if n, ok := x["byte_length"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderByteLength, n)
+		}
+		if n, ok := x["max_length"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderMaxLength, n)
+		}
+		if n, ok := x["start"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderStart, n)
+		}
+		if n, ok := x["end"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderEnd, n)
+		}
+		if elems, ok := x["elements"].([]any); ok {
+			arr, err := pbArray(elems)
+			if err != nil {
+				return nil, err
+			}
+			ph = pbAppendBytes(ph, pbPlaceholderElements, arr)
+		}
+		if tag, ok := x["tag"].(uint32); ok {
+			ph = pbAppendUint(ph, pbPlaceholderTag, uint64(tag))
+		}
+		if n, ok := x["skipped"].(uint64); ok {
+			ph = pbAppendUint(ph, pbPlaceholderSkipped, n)
+		}
+		b = pbAppendBytes(b, pbValuePlaceholder, ph)
+	default:
+		return nil, fmt.Errorf("proto: unsupported value type %T", v)
+	}
+	return b, nil
+}
+
+// pbArray encodes elements as an Array message body.
+func pbArray(elems []any) ([]byte, error) {
+	var arr []byte
+	for _, el := range elems {
+		ev, err := pbValue(el)
+		if err != nil {
+			return nil, err
+		}
+		arr = pbAppendBytes(arr, pbArrayElements, ev)
+	}
+	return arr, nil
+}
+
+// pbAppendVarint always emits the field; used inside oneofs where zero is meaningful.
+func pbAppendVarint(b []byte, field int, u uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|pbVarint)
+	return binary.AppendUvarint(b, u)
+}
+
+// pbAppendUint emits a plain proto3 scalar field, omitting the zero default.
+func pbAppendUint(b []byte, field int, u uint64) []byte {
+	if u == 0 {
+		return b
+	}
+	return pbAppendVarint(b, field, u)
+}
+
+// pbAppendString emits a plain proto3 string field, omitting the empty default.
+func pbAppendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return pbAppendBytes(b, field, []byte(s))
+}
+
+// pbAppendBytes emits a length-delimited field (bytes, string or sub-message).
+func pbAppendBytes(b []byte, field int, payload []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|pbBytes)
+	b = binary.AppendUvarint(b, uint64(len(payload)))
+	return append(b, payload...)
+}
+
+// pbZigZag maps signed integers onto unsigned ones for sint64 fields.
+func pbZigZag(i int64) uint64 { return uint64(i<<1) ^ uint64(i>>63) }
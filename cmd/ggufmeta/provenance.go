@@ -0,0 +1,110 @@
+// Package main implements provenance stamping for the rewriting subcommands.
+// With --stamp, every rewrite appends one entry to parallel string arrays
+// under ggufmeta.*, so a file carries the history of the edits made to it and
+// each entry names the exact bytes it was made from.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// Provenance keys; entry i of each array describes the i-th stamped edit.
+const (
+	stampTimeKey = "ggufmeta.edited_at"       // RFC 3339 UTC timestamps
+	stampToolKey = "ggufmeta.edited_by"       // "ggufmeta VERSION"
+	stampHashKey = "ggufmeta.previous_sha256" // hex SHA-256 of the whole file before the edit
+)
+
+// toolVersion is the module version the binary was built from, or "(devel)".
+func toolVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "(devel)"
+}
+
+// stampPrefix must be expanded by the policy a rewrite reads its pairs with,
+// so stampProvenance finds the existing history in rawKV.val.
+const stampPrefix = "ggufmeta."
+
+// provenanceKVs returns the provenance arrays after one more edit of the file
+// at path, each extending the entries prior returns for its key.
+func provenanceKVs(path string, prior func(key string) []string) ([]kvEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{
+		stampTimeKey: time.Now().UTC().Format(time.RFC3339),
+		stampToolKey: "ggufmeta " + toolVersion(),
+		stampHashKey: hex.EncodeToString(h.Sum(nil)),
+	}
+	var kvs []kvEvent
+	for _, key := range []string{stampTimeKey, stampToolKey, stampHashKey} {
+		history := append(prior(key), entries[key])
+		kvs = append(kvs, kvEvent{Key: key, Type: "array[string]", Value: history})
+	}
+	return kvs, nil
+}
+
+// stampProvenance returns kvs with one entry appended to each provenance
+// array for an edit of the file at path, which must be the file kvs came from.
+func stampProvenance(path string, kvs []rawKV) ([]rawKV, error) {
+	stamped, err := provenanceKVs(path, func(key string) []string {
+		var history []string
+		for _, kv := range kvs {
+			if kv.key == key {
+				history = stringItems(kv.val) // a key of the wrong type is replaced rather than extended
+			}
+		}
+		return history
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range stamped {
+		gw := newWriter(io.Discard)
+		if err := gw.AddKV(s.Key, s.Value); err != nil {
+			return nil, err
+		}
+		enc := gw.kvs.Bytes()
+		kept := kvs[:0]
+		for _, kv := range kvs {
+			if kv.key != s.Key {
+				kept = append(kept, kv)
+			}
+		}
+		kvs = append(kept, rawKV{key: s.Key, tag: tArray, val: s.Value, enc: enc})
+	}
+	return kvs, nil
+}
+
+// stringItems returns v as a string slice if it is an expanded array of strings.
+func stringItems(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case []any:
+		out := make([]string, len(v))
+		for i, it := range v {
+			s, ok := it.(string)
+			if !ok {
+				return nil
+			}
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Package main implements the `quant-provenance` subcommand.
+// It gathers what a file records about how it was quantized - the preset,
+// the quantization format version, who quantized it, and the importance
+// matrix (imatrix) llama-quantize used with the calibration data behind it -
+// into one record per file, so a quant can be traced back to its calibration
+// run and quants of the same model compared.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// quantProvenance is the record `ggufmeta quant-provenance` prints per file.
+type quantProvenance struct {
+	Path                string         `json:"path"`
+	Quant               string         `json:"quant,omitempty"`    // see quantLabel
+	FileType            *uint64        `json:"fileType,omitempty"` // general.file_type
+	QuantizationVersion *uint64        `json:"quantizationVersion,omitempty"`
+	QuantizedBy         string         `json:"quantizedBy,omitempty"`
+	Imatrix             *imatrixInfo   `json:"imatrix"`         // null when none is recorded
+	Other               map[string]any `json:"other,omitempty"` // remaining quantize.* keys
+}
+
+// imatrixInfo describes the importance matrix a file was quantized with
+// (llama-quantize's quantize.imatrix.* keys).
+type imatrixInfo struct {
+	File    string  `json:"file,omitempty"`
+	Dataset string  `json:"dataset,omitempty"`
+	Entries *uint64 `json:"entries,omitempty"` // tensors covered
+	Chunks  *uint64 `json:"chunks,omitempty"`  // calibration chunks
+}
+
+// quantProvenanceOf extracts the record from gf.
+func quantProvenanceOf(gf *ggufFile) quantProvenance {
+	p := quantProvenance{Path: gf.Path, Quant: quantLabel(gf)}
+	uintPtr := func(key string) *uint64 {
+		if n, ok := gf.Uint(key); ok {
+			return &n
+		}
+		return nil
+	}
+	p.FileType = uintPtr("general.file_type")
+	p.QuantizationVersion = uintPtr("general.quantization_version")
+	p.QuantizedBy, _ = gf.GetString("general.quantized_by")
+
+	var im imatrixInfo
+	im.File, _ = gf.GetString("quantize.imatrix.file")
+	im.Dataset, _ = gf.GetString("quantize.imatrix.dataset")
+	im.Entries = uintPtr("quantize.imatrix.entries_count")
+	im.Chunks = uintPtr("quantize.imatrix.chunks_count")
+	if im != (imatrixInfo{}) {
+		p.Imatrix = &im
+	}
+	for _, kv := range gf.KVs {
+		switch kv.Key {
+		case "quantize.imatrix.file", "quantize.imatrix.dataset", "quantize.imatrix.entries_count", "quantize.imatrix.chunks_count":
+			continue
+		}
+		if strings.HasPrefix(kv.Key, "quantize.") {
+			if p.Other == nil {
+				p.Other = make(map[string]any)
+			}
+			p.Other[kv.Key] = kv.Value
+		}
+	}
+	return p
+}
+
+func runQuantProvenance(args []string) error {
+	fs := flag.NewFlagSet("quant-provenance", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print one JSON record per file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta quant-provenance [--json] file.gguf...\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for i, path := range rest {
+		gf, err := loadFile(context.Background(), path, basePolicy())
+		if err != nil {
+			return err
+		}
+		p := quantProvenanceOf(gf)
+		if *asJSON {
+			if err := enc.Encode(p); err != nil {
+				return err
+			}
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := writeQuantProvenance(os.Stdout, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeQuantProvenance prints p as aligned text.
+func writeQuantProvenance(w io.Writer, p quantProvenance) error {
+	ew := &errWriter{w: w}
+	ew.printf("%s\n", p.Path)
+	line := func(label, format string, args ...any) {
+		ew.printf("  %-21s %s\n", label, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case p.FileType != nil:
+		line("quantization", "%s (file_type %d)", p.Quant, *p.FileType)
+	case p.Quant != "":
+		line("quantization", "%s (largest tensor type; no file_type)", p.Quant)
+	}
+	if p.QuantizationVersion != nil {
+		line("quantization version", "%d", *p.QuantizationVersion)
+	}
+	if p.QuantizedBy != "" {
+		line("quantized by", "%s", p.QuantizedBy)
+	}
+	if p.Imatrix == nil {
+		line("imatrix", "none recorded")
+	} else {
+		im := p.Imatrix
+		if im.File != "" {
+			line("imatrix", "%s", im.File)
+		}
+		if im.Dataset != "" {
+			line("imatrix dataset", "%s", im.Dataset)
+		}
+		if im.Chunks != nil {
+			line("imatrix chunks", "%d", *im.Chunks)
+		}
+		if im.Entries != nil {
+			line("imatrix entries", "%d", *im.Entries)
+		}
+	}
+	keys := make([]string, 0, len(p.Other))
+	for k := range p.Other {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b, _ := json.Marshal(p.Other[k])
+		line(strings.TrimPrefix(k, "quantize."), "%s", b)
+	}
+	return ew.err
+}
@@ -0,0 +1,163 @@
+// Package main implements the `quirks` subcommand.
+// This file classifies how a file deviates from the GGUF spec (padded values,
+// big-endian encoding, NUL-padded strings, duplicate keys, odd alignment) into a
+// JSON report, so converter authors can see what their emitter gets wrong.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// quirk is one class of deviation found in a file.
+type quirk struct {
+	Kind    string   `json:"kind"`   // e.g. "padded_values", "big_endian"
+	Detail  string   `json:"detail"` // human-readable explanation
+	Keys    []string `json:"keys,omitempty"`
+	Tensors []string `json:"tensors,omitempty"`
+}
+
+// quirkReport is the document `ggufmeta quirks` prints.
+type quirkReport struct {
+	File   string  `json:"file"`
+	Clean  bool    `json:"clean"` // no quirks found
+	Quirks []quirk `json:"quirks"`
+}
+
+func runQuirks(args []string) error {
+	fs := flag.NewFlagSet("quirks", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta quirks file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	rep, err := findQuirks(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// findQuirks parses path with every array expanded, so strings inside arrays
+// are checked too, and collects the deviations it meets.
+func findQuirks(path string) (*quirkReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var size uint64
+	if st, err := f.Stat(); err == nil && st.Mode().IsRegular() {
+		size = uint64(st.Size())
+	}
+
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	p, _, err := newParser(f, size, pol)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	rep := &quirkReport{File: path, Quirks: []quirk{}}
+	add := func(q quirk) { rep.Quirks = append(rep.Quirks, q) }
+	if p.endianHint == "BE" {
+		add(quirk{Kind: "big_endian", Detail: "file is big-endian; most readers only accept little-endian GGUF"})
+	}
+
+	var padded, nulStrings, dups []string
+	seen := make(map[string]bool)
+	var alignment uint64 = 32
+	alignSet := false
+	for {
+		before := p.paddedValues
+		kv, ok, err := p.nextKV()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if !ok {
+			break
+		}
+		if kv.Key == "" {
+			continue
+		}
+		if p.paddedValues > before {
+			padded = append(padded, kv.Key)
+		}
+		if seen[kv.Key] {
+			dups = append(dups, kv.Key)
+		}
+		seen[kv.Key] = true
+		if hasNulPadding(kv.Value) {
+			nulStrings = append(nulStrings, kv.Key)
+		}
+		if kv.Key == "general.alignment" {
+			alignment, alignSet = asUint64(kv.Value)
+			if kv.Type != "uint32" {
+				add(quirk{Kind: "alignment_type", Detail: fmt.Sprintf("general.alignment is %s, the spec requires uint32", kv.Type), Keys: []string{kv.Key}})
+			}
+		}
+	}
+	if len(padded) > 0 {
+		add(quirk{Kind: "padded_values", Detail: "values are padded to 8 bytes after their type tag", Keys: padded})
+	}
+	if len(dups) > 0 {
+		add(quirk{Kind: "duplicate_keys", Detail: "keys occur more than once; readers disagree on which value wins", Keys: dups})
+	}
+	if len(nulStrings) > 0 {
+		add(quirk{Kind: "padded_strings", Detail: "string values carry trailing NUL bytes inside their length", Keys: nulStrings})
+	}
+	if alignSet && (alignment == 0 || alignment%8 != 0) {
+		add(quirk{Kind: "nonstandard_alignment", Detail: fmt.Sprintf("general.alignment is %d, the spec requires a non-zero multiple of 8", alignment), Keys: []string{"general.alignment"}})
+		alignment = 32
+	}
+
+	var nulNames, unaligned []string
+	for {
+		t, ok, err := p.nextTensor()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if !ok {
+			break
+		}
+		if strings.HasSuffix(t.Name, "\x00") {
+			nulNames = append(nulNames, t.Name)
+		}
+		if t.Offset%alignment != 0 {
+			unaligned = append(unaligned, t.Name)
+		}
+	}
+	if len(nulNames) > 0 {
+		add(quirk{Kind: "padded_tensor_names", Detail: "tensor names carry trailing NUL bytes inside their length", Tensors: nulNames})
+	}
+	if len(unaligned) > 0 {
+		add(quirk{Kind: "unaligned_tensors", Detail: fmt.Sprintf("tensor offsets are not multiples of the %d-byte alignment", alignment), Tensors: unaligned})
+	}
+	rep.Clean = len(rep.Quirks) == 0
+	return rep, nil
+}
+
+// hasNulPadding reports whether a string value, or any string in an expanded
+// array, ends in a NUL byte.
+func hasNulPadding(v any) bool {
+	switch x := v.(type) {
+	case string:
+		return strings.HasSuffix(x, "\x00")
+	case []any:
+		for _, it := range x {
+			if s, ok := it.(string); ok && strings.HasSuffix(s, "\x00") {
+				return true
+			}
+		}
+	}
+	return false
+}
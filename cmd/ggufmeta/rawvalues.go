@@ -0,0 +1,51 @@
+// Package main implements -r/--raw-values, the counterpart of jq -r.
+// Only values are written, one per line: strings as their raw text, without
+// quotes or escapes, and everything else as JSON. Expanded arrays put each
+// element on its own line the same way. With --get this turns a
+// key straight into a file: ggufmeta --get tokenizer.chat_template -r > t.jinja.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// rawValueEncoder writes the value of each kv record and drops the rest.
+// Like the JSON encoder it writes through; stdout is already buffered.
+type rawValueEncoder struct{ w io.Writer }
+
+func (e rawValueEncoder) Encode(v any) error {
+	kv, ok := v.(kvEvent)
+	if !ok {
+		return nil
+	}
+	items, ok := kv.Value.([]any)
+	if !ok {
+		items = []any{kv.Value}
+	}
+	for _, item := range items {
+		if err := e.writeLine(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLine writes one value: a string as is, anything else as unescaped JSON.
+func (e rawValueEncoder) writeLine(v any) error {
+	if s, ok := v.(string); ok {
+		_, err := io.WriteString(e.w, s+"\n")
+		return err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := e.w.Write(buf.Bytes()) // Encode ends the line
+	return err
+}
+
+func (rawValueEncoder) Flush() error { return nil }
@@ -0,0 +1,234 @@
+// Package main implements remote inputs.
+// openInput accepts a local path or a remote reference and returns something
+// the parser can read sequentially and the key index can read at offsets.
+// Remote files are read with HTTP range requests through a read-ahead window
+// that doubles on each miss, so the metadata of a multi-gigabyte model costs
+// a handful of requests and never the tensor data.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// inputFile is an opened input: sequential reads for the parser, ReadAt for
+// random access.
+type inputFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+}
+
+// openInput opens path, which may be an oci://, http(s):// or hf:// reference.
+// size is 0 when it is unknown (e.g. a pipe).
+func openInput(ctx context.Context, path string) (f inputFile, size uint64, err error) {
+	switch {
+	case strings.HasPrefix(path, "oci://"):
+		return openOCI(ctx, path)
+	case strings.HasPrefix(path, "https://"), strings.HasPrefix(path, "http://"):
+		return openHTTP(ctx, path, nil)
+	case strings.HasPrefix(path, "hf://"):
+		u, err := hfURL(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Gated repositories need the token huggingface-cli uses
+		header := make(http.Header)
+		if tok := os.Getenv("HF_TOKEN"); tok != "" && remoteOpts.bearer == "" {
+			header.Set("Authorization", "Bearer "+tok)
+		}
+		return openHTTP(ctx, u, header)
+	}
+	lf, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if st, err := lf.Stat(); err == nil && st.Mode().IsRegular() {
+		size = uint64(st.Size())
+	}
+	return lf, size, nil
+}
+
+const (
+	remoteFirstChunk = 256 << 10 // covers the header and most KV sections
+	remoteMaxChunk   = 16 << 20
+)
+
+// remoteFile reads a URL of known size with range requests.
+type remoteFile struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	header http.Header // sent with every request, e.g. Authorization
+	size   int64
+
+	pos    int64  // next sequential Read
+	buf    []byte // read-ahead window
+	bufOff int64  // file offset of buf[0]
+	chunk  int64
+
+	// cache, when set, holds the bytes read so far from offset 0; they are
+	// served before any request and saved on Close if dirty.
+	cache *remoteCacheEntry
+	dirty bool
+
+	// Prefetched chunks in file order (see prefetch); ahead is the end of
+	// everything fetched or requested so far, hinted the end of what the
+	// parser announced it will read.
+	pending []*remoteChunk
+	ahead   int64
+	hinted  int64
+	queue   chan *remoteChunk // started on the first launch
+	cancel  context.CancelFunc
+}
+
+func newRemoteFile(ctx context.Context, client *http.Client, url string, header http.Header, size int64) *remoteFile {
+	// Close cancels prefetches the parser turned out not to need
+	ctx, cancel := context.WithCancel(ctx)
+	return &remoteFile{ctx: ctx, client: client, url: url, header: header, size: size, chunk: remoteFirstChunk, cancel: cancel}
+}
+
+func (r *remoteFile) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *remoteFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := 0
+	if r.cache != nil && off < int64(len(r.cache.data)) {
+		n = copy(p, r.cache.data[off:])
+		off += int64(n)
+	}
+	for n < len(p) && off < r.size {
+		if off < r.bufOff || off >= r.bufOff+int64(len(r.buf)) {
+			if err := r.fill(off, int64(len(p)-n)); err != nil {
+				return n, err
+			}
+		}
+		c := copy(p[n:], r.buf[off-r.bufOff:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fill fetches at least want bytes at off into the window, growing the
+// window for the next miss: a long run of misses means a large array is
+// being read.
+func (r *remoteFile) fill(off, want int64) error {
+	if c := r.takePending(off); c != nil {
+		// Reading through a large prefetch is a run of misses too
+		r.chunk = min(max(r.chunk, c.n), remoteMaxChunk)
+		r.setWindow(c.data, c.off)
+		return nil
+	}
+	// Stop at the next prefetched chunk rather than fetch it twice
+	length := min(max(want, r.chunk), r.nextPending(off)-off)
+	r.chunk = min(r.chunk*2, remoteMaxChunk)
+	data, err := r.fetch(off, length)
+	if err != nil {
+		return err
+	}
+	r.ahead = max(r.ahead, off+length)
+	r.setWindow(data, off)
+	r.launch()
+	return nil
+}
+
+// setWindow makes data at off the window, extending the cached prefix when
+// it continues it.
+func (r *remoteFile) setWindow(data []byte, off int64) {
+	r.buf, r.bufOff = data, off
+	if c := r.cache; c != nil && off <= int64(len(c.data)) && len(c.data) < remoteCacheMax {
+		if end := off + int64(len(data)); end > int64(len(c.data)) {
+			c.data = append(c.data, data[int64(len(c.data))-off:min(end, remoteCacheMax)-off]...)
+			r.dirty = true
+		}
+	}
+}
+
+// fetch reads [off, off+length) with a range request. A body cut off
+// midway is resumed from the first missing byte, up to the retry limit.
+func (r *remoteFile) fetch(off, length int64) ([]byte, error) {
+	data := make([]byte, length)
+	var got int64
+	for attempt := 0; ; attempt++ {
+		resp, err := r.request(off+got, length-got)
+		if err != nil {
+			return nil, err
+		}
+		n, err := io.ReadFull(resp.Body, data[got:])
+		resp.Body.Close()
+		got += int64(n)
+		if err == nil {
+			return data, nil
+		}
+		if r.ctx.Err() != nil || attempt >= remoteOpts.retries {
+			return nil, fmt.Errorf("range request: %w", err)
+		}
+		wait := remoteBackoff(attempt, "")
+		logger.Warn("remote transfer interrupted; resuming", "url", redactURL(r.url), "offset", off+got, "err", err, "wait", wait)
+		if err := sleepCtx(r.ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// request starts the range request for [off, off+length).
+func (r *remoteFile) request(off, length int64) (*http.Response, error) {
+	if remoteOpts.offline {
+		return nil, fmt.Errorf("%s: bytes %d-%d are not in the metadata cache (offline)", redactURL(r.url), off, off+length-1)
+	}
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	if r.cache != nil && r.cache.ETag != "" && !strings.HasPrefix(r.cache.ETag, "W/") {
+		// A file replaced mid-read then answers 200 instead of mixing versions
+		req.Header.Set("If-Range", r.cache.ETag)
+	}
+	resp, err := remoteDo(r.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK && req.Header.Get("If-Range") != "" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s changed on the server while being read", redactURL(r.url))
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("range request: %s (the server must support HTTP range requests)", resp.Status)
+	}
+	return resp, nil
+}
+
+// Close saves newly fetched leading bytes to the cache. A cache that cannot be
+// written only costs the next run a download, so that is logged, not returned.
+func (r *remoteFile) Close() error {
+	r.cancel()
+	if r.cache != nil && r.dirty && r.cache.revalidatable() {
+		if err := r.cache.save(); err != nil {
+			logger.Warn("could not cache remote metadata", "url", redactURL(r.url), "err", err)
+		}
+		r.dirty = false
+	}
+	return nil
+}
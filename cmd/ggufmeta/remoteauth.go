@@ -0,0 +1,80 @@
+// Package main implements credentials and extra headers for remote inputs.
+// Private mirrors, gated Hugging Face repositories and corporate gateways all
+// want something attached to each request: a bearer token, basic auth, or a
+// header of their own. Proxies need nothing here - the transport honours
+// HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// headerFlag collects repeated --header "Name: value" flags.
+type headerFlag struct{ h http.Header }
+
+func (f headerFlag) String() string {
+	var parts []string
+	for name, vals := range f.h {
+		for _, v := range vals {
+			parts = append(parts, name+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f headerFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("want \"Name: value\", got %q", s)
+	}
+	f.h.Add(name, strings.TrimSpace(value))
+	return nil
+}
+
+// envHeaders parses $GGUF_META_HEADERS: "Name: value" lines.
+func envHeaders() http.Header {
+	h := make(http.Header)
+	for _, line := range strings.Split(os.Getenv("GGUF_META_HEADERS"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := (headerFlag{h}).Set(line); err != nil {
+			logger.Warn("ignored GGUF_META_HEADERS line", "err", err)
+		}
+	}
+	return h
+}
+
+// authorize adds the configured headers and credentials to req, leaving
+// anything the request already carries alone - e.g. a registry token or
+// user:password in the URL. A bearer token wins over basic auth.
+func (o remoteOptions) authorize(req *http.Request) {
+	for name, vals := range o.headers {
+		if req.Header.Get(name) == "" {
+			req.Header[name] = vals
+		}
+	}
+	if req.Header.Get("Authorization") != "" || req.URL.User != nil {
+		return
+	}
+	switch {
+	case o.bearer != "":
+		req.Header.Set("Authorization", "Bearer "+o.bearer)
+	case o.user != "":
+		user, pass, _ := strings.Cut(o.user, ":")
+		req.SetBasicAuth(user, pass)
+	}
+}
+
+// redactURL hides a password in u, for logs and cache entries.
+func redactURL(u string) string {
+	if p, err := url.Parse(u); err == nil {
+		return p.Redacted()
+	}
+	return u
+}
@@ -0,0 +1,133 @@
+// Package main implements the cache for remote inputs.
+// The bytes fetched from the start of a remote file - the header, the KV
+// section and the tensor infos - are kept on disk with the ETag and
+// Last-Modified the server sent. The next run revalidates them with a
+// conditional request and, on 304 Not Modified, reads the metadata from disk,
+// so repeated CI runs against the same URL don't download vocab arrays again.
+// oci:// blobs are content-addressed; their entries are keyed by reference
+// and carry the layer digest instead. With --offline the cache is the only
+// source.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// remoteCacheMax bounds the cached prefix of one file; metadata sections are
+// a few megabytes, anything beyond is tensor data read by hashing commands.
+const remoteCacheMax = 64 << 20
+
+// remoteCacheEntry is one cached prefix. On disk it is the JSON header, a
+// newline, then the data.
+type remoteCacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Digest       string `json:"digest,omitempty"` // oci:// blobs: the layer digest the prefix belongs to
+	Size         int64  `json:"size"`
+	data         []byte
+}
+
+// revalidatable reports whether there is anything to revalidate the entry with.
+func (e *remoteCacheEntry) revalidatable() bool {
+	return e.ETag != "" || e.LastModified != "" || e.Digest != ""
+}
+
+// remoteCacheDir is $GGUF_META_CACHE_DIR, or ggufmeta/remote under the user
+// cache directory (~/.cache on Linux).
+func remoteCacheDir() (string, error) {
+	if dir := os.Getenv("GGUF_META_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ggufmeta", "remote"), nil
+}
+
+func remoteCachePath(url string) (string, error) {
+	dir, err := remoteCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])), nil
+}
+
+// loadRemoteCache returns the cached entry for url, or nil when there is
+// none. A damaged entry is treated as missing; it is overwritten later.
+func loadRemoteCache(url string) *remoteCacheEntry {
+	path, err := remoteCachePath(url)
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil
+	}
+	var e remoteCacheEntry
+	if err := json.Unmarshal(line, &e); err != nil || e.URL != url {
+		return nil
+	}
+	if e.data, err = io.ReadAll(io.LimitReader(br, remoteCacheMax)); err != nil {
+		logger.Debug("ignored unreadable cache entry", "path", path, "err", err)
+		return nil
+	}
+	return &e
+}
+
+// save writes e atomically, so concurrent runs never see a torn entry.
+func (e *remoteCacheEntry) save() error {
+	path, err := remoteCachePath(e.URL)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	head, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	out, err := createAtomicFile(path)
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	if _, err := out.Write(append(head, '\n')); err != nil {
+		out.Abort()
+		return fmt.Errorf("cache: %w", err)
+	}
+	if _, err := out.Write(e.data); err != nil {
+		out.Abort()
+		return fmt.Errorf("cache: %w", err)
+	}
+	return out.Commit()
+}
+
+// openCached serves key from the cache alone, without revalidating it:
+// --offline. Reads beyond the cached prefix fail (see remoteFile.request).
+func openCached(ctx context.Context, key string) (inputFile, uint64, error) {
+	e := loadRemoteCache(key)
+	if e == nil {
+		return nil, 0, fmt.Errorf("%s: not in the metadata cache; fetch it once without --offline", key)
+	}
+	logger.Debug("offline: serving from cache", "url", key, "bytes", len(e.data))
+	r := newRemoteFile(ctx, nil, key, nil, e.Size)
+	r.cache = e
+	return r, uint64(e.Size), nil
+}
@@ -0,0 +1,131 @@
+// Package main implements the HTTP client behind remote inputs.
+// Mirrors and registries fail transiently - a refused connection, a 503
+// while a CDN node warms up, a body cut off halfway - and a parse that has
+// already read most of a vocab array should not start over because of one.
+// Requests are retried with exponential backoff (or the server's
+// Retry-After), and an interrupted range is resumed from the last byte
+// received rather than fetched again.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// remoteOptions configure every remote input. The defaults come from the
+// environment so subcommands share them; the dump's flags override them.
+type remoteOptions struct {
+	retries        int
+	connectTimeout time.Duration
+	bearer         string      // Authorization: Bearer token
+	user           string      // USER:PASSWORD for basic auth
+	headers        http.Header // sent with every request
+	offline        bool        // serve remote inputs from the cache only
+}
+
+var remoteOpts = remoteOptions{
+	retries:        int(envUint64("GGUF_META_RETRIES", 3)),
+	connectTimeout: envDuration("GGUF_META_CONNECT_TIMEOUT", 10*time.Second),
+	bearer:         os.Getenv("GGUF_META_BEARER_TOKEN"),
+	user:           os.Getenv("GGUF_META_USER"),
+	headers:        envHeaders(),
+	offline:        envBool("GGUF_META_OFFLINE", false),
+}
+
+const (
+	remoteFirstBackoff = 500 * time.Millisecond
+	remoteMaxBackoff   = 30 * time.Second
+)
+
+var (
+	remoteClientOnce sync.Once
+	remoteClient     *http.Client
+)
+
+// remoteHTTPClient is the shared client, built on first use so flags are
+// already applied. It keeps the default transport's TLS settings and its
+// proxy from HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func remoteHTTPClient() *http.Client {
+	remoteClientOnce.Do(func() {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{Timeout: remoteOpts.connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		t.TLSHandshakeTimeout = remoteOpts.connectTimeout
+		t.Proxy = http.ProxyFromEnvironment
+		remoteClient = &http.Client{Transport: t}
+	})
+	return remoteClient
+}
+
+// retryableStatus reports whether a response is worth asking for again.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// remoteDo sends req with the configured credentials, retrying failed
+// connections and retryable statuses.
+// Once retries run out the last response is returned as is, so callers
+// report the status they would have reported without retries.
+func remoteDo(client *http.Client, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	remoteOpts.authorize(req)
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req.Clone(ctx))
+		retryAfter := ""
+		if err == nil {
+			if !retryableStatus(resp.StatusCode) || attempt >= remoteOpts.retries {
+				return resp, nil
+			}
+			retryAfter = resp.Header.Get("Retry-After")
+			resp.Body.Close()
+			err = errors.New(resp.Status)
+		} else if ctx.Err() != nil || attempt >= remoteOpts.retries {
+			return nil, err
+		}
+		wait := remoteBackoff(attempt, retryAfter)
+		logger.Warn("remote request failed; retrying", "url", req.URL.Redacted(), "err", err, "attempt", attempt+1, "wait", wait)
+		if err := sleepCtx(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// remoteBackoff is the wait before retry attempt+1: the server's
+// Retry-After if it sent one, otherwise an exponential delay with jitter so
+// parallel runs don't retry in lockstep.
+func remoteBackoff(attempt int, retryAfter string) time.Duration {
+	if retryAfter != "" {
+		if s, err := strconv.Atoi(retryAfter); err == nil && s >= 0 {
+			return min(time.Duration(s)*time.Second, remoteMaxBackoff)
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			return min(max(time.Until(t), 0), remoteMaxBackoff)
+		}
+	}
+	d := min(remoteFirstBackoff<<attempt, remoteMaxBackoff)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("gave up retrying: %w", ctx.Err())
+	}
+}
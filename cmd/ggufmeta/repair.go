@@ -0,0 +1,475 @@
+// Package main implements the `repair` subcommand.
+// This file fixes known emitter bugs - string lengths off by one, a header KV
+// count that disagrees with the KV section, stale split.* counts - by rewriting
+// the header and KV section. Untouched KV pairs, the tensor info table and the
+// tensor data are copied byte for byte.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// rawKV is one KV pair of the file being repaired.
+type rawKV struct {
+	key        string
+	tag        uint32
+	val        any
+	start, end uint64 // original byte range
+	enc        []byte // replacement encoding; nil copies the original bytes
+}
+
+// repairer holds the state of one repair run over a little-endian file.
+type repairer struct {
+	ra    io.ReaderAt
+	size  uint64
+	tc    uint64
+	pol   policy
+	probe *parser // only used for its look-ahead heuristics and byte order
+	fixes []string
+}
+
+func runRepair(args []string) error {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would be fixed without writing OUT")
+	stamp := fs.Bool("stamp", false, "record the edit time, tool version and prior file hash under ggufmeta.*")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta repair [--dry-run] [--stamp] in.gguf out.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 && !(*dryRun && fs.NArg() == 1) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	in := fs.Arg(0)
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !st.Mode().IsRegular() {
+		return fmt.Errorf("repair: %s is not a regular file", in)
+	}
+
+	pol := basePolicy()
+	pol.noAutoExpand = true                    // values are copied as raw bytes, never looked at
+	pol.expandPrefixes = []string{stampPrefix} // except the provenance history --stamp extends
+	p, hdr, err := newParserAt(f, uint64(st.Size()), pol)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if p.endianHint != "LE" {
+		return fmt.Errorf("repair: %s is big-endian, which repair does not support", in)
+	}
+	r := &repairer{ra: f, size: uint64(st.Size()), tc: hdr.GGUF.TensorCount, pol: pol, probe: p}
+
+	kvs, infoStart, err := r.scanKVs(p.scn.pos, hdr.GGUF.KVCount)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	align := uint64(32)
+	for _, kv := range kvs {
+		if a, ok := asUint64(kv.val); ok && kv.key == "general.alignment" && a > 0 {
+			align = a
+		}
+	}
+	infoEnd, ok := r.tensorInfosAt(infoStart, align)
+	if !ok {
+		return fmt.Errorf("%s: tensor info table at offset %d does not parse", in, infoStart)
+	}
+	r.fixSplitCounts(in, kvs)
+
+	for _, fix := range r.fixes {
+		fmt.Println(fix)
+	}
+	if len(r.fixes) == 0 {
+		fmt.Println("nothing to repair")
+		return nil
+	}
+	if *dryRun {
+		return nil
+	}
+	out := fs.Arg(1)
+	if samePath(in, out) {
+		return fmt.Errorf("repair: output must differ from input")
+	}
+	if *stamp {
+		if kvs, err = stampProvenance(in, kvs); err != nil {
+			return fmt.Errorf("repair: %w", err)
+		}
+	}
+	return r.write(out, kvs, infoStart, infoEnd, alignUp(infoEnd, align), align)
+}
+
+// scanKVs walks the KV section from off, trusting the header count only as
+// far as the bytes agree with it, and returns the pairs and where the tensor
+// info table starts.
+func (r *repairer) scanKVs(off, declared uint64) ([]rawKV, uint64, error) {
+	var kvs []rawKV
+	for i := uint64(0); ; i++ {
+		if i >= declared {
+			if _, ok := r.tensorInfosAt(off, 0); ok {
+				break
+			}
+			kv, ok := r.kvAt(off, 0, 0)
+			if !ok {
+				return nil, 0, fmt.Errorf("neither a KV pair nor the tensor info table at offset %d", off)
+			}
+			kvs = append(kvs, kv)
+			off = kv.end
+			continue
+		}
+		if kv, ok := r.kvAt(off, 0, 0); ok {
+			kvs = append(kvs, kv)
+			off = kv.end
+			continue
+		}
+		if _, ok := r.tensorInfosAt(off, 0); ok {
+			break // the header claims more pairs than there are
+		}
+		kv, ok := r.offByOne(off)
+		if !ok {
+			return nil, 0, fmt.Errorf("KV pair %d at offset %d does not parse and no known repair applies", i, off)
+		}
+		kvs = append(kvs, kv)
+		off = kv.end
+	}
+	if n := uint64(len(kvs)); n != declared {
+		r.fixes = append(r.fixes, fmt.Sprintf("kv_count: header says %d, KV section holds %d", declared, n))
+	}
+	return kvs, off, nil
+}
+
+// offByOneAdjustments are the key and string value length corrections tried,
+// in order, on a pair that does not parse.
+var offByOneAdjustments = [][2]int64{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+// offByOne retries the pair at off with the key or string value length one
+// byte shorter or longer, and re-encodes the first variant that parses.
+func (r *repairer) offByOne(off uint64) (rawKV, bool) {
+	for _, adj := range offByOneAdjustments {
+		kv, ok := r.kvAt(off, adj[0], adj[1])
+		if !ok {
+			continue
+		}
+		what := "key"
+		if adj[1] != 0 {
+			what = "value"
+		}
+		r.fixes = append(r.fixes, fmt.Sprintf("string_length: declared %s length of %q at offset %d is off by %+d", what, kv.key, off, -(adj[0]+adj[1])))
+		if adj[1] != 0 {
+			kv.enc = encodeKV(kv.key, kv.tag, kv.val) // a string value with its corrected length
+			return kv, true
+		}
+		// Only the key length was wrong: keep the value bytes, which may be an array, as they are
+		valStart := off + 8 + uint64(len(kv.key)) + 4
+		body := make([]byte, kv.end-valStart)
+		if _, err := r.ra.ReadAt(body, int64(valStart)); err != nil {
+			return rawKV{}, false
+		}
+		var head bytes.Buffer
+		putString(&head, kv.key)
+		putU32(&head, kv.tag)
+		kv.enc = append(head.Bytes(), body...)
+		return kv, true
+	}
+	return rawKV{}, false
+}
+
+// kvAt decodes the pair at off, adding keyAdj and valAdj to the declared key
+// and string value lengths. The pair only counts if what follows it looks like
+// another pair, the tensor info table or the end of the file, or is a pair
+// that offByOne can repair in turn.
+func (r *repairer) kvAt(off uint64, keyAdj, valAdj int64) (rawKV, bool) {
+	return r.decodeKV(off, keyAdj, valAdj, true)
+}
+
+// decodeKV is kvAt; without lookahead, only a plausible next entry counts.
+func (r *repairer) decodeKV(off uint64, keyAdj, valAdj int64, lookahead bool) (rawKV, bool) {
+	scn := newScannerAt(r.ra, off)
+	scn.order = r.probe.scn.order
+	readString := func(adj int64, limit uint64) (string, bool) {
+		n, err := scn.U64()
+		if err != nil || (adj < 0 && n == 0) {
+			return "", false
+		}
+		n = uint64(int64(n) + adj)
+		if n > limit || n > r.size {
+			return "", false
+		}
+		b, err := scn.readExact(int(n))
+		return string(b), err == nil
+	}
+	key, ok := readString(keyAdj, maxResyncName)
+	if !ok {
+		return rawKV{}, false
+	}
+	if !printable([]byte(key)) {
+		return rawKV{}, false
+	}
+	tag, err := scn.U32()
+	if err != nil {
+		return rawKV{}, false
+	}
+	var val any
+	switch {
+	case tag == tString:
+		if val, ok = readString(valAdj, r.pol.maxString); !ok {
+			return rawKV{}, false
+		}
+	case valAdj != 0:
+		return rawKV{}, false
+	default:
+		t := &parser{scn: scn, fileSize: r.size, pol: r.pol, ctx: context.Background()}
+		if val, _, _, err = t.readValue(tag, key); err != nil {
+			return rawKV{}, false
+		}
+	}
+	if !r.plausibleAt(scn.pos) && !(lookahead && r.repairableAt(scn.pos)) {
+		return rawKV{}, false
+	}
+	return rawKV{key: key, tag: tag, val: val, start: off, end: scn.pos}, true
+}
+
+// repairableAt reports whether the pair at off parses after one of the
+// offByOne corrections, so a broken pair does not also reject the one before it.
+func (r *repairer) repairableAt(off uint64) bool {
+	for _, adj := range offByOneAdjustments {
+		if _, ok := r.decodeKV(off, adj[0], adj[1], false); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// plausibleAt reports whether off is the end of the file or starts a KV pair
+// or tensor info.
+func (r *repairer) plausibleAt(off uint64) bool {
+	if off == r.size {
+		return true
+	}
+	window := make([]byte, maxResyncName+16)
+	n, _ := r.ra.ReadAt(window, int64(off))
+	window = window[:n]
+	return r.probe.looksLikeKV(window) || r.probe.looksLikeTensorInfo(window)
+}
+
+// tensorInfosAt parses the whole tensor info table from off and returns its
+// end. With a non-zero align it also checks that every tensor fits in the file.
+func (r *repairer) tensorInfosAt(off, align uint64) (uint64, bool) {
+	if r.tc == 0 {
+		return off, off == r.size // an empty table can only sit at the end
+	}
+	scn := newScannerAt(r.ra, off)
+	scn.order = r.probe.scn.order
+	t := &parser{scn: scn, fileSize: r.size, pol: r.pol, tnRemain: r.tc, ctx: context.Background()}
+	var dataEnd uint64
+	for {
+		ti, ok, err := t.nextTensor()
+		if err != nil {
+			return 0, false
+		}
+		if !ok {
+			break
+		}
+		size, ok := ggmlTensorSize(ti.Type, ti.Dims)
+		if !ok {
+			return 0, false
+		}
+		dataEnd = max(dataEnd, ti.Offset+size)
+	}
+	if align > 0 && alignUp(scn.pos, align)+dataEnd > r.size {
+		return 0, false
+	}
+	return scn.pos, true
+}
+
+// splitName matches llama.cpp shard names such as model-00002-of-00005.gguf.
+var splitName = regexp.MustCompile(`^(.*)-(\d{5})-of-(\d{5})\.gguf$`)
+
+// fixSplitCounts corrects split.no and split.count from the shard's file name
+// and split.tensors.count from the sibling shards, when they are all present.
+func (r *repairer) fixSplitCounts(path string, kvs []rawKV) {
+	m := splitName.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return
+	}
+	no, _ := strconv.ParseUint(m[2], 10, 64)
+	count, _ := strconv.ParseUint(m[3], 10, 64)
+	want := map[string]uint64{"split.no": no - 1, "split.count": count}
+	if total, ok := shardTensorTotal(filepath.Join(filepath.Dir(path), m[1]), count); ok {
+		want["split.tensors.count"] = total
+	}
+	for i := range kvs {
+		kv := &kvs[i]
+		w, ok := want[kv.key]
+		if !ok {
+			continue
+		}
+		if got, ok := asUint64(kv.val); ok && got != w {
+			v, ok := intOfTag(kv.tag, w)
+			if !ok {
+				continue
+			}
+			r.fixes = append(r.fixes, fmt.Sprintf("split_count: %s is %d, shards say %d", kv.key, got, w))
+			kv.val, kv.enc = v, encodeKV(kv.key, kv.tag, v)
+		}
+	}
+}
+
+// shardTensorTotal sums the tensor counts of prefix-0000k-of-NNNNN.gguf for
+// every k, failing if any shard is missing or unreadable.
+func shardTensorTotal(prefix string, count uint64) (uint64, bool) {
+	var total uint64
+	for k := uint64(1); k <= count; k++ {
+		f, err := os.Open(fmt.Sprintf("%s-%05d-of-%05d.gguf", prefix, k, count))
+		if err != nil {
+			return 0, false
+		}
+		_, hdr, err := newParserAt(f, 0, basePolicy())
+		f.Close()
+		if err != nil {
+			return 0, false
+		}
+		total += hdr.GGUF.TensorCount
+	}
+	return total, true
+}
+
+// write assembles the repaired file: new header, KV pairs, the original
+// tensor info table and, after fresh alignment padding, the original data.
+func (r *repairer) write(out string, kvs []rawKV, infoStart, infoEnd, dataStart, align uint64) error {
+	af, err := createAtomicFile(out)
+	if err != nil {
+		return err
+	}
+	var head bytes.Buffer
+	head.WriteString(magicGGUF)
+	putU32(&head, 3)
+	putU64(&head, r.tc)
+	putU64(&head, uint64(len(kvs)))
+	pos := uint64(head.Len())
+	copyRange := func(start, end uint64) error {
+		n, err := io.Copy(af, io.NewSectionReader(r.ra, int64(start), int64(end-start)))
+		pos += uint64(n)
+		return err
+	}
+	err = func() error {
+		if _, err := af.Write(head.Bytes()); err != nil {
+			return err
+		}
+		for _, kv := range kvs {
+			if kv.enc == nil {
+				if err := copyRange(kv.start, kv.end); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := af.Write(kv.enc); err != nil {
+				return err
+			}
+			pos += uint64(len(kv.enc))
+		}
+		if err := copyRange(infoStart, infoEnd); err != nil {
+			return err
+		}
+		if dataStart <= r.size && (r.tc > 0 || dataStart < r.size) {
+			if _, err := af.Write(make([]byte, alignUp(pos, align)-pos)); err != nil {
+				return err
+			}
+			return copyRange(dataStart, r.size)
+		}
+		return nil
+	}()
+	if err == nil {
+		err = af.Close()
+	}
+	if err == nil {
+		keys := make([]string, len(kvs))
+		for i, kv := range kvs {
+			keys[i] = kv.key
+		}
+		err = checkRewrite(af.tmp.Name(), keys, r.tc)
+	}
+	if err != nil {
+		af.Abort()
+		return err
+	}
+	return af.Commit()
+}
+
+// checkRewrite re-parses a rewritten file before it is published: it must
+// hold exactly keys, in order, and tc tensors whose data fits in the file.
+func checkRewrite(path string, keys []string, tc uint64) error {
+	pol := basePolicy()
+	pol.lenient = false
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return fmt.Errorf("rewritten file does not parse: %w", err)
+	}
+	if len(gf.KVs) != len(keys) || uint64(len(gf.Tensors)) != tc {
+		return fmt.Errorf("rewritten file holds %d KV pairs and %d tensors, want %d and %d", len(gf.KVs), len(gf.Tensors), len(keys), tc)
+	}
+	for i, kv := range gf.KVs {
+		if kv.Key != keys[i] {
+			return fmt.Errorf("rewritten file has key %q where %q belongs", kv.Key, keys[i])
+		}
+	}
+	for _, t := range gf.Tensors {
+		if size, ok := ggmlTensorSize(t.Type, t.Dims); ok && gf.DataOffset+t.Offset+size > gf.Size {
+			return fmt.Errorf("rewritten file cuts off the data of tensor %q", t.Name)
+		}
+	}
+	return nil
+}
+
+// encodeKV encodes a scalar KV pair in little-endian order.
+func encodeKV(key string, tag uint32, v any) []byte {
+	var buf bytes.Buffer
+	putString(&buf, key)
+	putU32(&buf, tag)
+	putScalar(&buf, v)
+	return buf.Bytes()
+}
+
+// intOfTag converts n to the Go type of an integer GGUF tag.
+func intOfTag(tag uint32, n uint64) (any, bool) {
+	switch tag {
+	case tUint8:
+		return uint8(n), n <= 0xff
+	case tInt8:
+		return int8(n), n <= 0x7f
+	case tUint16:
+		return uint16(n), n <= 0xffff
+	case tInt16:
+		return int16(n), n <= 0x7fff
+	case tUint32:
+		return uint32(n), n <= 0xffffffff
+	case tInt32:
+		return int32(n), n <= 0x7fffffff
+	case tUint64:
+		return n, true
+	case tInt64:
+		return int64(n), n <= 1<<63-1
+	}
+	return nil, false
+}
+
+// samePath reports whether a and b name the same file location.
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -0,0 +1,236 @@
+// Package main implements the `requant-estimate` subcommand.
+// It predicts the size of a file requantized to a llama.cpp file type by
+// choosing each tensor's type with a simplified version of llama-quantize's
+// mixing rules (which tensors keep more bits) and summing the block layouts.
+// Expect the prediction within a few percent; the rules for the IQ types and
+// for unusual architectures are coarser.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// quantMix describes one llama-quantize target: the base type and the
+// stronger types used for the sensitive tensors.
+type quantMix struct {
+	Base   string
+	Output string // output.weight (and tied embeddings)
+	AttnV  string // attn_v.weight, "" for Base
+	FFNDn  string // ffn_down.weight in the layers that get more bits, "" for Base
+	AttnO  string // attn_output.weight, "" for Base
+	// MoreBits selects which layers get FFNDn: "half" uses llama.cpp's
+	// use_more_bits pattern, "first8" the first eighth of layers, "all" every layer.
+	MoreBits string
+}
+
+var quantMixes = map[string]quantMix{
+	"F32":     {Base: "F32", Output: "F32"},
+	"F16":     {Base: "F16", Output: "F16"},
+	"BF16":    {Base: "BF16", Output: "BF16"},
+	"Q8_0":    {Base: "Q8_0", Output: "Q8_0"},
+	"Q4_0":    {Base: "Q4_0", Output: "Q6_K"},
+	"Q4_1":    {Base: "Q4_1", Output: "Q6_K"},
+	"Q5_0":    {Base: "Q5_0", Output: "Q6_K"},
+	"Q5_1":    {Base: "Q5_1", Output: "Q6_K"},
+	"Q2_K":    {Base: "Q2_K", Output: "Q6_K", AttnV: "Q4_K", FFNDn: "Q3_K", MoreBits: "all", AttnO: "Q3_K"},
+	"Q3_K_S":  {Base: "Q3_K", Output: "Q6_K"},
+	"Q3_K_M":  {Base: "Q3_K", Output: "Q6_K", AttnV: "Q4_K", FFNDn: "Q4_K", MoreBits: "half", AttnO: "Q4_K"},
+	"Q3_K_L":  {Base: "Q3_K", Output: "Q6_K", AttnV: "Q5_K", FFNDn: "Q5_K", MoreBits: "all", AttnO: "Q5_K"},
+	"Q4_K_S":  {Base: "Q4_K", Output: "Q6_K", FFNDn: "Q5_K", MoreBits: "first8"},
+	"Q4_K_M":  {Base: "Q4_K", Output: "Q6_K", AttnV: "Q6_K", FFNDn: "Q6_K", MoreBits: "half"},
+	"Q5_K_S":  {Base: "Q5_K", Output: "Q6_K"},
+	"Q5_K_M":  {Base: "Q5_K", Output: "Q6_K", AttnV: "Q6_K", FFNDn: "Q6_K", MoreBits: "half"},
+	"Q6_K":    {Base: "Q6_K", Output: "Q6_K"},
+	"IQ4_NL":  {Base: "IQ4_NL", Output: "Q6_K", AttnV: "Q5_K"},
+	"IQ4_XS":  {Base: "IQ4_XS", Output: "Q6_K", AttnV: "Q5_K"},
+	"IQ3_M":   {Base: "IQ3_S", Output: "Q6_K", AttnV: "Q4_K", FFNDn: "Q4_K", MoreBits: "half"},
+	"IQ3_XXS": {Base: "IQ3_XXS", Output: "Q6_K", AttnV: "Q4_K"},
+	"IQ2_M":   {Base: "IQ2_S", Output: "Q6_K", AttnV: "Q4_K", FFNDn: "Q3_K", MoreBits: "first8"},
+	"IQ2_XS":  {Base: "IQ2_XS", Output: "Q6_K", AttnV: "Q4_K", FFNDn: "Q3_K", MoreBits: "first8"},
+	"IQ1_M":   {Base: "IQ1_M", Output: "Q6_K", AttnV: "Q4_K", FFNDn: "Q2_K", MoreBits: "first8"},
+}
+
+// requantReport is the document `ggufmeta requant-estimate` prints.
+type requantReport struct {
+	File       string                  `json:"file"`
+	Target     string                  `json:"target"`
+	Current    uint64                  `json:"currentSize"`
+	Predicted  uint64                  `json:"predictedSize"`
+	Human      string                  `json:"predictedHuman"`
+	Ratio      float64                 `json:"ratio"` // predicted / current
+	Types      map[string]requantShare `json:"types"`
+	BitsPerWgt float64                 `json:"bitsPerWeight"` // over quantized tensors
+}
+
+// requantShare is how many tensors, and bytes, end up in one ggml type.
+type requantShare struct {
+	Tensors int    `json:"tensors"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+func runRequantEstimate(args []string) error {
+	fs := flag.NewFlagSet("requant-estimate", flag.ExitOnError)
+	target := fs.String("target", "", "llama-quantize file type, e.g. Q4_K_M, Q8_0, IQ4_XS")
+	fs.Usage = func() {
+		names := make([]string, 0, len(quantMixes))
+		for name := range quantMixes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta requant-estimate --target TYPE file.gguf\n")
+		fmt.Fprintf(os.Stderr, "\nTargets: %s\n", strings.Join(names, ", "))
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *target == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	mix, ok := quantMixes[strings.ToUpper(*target)]
+	if !ok {
+		return fmt.Errorf("requant-estimate: unknown target %q (see -h)", *target)
+	}
+	gf, err := loadFile(context.Background(), fs.Arg(0), basePolicy())
+	if err != nil {
+		return err
+	}
+	rep := estimateRequant(gf, mix)
+	rep.Target = strings.ToUpper(*target)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// estimateRequant lays the requantized tensors out after the unchanged
+// metadata, at the file's alignment, and sums the result.
+func estimateRequant(gf *ggufFile, mix quantMix) requantReport {
+	rep := requantReport{File: gf.Path, Current: gf.Size, Types: make(map[string]requantShare)}
+	layers, _ := gf.Uint("{arch}.block_count")
+	align := gf.Alignment()
+	var rel, qBytes, qElems uint64
+	for _, t := range gf.Tensors {
+		typ := requantType(t, mix, layers)
+		size, ok := ggmlTensorSize(typ, t.Dims)
+		if !ok {
+			// Unknown source layout or a row not divisible by the block: keep as is
+			typ = t.Type
+			size, _ = ggmlTensorSize(typ, t.Dims)
+		}
+		rel = alignUp(rel, align) + size
+		share := rep.Types[ggmlTypeName(typ)]
+		share.Tensors++
+		share.Bytes += size
+		rep.Types[ggmlTypeName(typ)] = share
+		if len(t.Dims) >= 2 {
+			qBytes += size
+			qElems += tensorElems(t.Dims)
+		}
+	}
+	rep.Predicted = alignUp(gf.InfoEnd, align) + alignUp(rel, align)
+	rep.Human = humanBytes(rep.Predicted)
+	if rep.Current > 0 {
+		rep.Ratio = float64(rep.Predicted) / float64(rep.Current)
+	}
+	if qElems > 0 {
+		rep.BitsPerWgt = float64(qBytes) * 8 / float64(qElems)
+	}
+	return rep
+}
+
+// requantType picks the ggml type llama-quantize would give t.
+func requantType(t tensorInfo, mix quantMix, layers uint64) uint32 {
+	name := mix.Base
+	layer, inBlock := blockIndex(t.Name)
+	switch {
+	case len(t.Dims) < 2:
+		// Norms and biases are never quantized
+		name = "F32"
+	case t.Name == "output.weight":
+		name = mix.Output
+	case strings.HasSuffix(t.Name, "attn_v.weight") && mix.AttnV != "":
+		name = mix.AttnV
+	case strings.HasSuffix(t.Name, "attn_output.weight") && mix.AttnO != "":
+		name = mix.AttnO
+	case strings.HasSuffix(t.Name, "ffn_down.weight") && mix.FFNDn != "" && inBlock && moreBits(mix.MoreBits, layer, layers):
+		name = mix.FFNDn
+	}
+	typ, _ := ggmlTypeByName(name)
+	if rowsFit(t, typ) {
+		return typ
+	}
+	// llama.cpp falls back once when rows do not split into whole blocks, then to F16
+	if fb, ok := requantFallback[ggmlTypeName(typ)]; ok {
+		if typ, _ = ggmlTypeByName(fb); rowsFit(t, typ) {
+			return typ
+		}
+	}
+	typ, _ = ggmlTypeByName("F16")
+	return typ
+}
+
+// requantFallback mirrors llama-quantize's substitutes for a type whose block
+// size does not divide a tensor's rows.
+var requantFallback = map[string]string{
+	"TQ1_0":   "Q4_0",
+	"TQ2_0":   "Q4_0",
+	"IQ1_S":   "IQ4_NL",
+	"IQ1_M":   "IQ4_NL",
+	"IQ2_XXS": "IQ4_NL",
+	"IQ2_XS":  "IQ4_NL",
+	"IQ2_S":   "IQ4_NL",
+	"IQ3_XXS": "IQ4_NL",
+	"IQ3_S":   "IQ4_NL",
+	"IQ4_XS":  "IQ4_NL",
+	"Q2_K":    "IQ4_NL",
+	"Q3_K":    "IQ4_NL",
+	"Q4_K":    "Q5_0",
+	"Q5_K":    "Q5_1",
+	"Q6_K":    "Q8_0",
+}
+
+// rowsFit reports whether t's rows split into whole blocks of typ.
+func rowsFit(t tensorInfo, typ uint32) bool {
+	blk := ggmlBlocks[typ]
+	return blk.Elems > 0 && t.Dims[0]%blk.Elems == 0
+}
+
+// moreBits reports whether layer i of n gets the stronger ffn_down type.
+func moreBits(pattern string, i, n uint64) bool {
+	switch pattern {
+	case "all":
+		return true
+	case "first8":
+		return i < n/8
+	case "half":
+		// llama.cpp's use_more_bits
+		return i < n/8 || i >= 7*n/8 || (i >= n/8 && (i-n/8)%3 == 2)
+	}
+	return false
+}
+
+// blockIndex extracts N from a "blk.N." tensor name.
+func blockIndex(name string) (uint64, bool) {
+	rest, ok := strings.CutPrefix(name, "blk.")
+	if !ok {
+		return 0, false
+	}
+	num, _, _ := strings.Cut(rest, ".")
+	n, err := strconv.ParseUint(num, 10, 64)
+	return n, err == nil
+}
+
+// tensorElems is the element count of a tensor with the given dims.
+func tensorElems(dims []uint64) uint64 {
+	n := uint64(1)
+	for _, d := range dims {
+		n *= d
+	}
+	return n
+}
@@ -0,0 +1,72 @@
+// Package main implements the per-architecture required-keys validation check.
+// Loaders abort on the first hyperparameter they cannot find, so a missing
+// key is the most common reason a converted file will not load; this check
+// lists every missing one at once.
+package main
+
+import "sort"
+
+// commonRequiredKeys are needed by every architecture; "{arch}" expands to
+// general.architecture.
+var commonRequiredKeys = []string{
+	"{arch}.context_length",
+	"{arch}.embedding_length",
+	"{arch}.block_count",
+}
+
+// archRequiredKeys adds what each architecture's loader reads without a
+// default, following llama.cpp's hyperparameter loading.
+var archRequiredKeys = func() map[string][]string {
+	const (
+		heads = "{arch}.attention.head_count"
+		ffn   = "{arch}.feed_forward_length"
+		rms   = "{arch}.attention.layer_norm_rms_epsilon"
+		norm  = "{arch}.attention.layer_norm_epsilon"
+		exp   = "{arch}.expert_count"
+		used  = "{arch}.expert_used_count"
+		expFF = "{arch}.expert_feed_forward_length"
+	)
+	m := make(map[string][]string)
+	for _, a := range []string{"llama", "llama4", "qwen2", "qwen3", "gemma", "gemma2", "gemma3", "mistral3", "granite", "olmo", "olmo2", "internlm2", "minicpm", "phi3", "exaone", "orion", "xverse", "baichuan", "cohere2", "chameleon"} {
+		m[a] = []string{heads, ffn, rms}
+	}
+	for _, a := range []string{"falcon", "gptneox", "gpt2", "gptj", "phi2", "stablelm", "starcoder", "starcoder2", "bloom", "mpt", "command-r", "refact", "bert", "nomic-bert", "jina-bert-v2", "codeshell", "qwen", "jais"} {
+		m[a] = []string{heads, ffn, norm}
+	}
+	for _, a := range []string{"qwen2moe", "qwen3moe", "olmoe", "granitemoe", "phimoe"} {
+		m[a] = []string{heads, rms, exp, used, expFF}
+	}
+	m["deepseek2"] = []string{heads, ffn, rms, exp, used, expFF,
+		"{arch}.leading_dense_block_count", "{arch}.attention.kv_lora_rank",
+		"{arch}.attention.key_length", "{arch}.attention.value_length"}
+	m["gpt-oss"] = []string{heads, rms, exp, used, expFF, "{arch}.attention.sliding_window"}
+	m["mamba"] = []string{rms, "{arch}.ssm.conv_kernel", "{arch}.ssm.inner_size", "{arch}.ssm.state_size", "{arch}.ssm.time_step_rank"}
+	return m
+}()
+
+func init() {
+	registerCheck("required", checkRequiredKeys)
+}
+
+func checkRequiredKeys(v *validation) {
+	arch := v.gf.Arch()
+	if arch == "" {
+		v.report("error", "general.architecture", "", "required key is missing; no other requirement can be checked")
+		return
+	}
+	extra, known := archRequiredKeys[arch]
+	if !known {
+		v.report("info", "general.architecture", "", "no key requirements known for architecture %q; checked the common keys only", arch)
+	}
+	var missing []string
+	for _, key := range append(append([]string{}, commonRequiredKeys...), extra...) {
+		key = expandArch(v.gf, key)
+		if _, ok := v.gf.Get(key); !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		v.report("error", key, "", "required by %s loaders but missing", arch)
+	}
+}
@@ -0,0 +1,123 @@
+// Package main implements the value sanity validation check.
+// A key can be present and well typed and still hold a value no model can
+// have: a zero RoPE base, heads that do not divide the embedding, a vocabulary
+// size that disagrees with the token list. Impossible values are errors;
+// merely unusual ones are warnings.
+package main
+
+import "fmt"
+
+// maxSaneContext is the largest context length accepted without a warning.
+const maxSaneContext = 1 << 24
+
+func init() {
+	registerCheck("sanity", checkSanity)
+}
+
+func checkSanity(v *validation) {
+	gf := v.gf
+	key := func(suffix string) string { return gf.Arch() + "." + suffix }
+
+	if n, ok := gf.Uint("{arch}.context_length"); ok {
+		switch {
+		case n == 0:
+			v.report("error", key("context_length"), "", "context length is 0")
+		case n > maxSaneContext:
+			v.report("warning", key("context_length"), "", "context length %d is implausibly large", n)
+		}
+	}
+	if n, ok := gf.Uint("{arch}.block_count"); ok && n == 0 {
+		v.report("error", key("block_count"), "", "block count is 0")
+	}
+
+	for _, suffix := range []string{"rope.freq_base", "rope.scaling.factor"} {
+		if f, ok := floatKey(gf, key(suffix)); ok && !(f > 0) {
+			v.report("error", key(suffix), "", "must be positive, got %g", f)
+		}
+	}
+	for _, suffix := range []string{"attention.layer_norm_epsilon", "attention.layer_norm_rms_epsilon"} {
+		if f, ok := floatKey(gf, key(suffix)); ok {
+			switch {
+			case !(f > 0):
+				v.report("error", key(suffix), "", "must be positive, got %g", f)
+			case f > 0.01:
+				v.report("warning", key(suffix), "", "%g is unusually large for a norm epsilon", f)
+			}
+		}
+	}
+
+	checkHeads(v)
+
+	// The vocabulary is the token list; vocab_size, when present, must agree
+	if kv, ok := gf.Get("tokenizer.ggml.tokens"); ok {
+		if tokens, ok := arrayLen(kv.Value); ok {
+			if n, ok := gf.Uint("{arch}.vocab_size"); ok && n != tokens {
+				v.report("error", key("vocab_size"), "", "vocab size %d does not match %d tokens in tokenizer.ggml.tokens", n, tokens)
+			}
+			for _, id := range []string{"bos", "eos", "eot", "eom", "unknown", "separator", "padding", "mask"} {
+				k := "tokenizer.ggml." + id + "_token_id"
+				if n, ok := gf.Uint(k); ok && n >= tokens {
+					v.report("error", k, "", "token id %d is outside the vocabulary of %d tokens", n, tokens)
+				}
+			}
+		}
+	}
+}
+
+// checkHeads verifies the attention head geometry, layer by layer when the
+// counts are per-layer arrays.
+func checkHeads(v *validation) {
+	gf := v.gf
+	layers, ok := gf.Uint("{arch}.block_count")
+	emb, okEmb := gf.Uint("{arch}.embedding_length")
+	if !ok || layers == 0 {
+		return
+	}
+	heads, err := perLayer(gf, "{arch}.attention.head_count", layers)
+	if err != nil {
+		return
+	}
+	kvHeads, err := perLayer(gf, "{arch}.attention.head_count_kv", layers)
+	if err != nil {
+		kvHeads = heads // absent means no grouped-query attention
+	}
+	_, explicitDim := gf.Uint("{arch}.attention.key_length")
+	headKey := expandArch(gf, "{arch}.attention.head_count")
+	reported := make(map[string]bool) // one finding per kind, not per layer
+	report := func(kind, format string, args ...any) {
+		if !reported[kind] {
+			reported[kind] = true
+			v.report("error", headKey, "", "%s", fmt.Sprintf(format, args...))
+		}
+	}
+	for i := range heads {
+		h, kvh := heads[i], kvHeads[i]
+		if h == 0 {
+			continue // attention-free layer (recurrent hybrids)
+		}
+		if okEmb && !explicitDim && emb%h != 0 {
+			report("embd", "layer %d: %d heads do not divide embedding length %d (set attention.key_length if heads are not embedding_length/head_count wide)", i, h, emb)
+		}
+		if kvh == 0 || h%kvh != 0 {
+			report("gqa", "layer %d: %d heads are not a multiple of %d key/value heads", i, h, kvh)
+		}
+	}
+}
+
+// floatKey returns a numeric key as float64.
+func floatKey(gf *ggufFile, key string) (float64, bool) {
+	kv, ok := gf.Get(key)
+	if !ok {
+		return 0, false
+	}
+	switch x := kv.Value.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	if n, ok := asUint64(kv.Value); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
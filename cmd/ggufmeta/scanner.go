@@ -5,26 +5,77 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"unicode/utf8"
 )
 
 type scanner struct {
 	r     io.Reader
+	ra    io.ReaderAt // when set, reads are positioned at pos and r is unused
 	order binary.ByteOrder
 	pos   uint64
+	tee   io.Writer // when set, receives every byte consumed (see skipArray)
 }
 
 func newScanner(r io.Reader) *scanner { return &scanner{r: r} }
 
+// newScannerAt reads through ReadAt starting at off. It keeps no state in the
+// ReaderAt, so any number of scanners can share one file handle concurrently.
+func newScannerAt(ra io.ReaderAt, off uint64) *scanner { return &scanner{ra: ra, pos: off} }
+
 // readExact reads exactly n bytes and updates position - single source of truth
 func (s *scanner) readExact(n int) ([]byte, error) {
 	buf := make([]byte, n)
-	_, err := io.ReadFull(s.r, buf)
+	var err error
+	if s.ra != nil {
+		var m int
+		m, err = s.ra.ReadAt(buf, int64(s.pos))
+		switch {
+		case m == n:
+			err = nil // ReadAt may report io.EOF alongside a full read
+		case err == io.EOF && m > 0:
+			err = io.ErrUnexpectedEOF // same contract as io.ReadFull
+		}
+	} else {
+		_, err = io.ReadFull(s.r, buf)
+	}
 	if err == nil {
 		s.pos += uint64(n)
+		if s.tee != nil {
+			s.tee.Write(buf)
+		}
+	} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: %w", errTruncated, err)
 	}
 	return buf, err
 }
 
+// peeker is implemented by readers that can look ahead without consuming, like bufio.Reader.
+type peeker interface {
+	Peek(n int) ([]byte, error)
+}
+
+// peek returns up to n bytes at the current position without consuming them.
+// Stream input must implement peeker; fewer than n bytes are returned near EOF.
+func (s *scanner) peek(n int) ([]byte, error) {
+	var b []byte
+	var err error
+	switch pk, ok := s.r.(peeker); {
+	case s.ra != nil:
+		b = make([]byte, n)
+		var m int
+		m, err = s.ra.ReadAt(b, int64(s.pos))
+		b = b[:m]
+	case ok:
+		b, err = pk.Peek(n)
+	default:
+		return nil, fmt.Errorf("input does not support look-ahead")
+	}
+	if len(b) > 0 {
+		return b, nil
+	}
+	return nil, err
+}
+
 func (s *scanner) b(n int) ([]byte, error) {
 	return s.readExact(n)
 }
@@ -95,7 +146,7 @@ func (s *scanner) GGUFString(max uint64) (string, error) {
 		return "", err
 	}
 	if n > max {
-		return "", fmt.Errorf("string too large: %d > %d", n, max)
+		return "", fmt.Errorf("%w: %d > %d", errStringTooLarge, n, max)
 	}
 	buf, err := s.b(int(n))
 	if err != nil {
@@ -104,6 +155,81 @@ func (s *scanner) GGUFString(max uint64) (string, error) {
 	return string(buf), nil
 }
 
+// GGUFStringPrefix reads a string like GGUFString, except that one longer
+// than max is cut to its first max bytes, less any UTF-8 sequence the cut
+// splits, and the rest is skipped. n is the full length.
+func (s *scanner) GGUFStringPrefix(max uint64) (prefix string, n uint64, err error) {
+	if n, err = s.U64(); err != nil {
+		return "", 0, err
+	}
+	keep := min(n, max)
+	buf, err := s.b(int(keep))
+	if err != nil {
+		return "", 0, err
+	}
+	if keep < n {
+		if err := s.skip(n - keep); err != nil {
+			return "", 0, err
+		}
+		for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(buf[i]) {
+				if !utf8.FullRune(buf[i:]) {
+					buf = buf[:i]
+				}
+				break
+			}
+		}
+	}
+	return string(buf), n, nil
+}
+
+// skip advances past n bytes without keeping them.
+func (s *scanner) skip(n uint64) error {
+	if n == 0 {
+		return nil
+	}
+	if n > math.MaxInt64 {
+		return fmt.Errorf("%w: cannot skip %d bytes", errTruncated, n)
+	}
+	if s.tee != nil {
+		return s.copyN(io.Discard, n)
+	}
+	if s.ra != nil {
+		// Positioned reads need not touch the bytes, only prove the last one exists
+		if _, err := s.ra.ReadAt(make([]byte, 1), int64(s.pos+n-1)); err != nil {
+			return fmt.Errorf("%w: %w", errTruncated, io.ErrUnexpectedEOF)
+		}
+		s.pos += n
+		return nil
+	}
+	m, err := io.CopyN(io.Discard, s.r, int64(n))
+	s.pos += uint64(m)
+	if err == io.EOF {
+		err = fmt.Errorf("%w: %w", errTruncated, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// copyN copies the next n bytes to w.
+func (s *scanner) copyN(w io.Writer, n uint64) error {
+	if n > math.MaxInt64 {
+		return fmt.Errorf("%w: cannot read %d bytes", errTruncated, n)
+	}
+	src := s.r
+	if s.ra != nil {
+		src = io.NewSectionReader(s.ra, int64(s.pos), int64(n))
+	}
+	if s.tee != nil {
+		w = io.MultiWriter(w, s.tee)
+	}
+	m, err := io.CopyN(w, src, int64(n))
+	s.pos += uint64(m)
+	if err == io.EOF {
+		err = fmt.Errorf("%w: %w", errTruncated, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
 func (s *scanner) Align(n uint64) error {
 	if n == 0 {
 		return nil
@@ -0,0 +1,237 @@
+// Package main implements the `schema` subcommand.
+// This file holds the JSON Schema describing every NDJSON record the tool emits,
+// so consumers in other languages can generate typed bindings.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// outputSchema is a JSON Schema (draft 2020-12) for one NDJSON output line.
+// Keep it in sync with headerEvent, kvEvent, traceEvent, conflictEvent, grepEvent,
+// tensorRecord, finding, keyAnnotation and the array placeholder maps.
+const outputSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/radiolabme/ggufmeta/schema/record.json",
+  "title": "ggufmeta NDJSON record",
+  "description": "One line of ggufmeta output: a header record followed by one kv record per metadata entry.",
+  "oneOf": [
+    { "$ref": "#/$defs/headerEvent" },
+    { "$ref": "#/$defs/kvEvent" },
+    { "$ref": "#/$defs/traceEvent" },
+    { "$ref": "#/$defs/conflictEvent" },
+    { "$ref": "#/$defs/grepEvent" },
+    { "$ref": "#/$defs/tensorRecord" },
+    { "$ref": "#/$defs/finding" }
+  ],
+  "$defs": {
+    "headerEvent": {
+      "type": "object",
+      "description": "First record of the stream; summarizes the GGUF header.",
+      "required": ["kind", "gguf"],
+      "additionalProperties": false,
+      "properties": {
+        "kind": { "const": "header" },
+        "gguf": {
+          "type": "object",
+          "required": ["version", "tensorCount", "kvCount"],
+          "additionalProperties": false,
+          "properties": {
+            "version": { "type": "integer", "minimum": 0, "maximum": 4294967295 },
+            "tensorCount": { "type": "integer", "minimum": 0 },
+            "kvCount": { "type": "integer", "minimum": 0 }
+          }
+        }
+      }
+    },
+    "kvEvent": {
+      "type": "object",
+      "description": "One metadata key/value pair.",
+      "required": ["key", "type", "value"],
+      "additionalProperties": false,
+      "properties": {
+        "key": { "type": "string" },
+        "type": {
+          "type": "string",
+          "description": "GGUF type name, e.g. \"uint32\", \"string\" or \"array[int32]\"; unknown tags render as \"unknown(N)\".",
+          "pattern": "^(uint8|int8|uint16|int16|uint32|int32|float32|bool|string|uint64|int64|float64|array\\[[a-z0-9()]+\\]|unknown\\([0-9]+\\))$"
+        },
+        "value": {
+          "anyOf": [
+            { "$ref": "#/$defs/scalar" },
+            { "$ref": "#/$defs/base64String" },
+            { "$ref": "#/$defs/arrayPlaceholder" },
+            { "$ref": "#/$defs/truncatedString" },
+            { "$ref": "#/$defs/stringFile" },
+            { "$ref": "#/$defs/arrayRange" },
+            { "$ref": "#/$defs/unknownPlaceholder" },
+            { "type": "array", "items": { "anyOf": [ { "$ref": "#/$defs/scalar" }, { "$ref": "#/$defs/base64String" }, { "$ref": "#/$defs/truncatedString" }, { "$ref": "#/$defs/arrayPlaceholder" } ] } }
+          ]
+        },
+        "annotation": {
+          "type": "object",
+          "description": "Added by --annotate from the key registry.",
+          "additionalProperties": false,
+          "properties": {
+            "owner": { "type": "string", "description": "Namespace owning the key: general, tokenizer, an architecture, ..." },
+            "expected": { "type": "string", "description": "Expected type class, e.g. \"int\", \"float\" or \"int|array[int]\"." },
+            "description": { "type": "string" },
+            "warning": { "type": "string", "description": "Unknown key, unexpected type or another architecture's namespace." },
+            "suggestion": { "type": "string", "description": "Closest known key to an unknown one." }
+          }
+        }
+      }
+    },
+    "traceEvent": {
+      "type": "object",
+      "description": "Emitted with --trace before the record it explains: the half-open byte range [start, end) of one parsed field.",
+      "required": ["kind", "field", "start", "end"],
+      "additionalProperties": false,
+      "properties": {
+        "kind": { "const": "trace" },
+        "field": { "enum": ["magic", "version", "tensor_count", "kv_count", "key", "tag", "value", "name", "n_dims", "dims", "type", "offset"] },
+        "key": { "type": "string" },
+        "tensor": { "type": "string" },
+        "start": { "type": "integer", "minimum": 0 },
+        "end": { "type": "integer", "minimum": 0 }
+      }
+    },
+    "conflictEvent": {
+      "type": "object",
+      "description": "Emitted by merge instead of a kv record for a key both sides changed differently; null means the key is absent on that side.",
+      "required": ["kind", "key", "base", "ours", "theirs"],
+      "additionalProperties": false,
+      "properties": {
+        "kind": { "const": "conflict" },
+        "key": { "type": "string" },
+        "base": { "anyOf": [ { "$ref": "#/$defs/kvEvent" }, { "type": "null" } ] },
+        "ours": { "anyOf": [ { "$ref": "#/$defs/kvEvent" }, { "type": "null" } ] },
+        "theirs": { "anyOf": [ { "$ref": "#/$defs/kvEvent" }, { "type": "null" } ] }
+      }
+    },
+    "grepEvent": {
+      "type": "object",
+      "description": "Emitted with --grep instead of kv records: one value, or one array element (with its index), that matched the pattern.",
+      "required": ["kind", "key", "type", "value"],
+      "additionalProperties": false,
+      "properties": {
+        "kind": { "const": "match" },
+        "key": { "type": "string" },
+        "index": { "type": "integer", "minimum": 0 },
+        "type": { "type": "string" },
+        "value": { "anyOf": [ { "$ref": "#/$defs/scalar" }, { "$ref": "#/$defs/base64String" } ] }
+      }
+    },
+    "tensorRecord": {
+      "type": "object",
+      "description": "One tensor, as printed by the tensors subcommand.",
+      "required": ["name", "type", "dims", "offset", "size", "share"],
+      "additionalProperties": false,
+      "properties": {
+        "name": { "type": "string" },
+        "type": { "type": "string", "description": "ggml type name, e.g. \"F16\" or \"Q4_K\"." },
+        "dims": { "type": "array", "items": { "type": "integer", "minimum": 0 } },
+        "offset": { "type": "integer", "minimum": 0, "description": "Byte offset relative to the start of the data section." },
+        "size": { "type": "integer", "minimum": 0, "description": "Payload size in bytes." },
+        "share": { "type": "number", "minimum": 0, "description": "size as a fraction of the file size." },
+        "hash": { "type": "string", "description": "With --hash, \"algo:hex\" of the payload.", "pattern": "^(md5|sha1|sha256|sha512):[0-9a-f]+$" }
+      }
+    },
+    "finding": {
+      "type": "object",
+      "description": "A warning or other finding from validate, as listed in its --json report.",
+      "required": ["severity", "check", "message"],
+      "additionalProperties": false,
+      "properties": {
+        "severity": { "enum": ["error", "warning", "info"] },
+        "check": { "type": "string", "description": "Name of the check that reported it." },
+        "key": { "type": "string" },
+        "tensor": { "type": "string" },
+        "message": { "type": "string" }
+      }
+    },
+    "unknownPlaceholder": {
+      "type": "object",
+      "description": "With --lenient, stands in for a value whose type tag is unknown; skipped is the number of bytes jumped over (absent when the value ended the metadata).",
+      "required": ["_placeholder", "tag"],
+      "properties": {
+        "_placeholder": { "const": "unknown_type" },
+        "tag": { "type": "integer", "minimum": 0 },
+        "skipped": { "type": "integer", "minimum": 0 }
+      }
+    },
+    "scalar": {
+      "type": ["string", "number", "integer", "boolean"]
+    },
+    "base64String": {
+      "type": "object",
+      "description": "A string value that is not valid UTF-8, as the base64 of its bytes.",
+      "required": ["encoding", "data"],
+      "additionalProperties": false,
+      "properties": {
+        "encoding": { "const": "base64" },
+        "data": { "type": "string", "contentEncoding": "base64" }
+      }
+    },
+    "truncatedString": {
+      "type": "object",
+      "description": "With --truncate-strings, stands in for a string longer than --max-string: its first bytes (less a UTF-8 sequence the cut would split) and its full length in bytes.",
+      "required": ["_placeholder", "length", "prefix"],
+      "properties": {
+        "_placeholder": { "const": "truncated_string" },
+        "length": { "type": "integer", "minimum": 0 },
+        "prefix": { "anyOf": [ { "type": "string" }, { "$ref": "#/$defs/base64String" } ] }
+      }
+    },
+    "stringFile": {
+      "type": "object",
+      "description": "With --strings-to-dir, stands in for a string longer than --max-string that was copied to the file at path; length is in bytes.",
+      "required": ["_placeholder", "length", "path"],
+      "properties": {
+        "_placeholder": { "const": "string_file" },
+        "length": { "type": "integer", "minimum": 0 },
+        "path": { "type": "string" }
+      }
+    },
+    "arrayRange": {
+      "type": "object",
+      "description": "With --expand-range, elements start to end-1 of an array of count elements.",
+      "required": ["_placeholder", "count", "element_type", "start", "end", "elements"],
+      "properties": {
+        "_placeholder": { "const": "array_range" },
+        "count": { "type": "integer", "minimum": 0 },
+        "element_type": { "type": "string" },
+        "start": { "type": "integer", "minimum": 0 },
+        "end": { "type": "integer", "minimum": 0 },
+        "elements": { "type": "array", "items": { "anyOf": [ { "$ref": "#/$defs/scalar" }, { "$ref": "#/$defs/base64String" }, { "$ref": "#/$defs/truncatedString" }, { "$ref": "#/$defs/arrayPlaceholder" } ] } }
+      }
+    },
+    "arrayPlaceholder": {
+      "type": "object",
+      "description": "Stands in for array contents that were skipped rather than expanded.",
+      "required": ["_placeholder", "count", "element_type"],
+      "properties": {
+        "_placeholder": { "enum": ["array", "nested_array"] },
+        "count": { "type": "integer", "minimum": 0 },
+        "element_type": { "type": "string", "description": "GGUF type name of the elements, or \"unknown\" for a tag this tool does not know." },
+        "element_tag": { "type": "integer", "minimum": 0, "description": "Only when element_type is \"unknown\": the raw element type tag." },
+        "byte_length": { "type": "integer", "minimum": 0, "description": "Encoded size of the skipped elements, string length prefixes and nested array headers included." },
+        "first": { "description": "First element; absent for empty arrays and arrays of arrays. Strings over 256 bytes are truncatedString placeholders.", "anyOf": [ { "$ref": "#/$defs/scalar" }, { "$ref": "#/$defs/base64String" }, { "$ref": "#/$defs/truncatedString" } ] },
+        "last": { "description": "Last element, as first.", "anyOf": [ { "$ref": "#/$defs/scalar" }, { "$ref": "#/$defs/base64String" }, { "$ref": "#/$defs/truncatedString" } ] },
+        "max_length": { "type": "integer", "minimum": 0, "description": "String arrays: length in bytes of the longest element." },
+        "digest": { "type": "string", "description": "With --array-digest, \"algo:hex\" over the skipped elements as stored.", "pattern": "^(md5|sha1|sha256|sha512):[0-9a-f]+$" }
+      }
+    }
+  }
+}
+`
+
+// runSchema prints the output JSON Schema to stdout.
+func runSchema(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: ggufmeta schema")
+	}
+	_, err := os.Stdout.WriteString(outputSchema)
+	return err
+}
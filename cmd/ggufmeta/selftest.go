@@ -0,0 +1,251 @@
+// Package main implements the `selftest` subcommand, a property-based check
+// of the writer and parser together on the user's own platform: each run
+// builds a file of random KV pairs and tensors with ggufWriter, parses it
+// back, and requires every key, type, value, tensor info and payload to come
+// back exactly as written. Runs are seeded from --seed, so a failure can be
+// reproduced anywhere.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// selftestScalars are the element and value types selftest generates.
+var selftestScalars = []uint32{
+	tUint8, tInt8, tUint16, tInt16, tUint32, tInt32, tFloat32,
+	tBool, tString, tUint64, tInt64, tFloat64,
+}
+
+// selftestKV is one generated KV pair as written.
+type selftestKV struct {
+	key, typ string
+	value    any
+}
+
+// selftestTensor is one generated tensor as written.
+type selftestTensor struct {
+	info tensorInfo
+	data []byte
+}
+
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	runs := fs.Int("runs", 100, "number of random files to write and parse back")
+	seed := fs.Uint64("seed", 0, "seed for the random files (default: from the clock, printed on failure)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta selftest [--runs N] [--seed N]\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 0 || *runs < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *seed == 0 {
+		*seed = uint64(time.Now().UnixNano())
+	}
+	for run := range *runs {
+		if err := selftestRun(rand.New(rand.NewPCG(*seed, uint64(run)))); err != nil {
+			return fmt.Errorf("selftest: run %d of --seed %d failed on %s/%s: %w", run, *seed, runtime.GOOS, runtime.GOARCH, err)
+		}
+	}
+	ew := &errWriter{w: os.Stdout}
+	ew.printf("selftest: %d runs passed (seed %d, %s/%s, %s)\n", *runs, *seed, runtime.GOOS, runtime.GOARCH, runtime.Version())
+	return ew.err
+}
+
+// selftestRun writes one random file and checks that it parses back unchanged.
+func selftestRun(r *rand.Rand) error {
+	var buf bytes.Buffer
+	gw := newWriter(&buf)
+	var kvs []selftestKV
+	if r.IntN(2) == 0 {
+		a := uint32(8 << r.IntN(6))
+		if err := gw.AddKV("general.alignment", a); err != nil {
+			return err
+		}
+		kvs = append(kvs, selftestKV{"general.alignment", "uint32", a})
+	}
+	for i := range r.IntN(40) {
+		kv := selftestRandomKV(r, i)
+		if err := gw.AddTypedKV(kv.key, kv.typ, kv.value); err != nil {
+			return err
+		}
+		kvs = append(kvs, kv)
+	}
+	var tensors []selftestTensor
+	for i := range r.IntN(8) {
+		t := selftestRandomTensor(r, i)
+		if err := gw.AddTensor(t.info.Name, t.info.Dims, t.info.Type, bytes.NewReader(t.data)); err != nil {
+			return err
+		}
+		t.info.Offset = gw.tensors[len(gw.tensors)-1].info.Offset
+		tensors = append(tensors, t)
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	file := buf.Bytes()
+	pol := policy{maxArray: math.MaxUint64, maxString: math.MaxUint32, expandPrefixes: []string{""}}
+	gf, err := readFile(context.Background(), bytes.NewReader(file), uint64(len(file)), pol)
+	if err != nil {
+		return fmt.Errorf("parsing the written file: %w", err)
+	}
+	if len(gf.KVs) != len(kvs) {
+		return fmt.Errorf("wrote %d KV pairs, read %d", len(kvs), len(gf.KVs))
+	}
+	for i, want := range kvs {
+		got := gf.KVs[i]
+		switch {
+		case got.Key != want.key:
+			return fmt.Errorf("KV %d: wrote key %q, read %q", i, want.key, got.Key)
+		case got.Type != want.typ:
+			return fmt.Errorf("key %q: wrote type %s, read %s", want.key, want.typ, got.Type)
+		case !selftestEqual(want.value, got.Value):
+			return fmt.Errorf("key %q: wrote %#v, read %#v", want.key, want.value, got.Value)
+		}
+	}
+	if len(gf.Tensors) != len(tensors) {
+		return fmt.Errorf("wrote %d tensors, read %d", len(tensors), len(gf.Tensors))
+	}
+	for i, want := range tensors {
+		got := gf.Tensors[i]
+		if got.Name != want.info.Name || got.Type != want.info.Type || got.Offset != want.info.Offset || !slices.Equal(got.Dims, want.info.Dims) {
+			return fmt.Errorf("tensor %d: wrote %s %s%v at %d, read %s %s%v at %d", i,
+				want.info.Name, ggmlTypeName(want.info.Type), want.info.Dims, want.info.Offset,
+				got.Name, ggmlTypeName(got.Type), got.Dims, got.Offset)
+		}
+		start := gf.DataOffset + got.Offset
+		if end := start + uint64(len(want.data)); end > uint64(len(file)) || !bytes.Equal(file[start:end], want.data) {
+			return fmt.Errorf("tensor %q: payload at %d differs from what was written", got.Name, start)
+		}
+	}
+	return nil
+}
+
+// selftestRandomKV returns KV pair i with a random scalar or array value.
+func selftestRandomKV(r *rand.Rand, i int) selftestKV {
+	tag := selftestScalars[r.IntN(len(selftestScalars))]
+	name, _ := typeName(tag)
+	if r.IntN(3) > 0 {
+		return selftestKV{fmt.Sprintf("selftest.%s.k%d", name, i), name, selftestRandomScalar(r, tag)}
+	}
+	n := r.IntN(20)
+	if r.IntN(8) == 0 {
+		n = r.IntN(10000) // past ctxCheckEvery
+	}
+	items := make([]any, n)
+	for j := range items {
+		items[j] = selftestRandomScalar(r, tag)
+	}
+	return selftestKV{fmt.Sprintf("selftest.array.%s.k%d", name, i), "array[" + name + "]", items}
+}
+
+// selftestRandomScalar returns a random value of type tag. Floats are random
+// bit patterns, NaNs and infinities included.
+func selftestRandomScalar(r *rand.Rand, tag uint32) any {
+	switch tag {
+	case tUint8:
+		return uint8(r.Uint32())
+	case tInt8:
+		return int8(r.Uint32())
+	case tUint16:
+		return uint16(r.Uint32())
+	case tInt16:
+		return int16(r.Uint32())
+	case tUint32:
+		return r.Uint32()
+	case tInt32:
+		return int32(r.Uint32())
+	case tFloat32:
+		return math.Float32frombits(r.Uint32())
+	case tBool:
+		return r.IntN(2) == 1
+	case tUint64:
+		return r.Uint64()
+	case tInt64:
+		return int64(r.Uint64())
+	case tFloat64:
+		return math.Float64frombits(r.Uint64())
+	}
+	return selftestRandomString(r)
+}
+
+// selftestRandomString mixes ASCII, multi-byte characters and, now and
+// then, bytes that are not UTF-8, which GGUF does not rule out.
+func selftestRandomString(r *rand.Rand) string {
+	var sb strings.Builder
+	for range r.IntN(48) {
+		switch r.IntN(8) {
+		case 0:
+			sb.WriteRune(rune(0x80 + r.IntN(0x10000-0x80)))
+		case 1:
+			sb.WriteRune(rune(0x10000 + r.IntN(utf8.MaxRune-0x10000)))
+		case 2:
+			sb.WriteByte(byte(r.Uint32()))
+		default:
+			sb.WriteByte(byte(0x20 + r.IntN(0x5f)))
+		}
+	}
+	return sb.String()
+}
+
+// selftestRandomTensor returns tensor i with a random ggml type, shape and
+// payload.
+func selftestRandomTensor(r *rand.Rand, i int) selftestTensor {
+	var typ uint32
+	for {
+		typ = uint32(r.IntN(len(ggmlBlocks)))
+		if ggmlBlocks[typ].Elems > 0 {
+			break
+		}
+	}
+	dims := make([]uint64, 1+r.IntN(4))
+	for j := range dims {
+		dims[j] = uint64(1 + r.IntN(4))
+	}
+	dims[0] *= ggmlBlocks[typ].Elems
+	size, _ := ggmlTensorSize(typ, dims)
+	data := make([]byte, size)
+	for j := range data {
+		data[j] = byte(r.Uint32())
+	}
+	return selftestTensor{info: tensorInfo{Name: fmt.Sprintf("blk.%d.selftest.weight", i), Dims: dims, Type: typ}, data: data}
+}
+
+// selftestEqual compares a written value with the parsed one, floats by
+// their bits so that NaNs compare equal to themselves.
+func selftestEqual(want, got any) bool {
+	switch w := want.(type) {
+	case float32:
+		g, ok := got.(float32)
+		return ok && math.Float32bits(w) == math.Float32bits(g)
+	case float64:
+		g, ok := got.(float64)
+		return ok && math.Float64bits(w) == math.Float64bits(g)
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !selftestEqual(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return want == got
+}
@@ -0,0 +1,225 @@
+// Package main implements the `sign` and `verify` subcommands.
+// A signature is an Ed25519 signature over the canonical form of the metadata
+// (the key order and encoding normalize produces, plus every tensor's name,
+// shape and type) and is stored in the reserved key ggufmeta.signature, so it
+// survives re-layout but not any change to keys, values or tensor shapes.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// signatureKey holds "ed25519:KEYID:BASE64SIG"; it is excluded from what is signed.
+const signatureKey = "ggufmeta.signature"
+
+// signedPrefix starts every signed message so the signature cannot be replayed
+// as one over some other kind of data.
+const signedPrefix = "ggufmeta signed metadata v1\x00"
+
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyFile := fs.String("key", "", "secret key file (from --generate)")
+	generate := fs.String("generate", "", "write a new key pair to `NAME`.key and NAME.pub and exit")
+	stamp := fs.Bool("stamp", false, "record the edit time, tool version and prior file hash under ggufmeta.* (covered by the signature)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta sign --key NAME.key [--stamp] in.gguf out.gguf\n")
+		fmt.Fprintf(os.Stderr, "       ggufmeta sign --generate NAME\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *generate != "" && fs.NArg() == 0 {
+		return generateKeyPair(*generate)
+	}
+	if *keyFile == "" || fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+	seed, err := readKeyFile(*keyFile, ed25519.SeedSize)
+	if err != nil {
+		return err
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	dropSignature := func(kvs []rawKV) []rawKV {
+		kept := kvs[:0]
+		for _, kv := range kvs {
+			if kv.key != signatureKey {
+				kept = append(kept, kv)
+			}
+		}
+		return kept
+	}
+
+	// The stamp is part of the signed metadata, so it is written to out first
+	// and out is then signed in place.
+	src := in
+	if *stamp {
+		err := rewriteMetadata(in, out, true, func(kvs []rawKV) ([]rawKV, error) { return dropSignature(kvs), nil })
+		if err != nil {
+			return fmt.Errorf("sign: %w", err)
+		}
+		src = out
+	}
+	msg, err := signedMessage(src)
+	if err != nil {
+		return fmt.Errorf("sign: %w", err)
+	}
+	id := keyID(priv.Public().(ed25519.PublicKey))
+	sig := "ed25519:" + id + ":" + base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg))
+	err = rewriteMetadata(src, out, false, func(kvs []rawKV) ([]rawKV, error) {
+		return append(dropSignature(kvs), rawKV{key: signatureKey, tag: tString, val: sig, enc: encodeKV(signatureKey, tString, sig)}), nil
+	})
+	if err != nil {
+		return fmt.Errorf("sign: %w", err)
+	}
+	fmt.Printf("%s: signed with key %s\n", out, id)
+	return nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	pubFile := fs.String("pub", "", "public key file (from sign --generate)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta verify --pub NAME.pub file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *pubFile == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	pubBytes, err := readKeyFile(*pubFile, ed25519.PublicKeySize)
+	if err != nil {
+		return err
+	}
+	pub := ed25519.PublicKey(pubBytes)
+
+	gf, err := loadFile(context.Background(), path, basePolicy())
+	if err != nil {
+		return err
+	}
+	stored, err := gf.GetString(signatureKey)
+	if err != nil {
+		return fmt.Errorf("verify: %s: %w", path, err)
+	}
+	algo, rest, _ := strings.Cut(stored, ":")
+	id, b64, _ := strings.Cut(rest, ":")
+	sig, err := base64.StdEncoding.DecodeString(b64)
+	if algo != "ed25519" || err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("verify: %s: malformed %s value", path, signatureKey)
+	}
+	if want := keyID(pub); id != want {
+		return fmt.Errorf("verify: %s: signed by key %s, not %s", path, id, want)
+	}
+	msg, err := signedMessage(path)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if !ed25519.Verify(pub, msg, sig) {
+		return fmt.Errorf("verify: %s: BAD signature: metadata changed since signing", path)
+	}
+	fmt.Printf("%s: good signature from key %s\n", path, id)
+	return nil
+}
+
+// signedMessage builds the bytes a signature covers: signedPrefix, every key
+// but signatureKey encoded as normalize would write it, and then for each
+// tensor in data order its name, dimensions and ggml type. Offsets and padding
+// are left out, so normalizing a signed file keeps the signature valid.
+func signedMessage(path string) ([]byte, error) {
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""}
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	for _, key := range canonicalKeys(gf) {
+		if key != signatureKey {
+			keys = append(keys, key)
+		}
+	}
+	gw := newWriter(io.Discard)
+	if err := addCanonicalKVs(gw, gf, f, keys); err != nil {
+		return nil, err
+	}
+	var msg bytes.Buffer
+	msg.WriteString(signedPrefix)
+	putU64(&msg, gw.nkv)
+	msg.Write(gw.kvs.Bytes())
+
+	tensors := append([]tensorInfo(nil), gf.Tensors...)
+	sort.SliceStable(tensors, func(i, j int) bool { return tensors[i].Offset < tensors[j].Offset })
+	putU64(&msg, uint64(len(tensors)))
+	for _, t := range tensors {
+		putString(&msg, t.Name)
+		putU32(&msg, uint32(len(t.Dims)))
+		for _, d := range t.Dims {
+			putU64(&msg, d)
+		}
+		putU32(&msg, t.Type)
+	}
+	return msg.Bytes(), nil
+}
+
+// keyID is a short fingerprint of a public key: the first 8 bytes of its SHA-256.
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// generateKeyPair writes name.key (the private seed, mode 0600) and name.pub.
+// Both are a comment line followed by the base64 key.
+func generateKeyPair(name string) error {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	id := keyID(pub)
+	secret := fmt.Sprintf("ggufmeta ed25519 secret key %s\n%s\n", id, base64.StdEncoding.EncodeToString(priv.Seed()))
+	if err := os.WriteFile(name+".key", []byte(secret), 0o600); err != nil {
+		return err
+	}
+	public := fmt.Sprintf("ggufmeta ed25519 public key %s\n%s\n", id, base64.StdEncoding.EncodeToString(pub))
+	if err := os.WriteFile(name+".pub", []byte(public), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s.key and %s.pub (key %s)\n", name, name, id)
+	return nil
+}
+
+// readKeyFile decodes the base64 key on the last non-empty line of path.
+func readKeyFile(path string, size int) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Fields(string(b))
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%s: empty key file", path)
+	}
+	key, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(key) != size {
+		return nil, fmt.Errorf("%s: not a %d-byte base64 key", path, size)
+	}
+	return key, nil
+}
@@ -0,0 +1,160 @@
+// Package main implements --split-output, which fans records out into a directory.
+// Each key (or each top-level namespace) gets its own file so metadata can live
+// under version control and individual keys can be diffed over time.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// headerFileName holds the header record in split output; the leading
+// underscore keeps it out of the way of real GGUF key names.
+const headerFileName = "_header"
+
+// splitOutput is a drop-in record encoder that routes each record to its own file.
+// All files are written atomically and only published on Commit. In per-key
+// mode each file is closed as soon as its one record is written, so only the
+// renames wait for Commit and large files don't exhaust the open-file limit.
+type splitOutput struct {
+	dir         string
+	byNamespace bool
+	ext         string
+	newEnc      func(io.Writer) recordEncoder
+	files       map[string]*atomicFile
+	encs        map[string]recordEncoder
+	owners      map[string]string // file name -> key or namespace that claimed it
+	mu          sync.Mutex        // guards files against Abort from a signal handler
+}
+
+func newSplitOutput(dir string, byNamespace bool, newEnc func(io.Writer) recordEncoder) (*splitOutput, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("split-output: %w", err)
+	}
+	ext := ".json"
+	if byNamespace {
+		ext = ".ndjson" // several records per file
+	}
+	return &splitOutput{
+		dir:         dir,
+		byNamespace: byNamespace,
+		ext:         ext,
+		newEnc:      newEnc,
+		files:       make(map[string]*atomicFile),
+		encs:        make(map[string]recordEncoder),
+		owners:      make(map[string]string),
+	}, nil
+}
+
+// Encode writes a header or kv record to the file chosen by its key.
+func (s *splitOutput) Encode(v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	group := headerFileName
+	if kv, ok := v.(kvEvent); ok {
+		group = kv.Key
+		if s.byNamespace {
+			group, _, _ = strings.Cut(kv.Key, ".")
+		}
+	}
+	enc, err := s.encoderFor(group)
+	if err != nil {
+		return err
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if s.byNamespace {
+		return nil
+	}
+	name := splitFileName(group) + s.ext
+	delete(s.encs, name)
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	if err := s.files[name].Close(); err != nil {
+		return fmt.Errorf("write output %s: %w", s.files[name].path, err)
+	}
+	return nil
+}
+
+// encoderFor returns the encoder for a group, creating its file on first use.
+func (s *splitOutput) encoderFor(group string) (recordEncoder, error) {
+	name := splitFileName(group) + s.ext
+	if owner, ok := s.owners[name]; ok {
+		if owner != group {
+			return nil, fmt.Errorf("split-output: %q and %q map to the same file %s", owner, group, name)
+		}
+		if s.byNamespace {
+			return s.encs[name], nil
+		}
+		return nil, fmt.Errorf("split-output: duplicate key %q", group)
+	}
+	af, err := createAtomicFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	s.owners[name] = group
+	s.files[name] = af
+	s.encs[name] = s.newEnc(af)
+	return s.encs[name], nil
+}
+
+// splitFileName maps a key to a safe file name, replacing path separators
+// and anything else a filesystem might object to.
+func splitFileName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, key)
+	if name == "" || strings.Trim(name, ".") == "" {
+		name = "_" + name // avoid "", "." and ".."
+	}
+	return name
+}
+
+// Flush finishes every per-file encoder.
+func (s *splitOutput) Flush() error {
+	for _, enc := range s.encs {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit publishes every file; on the first failure the rest are discarded.
+func (s *splitOutput) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, af := range s.files {
+		if err := af.Commit(); err != nil {
+			s.abort()
+			return err
+		}
+		delete(s.files, name)
+	}
+	return nil
+}
+
+// Abort discards every file that has not been published yet.
+func (s *splitOutput) Abort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abort()
+}
+
+func (s *splitOutput) abort() {
+	for _, af := range s.files {
+		af.Abort()
+	}
+}
@@ -0,0 +1,633 @@
+// Package main implements the `sql` subcommand.
+// Linking SQLite would end the tool's standard-library-only build, so this is a
+// small SQL subset evaluated over two in-memory tables: one SELECT with
+// WHERE, ORDER BY and LIMIT, enough for the questions people ask of metadata.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// sqlTables lists each table's columns in SELECT * order.
+var sqlTables = map[string][]string{
+	"kv":      {"key", "type", "value"},
+	"tensors": {"name", "type", "n_dims", "dims", "n_elements", "offset", "size"},
+}
+
+func runSQL(args []string) error {
+	fs := flag.NewFlagSet("sql", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta sql QUERY file.gguf\n")
+		fmt.Fprintf(os.Stderr, "\nTables: kv(key, type, value), tensors(name, type, n_dims, dims, n_elements, offset, size).\n")
+		fmt.Fprintf(os.Stderr, "Supported: SELECT cols|*|COUNT(*) FROM t [WHERE expr] [ORDER BY col [ASC|DESC], ...] [LIMIT n]\n")
+		fmt.Fprintf(os.Stderr, "with = != <> < <= > >= LIKE, IS [NOT] NULL, AND, OR, NOT and parentheses.\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	q, err := parseSQL(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("sql: %w", err)
+	}
+	gf, err := loadFile(context.Background(), fs.Arg(1), basePolicy())
+	if err != nil {
+		return err
+	}
+	rows, err := q.run(sqlRows(gf, q.table))
+	if err != nil {
+		return fmt.Errorf("sql: %w", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlRow is one table row; values keep their Go types for output.
+type sqlRow map[string]any
+
+func sqlRows(gf *ggufFile, table string) []sqlRow {
+	var rows []sqlRow
+	if table == "kv" {
+		for _, kv := range gf.KVs {
+			rows = append(rows, sqlRow{"key": kv.Key, "type": kv.Type, "value": kv.Value})
+		}
+		return rows
+	}
+	ends := gf.tensorEnds()
+	for _, t := range gf.Tensors {
+		n := uint64(1)
+		dims := make([]string, len(t.Dims))
+		for i, d := range t.Dims {
+			n *= d
+			dims[i] = strconv.FormatUint(d, 10)
+		}
+		size := gf.tensorSize(t, ends)
+		rows = append(rows, sqlRow{
+			"name": t.Name, "type": ggmlTypeName(t.Type), "n_dims": len(t.Dims),
+			"dims": strings.Join(dims, "x"), "n_elements": n, "offset": t.Offset, "size": size,
+		})
+	}
+	return rows
+}
+
+// sqlQuery is a parsed SELECT statement.
+type sqlQuery struct {
+	cols  []string // nil selects every column
+	count bool     // SELECT COUNT(*)
+	table string
+	where sqlExpr // nil matches every row
+	order []sqlOrder
+	limit int // -1 for no limit
+}
+
+type sqlOrder struct {
+	col  string
+	desc bool
+}
+
+func (q *sqlQuery) run(rows []sqlRow) ([]sqlRow, error) {
+	var out []sqlRow
+	for _, row := range rows {
+		if q.where == nil || sqlTruth(q.where.eval(row)) {
+			out = append(out, row)
+		}
+	}
+	if q.count {
+		return []sqlRow{{"count": len(out)}}, nil
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		for _, o := range q.order {
+			c := sqlCompare(out[i][o.col], out[j][o.col])
+			if c != 0 {
+				return (c < 0) != o.desc
+			}
+		}
+		return false
+	})
+	if q.limit >= 0 && len(out) > q.limit {
+		out = out[:q.limit]
+	}
+	if q.cols == nil {
+		return out, nil
+	}
+	for i, row := range out {
+		proj := make(sqlRow, len(q.cols))
+		for _, c := range q.cols {
+			proj[c] = row[c]
+		}
+		out[i] = proj
+	}
+	return out, nil
+}
+
+// sqlExpr is a WHERE expression node.
+type sqlExpr interface {
+	eval(row sqlRow) any
+}
+
+type (
+	sqlCol string
+	sqlLit struct{ v any }
+	sqlNot struct{ x sqlExpr }
+	sqlBin struct {
+		op   string
+		l, r sqlExpr
+	}
+	sqlNull struct {
+		x   sqlExpr
+		not bool
+	}
+	sqlLike struct {
+		x   sqlExpr
+		re  *regexp.Regexp
+		not bool
+	}
+)
+
+func (c sqlCol) eval(row sqlRow) any { return row[string(c)] }
+func (l sqlLit) eval(sqlRow) any     { return l.v }
+func (n sqlNot) eval(row sqlRow) any { return !sqlTruth(n.x.eval(row)) }
+
+func (n sqlNull) eval(row sqlRow) any { return (n.x.eval(row) == nil) != n.not }
+
+func (l sqlLike) eval(row sqlRow) any {
+	v := l.x.eval(row)
+	return v != nil && l.re.MatchString(sqlText(v)) != l.not
+}
+
+func (b sqlBin) eval(row sqlRow) any {
+	switch b.op {
+	case "AND":
+		return sqlTruth(b.l.eval(row)) && sqlTruth(b.r.eval(row))
+	case "OR":
+		return sqlTruth(b.l.eval(row)) || sqlTruth(b.r.eval(row))
+	}
+	l, r := b.l.eval(row), b.r.eval(row)
+	if l == nil || r == nil {
+		return false // comparisons with NULL are never true
+	}
+	c := sqlCompare(l, r)
+	switch b.op {
+	case "=":
+		return c == 0
+	case "!=", "<>":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0 // ">="
+}
+
+func sqlTruth(v any) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case nil:
+		return false
+	}
+	f, ok := sqlNumber(v)
+	return ok && f != 0
+}
+
+// sqlNumber returns v as a float64 if it is numeric.
+func sqlNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	}
+	u, ok := asUint64(v)
+	return float64(u), ok
+}
+
+// sqlText renders a value for LIKE and mixed comparisons; arrays and
+// placeholders compare as their JSON text.
+func sqlText(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	}
+	if f, ok := sqlNumber(v); ok {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// sqlCompare orders two values: numerically when both are numbers (booleans
+// count as 0/1), by text otherwise, with NULL first.
+func sqlCompare(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == b:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	fa, okA := sqlNumber(sqlBoolNum(a))
+	fb, okB := sqlNumber(sqlBoolNum(b))
+	if okA && okB {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(sqlText(a), sqlText(b))
+}
+
+func sqlBoolNum(v any) any {
+	if b, ok := v.(bool); ok {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return v
+}
+
+// likeRegexp translates a LIKE pattern (% and _, ASCII case-insensitive as
+// in SQLite) to an anchored regular expression.
+func likeRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// sqlToken is one lexeme; kind is 'i' (identifier or keyword), 's' (string),
+// 'n' (number) or 'p' (punctuation/operator).
+type sqlToken struct {
+	kind byte
+	text string
+}
+
+func lexSQL(src string) ([]sqlToken, error) {
+	var toks []sqlToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'' || c == '"':
+			// '' (or "") inside a quoted run is an escaped quote
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return nil, fmt.Errorf("unterminated quote at %d", i)
+				}
+				if rune(src[j]) == c {
+					if j+1 < len(src) && rune(src[j+1]) == c {
+						sb.WriteRune(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(src[j])
+				j++
+			}
+			kind := byte('s')
+			if c == '"' {
+				kind = 'i' // quoted identifier
+			}
+			toks = append(toks, sqlToken{kind, sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				(src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			toks = append(toks, sqlToken{'n', src[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, sqlToken{'i', src[i:j]})
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(src) {
+				if two := src[i : i+2]; two == "<=" || two == ">=" || two == "!=" || two == "<>" {
+					op = two
+				}
+			}
+			if !strings.Contains("=<>!(),*-", string(c)) || op == "!" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			toks = append(toks, sqlToken{'p', op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+// sqlParser is a recursive-descent parser over the token list.
+type sqlParser struct {
+	toks  []sqlToken
+	pos   int
+	table string
+}
+
+func parseSQL(src string) (*sqlQuery, error) {
+	toks, err := lexSQL(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{toks: toks}
+	q := &sqlQuery{limit: -1}
+	if !p.keyword("SELECT") {
+		return nil, fmt.Errorf("only SELECT is supported")
+	}
+	var cols []string
+	switch {
+	case p.punct("*"):
+	case p.keyword("COUNT"):
+		if !p.punct("(") || !p.punct("*") || !p.punct(")") {
+			return nil, fmt.Errorf("only COUNT(*) is supported")
+		}
+		q.count = true
+	default:
+		for {
+			t, ok := p.ident()
+			if !ok {
+				return nil, fmt.Errorf("expected column name")
+			}
+			cols = append(cols, t)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	if !p.keyword("FROM") {
+		return nil, fmt.Errorf("expected FROM")
+	}
+	table, ok := p.ident()
+	if _, known := sqlTables[table]; !ok || !known {
+		return nil, fmt.Errorf("unknown table %q (want kv or tensors)", table)
+	}
+	q.table, p.table = table, table
+	if err := p.checkCols(cols); err != nil {
+		return nil, err
+	}
+	q.cols = cols
+
+	if p.keyword("WHERE") {
+		if q.where, err = p.or(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("ORDER") {
+		if !p.keyword("BY") {
+			return nil, fmt.Errorf("expected BY after ORDER")
+		}
+		for {
+			col, ok := p.ident()
+			if !ok {
+				return nil, fmt.Errorf("expected column after ORDER BY")
+			}
+			if err := p.checkCols([]string{col}); err != nil {
+				return nil, err
+			}
+			o := sqlOrder{col: col}
+			if p.keyword("DESC") {
+				o.desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			q.order = append(q.order, o)
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != 'n' || err != nil || n < 0 {
+			return nil, fmt.Errorf("LIMIT wants a non-negative integer")
+		}
+		q.limit = n
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return q, nil
+}
+
+func (p *sqlParser) next() sqlToken {
+	if p.pos >= len(p.toks) {
+		return sqlToken{}
+	}
+	p.pos++
+	return p.toks[p.pos-1]
+}
+
+func (p *sqlParser) peek() sqlToken {
+	if p.pos >= len(p.toks) {
+		return sqlToken{}
+	}
+	return p.toks[p.pos]
+}
+
+// keyword consumes the next token if it is the keyword kw (any case).
+func (p *sqlParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == 'i' && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) punct(s string) bool {
+	if t := p.peek(); t.kind == 'p' && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) ident() (string, bool) {
+	if t := p.peek(); t.kind == 'i' {
+		p.pos++
+		return strings.ToLower(t.text), true
+	}
+	return "", false
+}
+
+func (p *sqlParser) checkCols(cols []string) error {
+	for _, c := range cols {
+		found := false
+		for _, known := range sqlTables[p.table] {
+			found = found || c == known
+		}
+		if !found {
+			return fmt.Errorf("no column %q in %s (have %s)", c, p.table, strings.Join(sqlTables[p.table], ", "))
+		}
+	}
+	return nil
+}
+
+func (p *sqlParser) or() (sqlExpr, error) {
+	l, err := p.and()
+	for err == nil && p.keyword("OR") {
+		var r sqlExpr
+		if r, err = p.and(); err == nil {
+			l = sqlBin{op: "OR", l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *sqlParser) and() (sqlExpr, error) {
+	l, err := p.not()
+	for err == nil && p.keyword("AND") {
+		var r sqlExpr
+		if r, err = p.not(); err == nil {
+			l = sqlBin{op: "AND", l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *sqlParser) not() (sqlExpr, error) {
+	if p.keyword("NOT") {
+		x, err := p.not()
+		return sqlNot{x}, err
+	}
+	return p.comparison()
+}
+
+func (p *sqlParser) comparison() (sqlExpr, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("IS") {
+		not := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, fmt.Errorf("expected NULL after IS")
+		}
+		return sqlNull{x: l, not: not}, nil
+	}
+	not := p.keyword("NOT")
+	if p.keyword("LIKE") {
+		t := p.next()
+		if t.kind != 's' {
+			return nil, fmt.Errorf("LIKE wants a string pattern")
+		}
+		return sqlLike{x: l, re: likeRegexp(t.text), not: not}, nil
+	}
+	if not {
+		return nil, fmt.Errorf("expected LIKE after NOT")
+	}
+	if t := p.peek(); t.kind == 'p' && strings.Contains("= != <> < <= > >=", t.text) && t.text != "!" && t.text != "" {
+		p.pos++
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return sqlBin{op: t.text, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *sqlParser) operand() (sqlExpr, error) {
+	if p.punct("(") {
+		x, err := p.or()
+		if err == nil && !p.punct(")") {
+			err = fmt.Errorf("expected )")
+		}
+		return x, err
+	}
+	neg := p.punct("-")
+	t := p.next()
+	switch {
+	case t.kind == 'n':
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("bad number %q", t.text)
+		}
+		if neg {
+			f = -f
+		}
+		return sqlLit{f}, nil
+	case neg:
+		return nil, fmt.Errorf("expected number after -")
+	case t.kind == 's':
+		return sqlLit{t.text}, nil
+	case t.kind == 'i':
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			return sqlLit{nil}, nil
+		case "TRUE":
+			return sqlLit{true}, nil
+		case "FALSE":
+			return sqlLit{false}, nil
+		}
+		col := strings.ToLower(t.text)
+		if err := p.checkCols([]string{col}); err != nil {
+			return nil, err
+		}
+		return sqlCol(col), nil
+	}
+	if t.text == "" {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,170 @@
+// Package main implements --stdio: a long-running JSON-RPC 2.0 server on
+// stdin/stdout for editor extensions, which can then answer a hover over a
+// .gguf path without spawning a process per query. A file is parsed once by
+// open and queried through the returned handle until close:
+//
+//	open  {path, maxArray?, maxString?, expandArrays?, lenient?, truncateStrings?, noAutoExpand?, expandTypes?}
+//	      -> {handle, path, size, header, kvCount, tensorCount, dataOffset}
+//	query {handle, key}           -> kv record
+//	query {handle, keys?}         -> {kvs: [kv record, ...]} (keys as for --keys)
+//	query {handle, tensors: true} -> {tensors: [tensor record, ...]}
+//	close {handle}                -> {}
+//
+// Messages are one JSON object per line (see serveRPC). Options default to
+// the command line's. Before answering a query on a local file the server
+// checks its size and modification time and parses it again if either
+// changed, so hovers stay current while a converter rewrites the file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// stdioFile is one open handle.
+type stdioFile struct {
+	path    string
+	pol     policy
+	gf      *ggufFile
+	size    int64 // stat at parse time; local files only
+	modTime time.Time
+}
+
+// stdioServer holds the open handles. serveRPC calls it sequentially, so it
+// needs no locking.
+type stdioServer struct {
+	pol   policy // from the command line
+	files map[int]*stdioFile
+	next  int
+}
+
+// load parses f.path again, recording the stat it was parsed at.
+func (f *stdioFile) load() error {
+	var st os.FileInfo
+	if !strings.Contains(f.path, "://") {
+		var err error
+		if st, err = os.Stat(f.path); err != nil {
+			return err
+		}
+	}
+	gf, err := loadFile(context.Background(), f.path, f.pol)
+	if err != nil {
+		return err
+	}
+	f.gf = gf
+	if st != nil {
+		f.size, f.modTime = st.Size(), st.ModTime()
+	}
+	return nil
+}
+
+// fresh reloads a local file that changed on disk since it was parsed.
+func (f *stdioFile) fresh() error {
+	if strings.Contains(f.path, "://") {
+		return nil
+	}
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if st.Size() == f.size && st.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	logger.Debug("stdio: file changed; parsing again", "path", f.path)
+	return f.load()
+}
+
+// stdioParams are the parameters of every method; each uses a subset.
+type stdioParams struct {
+	embedOptions
+	Path    string  `json:"path"`
+	Handle  int     `json:"handle"`
+	Key     *string `json:"key"`
+	Keys    string  `json:"keys"`
+	Tensors bool    `json:"tensors"`
+}
+
+// stdioFail reports a problem with the file itself (unreadable, not GGUF, gone).
+func stdioFail(err error) error {
+	return &rpcError{Code: rpcServerError, Message: err.Error()}
+}
+
+func (s *stdioServer) handle(method string, raw json.RawMessage) (any, error) {
+	var p stdioParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, rpcErrorf(rpcInvalidParams, "%s: %v", method, err)
+		}
+	}
+	if method == "open" {
+		if p.Path == "" {
+			return nil, rpcErrorf(rpcInvalidParams, "open: path is required")
+		}
+		f := &stdioFile{path: p.Path, pol: p.embedOptions.apply(s.pol)}
+		if err := f.load(); err != nil {
+			return nil, stdioFail(err)
+		}
+		s.next++
+		s.files[s.next] = f
+		return map[string]any{
+			"handle":      s.next,
+			"path":        f.path,
+			"size":        f.gf.Size,
+			"header":      f.gf.Header.GGUF,
+			"kvCount":     len(f.gf.KVs),
+			"tensorCount": len(f.gf.Tensors),
+			"dataOffset":  f.gf.DataOffset,
+		}, nil
+	}
+
+	var f *stdioFile
+	switch method {
+	case "query", "close":
+		var ok bool
+		if f, ok = s.files[p.Handle]; !ok {
+			return nil, rpcErrorf(rpcInvalidParams, "%s: no open handle %d", method, p.Handle)
+		}
+	default:
+		return nil, rpcErrorf(rpcMethodNotFound, "method %q not found (want open, query or close)", method)
+	}
+	if method == "close" {
+		delete(s.files, p.Handle)
+		return map[string]any{}, nil
+	}
+
+	if err := f.fresh(); err != nil {
+		return nil, stdioFail(err)
+	}
+	switch {
+	case p.Key != nil:
+		kv, ok := f.gf.Get(*p.Key)
+		if !ok {
+			return nil, stdioFail(fmt.Errorf("%q: %w", *p.Key, errKeyNotFound))
+		}
+		return kv, nil
+	case p.Tensors:
+		return map[string]any{"tensors": tensorRecords(f.gf)}, nil
+	}
+	filter, err := parseKeyFilter(p.Keys, false)
+	if err != nil {
+		return nil, rpcErrorf(rpcInvalidParams, "query: %v", err)
+	}
+	kvs := []kvEvent{}
+	for _, kv := range f.gf.KVs {
+		if filter.match(kv.Key) {
+			kvs = append(kvs, kv)
+		}
+	}
+	return map[string]any{"kvs": kvs}, nil
+}
+
+// serveStdio runs the --stdio server until stdin closes.
+func serveStdio(pol policy) error {
+	s := &stdioServer{pol: pol, files: make(map[int]*stdioFile)}
+	logger.Info("stdio: serving JSON-RPC on stdin/stdout")
+	return serveRPC(os.Stdin, os.Stdout, s.handle)
+}
@@ -0,0 +1,75 @@
+// Package main implements --strings-to-dir. A string value longer than
+// --max-string - a multi-megabyte chat template, an embedded JSON document -
+// is copied from the input straight into its own file under DIR instead of
+// being read into memory, and its record points there:
+//
+//	{"_placeholder":"string_file","length":N,"path":"DIR/KEY"}
+//
+// File names come from the key as for --split-output. Only top-level string
+// values are written out; strings inside arrays follow --max-string and
+// --truncate-strings as before.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stringFiles writes the string values of one parse to a directory.
+type stringFiles struct {
+	dir    string
+	owners map[string]string // file name -> key that claimed it
+}
+
+func newStringFiles(dir string) (*stringFiles, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("strings-to-dir: %w", err)
+	}
+	return &stringFiles{dir: dir, owners: make(map[string]string)}, nil
+}
+
+// readStringToFile reads the string value of key at the scanner's position.
+// One within --max-string is returned as usual; a longer one is copied to
+// its file and replaced by a string_file placeholder.
+func (p *parser) readStringToFile(key string) (any, error) {
+	n, err := p.scn.U64()
+	if err != nil {
+		return nil, err
+	}
+	if n <= p.pol.maxString {
+		buf, err := p.scn.b(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return string(buf), nil
+	}
+
+	sf := p.strFiles
+	name := splitFileName(key)
+	if owner, ok := sf.owners[name]; ok {
+		if owner == key {
+			return nil, fmt.Errorf("strings-to-dir: duplicate key %q", key)
+		}
+		return nil, fmt.Errorf("strings-to-dir: %q and %q map to the same file %s", owner, key, name)
+	}
+	path := filepath.Join(sf.dir, name)
+	af, err := createAtomicFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.scn.copyN(af, n); err != nil {
+		af.Abort()
+		return nil, err
+	}
+	if err := af.Commit(); err != nil {
+		return nil, err
+	}
+	sf.owners[name] = key
+	logger.Debug("string value written to file", "key", key, "bytes", n, "path", path)
+	return map[string]any{
+		"_placeholder": "string_file",
+		"length":       n,
+		"path":         path,
+	}, nil
+}
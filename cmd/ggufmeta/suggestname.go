@@ -0,0 +1,131 @@
+// Package main implements the `suggest-name` subcommand.
+// It builds the file name the GGUF naming convention prescribes,
+// BaseName-SizeLabel-FineTune-Version-Encoding[-NNNNN-of-NNNNN].gguf, from the
+// general.* metadata (falling back to general.name and the tensor table), and
+// with --rename moves the file there.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// nameUnsafe matches runs of characters a name component may not contain.
+var nameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._]+`)
+
+// sizeToken matches a size label inside a free-form name, e.g. "7B" or "8x22B".
+var sizeToken = regexp.MustCompile(`(?i)\b(\d+x)?\d+(\.\d+)?[KMBT]\b`)
+
+func runSuggestName(args []string) error {
+	fs := flag.NewFlagSet("suggest-name", flag.ExitOnError)
+	rename := fs.Bool("rename", false, "rename each file to its suggested name (never overwrites)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta suggest-name [--rename] file.gguf...\n")
+		fs.PrintDefaults()
+	}
+	files := parseInterspersed(fs, args)
+	if len(files) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	for _, path := range files {
+		gf, err := loadFile(context.Background(), path, basePolicy())
+		if err != nil {
+			return err
+		}
+		name := suggestName(gf)
+		target := filepath.Join(filepath.Dir(path), name)
+		if !*rename {
+			fmt.Printf("%s\t%s\n", path, name)
+			continue
+		}
+		if filepath.Base(path) == name {
+			continue
+		}
+		// Link then remove, so an existing target is never clobbered
+		if err := os.Link(path, target); err != nil {
+			return fmt.Errorf("suggest-name: %w", err)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("suggest-name: %w", err)
+		}
+		fmt.Printf("%s -> %s\n", path, target)
+	}
+	return nil
+}
+
+// suggestName returns gf's conventional file name.
+func suggestName(gf *ggufFile) string {
+	base, _ := gf.GetString("general.basename")
+	if base == "" {
+		base, _ = gf.GetString("general.name")
+	}
+	size, _ := gf.GetString("general.size_label")
+	if size == "" && !sizeToken.MatchString(base) {
+		size = sizeLabel(gf)
+	}
+	finetune, _ := gf.GetString("general.finetune")
+	version, _ := gf.GetString("general.version")
+
+	var parts []string
+	for _, p := range []string{base, size, finetune, version, quantLabel(gf)} {
+		if p = strings.Trim(nameUnsafe.ReplaceAllString(p, "-"), "-."); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "model")
+	}
+	name := strings.Join(parts, "-")
+	// A base name often repeats the size or finetune ("Llama-3-8B-Instruct")
+	name = dedupeNameParts(name)
+	if n, ok := gf.Uint("split.no"); ok {
+		if count, ok := gf.Uint("split.count"); ok && count > 1 {
+			name += fmt.Sprintf("-%05d-of-%05d", n+1, count)
+		}
+	}
+	return name + ".gguf"
+}
+
+// sizeLabel derives a size label from the tensors, e.g. "7.2B" or, for
+// mixture-of-experts models, "8x7.2B" with the per-expert size.
+func sizeLabel(gf *ggufFile) string {
+	n := paramCount(gf)
+	if n == 0 {
+		return ""
+	}
+	if experts, ok := gf.Uint("{arch}.expert_count"); ok && experts > 1 {
+		// Expert tensors hold the bulk; divide them out to get one expert's share
+		var shared, expert uint64
+		for _, t := range gf.Tensors {
+			if strings.Contains(t.Name, "_exps.") {
+				expert += tensorElems(t.Dims)
+			} else {
+				shared += tensorElems(t.Dims)
+			}
+		}
+		if expert > 0 {
+			return fmt.Sprintf("%dx%s", experts, humanCount(shared+expert/experts))
+		}
+	}
+	return humanCount(n)
+}
+
+// dedupeNameParts drops a dash-separated component that repeats an earlier
+// one, case-insensitively.
+func dedupeNameParts(name string) string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, p := range strings.Split(name, "-") {
+		if k := strings.ToLower(p); !seen[k] {
+			seen[k] = true
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "-")
+}
@@ -0,0 +1,386 @@
+// Package main implements the `summary` subcommand.
+// It prints the hyperparameters that matter for a file's architecture under
+// readable labels - heads and KV heads with the grouping and RoPE settings
+// for transformers, d_state and d_conv for Mamba, the head size for RWKV -
+// instead of the flat key dump. What each architecture shows comes from
+// archSummaries; unknown architectures get the fields every model has.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// summaryField is one line of a summary. render returns the value for gf, or
+// false to leave the line out (the keys it needs are missing).
+type summaryField struct {
+	label  string
+	render func(gf *ggufFile) (string, bool)
+}
+
+// summaryLine is a rendered field; the --json output is a list of them.
+type summaryLine struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// sumKey shows one key's value; key may contain "{arch}".
+func sumKey(label, key string) summaryField {
+	return summaryField{label, func(gf *ggufFile) (string, bool) {
+		return sumValue(gf, key)
+	}}
+}
+
+// sumValue renders key's value for a summary: floats in their shortest form,
+// per-layer arrays as a single value or a range.
+func sumValue(gf *ggufFile, key string) (string, bool) {
+	kv, ok := gf.Get(expandArch(gf, key))
+	if !ok {
+		return "", false
+	}
+	return sumText(kv.Value), true
+}
+
+func sumText(v any) string {
+	switch x := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		if x {
+			return "yes"
+		}
+		return "no"
+	case []any:
+		return sumPerLayer(x)
+	case map[string]any:
+		n, _ := arrayLen(x)
+		return fmt.Sprintf("%d values", n)
+	}
+	return fmt.Sprint(v)
+}
+
+// sumPerLayer renders a per-layer array: its value when all layers agree,
+// otherwise the range and how many layers there are.
+func sumPerLayer(items []any) string {
+	if len(items) == 0 {
+		return "none"
+	}
+	lo, hi := 0, 0
+	for i, it := range items {
+		x, ok := asFloat(it)
+		if !ok {
+			return fmt.Sprintf("%d values", len(items))
+		}
+		if l, _ := asFloat(items[lo]); x < l {
+			lo = i
+		}
+		if h, _ := asFloat(items[hi]); x > h {
+			hi = i
+		}
+	}
+	if lo == hi {
+		return sumText(items[lo])
+	}
+	return fmt.Sprintf("%s-%s over %d layers", sumText(items[lo]), sumText(items[hi]), len(items))
+}
+
+// asFloat converts any GGUF number to float64.
+func asFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	if n, ok := asUint64(v); ok {
+		return float64(n), true
+	}
+	switch x := v.(type) {
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+// Fields shared by several families.
+var (
+	sumContext = sumKey("context", "{arch}.context_length")
+	sumEmbed   = sumKey("embedding", "{arch}.embedding_length")
+	sumLayers  = sumKey("layers", "{arch}.block_count")
+	sumFFN     = sumKey("feed-forward", "{arch}.feed_forward_length")
+
+	// heads: "32 (kv 8, group size 4)"
+	sumHeads = summaryField{"heads", func(gf *ggufFile) (string, bool) {
+		heads, ok := sumValue(gf, "{arch}.attention.head_count")
+		if !ok {
+			return "", false
+		}
+		kv, ok := sumValue(gf, "{arch}.attention.head_count_kv")
+		if !ok || kv == heads {
+			return heads, true
+		}
+		h, herr := strconv.ParseUint(heads, 10, 64)
+		k, kerr := strconv.ParseUint(kv, 10, 64)
+		if herr == nil && kerr == nil && k > 0 && h%k == 0 {
+			return fmt.Sprintf("%s (kv %s, group size %d)", heads, kv, h/k), true
+		}
+		return fmt.Sprintf("%s (kv %s)", heads, kv), true
+	}}
+
+	// head size: key_length, or embedding / heads when the file leaves it out
+	sumHeadSize = summaryField{"head size", func(gf *ggufFile) (string, bool) {
+		k, kok := sumValue(gf, "{arch}.attention.key_length")
+		v, vok := sumValue(gf, "{arch}.attention.value_length")
+		switch {
+		case kok && vok && k != v:
+			return fmt.Sprintf("k %s, v %s", k, v), true
+		case kok:
+			return k, true
+		}
+		embed, eok := gf.Uint("{arch}.embedding_length")
+		heads, hok := gf.Uint("{arch}.attention.head_count")
+		if eok && hok && heads > 0 {
+			return fmt.Sprint(embed / heads), true
+		}
+		return "", false
+	}}
+
+	// rope: "base 500000, 128 dims, yarn x32 from 4096"
+	sumRope = summaryField{"rope", func(gf *ggufFile) (string, bool) {
+		var parts []string
+		if base, ok := sumValue(gf, "{arch}.rope.freq_base"); ok {
+			parts = append(parts, "base "+base)
+		}
+		if dims, ok := sumValue(gf, "{arch}.rope.dimension_count"); ok {
+			parts = append(parts, dims+" dims")
+		}
+		scaling, _ := sumValue(gf, "{arch}.rope.scaling.type")
+		if scaling != "" && scaling != "none" {
+			if f, ok := sumValue(gf, "{arch}.rope.scaling.factor"); ok {
+				scaling += " x" + f
+			}
+			if orig, ok := sumValue(gf, "{arch}.rope.scaling.original_context_length"); ok {
+				scaling += " from " + orig
+			}
+			parts = append(parts, scaling)
+		}
+		return strings.Join(parts, ", "), len(parts) > 0
+	}}
+
+	// norm: "1e-05 (RMS)" or "1e-05 (LayerNorm)"
+	sumNorm = summaryField{"norm eps", func(gf *ggufFile) (string, bool) {
+		if eps, ok := sumValue(gf, "{arch}.attention.layer_norm_rms_epsilon"); ok {
+			return eps + " (RMS)", true
+		}
+		if eps, ok := sumValue(gf, "{arch}.attention.layer_norm_epsilon"); ok {
+			return eps + " (LayerNorm)", true
+		}
+		return "", false
+	}}
+
+	// experts: "64, 8 active, 2 shared, 1408 ff"
+	sumExperts = summaryField{"experts", func(gf *ggufFile) (string, bool) {
+		n, ok := sumValue(gf, "{arch}.expert_count")
+		if !ok || n == "0" {
+			return "", false
+		}
+		if used, ok := sumValue(gf, "{arch}.expert_used_count"); ok {
+			n += ", " + used + " active"
+		}
+		if shared, ok := sumValue(gf, "{arch}.expert_shared_count"); ok && shared != "0" {
+			n += ", " + shared + " shared"
+		}
+		if ff, ok := sumValue(gf, "{arch}.expert_feed_forward_length"); ok {
+			n += ", " + ff + " ff"
+		}
+		return n, true
+	}}
+
+	sumWindow = sumKey("sliding window", "{arch}.attention.sliding_window")
+
+	sumVocab = summaryField{"vocab", func(gf *ggufFile) (string, bool) {
+		kv, ok := gf.Get("tokenizer.ggml.tokens")
+		if !ok {
+			return "", false
+		}
+		n, _ := arrayLen(kv.Value)
+		s := fmt.Sprint(n)
+		if model, err := gf.GetString("tokenizer.ggml.model"); err == nil {
+			s += " (" + model + ")"
+		}
+		return s, true
+	}}
+
+	sumChat = summaryField{"chat template", func(gf *ggufFile) (string, bool) {
+		_, err := gf.GetString("tokenizer.chat_template")
+		return "embedded", err == nil
+	}}
+)
+
+// summaryCommon is what an unknown architecture gets.
+var summaryCommon = []summaryField{sumLayers, sumEmbed, sumContext, sumFFN, sumHeads, sumNorm}
+
+// archSummaries lists the fields per architecture, grouped by family. The
+// tokenizer lines are appended to every list.
+var archSummaries = func() map[string][]summaryField {
+	transformer := []summaryField{sumLayers, sumEmbed, sumFFN, sumHeads, sumHeadSize, sumContext, sumRope, sumNorm}
+	moe := append(append([]summaryField{}, transformer...), sumExperts)
+	m := make(map[string][]summaryField)
+	for _, a := range []string{"llama", "qwen2", "qwen3", "mistral3", "granite", "olmo", "olmo2", "internlm2", "minicpm", "phi2", "phi3", "exaone", "orion", "xverse", "baichuan", "command-r", "cohere2", "chameleon", "falcon", "gptneox", "stablelm", "starcoder2", "deepseek", "chatglm", "glm4", "smollm3"} {
+		m[a] = transformer
+	}
+	for _, a := range []string{"gemma", "gemma2", "gemma3", "gemma3n"} {
+		m[a] = append(append([]summaryField{}, transformer...), sumWindow,
+			sumKey("attention softcap", "{arch}.attn_logit_softcapping"),
+			sumKey("final softcap", "{arch}.final_logit_softcapping"))
+	}
+	for _, a := range []string{"llama4", "qwen2moe", "qwen3moe", "olmoe", "granitemoe", "phimoe", "dbrx", "arctic", "grok", "glm4moe"} {
+		m[a] = moe
+	}
+	m["deepseek2"] = append(append([]summaryField{}, moe...),
+		sumKey("dense layers", "{arch}.leading_dense_block_count"),
+		sumKey("kv lora rank", "{arch}.attention.kv_lora_rank"),
+		sumKey("q lora rank", "{arch}.attention.q_lora_rank"))
+	m["gpt-oss"] = append(append([]summaryField{}, moe...), sumWindow)
+	for _, a := range []string{"bert", "nomic-bert", "jina-bert-v2", "modern-bert"} {
+		m[a] = append(append([]summaryField{}, transformer...),
+			sumKey("pooling", "{arch}.pooling_type"),
+			sumKey("causal", "{arch}.attention.causal"))
+	}
+
+	ssm := []summaryField{sumLayers, sumEmbed, sumContext,
+		sumKey("d_state", "{arch}.ssm.state_size"),
+		sumKey("d_conv", "{arch}.ssm.conv_kernel"),
+		sumKey("d_inner", "{arch}.ssm.inner_size"),
+		sumKey("dt_rank", "{arch}.ssm.time_step_rank"),
+		sumNorm}
+	m["mamba"] = ssm
+	m["mamba2"] = append(append([]summaryField{}, ssm...), sumKey("groups", "{arch}.ssm.group_count"))
+	// Hybrids interleave attention layers with SSM ones
+	for _, a := range []string{"jamba", "falcon-h1", "granitehybrid", "nemotron_h"} {
+		m[a] = append(append([]summaryField{}, m["mamba2"]...), sumHeads, sumHeadSize, sumRope)
+	}
+
+	rwkv := []summaryField{sumLayers, sumEmbed, sumContext, sumFFN,
+		sumKey("head size", "{arch}.wkv.head_size"),
+		sumKey("time mix extra dim", "{arch}.time_mix_extra_dim"),
+		sumKey("time decay extra dim", "{arch}.time_decay_extra_dim"),
+		sumKey("rescale every", "{arch}.rescale_every_n_layers"),
+		sumNorm}
+	m["rwkv6"], m["rwkv6qwen2"] = rwkv, rwkv
+	rwkv7 := append(append([]summaryField{}, rwkv...),
+		sumKey("decay lora rank", "{arch}.decay_lora_rank"),
+		sumKey("iclr lora rank", "{arch}.iclr_lora_rank"),
+		sumKey("value residual lora rank", "{arch}.value_residual_mix_lora_rank"),
+		sumKey("gate lora rank", "{arch}.gate_lora_rank"))
+	m["rwkv7"], m["arwkv7"] = rwkv7, rwkv7
+
+	for a, fields := range m {
+		m[a] = append(append([]summaryField{}, fields...), sumVocab, sumChat)
+	}
+	return m
+}()
+
+// summarize renders gf's summary lines; known is false for architectures
+// without a tailored field list.
+func summarize(gf *ggufFile) (lines []summaryLine, known bool) {
+	fields, known := archSummaries[gf.Arch()]
+	if !known {
+		fields = append(append([]summaryField{}, summaryCommon...), sumVocab, sumChat)
+	}
+	for _, f := range fields {
+		if v, ok := f.render(gf); ok {
+			lines = append(lines, summaryLine{Label: f.label, Value: v})
+		}
+	}
+	return lines, known
+}
+
+func runSummary(args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print one JSON document instead of text")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta summary [--json] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := rest[0]
+	pol := basePolicy()
+	pol.expandPrefixes = []string{""} // per-layer head counts and the like
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return err
+	}
+	lines, known := summarize(gf)
+	name, _ := gf.GetString("general.name")
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{
+			"file":     path,
+			"name":     name,
+			"arch":     gf.Arch(),
+			"modality": modalityLabel(modalitiesOf(gf)),
+			"known":    known,
+			"quant":    quantLabel(gf),
+			"params":   paramCount(gf),
+			"size":     gf.Size,
+			"fields":   lines,
+		})
+	}
+	return writeSummary(os.Stdout, gf, name, lines, known)
+}
+
+// writeSummary prints a heading and the lines with aligned labels.
+func writeSummary(w io.Writer, gf *ggufFile, name string, lines []summaryLine, known bool) error {
+	ew := &errWriter{w: w}
+	arch := gf.Arch()
+	if arch == "" {
+		arch = "unknown architecture"
+	}
+	head := []string{arch}
+	if m := modalityLabel(modalitiesOf(gf)); m != "" {
+		head = append(head, m)
+	}
+	if q := quantLabel(gf); q != "" {
+		head = append(head, q)
+	}
+	if n := paramCount(gf); n > 0 {
+		head = append(head, humanCount(n)+" params")
+	}
+	head = append(head, humanBytes(gf.Size))
+	ew.printf("%s: %s\n", name, strings.Join(head, ", "))
+	width := 0
+	for _, l := range lines {
+		width = max(width, len(l.Label))
+	}
+	for _, l := range lines {
+		ew.printf("  %-*s  %s\n", width, l.Label, l.Value)
+	}
+	if !known && gf.Arch() != "" {
+		ew.printf("  (no tailored summary for %s; showing the common fields)\n", gf.Arch())
+	}
+	return ew.err
+}
@@ -0,0 +1,71 @@
+// Package main implements streaming access to tensor payloads.
+// A parsed ggufFile already knows where every tensor lives; TensorData pairs
+// each tensor info with a SectionReader over its bytes so callers can copy,
+// hash or convert tensors without re-deriving the data section layout.
+// "Callers" here means other subcommands: package main is not importable.
+package main
+
+import (
+	"io"
+	"iter"
+	"sort"
+)
+
+// tensorData is a tensor info together with the location of its payload.
+type tensorData struct {
+	tensorInfo
+	Size uint64            // payload size in bytes
+	Data *io.SectionReader // payload bytes, read through the ReaderAt given to TensorData
+}
+
+// TensorData yields the tensors of gf in info-table order, reading payloads
+// through ra (normally the file gf was loaded from). Sizes come from the ggml
+// block layout; for unknown types the size runs to the next tensor or to the
+// end of the file, so the reader covers the payload plus any padding.
+func (gf *ggufFile) TensorData(ra io.ReaderAt) iter.Seq[tensorData] {
+	return func(yield func(tensorData) bool) {
+		ends := gf.tensorEnds()
+		for _, t := range gf.Tensors {
+			start := gf.DataOffset + t.Offset
+			size := gf.tensorSize(t, ends)
+			td := tensorData{tensorInfo: t, Size: size, Data: io.NewSectionReader(ra, int64(start), int64(size))}
+			if !yield(td) {
+				return
+			}
+		}
+	}
+}
+
+// tensorSize is the payload size of t as TensorData computes it; ends comes
+// from tensorEnds and is only consulted for types without a known layout.
+func (gf *ggufFile) tensorSize(t tensorInfo, ends map[uint64]uint64) uint64 {
+	if size, ok := ggmlTensorSize(t.Type, t.Dims); ok {
+		return size
+	}
+	return ends[t.Offset] - (gf.DataOffset + t.Offset)
+}
+
+// tensorEnds maps each tensor offset to the absolute offset where the next
+// tensor starts, or to the file size for the last one.
+func (gf *ggufFile) tensorEnds() map[uint64]uint64 {
+	offs := make([]uint64, 0, len(gf.Tensors))
+	for _, t := range gf.Tensors {
+		offs = append(offs, t.Offset)
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	ends := make(map[uint64]uint64, len(offs))
+	for i, off := range offs {
+		end := gf.Size
+		for _, next := range offs[i+1:] {
+			if next > off {
+				end = gf.DataOffset + next
+				break
+			}
+		}
+		if end < gf.DataOffset+off {
+			end = gf.DataOffset + off
+		}
+		ends[off] = end
+	}
+	return ends
+}
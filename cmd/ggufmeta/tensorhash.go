@@ -0,0 +1,103 @@
+// Package main implements per-tensor hashing for `ggufmeta tensors --hash`.
+// Hashing a 40 GB file on one core takes minutes, so tensors are hashed by a
+// bounded pool of workers, each reading its tensor's payload through
+// positioned reads on the shared file.
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"sync"
+)
+
+// tensorHashBuffer is the read size of each hashing worker.
+const tensorHashBuffer = 1 << 20
+
+// hashTensors sets each record's Hash to the "algo:hex" digest of its
+// payload, read from data with payloads starting at dataOffset. Up to jobs
+// tensors are hashed at once, largest first so that one big tensor does not
+// start last and hold up the end. The first error stops further tensors from
+// starting; cancelling ctx also stops the tensors in progress between reads.
+func hashTensors(ctx context.Context, data io.ReaderAt, dataOffset uint64, recs []tensorRecord, algo string, jobs int) error {
+	newHash, ok := hashAlgos[algo]
+	if !ok {
+		return fmt.Errorf("unknown hash %q (want md5, sha1, sha256 or sha512)", algo)
+	}
+	order := make([]int, len(recs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return recs[order[a]].Size > recs[order[b]].Size })
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	next := make(chan int)
+	for range min(jobs, len(recs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, tensorHashBuffer)
+			for i := range next {
+				r := &recs[i]
+				digest, err := hashTensor(ctx, data, int64(dataOffset+r.Offset), r.Size, newHash(), buf)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil && ctx.Err() == nil {
+						firstErr = fmt.Errorf("tensor %q: %w", r.Name, err)
+					}
+					mu.Unlock()
+					cancel()
+					continue
+				}
+				r.Hash = algo + ":" + digest
+			}
+		}()
+	}
+feed:
+	for _, i := range order {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if firstErr == nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// hashTensor hashes size bytes of data at off with h, checking ctx before each read.
+func hashTensor(ctx context.Context, data io.ReaderAt, off int64, size uint64, h hash.Hash, buf []byte) (string, error) {
+	sr := io.NewSectionReader(data, off, int64(size))
+	var n uint64
+	for n < size {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		m, err := sr.Read(buf)
+		h.Write(buf[:m])
+		n += uint64(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if n != size {
+		return "", fmt.Errorf("%w: payload needs %d bytes, file has %d", errTruncated, size, n)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
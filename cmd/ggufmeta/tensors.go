@@ -0,0 +1,119 @@
+// Package main implements the `tensors` subcommand.
+// It lists the tensor info table with each tensor's payload size and share of
+// the file, optionally sorted and cut to the top N, to show what dominates a
+// model's footprint.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"syscall"
+)
+
+// tensorRecord is one line of `ggufmeta tensors` output.
+type tensorRecord struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"` // ggml type name, e.g. "Q4_K"
+	Dims   []uint64 `json:"dims"`
+	Offset uint64   `json:"offset"`         // relative to the data section
+	Size   uint64   `json:"size"`           // payload bytes
+	Share  float64  `json:"share"`          // Size as a fraction of the file size
+	Hash   string   `json:"hash,omitempty"` // "algo:hex" of the payload, with --hash
+}
+
+func runTensors(args []string) error {
+	fs := flag.NewFlagSet("tensors", flag.ExitOnError)
+	sortBy := fs.String("sort", "", "order by 'size' (largest first), 'name' or 'offset'; default is info-table order")
+	top := fs.Int("top", 0, "print only the first N tensors after sorting (0 for all)")
+	format := fs.String("format", "ndjson", "output format: 'ndjson' or 'arrow' (an Arrow IPC stream)")
+	hashAlgo := fs.String("hash", "", "add each tensor's payload digest using `ALGO` (md5, sha1, sha256 or sha512)")
+	jobs := fs.Int("jobs", runtime.GOMAXPROCS(0), "with --hash, hash up to N tensors in parallel")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta tensors [--sort size|name|offset] [--top N] [--hash ALGO [--jobs N]] [--format ndjson|arrow] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *top < 0 || *jobs < 1 || (*format != "ndjson" && *format != "arrow") {
+		fs.Usage()
+		os.Exit(2)
+	}
+	// Ctrl-C or SIGTERM cancels a long --hash run between reads
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	gf, err := loadFile(ctx, fs.Arg(0), basePolicy())
+	if err != nil {
+		return err
+	}
+	recs := tensorRecords(gf)
+	switch *sortBy {
+	case "":
+	case "size":
+		sort.SliceStable(recs, func(i, j int) bool { return recs[i].Size > recs[j].Size })
+	case "name":
+		sort.SliceStable(recs, func(i, j int) bool { return recs[i].Name < recs[j].Name })
+	case "offset":
+		sort.SliceStable(recs, func(i, j int) bool { return recs[i].Offset < recs[j].Offset })
+	default:
+		return fmt.Errorf("tensors: unknown sort %q (want 'size', 'name' or 'offset')", *sortBy)
+	}
+	if *top > 0 && len(recs) > *top {
+		recs = recs[:*top]
+	}
+	if *hashAlgo != "" {
+		// Same loader as loadFile, so http(s)://, hf:// and oci:// inputs hash too
+		f, _, err := openInput(ctx, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n := *jobs
+		if _, local := f.(*os.File); !local {
+			// A remote file reads through one shared window, so one worker
+			n = 1
+		}
+		if err := hashTensors(ctx, f, gf.DataOffset, recs, *hashAlgo, n); err != nil {
+			return fmt.Errorf("tensors: %w", err)
+		}
+	}
+	if *format == "arrow" {
+		w := bufio.NewWriter(os.Stdout)
+		enc := newArrowStream(w, tensorArrowColumns(), appendTensorRow)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, r := range recs {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tensorRecords describes gf's tensors in info-table order.
+func tensorRecords(gf *ggufFile) []tensorRecord {
+	ends := gf.tensorEnds()
+	recs := make([]tensorRecord, 0, len(gf.Tensors))
+	for _, t := range gf.Tensors {
+		r := tensorRecord{Name: t.Name, Type: ggmlTypeName(t.Type), Dims: t.Dims, Offset: t.Offset, Size: gf.tensorSize(t, ends)}
+		if gf.Size > 0 {
+			r.Share = float64(r.Size) / float64(gf.Size)
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
@@ -0,0 +1,65 @@
+// Package main implements the tokenizer array consistency check.
+// tokenizer.ggml.tokens, .scores and .token_type are parallel arrays indexed
+// by token id; loaders index one by the length of another, so a count
+// mismatch crashes or silently misreads. Counts come from the array headers,
+// so the check costs nothing even when the arrays are not expanded.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenizerArrayKeys are the arrays that must have one entry per token.
+var tokenizerArrayKeys = []string{"tokenizer.ggml.tokens", "tokenizer.ggml.scores", "tokenizer.ggml.token_type"}
+
+func init() {
+	registerCheck("tokenizer", checkTokenizerArrays)
+}
+
+func checkTokenizerArrays(v *validation) {
+	counts := make(map[string]uint64)
+	for _, key := range tokenizerArrayKeys {
+		if kv, ok := v.gf.Get(key); ok {
+			if n, ok := arrayLen(kv.Value); ok {
+				counts[key] = n
+			}
+		}
+	}
+	if msg := tokenArrayMismatch(counts); msg != "" {
+		v.report("error", "tokenizer.ggml.tokens", "", "%s", msg)
+	}
+	tokens, ok := counts["tokenizer.ggml.tokens"]
+	if !ok {
+		return
+	}
+	for _, t := range v.gf.Tensors {
+		if t.Name == "token_embd.weight" && len(t.Dims) == 2 && t.Dims[1] != tokens {
+			v.report("error", "", t.Name, "has %d rows but the vocabulary has %d tokens", t.Dims[1], tokens)
+		}
+	}
+}
+
+// tokenArrayMismatch describes disagreeing counts among the tokenizer arrays
+// present in counts, or returns "" when they agree.
+func tokenArrayMismatch(counts map[string]uint64) string {
+	var parts []string
+	var first uint64
+	agree := true
+	for _, key := range tokenizerArrayKeys {
+		n, ok := counts[key]
+		if !ok {
+			continue
+		}
+		if len(parts) == 0 {
+			first = n
+		} else if n != first {
+			agree = false
+		}
+		parts = append(parts, fmt.Sprintf("%s=%d", strings.TrimPrefix(key, "tokenizer.ggml."), n))
+	}
+	if agree {
+		return ""
+	}
+	return "tokenizer arrays differ in length: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,39 @@
+// Package main implements --trace, which reports the byte range of every field.
+// Trace records are interleaved with the normal output, each just before the
+// record it explains, so a converter bug can be located byte by byte with a hex
+// dump of the file next to the trace.
+package main
+
+// traceEvent is one parsed field and the half-open byte range [Start, End) it occupied.
+type traceEvent struct {
+	Kind   string `json:"kind"`             // always "trace"
+	Field  string `json:"field"`            // e.g. "magic", "key", "tag", "value", "dims"
+	Key    string `json:"key,omitempty"`    // KV key the field belongs to
+	Tensor string `json:"tensor,omitempty"` // tensor the field belongs to
+	Start  uint64 `json:"start"`
+	End    uint64 `json:"end"`
+}
+
+// headerTrace returns the trace of the fixed 24-byte header.
+func headerTrace() []traceEvent {
+	return []traceEvent{
+		{Kind: "trace", Field: "magic", Start: 0, End: 4},
+		{Kind: "trace", Field: "version", Start: 4, End: 8},
+		{Kind: "trace", Field: "tensor_count", Start: 8, End: 16},
+		{Kind: "trace", Field: "kv_count", Start: 16, End: 24},
+	}
+}
+
+// traceKV reports a KV field that started at start and ends at the current position.
+func (p *parser) traceKV(field, key string, start uint64) {
+	if p.trace != nil {
+		p.trace(traceEvent{Kind: "trace", Field: field, Key: key, Start: start, End: p.scn.pos})
+	}
+}
+
+// traceTensor reports a tensor info field like traceKV.
+func (p *parser) traceTensor(field, name string, start uint64) {
+	if p.trace != nil {
+		p.trace(traceEvent{Kind: "trace", Field: field, Tensor: name, Start: start, End: p.scn.pos})
+	}
+}
@@ -0,0 +1,90 @@
+// Package main implements the hierarchical --format tree output.
+// Dotted keys are exploded into nested objects (general.name -> general{name})
+// and the whole file is written as a single JSON document on Flush.
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+func init() {
+	registerFormat("tree", outputFormat{
+		Description: "one nested JSON document, dotted keys exploded into objects",
+		JSON:        true,
+		New: func(w io.Writer, canonical bool) recordEncoder {
+			return newTreeEncoder(newJSONRecordEncoder(w, canonical))
+		},
+	})
+}
+
+// treeLeafKey holds a value whose key is also the prefix of deeper keys,
+// e.g. "a.b" next to "a.b.c" becomes {"a":{"b":{"_value":..., "c":...}}}.
+const treeLeafKey = "_value"
+
+// treeNode is an interior object of the tree. It is a distinct type so map-shaped
+// values (array placeholders) are never mistaken for namespaces.
+type treeNode map[string]any
+
+// treeEncoder accumulates records and emits one nested document, shaped like
+// the README's jq fold: {"gguf": {version, tensorCount, kvCount, kv: {...}}}.
+type treeEncoder struct {
+	out  recordEncoder
+	hdr  *headerEvent
+	root treeNode
+}
+
+func newTreeEncoder(out recordEncoder) *treeEncoder {
+	return &treeEncoder{out: out, root: make(treeNode)}
+}
+
+// Encode records a header or kv event; nothing is written until Flush.
+func (t *treeEncoder) Encode(v any) error {
+	switch ev := v.(type) {
+	case headerEvent:
+		t.hdr = &ev
+	case kvEvent:
+		t.insert(strings.Split(ev.Key, "."), jsonSafeValue(ev.Value))
+	}
+	return nil
+}
+
+// insert walks the key path, creating intermediate objects as needed.
+func (t *treeEncoder) insert(path []string, value any) {
+	node := t.root
+	for _, part := range path[:len(path)-1] {
+		switch child := node[part].(type) {
+		case treeNode:
+			node = child
+		case nil:
+			m := make(treeNode)
+			node[part] = m
+			node = m
+		default:
+			// A leaf already lives here; demote it so the subtree can grow
+			m := treeNode{treeLeafKey: child}
+			node[part] = m
+			node = m
+		}
+	}
+	last := path[len(path)-1]
+	if child, ok := node[last].(treeNode); ok {
+		child[treeLeafKey] = value
+		return
+	}
+	node[last] = value
+}
+
+// Flush writes the accumulated document through the underlying encoder.
+func (t *treeEncoder) Flush() error {
+	gguf := map[string]any{"kv": t.root}
+	if t.hdr != nil {
+		gguf["version"] = t.hdr.GGUF.Version
+		gguf["tensorCount"] = t.hdr.GGUF.TensorCount
+		gguf["kvCount"] = t.hdr.GGUF.KVCount
+	}
+	if err := t.out.Encode(map[string]any{"gguf": gguf}); err != nil {
+		return err
+	}
+	return t.out.Flush()
+}
@@ -0,0 +1,113 @@
+// Package main implements the --format typed output.
+// Warehouse loaders (BigQuery, Snowflake, ...) infer one type per column and
+// reject the mixed-type value field of ndjson. Here every line has the same
+// columns: exactly one of value_string, value_int, value_float and value_bool
+// holds a scalar, and value_json holds arrays and anything else as JSON text.
+// The header record is not written, so every line is a row of the same table.
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"unicode/utf8"
+)
+
+func init() {
+	registerFormat("typed", outputFormat{
+		Description: "one JSON row per key with typed value_* columns, for warehouse loads",
+		JSON:        true,
+		New: func(w io.Writer, canonical bool) recordEncoder {
+			return typedEncoder{newJSONRecordEncoder(w, canonical)}
+		},
+	})
+}
+
+// typedKV is one row; unset columns are written as null so every row has
+// every column.
+type typedKV struct {
+	Key         string   `json:"key"`
+	Type        string   `json:"type"`
+	ValueString *string  `json:"value_string"`
+	ValueInt    *int64   `json:"value_int"`
+	ValueFloat  *float64 `json:"value_float"`
+	ValueBool   *bool    `json:"value_bool"`
+	ValueJSON   *string  `json:"value_json"`
+}
+
+// typedKVOf splits kv's value into the column matching its Go type. uint64
+// values above the int64 range do not fit value_int and go to value_json.
+func typedKVOf(kv kvEvent) (typedKV, error) {
+	row := typedKV{Key: kv.Key, Type: kv.Type}
+	switch x := kv.Value.(type) {
+	case string:
+		if utf8.ValidString(x) {
+			row.ValueString = &x
+			return row, nil
+		}
+	case bool:
+		row.ValueBool = &x
+		return row, nil
+	case float32:
+		f := float64(x)
+		row.ValueFloat = &f
+		return row, nil
+	case float64:
+		row.ValueFloat = &x
+		return row, nil
+	case uint64:
+		if x <= math.MaxInt64 {
+			i := int64(x)
+			row.ValueInt = &i
+			return row, nil
+		}
+	default:
+		if i, ok := asInt64(x); ok {
+			row.ValueInt = &i
+			return row, nil
+		}
+	}
+	js, err := json.Marshal(jsonSafeValue(kv.Value))
+	if err != nil {
+		return row, err
+	}
+	s := string(js)
+	row.ValueJSON = &s
+	return row, nil
+}
+
+// typedEncoder rewrites kv records as typedKV rows and drops everything else.
+type typedEncoder struct{ recordEncoder }
+
+func (e typedEncoder) Encode(v any) error {
+	kv, ok := v.(kvEvent)
+	if !ok {
+		return nil
+	}
+	row, err := typedKVOf(kv)
+	if err != nil {
+		return err
+	}
+	return e.recordEncoder.Encode(row)
+}
+
+// asInt64 converts the narrower integer types the parser produces.
+func asInt64(v any) (int64, bool) {
+	switch x := v.(type) {
+	case int8:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint8:
+		return int64(x), true
+	case uint16:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	}
+	return 0, false
+}
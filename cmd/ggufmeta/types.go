@@ -3,6 +3,12 @@
 // the official GGUF v3 specification for reading metadata from GGUF files.
 package main
 
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
 // GGUF v3 Reference - https://github.com/ggml-org/ggml/blob/master/docs/gguf.md
 
 // magicGGUF is the 4-byte magic number that identifies GGUF files.
@@ -28,6 +34,15 @@ type kvEvent struct {
 	Value interface{} `json:"value"` // The actual value or placeholder for large arrays
 }
 
+// tensorInfo describes one entry of the tensor info table that follows the KV section.
+// Offset is relative to the start of the (aligned) tensor data section.
+type tensorInfo struct {
+	Name   string   `json:"name"`   // Tensor name (e.g., "blk.0.attn_q.weight")
+	Dims   []uint64 `json:"dims"`   // Dimensions, innermost (fastest varying) first
+	Type   uint32   `json:"type"`   // ggml_type enum value
+	Offset uint64   `json:"offset"` // Byte offset into the tensor data section
+}
+
 // GGUF type constants based on the official GGUF v3 specification.
 // These numeric values are encoded in the file and must match exactly.
 // The order and values here are critical for correct parsing.
@@ -47,31 +62,202 @@ const (
 	tFloat64 uint32 = 12 // 64-bit IEEE 754 floating point
 )
 
-// typeNames provides human-readable names for GGUF type constants.
-// The array index corresponds to the type constant value.
-// Used for generating user-friendly type descriptions in output.
-var typeNames = []string{
-	"uint8",   // 0 - tUint8
-	"int8",    // 1 - tInt8
-	"uint16",  // 2 - tUint16
-	"int16",   // 3 - tInt16
-	"uint32",  // 4 - tUint32
-	"int32",   // 5 - tInt32
-	"float32", // 6 - tFloat32
-	"bool",    // 7 - tBool
-	"string",  // 8 - tString
-	"array",   // 9 - tArray
-	"uint64",  // 10 - tUint64
-	"int64",   // 11 - tInt64
-	"float64", // 12 - tFloat64
+// valueType describes one GGUF value type tag.
+type valueType struct {
+	Name   string    // label used in kv records, e.g. "uint32"
+	Decode scalarDec // reads one value; nil for string and array, which the parser reads itself
+}
+
+// valueTypes holds every known value type, keyed by tag. The built-in GGUF v3
+// types are registered in value.go; a newer spec revision or an experimental
+// writer can add its own tags with registerType without touching the parser.
+var valueTypes = make(map[uint32]valueType)
+
+// registerType adds a value type; call it from an init function.
+func registerType(tag uint32, vt valueType) {
+	if _, dup := valueTypes[tag]; dup {
+		panic(fmt.Sprintf("value type tag %d registered twice", tag))
+	}
+	if _, dup := typeTag(vt.Name); dup {
+		panic(fmt.Sprintf("value type name %q registered twice", vt.Name))
+	}
+	valueTypes[tag] = vt
+}
+
+// typeName returns the registered name for tag.
+func typeName(tag uint32) (string, bool) {
+	vt, ok := valueTypes[tag]
+	return vt.Name, ok
+}
+
+// typeTag maps a registered type name back to its tag.
+func typeTag(name string) (uint32, bool) {
+	for tag, vt := range valueTypes {
+		if vt.Name == name {
+			return tag, true
+		}
+	}
+	return 0, false
+}
+
+// ggmlTypeNames maps ggml_type enum values (as stored in tensor infos) to their names.
+// Empty entries are enum values that were removed from ggml before GGUF existed.
+// The ARM repacked types (31-33, 36-38) were removed later, when ggml started
+// repacking at load time, but files written in between still use them.
+var ggmlTypeNames = []string{
+	"F32",        // 0
+	"F16",        // 1
+	"Q4_0",       // 2
+	"Q4_1",       // 3
+	"",           // 4 - Q4_2 (removed)
+	"",           // 5 - Q4_3 (removed)
+	"Q5_0",       // 6
+	"Q5_1",       // 7
+	"Q8_0",       // 8
+	"Q8_1",       // 9
+	"Q2_K",       // 10
+	"Q3_K",       // 11
+	"Q4_K",       // 12
+	"Q5_K",       // 13
+	"Q6_K",       // 14
+	"Q8_K",       // 15
+	"IQ2_XXS",    // 16
+	"IQ2_XS",     // 17
+	"IQ3_XXS",    // 18
+	"IQ1_S",      // 19
+	"IQ4_NL",     // 20
+	"IQ3_S",      // 21
+	"IQ2_S",      // 22
+	"IQ4_XS",     // 23
+	"I8",         // 24
+	"I16",        // 25
+	"I32",        // 26
+	"I64",        // 27
+	"F64",        // 28
+	"IQ1_M",      // 29
+	"BF16",       // 30
+	"Q4_0_4_4",   // 31 - removed, still in older files
+	"Q4_0_4_8",   // 32 - removed
+	"Q4_0_8_8",   // 33 - removed
+	"TQ1_0",      // 34
+	"TQ2_0",      // 35
+	"IQ4_NL_4_4", // 36 - removed
+	"IQ4_NL_4_8", // 37 - removed
+	"IQ4_NL_8_8", // 38 - removed
+	"MXFP4",      // 39
+}
+
+// ggmlTypeName returns the ggml name for a tensor type, or "type(N)" if unknown.
+func ggmlTypeName(t uint32) string {
+	if int(t) < len(ggmlTypeNames) && ggmlTypeNames[t] != "" {
+		return ggmlTypeNames[t]
+	}
+	return fmt.Sprintf("type(%d)", t)
+}
+
+// ggmlTypeRemoved reports whether t is a type current ggml no longer has.
+// Such types are named and sized for reading old files, but never offered as
+// targets.
+func ggmlTypeRemoved(t uint32) bool {
+	return (t >= 31 && t <= 33) || (t >= 36 && t <= 38)
+}
+
+// ggmlTypeByName returns the current ggml type with the given name, ignoring case.
+func ggmlTypeByName(name string) (uint32, bool) {
+	for t, n := range ggmlTypeNames {
+		if n != "" && !ggmlTypeRemoved(uint32(t)) && strings.EqualFold(n, name) {
+			return uint32(t), true
+		}
+	}
+	return 0, false
+}
+
+// ggmlBlock is the storage layout of a ggml type: Elems values packed into Bytes bytes.
+type ggmlBlock struct {
+	Elems uint64
+	Bytes uint64
+}
+
+// ggmlBlocks gives the block layout per ggml_type, indexed like ggmlTypeNames.
+// Zero entries are types whose size cannot be computed.
+var ggmlBlocks = []ggmlBlock{
+	{1, 4},     // 0 - F32
+	{1, 2},     // 1 - F16
+	{32, 18},   // 2 - Q4_0
+	{32, 20},   // 3 - Q4_1
+	{},         // 4
+	{},         // 5
+	{32, 22},   // 6 - Q5_0
+	{32, 24},   // 7 - Q5_1
+	{32, 34},   // 8 - Q8_0
+	{32, 36},   // 9 - Q8_1
+	{256, 84},  // 10 - Q2_K
+	{256, 110}, // 11 - Q3_K
+	{256, 144}, // 12 - Q4_K
+	{256, 176}, // 13 - Q5_K
+	{256, 210}, // 14 - Q6_K
+	{256, 292}, // 15 - Q8_K
+	{256, 66},  // 16 - IQ2_XXS
+	{256, 74},  // 17 - IQ2_XS
+	{256, 98},  // 18 - IQ3_XXS
+	{256, 50},  // 19 - IQ1_S
+	{32, 18},   // 20 - IQ4_NL
+	{256, 110}, // 21 - IQ3_S
+	{256, 82},  // 22 - IQ2_S
+	{256, 136}, // 23 - IQ4_XS
+	{1, 1},     // 24 - I8
+	{1, 2},     // 25 - I16
+	{1, 4},     // 26 - I32
+	{1, 8},     // 27 - I64
+	{1, 8},     // 28 - F64
+	{256, 56},  // 29 - IQ1_M
+	{1, 2},     // 30 - BF16
+	{32, 18},   // 31 - Q4_0_4_4: Q4_0 blocks, interleaved
+	{32, 18},   // 32 - Q4_0_4_8
+	{32, 18},   // 33 - Q4_0_8_8
+	{256, 54},  // 34 - TQ1_0
+	{256, 66},  // 35 - TQ2_0
+	{32, 18},   // 36 - IQ4_NL_4_4: IQ4_NL blocks, interleaved
+	{32, 18},   // 37 - IQ4_NL_4_8
+	{32, 18},   // 38 - IQ4_NL_8_8
+	{32, 17},   // 39 - MXFP4
+}
+
+// ggmlTensorSize returns the payload size in bytes of a tensor with the given
+// type and dims. It fails for unknown types and rows that are not whole blocks.
+func ggmlTensorSize(t uint32, dims []uint64) (uint64, bool) {
+	if int(t) >= len(ggmlBlocks) || ggmlBlocks[t].Elems == 0 {
+		return 0, false
+	}
+	blk := ggmlBlocks[t]
+	if len(dims) == 0 {
+		return blk.Bytes, blk.Elems == 1
+	}
+	if dims[0]%blk.Elems != 0 {
+		return 0, false
+	}
+	size := dims[0] / blk.Elems * blk.Bytes
+	for _, d := range dims[1:] {
+		if d != 0 && size > math.MaxUint64/d {
+			return 0, false
+		}
+		size *= d
+	}
+	return size, true
 }
 
 // policy controls parsing behavior and output formatting decisions.
 // This implements the two-pass strategy: show structure by default, expand selectively.
 type policy struct {
-	maxArray       uint64            // Arrays larger than this show placeholders instead of full content
-	maxString      uint64            // Maximum string length to prevent memory exhaustion
-	debug          bool              // Enable detailed debug output to stderr
-	expandArrays   map[string]bool   // Exact array key names that should be expanded fully
-	expandPrefixes []string          // Key prefixes that should have their arrays expanded (from "prefix.*")
+	maxArray       uint64                // Arrays larger than this show placeholders instead of full content
+	maxString      uint64                // Maximum string length to prevent memory exhaustion
+	expandArrays   map[string]bool       // Exact array key names that should be expanded fully
+	expandPrefixes []string              // Key prefixes that should have their arrays expanded (from "prefix.*")
+	expandRanges   map[string]arrayRange // Arrays of which only a slice is expanded (--expand-range)
+	lenient        bool                  // Skip values of unknown type instead of failing (see skipUnknown)
+	truncStrings   bool                  // Cut string values longer than maxString instead of failing
+	arrayDigest    string                // Hash algorithm (see hashAlgos) for placeholder digests; "" for none
+	noAutoExpand   bool                  // Keep arrays within maxArray as placeholders too (--no-auto-expand)
+	expandTypes    map[string]uint64     // Auto-expansion limits by element type or class, overriding maxArray (--expand-type)
+	prefetch       func(off, n uint64)   // When set, told of byte ranges about to be read (see hintArray)
 }
@@ -0,0 +1,227 @@
+// Package main implements the `user` subcommand.
+// It edits the free-form user.* and x.* namespaces, which no loader
+// interprets, so pipelines can stamp deployment metadata into model files
+// without touching the keys llama.cpp reads.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// userPrefixes are the namespaces `ggufmeta user` may write.
+var userPrefixes = []string{"user.", "x."}
+
+func isUserKey(key string) bool {
+	for _, p := range userPrefixes {
+		if strings.HasPrefix(key, p) && len(key) > len(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func runUser(args []string) error {
+	fs := flag.NewFlagSet("user", flag.ExitOnError)
+	out := fs.String("o", "", "write the edited file to `OUT` instead of replacing FILE")
+	stamp := fs.Bool("stamp", false, "record the edit time, tool version and prior file hash under ggufmeta.*")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta user list file.gguf\n")
+		fmt.Fprintf(os.Stderr, "       ggufmeta user set [-o out.gguf] [--stamp] file.gguf KEY=VALUE...\n")
+		fmt.Fprintf(os.Stderr, "       ggufmeta user clear [-o out.gguf] [--stamp] file.gguf [KEY...]\n")
+		fmt.Fprintf(os.Stderr, "\nKEY must start with user. or x.; VALUE is JSON (a bare word is a string).\n")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	verb := args[0]
+	_ = fs.Parse(args[1:])
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	dest := path
+	if *out != "" {
+		dest = *out
+	}
+
+	switch verb {
+	case "list":
+		if fs.NArg() != 1 || *out != "" || *stamp {
+			fs.Usage()
+			os.Exit(2)
+		}
+		return listUserKeys(path)
+	case "set":
+		if fs.NArg() < 2 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		set := make(map[string][]byte)
+		var order []string
+		for _, arg := range fs.Args()[1:] {
+			key, raw, ok := strings.Cut(arg, "=")
+			if !ok {
+				return fmt.Errorf("user set: %q is not KEY=VALUE", arg)
+			}
+			if !isUserKey(key) {
+				return fmt.Errorf("user set: key %q is outside the %s namespaces", key, strings.Join(userPrefixes, "*/")+"*")
+			}
+			enc, err := encodeUserValue(key, raw)
+			if err != nil {
+				return fmt.Errorf("user set: %w", err)
+			}
+			if _, dup := set[key]; !dup {
+				order = append(order, key)
+			}
+			set[key] = enc
+		}
+		return rewriteMetadata(path, dest, *stamp, func(kvs []rawKV) ([]rawKV, error) {
+			// Replace existing keys in place and append new ones in argument order
+			kept := kvs[:0]
+			for _, kv := range kvs {
+				if enc, ok := set[kv.key]; ok {
+					if enc == nil {
+						continue // earlier duplicate already replaced
+					}
+					kv.enc = enc
+					set[kv.key] = nil
+				}
+				kept = append(kept, kv)
+			}
+			for _, key := range order {
+				if enc := set[key]; enc != nil {
+					kept = append(kept, rawKV{key: key, enc: enc})
+				}
+			}
+			return kept, nil
+		})
+	case "clear":
+		only := make(map[string]bool)
+		for _, key := range fs.Args()[1:] {
+			if !isUserKey(key) {
+				return fmt.Errorf("user clear: key %q is outside the %s namespaces", key, strings.Join(userPrefixes, "*/")+"*")
+			}
+			only[key] = true
+		}
+		return rewriteMetadata(path, dest, *stamp, func(kvs []rawKV) ([]rawKV, error) {
+			kept := kvs[:0]
+			for _, kv := range kvs {
+				if isUserKey(kv.key) && (len(only) == 0 || only[kv.key]) {
+					continue
+				}
+				kept = append(kept, kv)
+			}
+			return kept, nil
+		})
+	}
+	fs.Usage()
+	os.Exit(2)
+	return nil
+}
+
+// listUserKeys prints the user.* and x.* pairs of path as NDJSON, arrays expanded.
+func listUserKeys(path string) error {
+	pol := basePolicy()
+	pol.expandPrefixes = userPrefixes
+	gf, err := loadFile(context.Background(), path, pol)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, kv := range gf.KVs {
+		if isUserKey(kv.Key) {
+			if err := enc.Encode(kv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeUserValue parses raw as JSON and encodes it as a KV pair. Strings,
+// booleans and numbers map to string, bool, and int64 or float64; arrays of
+// one such kind become GGUF arrays. Objects, null and mixed arrays have no
+// GGUF equivalent and are stored as their compact JSON text. Input that is not
+// JSON at all is stored as a string.
+func encodeUserValue(key, raw string) ([]byte, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		v = raw
+	}
+	gw := newWriter(io.Discard)
+	if err := gw.AddKV(key, userValue(v)); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(gw.kvs.Bytes()), nil
+}
+
+// userValue maps a decoded JSON value to the Go value AddKV encodes.
+func userValue(v any) any {
+	switch x := v.(type) {
+	case string, bool:
+		return x
+	case json.Number:
+		return jsonNumber(x, true)
+	case []any:
+		if len(x) == 0 {
+			break
+		}
+		ints := true
+		for _, it := range x {
+			n, ok := it.(json.Number)
+			if !ok {
+				ints = false
+				break
+			}
+			if _, err := n.Int64(); err != nil {
+				ints = false
+			}
+		}
+		items := make([]any, len(x))
+		same := true
+		for i, it := range x {
+			switch it := it.(type) {
+			case json.Number:
+				items[i] = jsonNumber(it, ints)
+			case string, bool:
+				items[i] = it
+			default:
+				same = false
+			}
+			if i > 0 && fmt.Sprintf("%T", items[i]) != fmt.Sprintf("%T", items[0]) {
+				same = false
+			}
+		}
+		if same {
+			return items
+		}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// jsonNumber returns n as int64 when allowed and exact, else as float64.
+func jsonNumber(n json.Number, asInt bool) any {
+	if asInt {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+	}
+	if f, err := n.Float64(); err == nil && !math.IsInf(f, 0) {
+		return f
+	}
+	return n.String()
+}
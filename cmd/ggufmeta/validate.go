@@ -0,0 +1,140 @@
+// Package main implements the `validate` subcommand.
+// Validation runs a list of registered checks over a loaded file; each check
+// lives in its own file and reports findings, and the command exits non-zero
+// when any finding is an error.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// finding is one result of a validation check.
+type finding struct {
+	Severity string `json:"severity"` // "error", "warning" or "info"
+	Check    string `json:"check"`
+	Key      string `json:"key,omitempty"`
+	Tensor   string `json:"tensor,omitempty"`
+	Message  string `json:"message"`
+}
+
+// validateReport is the document `ggufmeta validate --json` prints.
+type validateReport struct {
+	File     string    `json:"file"`
+	OK       bool      `json:"ok"` // no error findings
+	Findings []finding `json:"findings"`
+}
+
+// validation is the state shared by the checks of one validate run.
+type validation struct {
+	gf       *ggufFile
+	data     io.ReaderAt // the file itself, for checks that read tensor data
+	findings []finding
+	check    string // name of the running check, stamped on its findings
+}
+
+func (v *validation) report(severity, key, tensor, format string, args ...any) {
+	v.findings = append(v.findings, finding{
+		Severity: severity,
+		Check:    v.check,
+		Key:      key,
+		Tensor:   tensor,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// validateCheck is one named check, run in registration order.
+type validateCheck struct {
+	Name string
+	Run  func(v *validation)
+}
+
+var validateChecks []validateCheck
+
+// registerCheck adds a check; call it from an init function.
+func registerCheck(name string, run func(v *validation)) {
+	for _, c := range validateChecks {
+		if c.Name == name {
+			panic(fmt.Sprintf("validate check %q registered twice", name))
+		}
+	}
+	validateChecks = append(validateChecks, validateCheck{Name: name, Run: run})
+}
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print findings as one JSON document")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta validate [--json] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	gf, err := loadFile(context.Background(), path, basePolicy())
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	v := &validation{gf: gf, data: f}
+	for _, c := range validateChecks {
+		v.check = c.Name
+		c.Run(v)
+	}
+	ok, err := writeFindings(path, v.findings, *asJSON)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// writeFindings prints findings as text lines or as one validateReport
+// document and reports whether none of them is an error.
+func writeFindings(path string, findings []finding, asJSON bool) (bool, error) {
+	ok := true
+	for _, fd := range findings {
+		if fd.Severity == "error" {
+			ok = false
+		}
+	}
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		rep := validateReport{File: path, OK: ok, Findings: findings}
+		if rep.Findings == nil {
+			rep.Findings = []finding{}
+		}
+		return ok, enc.Encode(rep)
+	}
+	for _, fd := range findings {
+		subject := fd.Key
+		if fd.Tensor != "" {
+			subject = "tensor " + fd.Tensor
+		}
+		if subject != "" {
+			subject += ": "
+		}
+		fmt.Printf("%-7s %-12s %s%s\n", fd.Severity, fd.Check, subject, fd.Message)
+	}
+	if ok {
+		fmt.Printf("%s: ok\n", path)
+	} else {
+		fmt.Printf("%s: FAILED\n", path)
+	}
+	return ok, nil
+}
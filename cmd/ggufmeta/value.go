@@ -4,50 +4,50 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
-	"os"
 	"strings"
+	"unicode/utf8"
 )
 
-// alignBeforeValue is an experimental toggle for GGUF format alignment behavior.
-// When true: align to 8-byte boundary before reading value payload after type tag
-// When false: read value immediately after type tag (standard GGUF behavior)
-// This helps debug GGUF files that may have non-standard alignment requirements.
+// alignBeforeValue forces the non-standard layout where every value is padded to
+// an 8-byte boundary after its type tag. Parsers start in this mode when it is
+// set; either way probeAlignment detects the layout per value and can switch.
 var alignBeforeValue bool
 
+// ctxCheckEvery is how many array elements are read between cancellation checks;
+// often enough to stop promptly, rare enough to stay off the hot path.
+const ctxCheckEvery = 4096
+
 // scalarDec defines the function signature for scalar value decoders.
 // Each GGUF scalar type has a decoder that reads from the scanner.
 type scalarDec = func(*scanner) (any, error)
 
-// scalars maps GGUF type constants to their corresponding decoder functions.
-// The array index corresponds to the type constant (tUint8=0, tInt8=1, etc.)
-// nil entries indicate types that need special handling (string, array).
-var scalars = []scalarDec{
-	func(s *scanner) (any, error) { return s.U8() },                    // uint8   (0) - tUint8
-	func(s *scanner) (any, error) { return s.I8() },                    // int8    (1) - tInt8
-	func(s *scanner) (any, error) { return s.U16() },                   // uint16  (2) - tUint16
-	func(s *scanner) (any, error) { return s.I16() },                   // int16   (3) - tInt16
-	func(s *scanner) (any, error) { return s.U32() },                   // uint32  (4) - tUint32
-	func(s *scanner) (any, error) { return s.I32() },                   // int32   (5) - tInt32
-	func(s *scanner) (any, error) { return s.F32() },                   // float32 (6) - tFloat32
-	func(s *scanner) (any, error) { u, e := s.U8(); return u != 0, e }, // bool    (7) - tBool (0=false, non-zero=true)
-	nil, // string (8) - tString: special case handled in readScalar
-	nil, // array (9) - tArray: special case handled in readValue
-	func(s *scanner) (any, error) { return s.U64() }, // uint64  (10) - tUint64
-	func(s *scanner) (any, error) { return s.I64() }, // int64   (11) - tInt64
-	func(s *scanner) (any, error) { return s.F64() }, // float64 (12) - tFloat64
+// The GGUF v3 value types. String and array have no decoder: readScalar and
+// readValue handle their variable-length layouts directly.
+func init() {
+	registerType(tUint8, valueType{"uint8", func(s *scanner) (any, error) { return s.U8() }})
+	registerType(tInt8, valueType{"int8", func(s *scanner) (any, error) { return s.I8() }})
+	registerType(tUint16, valueType{"uint16", func(s *scanner) (any, error) { return s.U16() }})
+	registerType(tInt16, valueType{"int16", func(s *scanner) (any, error) { return s.I16() }})
+	registerType(tUint32, valueType{"uint32", func(s *scanner) (any, error) { return s.U32() }})
+	registerType(tInt32, valueType{"int32", func(s *scanner) (any, error) { return s.I32() }})
+	registerType(tFloat32, valueType{"float32", func(s *scanner) (any, error) { return s.F32() }})
+	// bool is stored as uint8: 0=false, non-zero=true
+	registerType(tBool, valueType{"bool", func(s *scanner) (any, error) { u, e := s.U8(); return u != 0, e }})
+	registerType(tString, valueType{"string", nil})
+	registerType(tArray, valueType{"array", nil})
+	registerType(tUint64, valueType{"uint64", func(s *scanner) (any, error) { return s.U64() }})
+	registerType(tInt64, valueType{"int64", func(s *scanner) (any, error) { return s.I64() }})
+	registerType(tFloat64, valueType{"float64", func(s *scanner) (any, error) { return s.F64() }})
 }
 
 // typeLabel creates human-readable type descriptions for NDJSON output.
 // Combines the base type name with optional shape information (e.g., "array[int32]").
 func typeLabel(tag uint32, shape string) string {
-	if int(tag) < len(typeNames) {
-		// Known type - use the predefined name
-		if shape == "" {
-			return typeNames[tag]
-		}
-		// Add shape information (for arrays)
-		return typeNames[tag] + shape
+	if name, ok := typeName(tag); ok {
+		// Known type - use the registered name, plus shape information for arrays
+		return name + shape
 	}
 	// Unknown type - show the raw tag value
 	if shape == "" {
@@ -60,6 +60,16 @@ func typeLabel(tag uint32, shape string) string {
 // Handles strings specially due to their length-prefixed format.
 // Returns the value, type label, and any error.
 func (p *parser) readScalar(tag uint32) (any, string, error) {
+	if tag == tString && p.pol.truncStrings {
+		s, n, err := p.scn.GGUFStringPrefix(p.pol.maxString)
+		if err != nil {
+			return nil, "", err
+		}
+		if uint64(len(s)) < n {
+			return truncatedString(s, n), "string", nil
+		}
+		return s, "string", nil
+	}
 	if tag == tString {
 		// Strings are special: uint64 length + UTF-8 bytes
 		s, err := p.scn.GGUFString(p.pol.maxString)
@@ -70,11 +80,12 @@ func (p *parser) readScalar(tag uint32) (any, string, error) {
 		return s, "string", nil
 	}
 	// Validate the scalar type tag
-	if int(tag) >= len(scalars) || scalars[tag] == nil {
-		return nil, "", fmt.Errorf("bad scalar tag %d", tag)
+	vt, ok := valueTypes[tag]
+	if !ok || vt.Decode == nil {
+		return nil, "", fmt.Errorf("%w %d", errUnknownType, tag)
 	}
 	// Use the appropriate decoder for this scalar type
-	v, err := scalars[tag](p.scn)
+	v, err := vt.Decode(p.scn)
 	if err != nil {
 		return nil, "", err
 	}
@@ -82,6 +93,29 @@ func (p *parser) readScalar(tag uint32) (any, string, error) {
 	return v, typeLabel(tag, ""), nil
 }
 
+// truncatedString is the placeholder for a string value cut by
+// --truncate-strings: its first bytes and its full length.
+func truncatedString(prefix string, length uint64) map[string]any {
+	return map[string]any{
+		"_placeholder": "truncated_string",
+		"length":       length,
+		"prefix":       prefix,
+	}
+}
+
+// cutString cuts s to at most n bytes without splitting a UTF-8 sequence.
+func cutString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := n; i > 0 && i > n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			return s[:i]
+		}
+	}
+	return s[:n]
+}
+
 // readArray implements the two-pass strategy for array handling.
 // By default, returns placeholders for arrays. Expands arrays only when explicitly requested.
 // This prevents memory issues with large arrays while allowing selective detail access.
@@ -97,16 +131,14 @@ func (p *parser) readArray(key string) (any, string, bool, error) {
 	}
 
 	// Get human-readable name for the element type
-	elemName := "unknown"
-	if int(et) < len(typeNames) {
-		elemName = typeNames[et]
+	elemName, ok := typeName(et)
+	if !ok {
+		elemName = "unknown"
 	}
 
 	// Debug output for array structure
-	if p.pol.debug {
-		fmt.Fprintf(os.Stderr, "[debug] key=%q array elemTag=%d(%s) len=%d pos=%d\n",
-			key, et, elemName, n, p.scn.pos)
-	}
+	logger.Debug("array", "key", key, "elem_tag", et, "elem_type", elemName,
+		"len", n, "pos", p.scn.pos)
 
 	// Determine if this array should be expanded based on user preferences
 	// Explicit expansion overrides size limits ("explicit should preempt implicit behavior")
@@ -120,7 +152,18 @@ func (p *parser) readArray(key string) (any, string, bool, error) {
 			}
 		}
 	}
+	// Arrays within the size limit are cheap enough to show in full
+	if limit, ok := p.pol.autoExpandLimit(elemName); !shouldExpand && ok && n <= limit {
+		shouldExpand = true
+	}
+
+	if r, ok := p.pol.expandRanges[key]; ok {
+		// A requested slice wins over full expansion
+		result, typeLabel, err := p.readArrayRange(et, n, elemName, r)
+		return result, typeLabel, false, err
+	}
 
+	p.hintArray(et, n)
 	if shouldExpand {
 		// User explicitly requested this array - expand it fully
 		result, typeLabel, err := p.readExpandedArray(et, n, elemName)
@@ -128,20 +171,79 @@ func (p *parser) readArray(key string) (any, string, bool, error) {
 	}
 
 	// Default behavior: skip array contents efficiently and return placeholder
-	err = p.bulkSkipArrayElements(et, n)
+	pv, err := p.skipArray(et, n)
 	if err != nil {
 		return nil, "", false, err
 	}
 
 	// Create placeholder with structural information
 	// This gives users the array metadata without the memory cost
-	placeholder := map[string]any{
-		"_placeholder": "array",    // Identifies this as a placeholder
-		"count":        n,           // Number of elements
-		"element_type": elemName,    // Type of each element
+	return pv.placeholder("array", et, n), "array[" + elemName + "]", false, nil
+}
+
+// previewMaxString bounds the first and last elements a placeholder shows
+// of a string array; longer ones appear as truncated_string placeholders.
+const previewMaxString = 256
+
+// arrayPreview is what a placeholder shows of the array it stands in for,
+// gathered while the elements are skipped.
+type arrayPreview struct {
+	first, last any    // first and last element; nil for arrays of arrays
+	byteLen     uint64 // encoded size of the elements
+	maxLen      uint64 // longest element in bytes, for string arrays
+	digest      string // "algo:hex" with --array-digest
+}
+
+// placeholder builds the placeholder map of the given kind ("array" or
+// "nested_array") for count elements of type et.
+func (pv arrayPreview) placeholder(kind string, et uint32, count uint64) map[string]any {
+	elemName, known := typeName(et)
+	if !known {
+		elemName = "unknown"
+	}
+	m := map[string]any{
+		"_placeholder": kind,       // Identifies this as a placeholder
+		"count":        count,      // Number of elements
+		"element_type": elemName,   // Type of each element
+		"byte_length":  pv.byteLen, // Encoded size of the elements
 	}
+	if !known {
+		// element_type stays "unknown" for consumers matching on it; the raw tag is extra
+		m["element_tag"] = et
+	}
+	if count > 0 && et != tArray {
+		m["first"], m["last"] = pv.first, pv.last
+	}
+	if et == tString {
+		m["max_length"] = pv.maxLen
+	}
+	if pv.digest != "" {
+		m["digest"] = pv.digest
+	}
+	return m
+}
 
-	return placeholder, "array[" + elemName + "]", false, nil
+// note records element i of count, with value v, in the preview.
+func (pv *arrayPreview) note(et uint32, i, count uint64, v any) {
+	n := uint64(0)
+	switch x := v.(type) {
+	case string:
+		n = uint64(len(x))
+		if n > previewMaxString && (i == 0 || i == count-1) {
+			v = truncatedString(cutString(x, previewMaxString), n)
+		}
+	case map[string]any: // already a truncated_string
+		n, _ = x["length"].(uint64)
+	}
+	if et == tString {
+		pv.maxLen = max(pv.maxLen, n)
+	}
+	if i == 0 {
+		pv.first = v
+	}
+	if i == count-1 {
+		pv.last = v
+	}
 }
 
 // readExpandedArray reads and returns the full array contents when explicitly requested.
@@ -152,7 +254,14 @@ func (p *parser) readExpandedArray(elementType uint32, count uint64, elemName st
 	results := make([]any, 0, safeCapFromCount(count))
 
 	// Read each array element
+	start := p.scn.pos
 	for i := uint64(0); i < count; i++ {
+		if i%ctxCheckEvery == 0 {
+			if err := p.ctx.Err(); err != nil {
+				return nil, "", err
+			}
+			p.hintRest(elementType, start, i, count)
+		}
 		if elementType == tArray {
 			// Nested arrays: read structure but don't expand recursively
 			// This prevents exponential memory usage with deeply nested arrays
@@ -165,15 +274,12 @@ func (p *parser) readExpandedArray(elementType uint32, count uint64, elemName st
 				return nil, "", err
 			}
 			// Skip the nested array contents
-			if err := p.bulkSkipArrayElements(nestedET, nestedN); err != nil {
+			pv, err := p.skipArray(nestedET, nestedN)
+			if err != nil {
 				return nil, "", err
 			}
 			// Add placeholder for the nested array
-			results = append(results, map[string]any{
-				"_placeholder": "nested_array",
-				"count":        nestedN,
-				"element_type": typeNames[nestedET],
-			})
+			results = append(results, pv.placeholder("nested_array", nestedET, nestedN))
 		} else {
 			// Scalar element - read the actual value
 			v, _, err := p.readScalar(elementType)
@@ -187,11 +293,44 @@ func (p *parser) readExpandedArray(elementType uint32, count uint64, elemName st
 	return results, "array[" + elemName + "]", nil
 }
 
+// skipArray skips an array's elements like bulkSkipArrayElements and returns
+// the preview for its placeholder. With --array-digest the preview includes
+// the digest of the skipped bytes: the elements exactly as stored, string
+// length prefixes and nested array headers included.
+func (p *parser) skipArray(elementType uint32, count uint64) (arrayPreview, error) {
+	var pv arrayPreview
+	start := p.scn.pos
+	newHash := hashAlgos[p.pol.arrayDigest]
+	if newHash == nil || p.scn.tee != nil {
+		err := p.bulkSkipArrayElements(elementType, count, &pv)
+		pv.byteLen = p.scn.pos - start
+		return pv, err
+	}
+	h := newHash()
+	p.scn.tee = h
+	err := p.bulkSkipArrayElements(elementType, count, &pv)
+	p.scn.tee = nil
+	if err != nil {
+		return pv, err
+	}
+	pv.byteLen = p.scn.pos - start
+	pv.digest = p.pol.arrayDigest + ":" + hex.EncodeToString(h.Sum(nil))
+	return pv, nil
+}
+
 // bulkSkipArrayElements efficiently skips over array elements without storing values.
 // This is the performance-critical path for large arrays that aren't being expanded.
 // Uses iterative approach to avoid stack overflow on deeply nested arrays.
-func (p *parser) bulkSkipArrayElements(elementType uint32, count uint64) error {
+// When pv is set, the elements are noted in it on the way.
+func (p *parser) bulkSkipArrayElements(elementType uint32, count uint64, pv *arrayPreview) error {
+	start := p.scn.pos
 	for i := uint64(0); i < count; i++ {
+		if i%ctxCheckEvery == 0 {
+			if err := p.ctx.Err(); err != nil {
+				return err
+			}
+			p.hintRest(elementType, start, i, count)
+		}
 		if elementType == tArray {
 			// Nested array - read its header then skip its contents recursively
 			nestedET, err := p.scn.U32()
@@ -203,15 +342,18 @@ func (p *parser) bulkSkipArrayElements(elementType uint32, count uint64) error {
 				return err
 			}
 			// Recursively skip nested array elements
-			if err := p.bulkSkipArrayElements(nestedET, nestedN); err != nil {
+			if err := p.bulkSkipArrayElements(nestedET, nestedN, nil); err != nil {
 				return err
 			}
 		} else {
 			// Scalar element - read it and discard (just for position advancement)
-			_, _, err := p.readScalar(elementType)
+			v, _, err := p.readScalar(elementType)
 			if err != nil {
 				return err
 			}
+			if pv != nil {
+				pv.note(elementType, i, count, v)
+			}
 		}
 	}
 	return nil
@@ -221,10 +363,12 @@ func (p *parser) bulkSkipArrayElements(elementType uint32, count uint64) error {
 // Handles the experimental alignment toggle and delegates to appropriate readers.
 // Returns value, type label, omitted flag, and error.
 func (p *parser) readValue(tag uint32, key string) (any, string, bool, error) {
-	// EXPERIMENTAL ALIGNMENT TOGGLE:
-	// Most GGUF files use tight packing (alignBeforeValue=false)
-	// Some non-standard files may need 8-byte alignment before values (alignBeforeValue=true)
-	if alignBeforeValue {
+	// Most GGUF files use tight packing; some non-standard files pad to 8 bytes
+	// before values (see probeAlignment)
+	if p.padded {
+		if p.scn.pos%8 != 0 {
+			p.paddedValues++
+		}
 		if err := p.scn.Align8(); err != nil {
 			return nil, "", false, err
 		}
@@ -234,6 +378,10 @@ func (p *parser) readValue(tag uint32, key string) (any, string, bool, error) {
 		// Arrays need special handling due to two-pass strategy
 		return p.readArray(key)
 	}
+	if tag == tString && p.strFiles != nil && key != "" {
+		v, err := p.readStringToFile(key)
+		return v, "string", false, err
+	}
 	// All other types are scalars (including strings)
 	v, typ, err := p.readScalar(tag)
 	return v, typ, false, err
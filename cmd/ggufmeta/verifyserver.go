@@ -0,0 +1,168 @@
+// Package main implements the `verify-server` subcommand.
+// It asks a running llama.cpp server (llama-server) what it has loaded, via
+// /props and /v1/models, and compares the reported vocabulary, context and
+// model size with the file on disk - to catch a server still running an old
+// quant, or one started on a different file than the deployment expects.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// llamaServerProps is the part of GET /props this command reads.
+type llamaServerProps struct {
+	ModelPath    string `json:"model_path"`
+	ChatTemplate string `json:"chat_template"`
+	Settings     struct {
+		NCtx uint64 `json:"n_ctx"`
+	} `json:"default_generation_settings"`
+}
+
+// llamaServerModels is GET /v1/models; meta is llama.cpp's extension.
+type llamaServerModels struct {
+	Data []struct {
+		ID   string `json:"id"`
+		Meta *struct {
+			NVocab    uint64 `json:"n_vocab"`
+			NCtxTrain uint64 `json:"n_ctx_train"`
+			NEmbd     uint64 `json:"n_embd"`
+			NParams   uint64 `json:"n_params"`
+			Size      uint64 `json:"size"`
+		} `json:"meta"`
+	} `json:"data"`
+}
+
+func runVerifyServer(args []string) error {
+	fs := flag.NewFlagSet("verify-server", flag.ExitOnError)
+	base := fs.String("url", "http://localhost:8080", "llama-server base `URL`")
+	apiKey := fs.String("api-key", os.Getenv("LLAMA_API_KEY"), "bearer token for a server started with --api-key (default $LLAMA_API_KEY)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each request")
+	asJSON := fs.Bool("json", false, "print findings as one JSON document")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ggufmeta verify-server [--url URL] [--api-key KEY] [--json] file.gguf\n")
+		fs.PrintDefaults()
+	}
+	rest := parseInterspersed(fs, args)
+	if len(rest) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	file := rest[0]
+	gf, err := loadFile(context.Background(), file, basePolicy())
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	get := func(endpoint string, v any) error {
+		u, err := url.JoinPath(*base, endpoint)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		if *apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+*apiKey)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: %s", u, resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("GET %s: %w", u, err)
+		}
+		return nil
+	}
+	var props llamaServerProps
+	if err := get("props", &props); err != nil {
+		return fmt.Errorf("verify-server: %w", err)
+	}
+	var models llamaServerModels
+	if err := get("v1/models", &models); err != nil {
+		return fmt.Errorf("verify-server: %w", err)
+	}
+
+	ok, err := writeFindings(file, compareServer(gf, file, props, models), *asJSON)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// compareServer checks what the server reports against gf.
+func compareServer(gf *ggufFile, file string, props llamaServerProps, models llamaServerModels) []finding {
+	var out []finding
+	report := func(sev, key, format string, args ...any) {
+		out = append(out, finding{Severity: sev, Check: "server", Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+	want := filepath.Base(file)
+
+	if props.ModelPath != "" && path.Base(filepath.ToSlash(props.ModelPath)) != want {
+		report("warning", "", "server loaded %s, not %s", props.ModelPath, want)
+	}
+	if len(models.Data) == 0 {
+		report("error", "", "/v1/models lists no model")
+		return out
+	}
+	// A router serves several models; pick the one named after the file
+	m := models.Data[0]
+	for _, d := range models.Data {
+		if path.Base(filepath.ToSlash(d.ID)) == want {
+			m = d
+		}
+	}
+	if m.Meta == nil {
+		report("warning", "", "/v1/models has no meta for %s (server too old?); only /props was compared", m.ID)
+	} else {
+		compare := func(key, what string, server uint64, disk uint64, ok bool) {
+			if !ok {
+				report("info", key, "%s: the file does not record it; the server reports %d", what, server)
+			} else if server != disk {
+				report("error", key, "%s: server reports %d, file has %d", what, server, disk)
+			}
+		}
+		tokens, hasTokens := uint64(0), false
+		if kv, ok := gf.Get("tokenizer.ggml.tokens"); ok {
+			tokens, hasTokens = arrayLen(kv.Value)
+		}
+		compare("tokenizer.ggml.tokens", "vocabulary size", m.Meta.NVocab, tokens, hasTokens)
+		ctx, ok := gf.Uint("{arch}.context_length")
+		compare(expandArch(gf, "{arch}.context_length"), "training context", m.Meta.NCtxTrain, ctx, ok)
+		embd, ok := gf.Uint("{arch}.embedding_length")
+		compare(expandArch(gf, "{arch}.embedding_length"), "embedding length", m.Meta.NEmbd, embd, ok)
+		compare("", "parameter count", m.Meta.NParams, paramCount(gf), true)
+		var size uint64
+		ends := gf.tensorEnds()
+		for _, t := range gf.Tensors {
+			size += gf.tensorSize(t, ends)
+		}
+		compare("", "tensor data bytes", m.Meta.Size, size, true)
+	}
+
+	if ctx, ok := gf.Uint("{arch}.context_length"); ok && props.Settings.NCtx > ctx {
+		report("warning", expandArch(gf, "{arch}.context_length"), "server context %d exceeds the training context %d", props.Settings.NCtx, ctx)
+	}
+	if tmpl, err := gf.GetString("tokenizer.chat_template"); err == nil && props.ChatTemplate != "" && strings.TrimSpace(tmpl) != strings.TrimSpace(props.ChatTemplate) {
+		report("warning", "tokenizer.chat_template", "server uses a different chat template than the file (started with --chat-template?)")
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+// Package main implements push-style parsing with walk.
+// Callers get every header, KV and tensor info event through a callback and can
+// stop at any point, instead of driving nextKV/nextTensor loops themselves.
+// walk is internal to the command; loadFile is built on it.
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// event is one item delivered by walk: a headerEvent, a kvEvent or a tensorInfo,
+// always in that file order.
+type event interface {
+	isEvent()
+}
+
+func (headerEvent) isEvent() {}
+func (kvEvent) isEvent()     {}
+func (tensorInfo) isEvent()  {}
+
+// errStopWalk can be returned by a walk callback to end parsing early.
+// walk itself then returns nil, like filepath.SkipAll.
+var errStopWalk = errors.New("stop walk")
+
+// walk parses r and calls fn for the header, each KV pair and each tensor info.
+// Omitted KV pairs are skipped. Any error from fn other than errStopWalk aborts
+// the walk and is returned unchanged. Cancelling ctx stops the walk with ctx.Err().
+func walk(ctx context.Context, r io.Reader, size uint64, pol policy, fn func(ev event) error) error {
+	p, hdr, err := newParser(r, size, pol)
+	if err != nil {
+		return err
+	}
+	return walkParser(ctx, p, hdr, fn)
+}
+
+// walkParser is walk over a parser that has just read hdr.
+func walkParser(ctx context.Context, p *parser, hdr headerEvent, fn func(ev event) error) error {
+	p.ctx = ctx
+	if err := fn(hdr); err != nil {
+		return stopOK(err)
+	}
+	for {
+		kv, ok, err := p.nextKV()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if kv.Key == "" { // omitted
+			continue
+		}
+		if err := fn(kv); err != nil {
+			return stopOK(err)
+		}
+	}
+	for {
+		ti, ok, err := p.nextTensor()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if err := fn(ti); err != nil {
+			return stopOK(err)
+		}
+	}
+}
+
+// stopOK maps errStopWalk to a clean nil return.
+func stopOK(err error) error {
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
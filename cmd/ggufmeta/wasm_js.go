@@ -0,0 +1,69 @@
+//go:build js && wasm
+
+// Package main implements the JavaScript bindings of the js/wasm build.
+// Instead of reading a command line, the module installs
+// globalThis.ggufmeta.parse(bytes, optionsJSON) and stays alive to serve it.
+// js/ggufmeta.js wraps that in a promise-based parse(ArrayBuffer|Blob|stream)
+// that feeds a stream in until the metadata is complete, so a browser never
+// reads more of a multi-gigabyte file than the header, KVs and tensor infos.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"syscall/js"
+)
+
+func init() { jsMain = serveJS }
+
+// jsResult is what parse returns, as JSON. Truncated asks the wrapper for
+// more bytes: the buffer ended before the tensor info table did.
+type jsResult struct {
+	Header     *headerEvent   `json:"header,omitempty"`
+	KVs        []kvEvent      `json:"kvs,omitempty"`
+	Tensors    []tensorRecord `json:"tensors,omitempty"`
+	DataOffset uint64         `json:"dataOffset,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Truncated  bool           `json:"truncated,omitempty"`
+}
+
+func serveJS() {
+	api := js.Global().Get("Object").New()
+	api.Set("parse", js.FuncOf(jsParse))
+	js.Global().Set("ggufmeta", api)
+	select {}
+}
+
+// jsParse parses args[0], a Uint8Array holding the file or a prefix of it,
+// with the options JSON in args[1].
+func jsParse(this js.Value, args []js.Value) any {
+	res := parseForJS(args)
+	out, err := json.Marshal(res)
+	if err != nil {
+		out, _ = json.Marshal(jsResult{Error: err.Error()})
+	}
+	return string(out)
+}
+
+func parseForJS(args []js.Value) jsResult {
+	if len(args) == 0 || args[0].Type() != js.TypeObject {
+		return jsResult{Error: "parse: want a Uint8Array"}
+	}
+	var opts embedOptions
+	if len(args) > 1 && args[1].Type() == js.TypeString {
+		if err := json.Unmarshal([]byte(args[1].String()), &opts); err != nil {
+			return jsResult{Error: "parse: bad options: " + err.Error()}
+		}
+	}
+	pol := opts.policy()
+
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+	gf, err := readFile(context.Background(), bytes.NewReader(data), opts.Size, pol)
+	if err != nil {
+		return jsResult{Error: err.Error(), Truncated: errors.Is(err, errTruncated)}
+	}
+	return jsResult{Header: &gf.Header, KVs: gf.KVs, Tensors: tensorRecords(gf), DataOffset: gf.DataOffset}
+}
@@ -0,0 +1,340 @@
+// Package main implements ggufWriter, which produces GGUF v3 files.
+// KV pairs and tensor infos are buffered until Close, because the header needs
+// their counts and the info table needs every tensor's offset; tensor payloads
+// are streamed from their readers only at Close, so nothing large is held.
+// It serves the subcommands that write GGUF output and is not exported.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// ggufWriter writes a little-endian GGUF v3 file to an io.Writer.
+// Call AddKV/AddTensor in file order, then Close to emit everything.
+type ggufWriter struct {
+	w       io.Writer
+	kvs     bytes.Buffer
+	nkv     uint64
+	keys    map[string]bool
+	tensors []writerTensor
+	names   map[string]bool
+	align   uint64
+	dataEnd uint64 // end of the last tensor, relative to the data section
+	closed  bool
+}
+
+type writerTensor struct {
+	info tensorInfo
+	size uint64
+	data io.Reader
+}
+
+// newWriter returns a writer with the default 32-byte alignment. Setting
+// general.alignment through AddKV changes it for the tensors added afterwards.
+func newWriter(w io.Writer) *ggufWriter {
+	return &ggufWriter{w: w, keys: make(map[string]bool), names: make(map[string]bool), align: 32}
+}
+
+// AddKV adds a KV pair whose GGUF type follows from the Go type of v: the
+// fixed-size numeric types, bool, string, slices of those, or a non-empty
+// []any of one such type (as produced by array expansion).
+func (gw *ggufWriter) AddKV(key string, v any) error {
+	if tag, ok := scalarTag(v); ok {
+		return gw.addKV(key, tag, 0, v)
+	}
+	elem, items, err := arrayItems(v)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", key, err)
+	}
+	return gw.addKV(key, tArray, elem, items)
+}
+
+// AddTypedKV adds a KV pair with an explicit type label as found in kvEvent.Type,
+// e.g. "uint32" or "array[string]". It round-trips parsed events, including
+// empty arrays whose element type cannot be inferred from the value.
+func (gw *ggufWriter) AddTypedKV(key, typ string, v any) error {
+	if elemName, ok := strings.CutPrefix(typ, "array["); ok {
+		elem, ok := typeTag(strings.TrimSuffix(elemName, "]"))
+		if !ok || elem == tArray {
+			return fmt.Errorf("key %q: unsupported type %q", key, typ)
+		}
+		if _, ok := v.([]any); !ok {
+			var err error
+			if _, v, err = arrayItems(v); err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+		}
+		return gw.addKV(key, tArray, elem, v.([]any))
+	}
+	tag, ok := typeTag(typ)
+	if !ok || tag == tArray {
+		return fmt.Errorf("key %q: unsupported type %q", key, typ)
+	}
+	if got, _ := scalarTag(v); got != tag {
+		return fmt.Errorf("key %q: value %T does not match type %s", key, v, typ)
+	}
+	return gw.addKV(key, tag, 0, v)
+}
+
+// AddRawKV adds an already encoded little-endian KV pair, such as one copied
+// from another file, for values the typed methods cannot express (nested arrays).
+func (gw *ggufWriter) AddRawKV(key string, pair []byte) error {
+	if gw.closed {
+		return fmt.Errorf("key %q: writer is closed", key)
+	}
+	if gw.keys[key] {
+		return fmt.Errorf("key %q: duplicate key", key)
+	}
+	gw.keys[key] = true
+	gw.nkv++
+	gw.kvs.Write(pair)
+	return nil
+}
+
+func (gw *ggufWriter) addKV(key string, tag, elem uint32, v any) error {
+	if gw.closed {
+		return fmt.Errorf("key %q: writer is closed", key)
+	}
+	if gw.keys[key] {
+		return fmt.Errorf("key %q: duplicate key", key)
+	}
+	if key == "general.alignment" {
+		a, ok := v.(uint32)
+		if !ok || a == 0 || a%8 != 0 {
+			return fmt.Errorf("key %q: must be a non-zero uint32 multiple of 8", key)
+		}
+		if len(gw.tensors) > 0 {
+			return fmt.Errorf("key %q: must be set before adding tensors", key)
+		}
+		gw.align = uint64(a)
+	}
+	var buf bytes.Buffer
+	putString(&buf, key)
+	putU32(&buf, tag)
+	if tag == tArray {
+		items := v.([]any)
+		putU32(&buf, elem)
+		putU64(&buf, uint64(len(items)))
+		for i, it := range items {
+			if got, _ := scalarTag(it); got != elem {
+				return fmt.Errorf("key %q: element %d is %T, want %s", key, i, it, typeLabel(elem, ""))
+			}
+			putScalar(&buf, it)
+		}
+	} else {
+		putScalar(&buf, v)
+	}
+	gw.keys[key] = true
+	gw.nkv++
+	gw.kvs.Write(buf.Bytes())
+	return nil
+}
+
+// AddTensor adds a tensor whose payload will be read from data at Close. The
+// payload size follows from dims and dtype, so the type must have a known
+// ggml block layout; data must yield at least that many bytes.
+func (gw *ggufWriter) AddTensor(name string, dims []uint64, dtype uint32, data io.Reader) error {
+	if gw.closed {
+		return fmt.Errorf("tensor %q: writer is closed", name)
+	}
+	if gw.names[name] {
+		return fmt.Errorf("tensor %q: duplicate tensor name", name)
+	}
+	if len(dims) == 0 || len(dims) > maxTensorDims {
+		return fmt.Errorf("tensor %q: invalid dimension count %d", name, len(dims))
+	}
+	size, ok := ggmlTensorSize(dtype, dims)
+	if !ok {
+		return fmt.Errorf("tensor %q: cannot size %s tensor with dims %v", name, ggmlTypeName(dtype), dims)
+	}
+	off := alignUp(gw.dataEnd, gw.align)
+	gw.names[name] = true
+	gw.tensors = append(gw.tensors, writerTensor{
+		info: tensorInfo{Name: name, Dims: append([]uint64(nil), dims...), Type: dtype, Offset: off},
+		size: size,
+		data: data,
+	})
+	gw.dataEnd = off + size
+	return nil
+}
+
+// Close writes the header, KV section, tensor info table and tensor data,
+// padding the data section start and every tensor to the alignment.
+// It does not close the underlying writer.
+func (gw *ggufWriter) Close() error {
+	if gw.closed {
+		return nil
+	}
+	gw.closed = true
+
+	var meta bytes.Buffer
+	meta.WriteString(magicGGUF)
+	putU32(&meta, 3)
+	putU64(&meta, uint64(len(gw.tensors)))
+	putU64(&meta, gw.nkv)
+	meta.Write(gw.kvs.Bytes())
+	for _, t := range gw.tensors {
+		putString(&meta, t.info.Name)
+		putU32(&meta, uint32(len(t.info.Dims)))
+		for _, d := range t.info.Dims {
+			putU64(&meta, d)
+		}
+		putU32(&meta, t.info.Type)
+		putU64(&meta, t.info.Offset)
+	}
+	if len(gw.tensors) > 0 {
+		meta.Write(make([]byte, alignUp(uint64(meta.Len()), gw.align)-uint64(meta.Len())))
+	}
+
+	bw := bufio.NewWriterSize(gw.w, 1<<20)
+	if _, err := bw.Write(meta.Bytes()); err != nil {
+		return err
+	}
+	var pos uint64 // relative to the data section
+	for _, t := range gw.tensors {
+		if _, err := bw.Write(make([]byte, t.info.Offset-pos)); err != nil {
+			return err
+		}
+		n, err := io.CopyN(bw, t.data, int64(t.size))
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("short data: got %d of %d bytes", n, t.size)
+			}
+			return fmt.Errorf("tensor %q: %w", t.info.Name, err)
+		}
+		pos = t.info.Offset + t.size
+	}
+	if len(gw.tensors) > 0 {
+		if _, err := bw.Write(make([]byte, alignUp(pos, gw.align)-pos)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// scalarTag returns the GGUF tag for a Go scalar value.
+func scalarTag(v any) (uint32, bool) {
+	switch v.(type) {
+	case uint8:
+		return tUint8, true
+	case int8:
+		return tInt8, true
+	case uint16:
+		return tUint16, true
+	case int16:
+		return tInt16, true
+	case uint32:
+		return tUint32, true
+	case int32:
+		return tInt32, true
+	case float32:
+		return tFloat32, true
+	case bool:
+		return tBool, true
+	case string:
+		return tString, true
+	case uint64:
+		return tUint64, true
+	case int64:
+		return tInt64, true
+	case float64:
+		return tFloat64, true
+	}
+	return 0, false
+}
+
+// arrayItems converts a supported slice to its element tag and []any items.
+func arrayItems(v any) (uint32, []any, error) {
+	switch x := v.(type) {
+	case []uint8:
+		return tUint8, anySlice(x), nil
+	case []int8:
+		return tInt8, anySlice(x), nil
+	case []uint16:
+		return tUint16, anySlice(x), nil
+	case []int16:
+		return tInt16, anySlice(x), nil
+	case []uint32:
+		return tUint32, anySlice(x), nil
+	case []int32:
+		return tInt32, anySlice(x), nil
+	case []float32:
+		return tFloat32, anySlice(x), nil
+	case []bool:
+		return tBool, anySlice(x), nil
+	case []string:
+		return tString, anySlice(x), nil
+	case []uint64:
+		return tUint64, anySlice(x), nil
+	case []int64:
+		return tInt64, anySlice(x), nil
+	case []float64:
+		return tFloat64, anySlice(x), nil
+	case []any:
+		if len(x) == 0 {
+			return 0, nil, fmt.Errorf("cannot infer element type of empty array (use AddTypedKV)")
+		}
+		elem, ok := scalarTag(x[0])
+		if !ok {
+			return 0, nil, fmt.Errorf("unsupported array element type %T", x[0])
+		}
+		return elem, x, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported value type %T", v)
+}
+
+func anySlice[T any](s []T) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+// putScalar appends v, which must satisfy scalarTag, in little-endian order.
+func putScalar(buf *bytes.Buffer, v any) {
+	switch x := v.(type) {
+	case uint8:
+		buf.WriteByte(x)
+	case int8:
+		buf.WriteByte(byte(x))
+	case uint16:
+		buf.Write(binary.LittleEndian.AppendUint16(nil, x))
+	case int16:
+		buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(x)))
+	case uint32:
+		putU32(buf, x)
+	case int32:
+		putU32(buf, uint32(x))
+	case float32:
+		putU32(buf, math.Float32bits(x))
+	case bool:
+		if x {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case string:
+		putString(buf, x)
+	case uint64:
+		putU64(buf, x)
+	case int64:
+		putU64(buf, uint64(x))
+	case float64:
+		putU64(buf, math.Float64bits(x))
+	}
+}
+
+func putU32(buf *bytes.Buffer, v uint32) { buf.Write(binary.LittleEndian.AppendUint32(nil, v)) }
+func putU64(buf *bytes.Buffer, v uint64) { buf.Write(binary.LittleEndian.AppendUint64(nil, v)) }
+
+func putString(buf *bytes.Buffer, s string) {
+	putU64(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
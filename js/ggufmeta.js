@@ -0,0 +1,107 @@
+// ggufmeta.js - JavaScript bindings for the js/wasm build of ggufmeta.
+//
+//   import { parse } from "./ggufmeta.js";
+//   const meta = await parse(file); // File, Blob, ArrayBuffer, typed array or ReadableStream
+//   meta.header, meta.kvs, meta.tensors, meta.dataOffset
+//
+// `make wasm` puts ggufmeta.wasm, this file and Go's wasm_exec.js side by
+// side in bin/. Records have the same shape as the command line's: kvs are
+// the dump's {key, type, value} records (arrays as placeholders unless
+// expanded) and tensors the `tensors` subcommand's records.
+import "./wasm_exec.js";
+
+let ready;
+
+// init loads the module once; parse calls it with the default location.
+// source is a URL, a fetch Response, or the module's bytes.
+export function init(source = new URL("ggufmeta.wasm", import.meta.url)) {
+  ready ??= (async () => {
+    const go = new Go();
+    let instance;
+    if (source instanceof ArrayBuffer || ArrayBuffer.isView(source)) {
+      ({ instance } = await WebAssembly.instantiate(source, go.importObject));
+    } else {
+      const resp = source instanceof Response ? source : fetch(source);
+      ({ instance } = await WebAssembly.instantiateStreaming(resp, go.importObject));
+    }
+    // Runs until main blocks, by which time globalThis.ggufmeta is installed
+    go.run(instance);
+  })();
+  return ready;
+}
+
+// parse returns {header, kvs, tensors, dataOffset} or throws. options:
+// maxArray, maxString, expandArrays (keys, or prefixes ending in *),
+// lenient, truncateStrings, noAutoExpand, expandTypes ({"string": 64}), and size (the file size, when input does not
+// carry it).
+export async function parse(input, options = {}) {
+  await init();
+  if (input instanceof ArrayBuffer) {
+    input = new Uint8Array(input);
+  }
+  if (ArrayBuffer.isView(input)) {
+    const bytes = new Uint8Array(input.buffer, input.byteOffset, input.byteLength);
+    return unwrap(call(bytes, { size: bytes.length, ...options }));
+  }
+  if (typeof Blob !== "undefined" && input instanceof Blob) {
+    return parseStream(input.stream(), { size: input.size, ...options });
+  }
+  if (input && typeof input.getReader === "function") {
+    return parseStream(input, options);
+  }
+  throw new TypeError("ggufmeta: parse wants an ArrayBuffer, typed array, Blob or ReadableStream");
+}
+
+// parseStream buffers the stream until the metadata parses, doubling the
+// amount read between attempts, then cancels the rest of the stream.
+async function parseStream(stream, options) {
+  const reader = stream.getReader();
+  const chunks = [];
+  let length = 0;
+  let attemptAt = 1 << 20;
+  try {
+    for (;;) {
+      const { done, value } = await reader.read();
+      if (value) {
+        chunks.push(value);
+        length += value.length;
+      }
+      if (!done && length < attemptAt) {
+        continue;
+      }
+      const res = call(concat(chunks, length), options);
+      if (!res.truncated || done) {
+        return unwrap(res);
+      }
+      attemptAt = length * 2;
+    }
+  } finally {
+    reader.cancel().catch(() => {});
+  }
+}
+
+function call(bytes, options) {
+  return JSON.parse(globalThis.ggufmeta.parse(bytes, JSON.stringify(options)));
+}
+
+function unwrap(res) {
+  if (res.error) {
+    throw new Error("ggufmeta: " + res.error);
+  }
+  return res;
+}
+
+function concat(chunks, length) {
+  if (chunks.length === 1) {
+    return chunks[0];
+  }
+  const out = new Uint8Array(length);
+  let off = 0;
+  for (const c of chunks) {
+    out.set(c, off);
+    off += c.length;
+  }
+  chunks.length = 0;
+  chunks.push(out);
+  return out;
+}